@@ -0,0 +1,315 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment override, e.g.
+// BOOKMANAGER_DATABASE_HOST or BOOKMANAGER_SERVER_LISTEN_ADDRESS.
+const EnvPrefix = "BOOKMANAGER"
+
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	Server   ServerConfig   `yaml:"server"`
+	Log      LogConfig      `yaml:"log"`
+	Features FeatureConfig  `yaml:"features"`
+	Trash    TrashConfig    `yaml:"trash"`
+}
+
+type DatabaseConfig struct {
+	Host            string   `yaml:"host"`
+	Port            int      `yaml:"port"`
+	User            string   `yaml:"user"`
+	Password        string   `yaml:"password" secret:"true"`
+	Name            string   `yaml:"name"`
+	SSLMode         string   `yaml:"sslmode"`
+	MaxOpenConns    int      `yaml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime"`
+}
+
+type ServerConfig struct {
+	ListenAddress   string   `yaml:"listen_address"`
+	ReadTimeout     Duration `yaml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+type FeatureConfig struct {
+	// InMemoryStore runs the API without a database; data is lost on exit.
+	InMemoryStore bool `yaml:"in_memory_store"`
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted books and collections stay in the trash.
+	Retention Duration `yaml:"retention"`
+	// PurgeInterval is how often expired trash is purged; 0 disables it.
+	PurgeInterval Duration `yaml:"purge_interval"`
+}
+
+// Duration is a time.Duration that reads and writes as "30s", "5m", ...
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", node.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			Password:        "postgres",
+			Name:            "bookmanager",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+		},
+		Server: ServerConfig{
+			ListenAddress:   ":8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(10 * time.Second),
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Features: FeatureConfig{
+			AutoMigrate: true,
+		},
+		Trash: TrashConfig{
+			Retention:     Duration(30 * 24 * time.Hour),
+			PurgeInterval: Duration(time.Hour),
+		},
+	}
+}
+
+// LoadFile overlays the YAML file at path onto the config. Keys that are not
+// part of the config are rejected so typos don't go unnoticed.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// LoadEnv applies BOOKMANAGER_<SECTION>_<KEY> overrides using the given
+// lookup function (normally os.LookupEnv).
+func (c *Config) LoadEnv(lookup func(string) (string, bool)) error {
+	return walk(reflect.ValueOf(c).Elem(), EnvPrefix, func(name string, field reflect.Value, _ reflect.StructField) error {
+		value, ok := lookup(name)
+		if !ok {
+			return nil
+		}
+		if err := setValue(field, value); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+		return nil
+	})
+}
+
+// EnvNames lists every supported environment variable.
+func EnvNames() []string {
+	var names []string
+	walk(reflect.ValueOf(Default()).Elem(), EnvPrefix, func(name string, _ reflect.Value, _ reflect.StructField) error {
+		names = append(names, name)
+		return nil
+	})
+	return names
+}
+
+func walk(v reflect.Value, prefix string, fn func(string, reflect.Value, reflect.StructField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		name := prefix + "_" + strings.ToUpper(key)
+		if field.Type.Kind() == reflect.Struct {
+			if err := walk(v.Field(i), name, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(name, v.Field(i), field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValue(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(Duration(d)))
+	case string:
+		field.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Validate reports every problem with the config at once.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !c.Features.InMemoryStore {
+		db := c.Database
+		if db.Host == "" {
+			add("database.host is required")
+		}
+		if db.Port < 1 || db.Port > 65535 {
+			add("database.port must be between 1 and 65535")
+		}
+		if db.User == "" {
+			add("database.user is required")
+		}
+		if db.Name == "" {
+			add("database.name is required")
+		}
+		if !sslModes[db.SSLMode] {
+			add("database.sslmode must be one of disable, allow, prefer, require, verify-ca, verify-full")
+		}
+		if db.MaxOpenConns < 0 {
+			add("database.max_open_conns must not be negative")
+		}
+		if db.MaxIdleConns < 0 {
+			add("database.max_idle_conns must not be negative")
+		}
+		if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+			add("database.max_idle_conns must not exceed database.max_open_conns")
+		}
+		if db.ConnMaxLifetime < 0 {
+			add("database.conn_max_lifetime must not be negative")
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.ListenAddress); err != nil {
+		add("server.listen_address must be host:port or :port")
+	}
+	for name, d := range map[string]Duration{
+		"server.read_timeout":     c.Server.ReadTimeout,
+		"server.write_timeout":    c.Server.WriteTimeout,
+		"server.idle_timeout":     c.Server.IdleTimeout,
+		"server.shutdown_timeout": c.Server.ShutdownTimeout,
+		"trash.retention":         c.Trash.Retention,
+		"trash.purge_interval":    c.Trash.PurgeInterval,
+	} {
+		if d < 0 {
+			add("%s must not be negative", name)
+		}
+	}
+
+	if _, err := c.Log.SlogLevel(); err != nil {
+		add("log.level must be one of debug, info, warn, error")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+func (l LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(l.Level))
+	return level, err
+}
+
+// DSN returns the lib/pq connection string for the database settings.
+func (d DatabaseConfig) DSN() string {
+	quote := func(s string) string {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		return "'" + strings.ReplaceAll(s, `'`, `\'`) + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password), quote(d.Name), d.SSLMode)
+}
+
+// Redacted returns a copy of the config with secrets masked, for printing.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	walk(reflect.ValueOf(&redacted).Elem(), EnvPrefix, func(_ string, field reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") == "true" && field.String() != "" {
+			field.SetString("REDACTED")
+		}
+		return nil
+	})
+	return &redacted
+}
+
+func (c *Config) YAML() (string, error) {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
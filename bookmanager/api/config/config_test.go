@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   []string
+	}{
+		{"defaults", func(*Config) {}, nil},
+		{"missing database settings", func(c *Config) {
+			c.Database.Host, c.Database.User, c.Database.Name = "", "", ""
+		}, []string{"database.host is required", "database.user is required", "database.name is required"}},
+		{"in-memory store skips the database", func(c *Config) {
+			c.Features.InMemoryStore = true
+			c.Database.Host, c.Database.Port = "", 0
+		}, nil},
+		{"port", func(c *Config) { c.Database.Port = 70000 }, []string{"database.port must be between 1 and 65535"}},
+		{"sslmode", func(c *Config) { c.Database.SSLMode = "sometimes" }, []string{"database.sslmode must be one of"}},
+		{"idle over open conns", func(c *Config) { c.Database.MaxOpenConns, c.Database.MaxIdleConns = 2, 5 }, []string{"database.max_idle_conns must not exceed"}},
+		{"unlimited open conns", func(c *Config) { c.Database.MaxOpenConns, c.Database.MaxIdleConns = 0, 5 }, nil},
+		{"negative conns", func(c *Config) { c.Database.MaxOpenConns = -1 }, []string{"database.max_open_conns must not be negative"}},
+		{"listen address", func(c *Config) { c.Server.ListenAddress = "8080" }, []string{"server.listen_address must be host:port or :port"}},
+		{"negative duration", func(c *Config) { c.Trash.Retention = -1 }, []string{"trash.retention must not be negative"}},
+		{"log level", func(c *Config) { c.Log.Level = "verbose" }, []string{"log.level must be one of"}},
+		{"upper case log level", func(c *Config) { c.Log.Level = "DEBUG" }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(cfg)
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() succeeded, want %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to report %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "s3cret"
+	redacted := cfg.Redacted()
+	if redacted.Database.Password != "REDACTED" {
+		t.Errorf("redacted password = %q", redacted.Database.Password)
+	}
+	if cfg.Database.Password != "s3cret" {
+		t.Errorf("Redacted changed the original password to %q", cfg.Database.Password)
+	}
+	if redacted.Database.User != cfg.Database.User {
+		t.Errorf("redacted user = %q, want %q", redacted.Database.User, cfg.Database.User)
+	}
+	out, err := redacted.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("YAML of the redacted config contains the password:\n%s", out)
+	}
+
+	cfg.Database.Password = ""
+	if got := cfg.Redacted().Database.Password; got != "" {
+		t.Errorf("redacted empty password = %q, want it left empty", got)
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Options are the command line settings that are not part of Config itself.
+type Options struct {
+	ConfigFile  string
+	PrintConfig bool
+	// Args are the remaining positional arguments (e.g. "migrate up").
+	Args []string
+}
+
+// flagOverrides maps command line flags to the environment variable they
+// override, so flags share the parsing of LoadEnv.
+var flagOverrides = []struct {
+	flag, env, usage string
+	isBool           bool
+}{
+	{"listen", "SERVER_LISTEN_ADDRESS", "Address to listen on (e.g. :8080)", false},
+	{"db-host", "DATABASE_HOST", "Database host", false},
+	{"db-port", "DATABASE_PORT", "Database port", false},
+	{"db-user", "DATABASE_USER", "Database user", false},
+	{"db-password", "DATABASE_PASSWORD", "Database password", false},
+	{"db-name", "DATABASE_NAME", "Database name", false},
+	{"db-sslmode", "DATABASE_SSLMODE", "Database sslmode", false},
+	{"db-max-open-conns", "DATABASE_MAX_OPEN_CONNS", "Maximum open database connections", false},
+	{"db-max-idle-conns", "DATABASE_MAX_IDLE_CONNS", "Maximum idle database connections", false},
+	{"log-level", "LOG_LEVEL", "Log level (debug, info, warn, error)", false},
+	{"memory", "FEATURES_IN_MEMORY_STORE", "Use the in-memory store instead of PostgreSQL", true},
+	{"auto-migrate", "FEATURES_AUTO_MIGRATE", "Apply pending migrations on startup", true},
+}
+
+type overrideFlag struct {
+	env    string
+	values map[string]string
+	isBool bool
+}
+
+func (f *overrideFlag) String() string   { return "" }
+func (f *overrideFlag) IsBoolFlag() bool { return f.isBool }
+
+func (f *overrideFlag) Set(value string) error {
+	f.values[f.env] = value
+	return nil
+}
+
+// Load builds the configuration from defaults, the config file, environment
+// variables and command line flags, in increasing order of precedence. The
+// result still needs to be checked with Validate.
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, *Options, error) {
+	opts := &Options{}
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.ConfigFile, "config", "", "Path to a YAML config file (or "+EnvPrefix+"_CONFIG)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "Print the effective configuration with secrets redacted and exit")
+
+	flagValues := make(map[string]string)
+	for _, o := range flagOverrides {
+		fs.Var(&overrideFlag{env: EnvPrefix + "_" + o.env, values: flagValues, isBool: o.isBool}, o.flag, o.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	opts.Args = fs.Args()
+
+	if opts.ConfigFile == "" {
+		opts.ConfigFile, _ = lookupEnv(EnvPrefix + "_CONFIG")
+	}
+
+	cfg := Default()
+	if opts.ConfigFile != "" {
+		if err := cfg.LoadFile(opts.ConfigFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// PORT and STORAGE predate the BOOKMANAGER_* variables and are still honored.
+	if port, ok := lookupEnv("PORT"); ok && port != "" {
+		cfg.Server.ListenAddress = ":" + port
+	}
+	if storage, ok := lookupEnv("STORAGE"); ok && storage == "memory" {
+		cfg.Features.InMemoryStore = true
+	}
+
+	if err := cfg.LoadEnv(lookupEnv); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.LoadEnv(func(name string) (string, bool) {
+		v, ok := flagValues[name]
+		return v, ok
+	}); err != nil {
+		return nil, nil, fmt.Errorf("invalid flag: %v", err)
+	}
+
+	return cfg, opts, nil
+}
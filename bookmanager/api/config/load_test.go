@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte("database:\n  host: file-host\n  port: 6000\nserver:\n  read_timeout: 1m\nlog:\n  level: warn\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(*Config) bool
+	}{
+		{"defaults", nil, nil, func(c *Config) bool {
+			return c.Database.Host == "localhost" && c.Log.Level == "info"
+		}},
+		{"file over defaults", []string{"--config", file}, nil, func(c *Config) bool {
+			return c.Database.Host == "file-host" && c.Database.Port == 6000 && c.Database.Name == "bookmanager" &&
+				c.Server.ReadTimeout == Duration(time.Minute)
+		}},
+		{"config file from env", nil, map[string]string{"BOOKMANAGER_CONFIG": file}, func(c *Config) bool {
+			return c.Database.Host == "file-host"
+		}},
+		{"env over file", []string{"--config", file}, map[string]string{"BOOKMANAGER_DATABASE_HOST": "env-host", "BOOKMANAGER_LOG_LEVEL": "error"}, func(c *Config) bool {
+			return c.Database.Host == "env-host" && c.Database.Port == 6000 && c.Log.Level == "error"
+		}},
+		{"flag over env", []string{"--config", file, "--db-host", "flag-host", "--log-level", "debug"}, map[string]string{"BOOKMANAGER_DATABASE_HOST": "env-host", "BOOKMANAGER_LOG_LEVEL": "error"}, func(c *Config) bool {
+			return c.Database.Host == "flag-host" && c.Log.Level == "debug"
+		}},
+		{"legacy env", nil, map[string]string{"PORT": "9000", "STORAGE": "memory"}, func(c *Config) bool {
+			return c.Server.ListenAddress == ":9000" && c.Features.InMemoryStore
+		}},
+		{"env over legacy env", nil, map[string]string{"PORT": "9000", "BOOKMANAGER_SERVER_LISTEN_ADDRESS": ":9100"}, func(c *Config) bool {
+			return c.Server.ListenAddress == ":9100"
+		}},
+		{"bool flag", []string{"--memory", "--auto-migrate=false"}, map[string]string{"BOOKMANAGER_FEATURES_IN_MEMORY_STORE": "false"}, func(c *Config) bool {
+			return c.Features.InMemoryStore && !c.Features.AutoMigrate
+		}},
+		{"duration env", nil, map[string]string{"BOOKMANAGER_TRASH_RETENTION": "48h"}, func(c *Config) bool {
+			return c.Trash.Retention == Duration(48*time.Hour)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := Load(tt.args, lookup(tt.env), io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("unexpected config:\n%+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadArgs(t *testing.T) {
+	_, opts, err := Load([]string{"--print-config", "--listen", ":9000", "migrate", "up"}, lookup(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.PrintConfig || len(opts.Args) != 2 || opts.Args[0] != "migrate" || opts.Args[1] != "up" {
+		t.Errorf("options = %+v, want print-config and migrate up", opts)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"missing file", []string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}, nil},
+		{"bad env int", nil, map[string]string{"BOOKMANAGER_DATABASE_PORT": "five"}},
+		{"bad env duration", nil, map[string]string{"BOOKMANAGER_SERVER_READ_TIMEOUT": "soon"}},
+		{"bad flag int", []string{"--db-port", "five"}, nil},
+		{"bad flag bool", []string{"--memory=maybe"}, nil},
+		{"unknown flag", []string{"--nope"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Load(tt.args, lookup(tt.env), io.Discard); err == nil {
+				t.Error("Load succeeded, want an error")
+			}
+		})
+	}
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("database:\n  hots: typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load([]string{"--config", file}, lookup(nil), io.Discard); err == nil {
+		t.Error("Load accepted an unknown key in the config file")
+	}
+
+	if _, _, err := Load([]string{"--help"}, lookup(nil), io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(--help) error = %v, want flag.ErrHelp", err)
+	}
+}
+
+// lookup returns a lookupEnv function over env.
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
@@ -0,0 +1,842 @@
+package db
+
+import (
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/patch"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const authorColumns = "id, name, sort_name, birth_date, death_date, bio, created_at, updated_at, version"
+
+type AuthorDB struct {
+	DB *sql.DB
+}
+
+func NewAuthor(db *sql.DB) *AuthorDB {
+	return &AuthorDB{DB: db}
+}
+
+// nullDate scans a nullable DATE column into a YYYY-MM-DD string, empty for
+// NULL.
+type nullDate struct {
+	s *string
+}
+
+func (d nullDate) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d.s = ""
+	case time.Time:
+		*d.s = v.Format("2006-01-02")
+	default:
+		return fmt.Errorf("cannot scan %T into a date", value)
+	}
+	return nil
+}
+
+// optionalString passes an empty string, e.g. a missing date or genre, as
+// NULL.
+func optionalString(date string) sql.NullString {
+	return sql.NullString{String: date, Valid: date != ""}
+}
+
+// authorFields returns the scan destinations for authorColumns.
+func authorFields(a *models.Author) []interface{} {
+	return []interface{}{
+		&a.ID,
+		&a.Name,
+		&a.SortName,
+		nullDate{&a.BirthDate},
+		nullDate{&a.DeathDate},
+		&a.Bio,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+		&a.Version,
+	}
+}
+
+// sortName returns the sort name of the author, "Last, First" unless it is
+// given.
+func sortName(author *models.AuthorRequest) string {
+	if author.SortName != "" {
+		return author.SortName
+	}
+	return models.SortNameOf(author.Name)
+}
+
+func (a *AuthorDB) CreateAuthor(ctx context.Context, author *models.AuthorRequest) (*models.Author, error) {
+	var newAuthor models.Author
+	query := `
+	INSERT INTO authors (name, sort_name, birth_date, death_date, bio)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + authorColumns
+
+	err := a.DB.QueryRowContext(ctx,
+		query,
+		strings.TrimSpace(author.Name),
+		sortName(author),
+		optionalString(author.BirthDate),
+		optionalString(author.DeathDate),
+		author.Bio,
+	).Scan(authorFields(&newAuthor)...)
+	if err != nil {
+		return nil, dbError("failed to create author", err)
+	}
+	return &newAuthor, nil
+}
+
+func (a *AuthorDB) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	return getAuthor(ctx, a.DB, id, "")
+}
+
+func getAuthor(ctx context.Context, q querier, id int, lock string) (*models.Author, error) {
+	var author models.Author
+	query := `
+	SELECT ` + authorColumns + `
+	FROM authors
+	WHERE id = $1
+	` + lock
+
+	err := q.QueryRowContext(ctx, query, id).Scan(authorFields(&author)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("author %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get author: %v", err)
+	}
+	return &author, nil
+}
+
+func (a *AuthorDB) UpdateAuthor(ctx context.Context, id int, author *models.AuthorRequest, version int) (*models.Author, error) {
+	return a.changeAuthor(ctx, id, version, func(*models.Author) (*models.AuthorRequest, error) {
+		return author, nil
+	})
+}
+
+// changeAuthor works like BookDB.changeBook. Authors have no history, but a
+// new name is copied to the author string of their books, which records a
+// revision of each. The author is locked FOR NO KEY UPDATE, so book writers
+// crediting the author, whose foreign keys take a KEY SHARE lock on it, go
+// ahead while the rename waits for their books.
+func (a *AuthorDB) changeAuthor(ctx context.Context, id, version int, change func(current *models.Author) (*models.AuthorRequest, error)) (*models.Author, error) {
+	var changed models.Author
+	err := inTx(ctx, a.DB, func(tx *sql.Tx) error {
+		current, err := getAuthor(ctx, tx, id, "FOR NO KEY UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("author", current.Version, version); err != nil {
+			return err
+		}
+
+		author, err := change(current)
+		if err != nil {
+			return err
+		}
+		// The books crediting the author are read before the new name is
+		// written, for the revisions their renaming records.
+		var credited []models.Book
+		if strings.TrimSpace(author.Name) != current.Name {
+			credited, err = queryBooks(ctx, tx, `
+			SELECT `+bookColumns+`
+			FROM books
+			WHERE id IN (SELECT book_id FROM book_authors WHERE author_id = $1)
+			ORDER BY id
+			FOR UPDATE`, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		query := `
+		UPDATE authors
+		SET name = $1, sort_name = $2, birth_date = $3, death_date = $4, bio = $5,
+		    updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $6
+		RETURNING ` + authorColumns
+		err = tx.QueryRowContext(ctx,
+			query,
+			strings.TrimSpace(author.Name),
+			sortName(author),
+			optionalString(author.BirthDate),
+			optionalString(author.DeathDate),
+			author.Bio,
+			id,
+		).Scan(authorFields(&changed)...)
+		if err != nil {
+			return dbError("failed to update author", err)
+		}
+		if len(credited) == 0 {
+			return nil
+		}
+
+		// Renaming an author changes every book crediting them, in any
+		// role, so each gets a new version and a revision.
+		renamed, err := queryBooks(ctx, tx, `
+		UPDATE books b
+		SET author = COALESCE((
+		        SELECT string_agg(au.name, ' & ' ORDER BY ba.position)
+		        FROM book_authors ba
+		        JOIN authors au ON au.id = ba.author_id
+		        WHERE ba.book_id = b.id AND ba.role = 'author'
+		    ), b.author),
+		    updated_at = CURRENT_TIMESTAMP, version = b.version + 1
+		WHERE b.id IN (SELECT book_id FROM book_authors WHERE author_id = $1)
+		RETURNING `+bookColumns, id)
+		if err != nil {
+			return err
+		}
+		before := make(map[int]*models.Book, len(credited))
+		for i := range credited {
+			before[credited[i].ID] = &credited[i]
+		}
+		for i := range renamed {
+			book := &renamed[i]
+			if err := recordRevision(ctx, tx, "book", models.RevisionUpdate, book.ID, book.Version, before[book.ID], book); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &changed, nil
+}
+
+// PatchAuthor applies p to the current author.
+func (a *AuthorDB) PatchAuthor(ctx context.Context, id int, p patch.Patch, version int) (*models.Author, error) {
+	return a.changeAuthor(ctx, id, version, func(current *models.Author) (*models.AuthorRequest, error) {
+		return patchAuthor(current, p)
+	})
+}
+
+// authorPatchSchema lists the fields a PATCH may change. Clearing sort_name
+// derives it from the name again.
+var authorPatchSchema = patch.Schema{
+	"name":       {Kind: patch.String},
+	"sort_name":  {Kind: patch.String, Optional: true},
+	"birth_date": {Kind: patch.String, Optional: true},
+	"death_date": {Kind: patch.String, Optional: true},
+	"bio":        {Kind: patch.String, Optional: true},
+}
+
+func patchAuthor(current *models.Author, p patch.Patch) (*models.AuthorRequest, error) {
+	doc := patch.Document{
+		"name":      current.Name,
+		"sort_name": current.SortName,
+		"bio":       current.Bio,
+	}
+	if current.BirthDate != "" {
+		doc["birth_date"] = current.BirthDate
+	}
+	if current.DeathDate != "" {
+		doc["death_date"] = current.DeathDate
+	}
+	doc, err := authorPatchSchema.Apply(p, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	author := &models.AuthorRequest{
+		Name:      doc.String("name"),
+		SortName:  doc.String("sort_name"),
+		BirthDate: doc.String("birth_date"),
+		DeathDate: doc.String("death_date"),
+		Bio:       doc.String("bio"),
+	}
+	if err := author.Validate(); err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// DeleteAuthor deletes the author for good. Authors still credited on a
+// book, including books in the trash, cannot be deleted.
+func (a *AuthorDB) DeleteAuthor(ctx context.Context, id int, version int) error {
+	return inTx(ctx, a.DB, func(tx *sql.Tx) error {
+		current, err := getAuthor(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("author", current.Version, version); err != nil {
+			return err
+		}
+
+		var books int
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(DISTINCT book_id) FROM book_authors WHERE author_id = $1", id).Scan(&books)
+		if err != nil {
+			return fmt.Errorf("failed to count books of author: %v", err)
+		}
+		if books > 0 {
+			return fmt.Errorf("author is still credited on %d book(s): %w", books, ErrConflict)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM authors WHERE id = $1", id); err != nil {
+			return dbError("failed to delete author", err)
+		}
+		return nil
+	})
+}
+
+// GroupAuthors lists the authors matching opts.Filter grouped by
+// opts.GroupBy.
+func (a *AuthorDB) GroupAuthors(ctx context.Context, opts ListOptions) ([]Group[models.Author], error) {
+	return groupRows(ctx, a.DB, "authors", authorColumns, filter.AuthorSchema, "sort_name", opts, authorRecord, authorFields)
+}
+
+// ListAuthors returns one page of authors, see BookDB.ListBooks.
+func (a *AuthorDB) ListAuthors(ctx context.Context, opts ListOptions) (*Page[models.Author], error) {
+	terms := sortTerms(opts, "sort_name")
+	cur, err := decodeCursor(opts.Cursor, filter.AuthorSchema, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := filter.NewSQLBuilder(filter.AuthorSchema, "")
+	conds := []string{"TRUE"}
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if cur != nil {
+		cond, err := sb.Keyset(terms, cur.Values, cur.Before)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+
+	query := `
+        SELECT ` + authorColumns + `
+        FROM authors
+        WHERE ` + strings.Join(conds, " AND ")
+
+	orderBy, err := sb.OrderBy(terms, cur != nil && cur.Before)
+	if err != nil {
+		return nil, err
+	}
+	query += " ORDER BY " + orderBy
+	query += " LIMIT " + sb.Arg(opts.pageSize()+1)
+	if cur == nil && opts.Offset > 0 {
+		query += " OFFSET " + sb.Arg(opts.Offset)
+	}
+
+	rows, err := a.DB.QueryContext(ctx, query, sb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list authors: %v", err)
+	}
+	defer rows.Close()
+
+	var authors []models.Author
+	for rows.Next() {
+		var author models.Author
+		if err := rows.Scan(authorFields(&author)...); err != nil {
+			return nil, fmt.Errorf("failed to scan author: %v", err)
+		}
+		authors = append(authors, author)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning authors: %v", err)
+	}
+
+	page := newPage(authors, authorRecord, terms, cur, opts)
+	if opts.IncludeTotal {
+		total, err := countRows(ctx, a.DB, "authors", filter.AuthorSchema, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+	return page, nil
+}
+
+// ListBooksByAuthor returns the books the author is credited on in any
+// role, oldest first.
+func (a *AuthorDB) ListBooksByAuthor(ctx context.Context, authorID int) ([]models.Book, error) {
+	if _, err := a.GetAuthor(ctx, authorID); err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT ` + bookColumns + `
+	FROM books
+	WHERE deleted_at IS NULL
+	  AND id IN (SELECT book_id FROM book_authors WHERE author_id = $1)
+	ORDER BY published_date, title, id`
+
+	rows, err := a.DB.QueryContext(ctx, query, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list books by author: %v", err)
+	}
+	defer rows.Close()
+
+	books := []models.Book{}
+	for rows.Next() {
+		var book models.Book
+		err := rows.Scan(bookFields(&book)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book: %v", err)
+		}
+		books = append(books, book)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning books: %v", err)
+	}
+
+	if err := loadBookAuthors(ctx, a.DB, bookPointers(books)...); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func bookPointers(books []models.Book) []*models.Book {
+	ptrs := make([]*models.Book, len(books))
+	for i := range books {
+		ptrs[i] = &books[i]
+	}
+	return ptrs
+}
+
+// loadBookAuthors fills in the credited authors of books.
+func loadBookAuthors(ctx context.Context, q querier, books ...*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	byID := make(map[int][]*models.Book, len(books))
+	ids := make([]int64, 0, len(books))
+	for _, book := range books {
+		book.Authors = []models.BookAuthor{}
+		byID[book.ID] = append(byID[book.ID], book)
+		ids = append(ids, int64(book.ID))
+	}
+
+	rows, err := q.QueryContext(ctx, `
+	SELECT ba.book_id, a.id, a.name, ba.role
+	FROM book_authors ba
+	JOIN authors a ON a.id = ba.author_id
+	WHERE ba.book_id = ANY($1)
+	ORDER BY ba.book_id, ba.position`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to list book authors: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var credit models.BookAuthor
+		if err := rows.Scan(&bookID, &credit.ID, &credit.Name, &credit.Role); err != nil {
+			return fmt.Errorf("failed to scan book author: %v", err)
+		}
+		for _, book := range byID[bookID] {
+			book.Authors = append(book.Authors, credit)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error after scanning book authors: %v", err)
+	}
+	return nil
+}
+
+// setBookAuthors replaces the credits of a book.
+func setBookAuthors(ctx context.Context, q querier, bookID int, credits []models.BookAuthor) error {
+	if _, err := q.ExecContext(ctx, "DELETE FROM book_authors WHERE book_id = $1", bookID); err != nil {
+		return dbError("failed to clear book authors", err)
+	}
+	for i, credit := range credits {
+		_, err := q.ExecContext(ctx,
+			"INSERT INTO book_authors (book_id, author_id, role, position) VALUES ($1, $2, $3, $4)",
+			bookID, credit.ID, credit.Role, i+1)
+		if err != nil {
+			return dbError("failed to credit book author", err)
+		}
+	}
+	return nil
+}
+
+// authorLookup finds the authors a book request credits. byID returns nil
+// for an unknown ID; byName creates the author when there is none of that
+// name yet.
+type authorLookup struct {
+	byID   func(id int) (*models.BookAuthor, error)
+	byName func(name string) (*models.BookAuthor, error)
+}
+
+// sqlAuthorLookup looks up authors through q, normally the transaction
+// writing the book.
+func sqlAuthorLookup(ctx context.Context, q querier) authorLookup {
+	return authorLookup{
+		byID: func(id int) (*models.BookAuthor, error) {
+			credit := models.BookAuthor{ID: id}
+			err := q.QueryRowContext(ctx, "SELECT name FROM authors WHERE id = $1", id).Scan(&credit.Name)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get author: %v", err)
+			}
+			return &credit, nil
+		},
+		// byName takes no lock on an existing author, which a rename holds
+		// while it waits for the books of the author.
+		byName: func(name string) (*models.BookAuthor, error) {
+			_, err := q.ExecContext(ctx, `
+			INSERT INTO authors (name, sort_name)
+			VALUES ($1, $2)
+			ON CONFLICT (name) DO NOTHING`, name, models.SortNameOf(name))
+			if err != nil {
+				return nil, dbError("failed to create author", err)
+			}
+			var credit models.BookAuthor
+			err = q.QueryRowContext(ctx, "SELECT id, name FROM authors WHERE name = $1", name).Scan(&credit.ID, &credit.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get author: %v", err)
+			}
+			return &credit, nil
+		},
+	}
+}
+
+// resolveCredits returns the authors credited by a book request and sets
+// book.Author to match them. Without Authors the request credits the names
+// in book.Author with the author role, and keeps the other roles of
+// current, the credits of the book being changed. Authors given by name
+// are only created once the whole request is known to be valid.
+func resolveCredits(book *models.BookRequest, current []models.BookAuthor, lookup authorLookup) ([]models.BookAuthor, error) {
+	requested := book.Authors
+	if len(requested) == 0 {
+		for _, name := range models.SplitAuthors(book.Author) {
+			requested = append(requested, models.BookAuthorRequest{Name: name, Role: models.RoleAuthor})
+		}
+		for _, credit := range current {
+			if credit.Role != models.RoleAuthor {
+				requested = append(requested, models.BookAuthorRequest{ID: credit.ID, Name: credit.Name, Role: credit.Role})
+			}
+		}
+	}
+
+	v := &models.ValidationError{}
+	credits := make([]models.BookAuthor, 0, len(requested))
+	seen := make(map[string]bool)
+	for i, r := range requested {
+		credit := models.BookAuthor{Name: strings.TrimSpace(r.Name), Role: r.Role}
+		if credit.Role == "" {
+			credit.Role = models.RoleAuthor
+		}
+		// Authors deleted since a revision was recorded are found by name.
+		if r.ID != 0 {
+			found, err := lookup.byID(r.ID)
+			if err != nil {
+				return nil, err
+			}
+			if found != nil {
+				credit.ID, credit.Name = found.ID, found.Name
+			} else if credit.Name == "" {
+				v.Add(fmt.Sprintf("authors[%d].id", i), fmt.Sprintf("author %d does not exist", r.ID))
+				continue
+			}
+		}
+		key := credit.Name + "\x00" + credit.Role
+		if !seen[key] {
+			seen[key] = true
+			credits = append(credits, credit)
+		}
+	}
+
+	author := models.JoinAuthors(credits)
+	switch {
+	case author == "" && len(v.Errors) == 0:
+		v.Add("authors", "must credit at least one author")
+	case len(book.Authors) > 0 && book.Author != "" && strings.Join(models.SplitAuthors(book.Author), " & ") != author:
+		v.Add("author", "does not match the authors credited with the author role")
+	case len(author) > 255:
+		v.Add("author", "must be at most 255 characters")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range credits {
+		if credits[i].ID != 0 {
+			continue
+		}
+		found, err := lookup.byName(credits[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		credits[i].ID, credits[i].Name = found.ID, found.Name
+	}
+	book.Author = author
+	return credits, nil
+}
+
+// authorsDocument and authorsFromDocument convert credits to and from the
+// authors field of a patch.Document.
+func authorsDocument(credits []models.BookAuthor) []interface{} {
+	doc := make([]interface{}, 0, len(credits))
+	for _, credit := range credits {
+		doc = append(doc, map[string]interface{}{"id": credit.ID, "name": credit.Name, "role": credit.Role})
+	}
+	return doc
+}
+
+func authorsFromDocument(value interface{}) ([]models.BookAuthorRequest, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode authors: %v", err)
+	}
+	var authors []models.BookAuthorRequest
+	if err := json.Unmarshal(data, &authors); err != nil {
+		return nil, &models.ValidationError{Errors: []models.FieldError{
+			{Field: "authors", Message: "must be an array of {id, name, role} objects"},
+		}}
+	}
+	return authors, nil
+}
+
+// memoryAuthorLookup looks up authors in m; the caller holds m.mu.
+func (m *MemoryStore) memoryAuthorLookup() authorLookup {
+	return authorLookup{
+		byID: func(id int) (*models.BookAuthor, error) {
+			author, ok := m.authors[id]
+			if !ok {
+				return nil, nil
+			}
+			return &models.BookAuthor{ID: author.ID, Name: author.Name}, nil
+		},
+		byName: func(name string) (*models.BookAuthor, error) {
+			author := m.authorNamed(name)
+			if author == nil {
+				author = m.insertAuthor(&models.AuthorRequest{Name: name})
+			}
+			return &models.BookAuthor{ID: author.ID, Name: author.Name}, nil
+		},
+	}
+}
+
+// insertAuthor adds a new author; the caller holds m.mu.
+func (m *MemoryStore) insertAuthor(author *models.AuthorRequest) *models.Author {
+	now := time.Now()
+	newAuthor := &models.Author{
+		ID:        m.nextAuthorID,
+		Name:      strings.TrimSpace(author.Name),
+		SortName:  sortName(author),
+		BirthDate: author.BirthDate,
+		DeathDate: author.DeathDate,
+		Bio:       author.Bio,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+	m.nextAuthorID++
+	m.authors[newAuthor.ID] = newAuthor
+	return newAuthor
+}
+
+// authorNamed returns the author with the given name, nil if there is none;
+// the caller holds m.mu.
+func (m *MemoryStore) authorNamed(name string) *models.Author {
+	for _, author := range m.authors {
+		if author.Name == name {
+			return author
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateAuthor(ctx context.Context, author *models.AuthorRequest) (*models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.authorNamed(strings.TrimSpace(author.Name)) != nil {
+		return nil, fmt.Errorf("author named %q already exists: %w", author.Name, ErrConflict)
+	}
+	result := *m.insertAuthor(author)
+	return &result, nil
+}
+
+func (m *MemoryStore) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	author, ok := m.authors[id]
+	if !ok {
+		return nil, fmt.Errorf("author %w", ErrNotFound)
+	}
+	result := *author
+	return &result, nil
+}
+
+func (m *MemoryStore) UpdateAuthor(ctx context.Context, id int, author *models.AuthorRequest, version int) (*models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.authors[id]
+	if !ok {
+		return nil, fmt.Errorf("author %w", ErrNotFound)
+	}
+	if err := checkVersion("author", current.Version, version); err != nil {
+		return nil, err
+	}
+	return m.updateAuthor(ctx, current, author)
+}
+
+// updateAuthor overwrites current with author and renames the author on
+// their books; the caller holds m.mu.
+func (m *MemoryStore) updateAuthor(ctx context.Context, current *models.Author, author *models.AuthorRequest) (*models.Author, error) {
+	name := strings.TrimSpace(author.Name)
+	if other := m.authorNamed(name); other != nil && other.ID != current.ID {
+		return nil, fmt.Errorf("author named %q already exists: %w", name, ErrConflict)
+	}
+
+	now := time.Now()
+	if name != current.Name {
+		for _, books := range []map[int]*models.Book{m.books, m.trashedBooks} {
+			for _, book := range books {
+				if !credits(book, current.ID) {
+					continue
+				}
+				before := *book
+				renamed := make([]models.BookAuthor, len(book.Authors))
+				copy(renamed, book.Authors)
+				for i := range renamed {
+					if renamed[i].ID == current.ID {
+						renamed[i].Name = name
+					}
+				}
+				book.Authors = renamed
+				book.Author = models.JoinAuthors(renamed)
+				book.UpdatedAt = now
+				book.Version++
+				if _, err := m.recordRevision(ctx, "book", models.RevisionUpdate, book.ID, book.Version, &before, book); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	current.Name = name
+	current.SortName = sortName(author)
+	current.BirthDate = author.BirthDate
+	current.DeathDate = author.DeathDate
+	current.Bio = author.Bio
+	current.UpdatedAt = now
+	current.Version++
+
+	result := *current
+	return &result, nil
+}
+
+func (m *MemoryStore) PatchAuthor(ctx context.Context, id int, p patch.Patch, version int) (*models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.authors[id]
+	if !ok {
+		return nil, fmt.Errorf("author %w", ErrNotFound)
+	}
+	if err := checkVersion("author", current.Version, version); err != nil {
+		return nil, err
+	}
+	merged, err := patchAuthor(current, p)
+	if err != nil {
+		return nil, err
+	}
+	return m.updateAuthor(ctx, current, merged)
+}
+
+func (m *MemoryStore) DeleteAuthor(ctx context.Context, id int, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	author, ok := m.authors[id]
+	if !ok {
+		return fmt.Errorf("author %w", ErrNotFound)
+	}
+	if err := checkVersion("author", author.Version, version); err != nil {
+		return err
+	}
+
+	books := 0
+	for _, all := range []map[int]*models.Book{m.books, m.trashedBooks} {
+		for _, book := range all {
+			if credits(book, id) {
+				books++
+			}
+		}
+	}
+	if books > 0 {
+		return fmt.Errorf("author is still credited on %d book(s): %w", books, ErrConflict)
+	}
+	delete(m.authors, id)
+	return nil
+}
+
+// credits reports whether the author is credited on book in any role.
+func credits(book *models.Book, authorID int) bool {
+	for _, credit := range book.Authors {
+		if credit.ID == authorID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) allAuthors() []models.Author {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	authors := make([]models.Author, 0, len(m.authors))
+	for _, author := range m.authors {
+		authors = append(authors, *author)
+	}
+	return authors
+}
+
+func (m *MemoryStore) ListAuthors(ctx context.Context, opts ListOptions) (*Page[models.Author], error) {
+	return listInMemory(m.allAuthors(), authorRecord, filter.AuthorSchema, "sort_name", opts)
+}
+
+func (m *MemoryStore) GroupAuthors(ctx context.Context, opts ListOptions) ([]Group[models.Author], error) {
+	return groupInMemory(m.allAuthors(), authorRecord, filter.AuthorSchema, "sort_name", opts)
+}
+
+func (m *MemoryStore) ListBooksByAuthor(ctx context.Context, authorID int) ([]models.Book, error) {
+	m.mu.RLock()
+	if _, ok := m.authors[authorID]; !ok {
+		m.mu.RUnlock()
+		return nil, fmt.Errorf("author %w", ErrNotFound)
+	}
+	books := []models.Book{}
+	for _, book := range m.books {
+		if credits(book, authorID) {
+			books = append(books, *book)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(books, func(i, j int) bool {
+		a, b := books[i], books[j]
+		if a.PublishedDate != b.PublishedDate {
+			return a.PublishedDate < b.PublishedDate
+		}
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.ID < b.ID
+	})
+	return books, nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"bookmanager/api/models"
+)
+
+func TestRenameAuthorRevisesBooks(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	book, err := store.CreateBook(ctx, &models.BookRequest{Title: "Dune", Author: "Frank Herbert", PublishedDate: "1965-08-01", Edition: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := book.Authors[0]
+
+	if _, err := store.UpdateAuthor(ctx, author.ID, &models.AuthorRequest{Name: "Frank Patrick Herbert"}, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed, err := store.GetBook(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Author != "Frank Patrick Herbert" {
+		t.Errorf("author = %q, want the new name", renamed.Author)
+	}
+	if renamed.Version != book.Version+1 {
+		t.Errorf("version = %d, want %d", renamed.Version, book.Version+1)
+	}
+	if !renamed.UpdatedAt.After(book.UpdatedAt) {
+		t.Errorf("updated_at = %v, want after %v", renamed.UpdatedAt, book.UpdatedAt)
+	}
+
+	history, err := store.BookHistory(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Revision != renamed.Version || history[0].Action != models.RevisionUpdate {
+		t.Fatalf("history = %+v, want an update revision %d on top of the create", history, renamed.Version)
+	}
+}
+
+func TestRenameAuthorDuringBookEdit(t *testing.T) {
+	store := NewMemoryStore()
+	testRenameAuthorDuringBookEdit(t, store, store)
+}
+
+// TestRenameAuthorDuringBookEditPostgres runs against the database named by
+// BOOKMANAGER_TEST_DSN, where a rename and a book edit lock the same rows.
+func TestRenameAuthorDuringBookEditPostgres(t *testing.T) {
+	conn := testDB(t)
+	testRenameAuthorDuringBookEdit(t, NewBook(conn), NewAuthor(conn))
+}
+
+// testDB connects to the database named by BOOKMANAGER_TEST_DSN and migrates
+// it, or skips the test when it is not set.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("BOOKMANAGER_TEST_DSN")
+	if dsn == "" {
+		t.Skip("BOOKMANAGER_TEST_DSN is not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func testRenameAuthorDuringBookEdit(t *testing.T, books BookStore, authors AuthorStore) {
+	ctx := context.Background()
+	name := fmt.Sprintf("Test Author %d", time.Now().UnixNano())
+	book, err := books.CreateBook(ctx, &models.BookRequest{Title: "Dune", Author: name, PublishedDate: "1965-08-01", Edition: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := book.Authors[0]
+
+	for round := range 20 {
+		renamed := fmt.Sprintf("%s (%d)", name, round)
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := authors.UpdateAuthor(ctx, author.ID, &models.AuthorRequest{Name: renamed}, AnyVersion)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			// Crediting a second author makes the edit look authors up by
+			// name while holding the book.
+			_, err := books.UpdateBook(ctx, book.ID, &models.BookRequest{
+				Title:         fmt.Sprintf("Dune, edit %d", round),
+				PublishedDate: "1965-08-01",
+				Edition:       1,
+				Authors: []models.BookAuthorRequest{
+					{ID: author.ID, Role: models.RoleAuthor},
+					{Name: name + " Translator", Role: models.RoleTranslator},
+				},
+			}, AnyVersion)
+			errs <- err
+		}()
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("round %d: %v", round, err)
+			}
+		}
+
+		got, err := books.GetBook(ctx, book.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Author != renamed {
+			t.Fatalf("round %d: author = %q, want %q", round, got.Author, renamed)
+		}
+	}
+}
@@ -1,280 +1,498 @@
-package db
-
-import (
-	"database/sql"
-	"fmt"
-	"strings"
-	"time"
-
-	"bookmanager/api/models"
-)
-
-type BookDB struct {
-	DB *sql.DB
-}
-
-type GroupedResult struct {
-	GroupKey string
-	Books    []models.Book
-}
-
-func NewBook(db *sql.DB) *BookDB {
-	return &BookDB{DB: db}
-}
-
-func (b *BookDB) CreateBook(book *models.BookRequest) (*models.Book, error) {
-	publishedDate, err := time.Parse("2006-01-02", book.PublishedDate)
-	if err != nil {
-		return nil, fmt.Errorf("invalid published date format: %v", err)
-	}
-
-	var newBook models.Book
-	query := `
-		INSERT INTO books (title, author, published_date, edition, description, genre)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, title, author, published_date, edition, description, genre, created_at, updated_at`
-
-	err = DB.QueryRow(
-		query,
-		book.Title,
-		book.Author,
-		publishedDate,
-		book.Edition,
-		book.Description,
-		book.Genre,
-	).Scan(
-		&newBook.ID,
-		&newBook.Title,
-		&newBook.Author,
-		&newBook.PublishedDate,
-		&newBook.Edition,
-		&newBook.Description,
-		&newBook.Genre,
-		&newBook.CreatedAt,
-		&newBook.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to create book %v", err)
-	}
-
-	return &newBook, nil
-}
-
-func (b *BookDB) GetBook(id int) (*models.Book, error) {
-	var book models.Book
-	query := `
-	SELECT id, title, author, published_date, edition, description, genre, created_at, updated_at
-	FROM books
-	WHERE id = $1
-	`
-
-	err := DB.QueryRow(query, id).Scan(
-		&book.ID,
-		&book.Title,
-		&book.Author,
-		&book.PublishedDate,
-		&book.Edition,
-		&book.Description,
-		&book.Genre,
-		&book.CreatedAt,
-		&book.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("book not found")
-		}
-		return nil, fmt.Errorf("failed to get book: %v", err)
-	}
-
-	return &book, nil
-}
-
-func (b *BookDB) UpdateBook(id int, book *models.BookRequest) (*models.Book, error) {
-	publishedDate, err := time.Parse("2006-01-02", book.PublishedDate)
-	if err != nil {
-		return nil, fmt.Errorf("invalid published date format: %v", err)
-	}
-
-	var updatedBook models.Book
-	query := `
-	UPDATE books
-	SET title = $1, author = $2, published_date = $3, edition = $4, 
-	    description = $5, genre = $6, updated_at = CURRENT_TIMESTAMP
-	WHERE id = $7
-	RETURNING id, title, author, published_date, edition, description, genre, created_at, updated_at`
-	err = DB.QueryRow(
-		query,
-		book.Title,
-		book.Author,
-		publishedDate,
-		book.Edition,
-		book.Description,
-		book.Genre,
-		id,
-	).Scan(
-		&updatedBook.ID,
-		&updatedBook.Title,
-		&updatedBook.Author,
-		&updatedBook.PublishedDate,
-		&updatedBook.Edition,
-		&updatedBook.Description,
-		&updatedBook.Genre,
-		&updatedBook.CreatedAt,
-		&updatedBook.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("book not found")
-		}
-		return nil, fmt.Errorf("failed to update book: %v", err)
-	}
-
-	return &updatedBook, nil
-}
-
-func (b *BookDB) PatchBook(id int, patch *models.BookRequest) (*models.Book, error) {
-	current, err := b.GetBook(id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get current book: %w", err)
-	}
-
-	merged := mergeBookWithPatch(current, patch)
-
-	return b.UpdateBook(id, merged)
-}
-
-func mergeBookWithPatch(current *models.Book, patch *models.BookRequest) *models.BookRequest {
-	currentDateParts := strings.Split(current.PublishedDate, "T")
-	currentDateOnly := currentDateParts[0]
-	merged := &models.BookRequest{
-		Title:         current.Title,
-		Author:        current.Author,
-		PublishedDate: currentDateOnly,
-		Edition:       current.Edition,
-		Description:   current.Description,
-		Genre:         current.Genre,
-	}
-
-	if patch.Title != "" {
-		merged.Title = patch.Title
-	}
-	if patch.Author != "" {
-		merged.Author = patch.Author
-	}
-	if patch.PublishedDate != "" {
-		merged.PublishedDate = patch.PublishedDate
-	}
-	if patch.Edition != 0 {
-		merged.Edition = patch.Edition
-	}
-	if patch.Description != "" {
-		merged.Description = patch.Description
-	}
-	if patch.Genre != "" {
-		merged.Genre = patch.Genre
-	}
-
-	return merged
-}
-
-func (b *BookDB) DeleteBook(id int) error {
-	query := `DELETE FROM books WHERE id = $1`
-	result, err := DB.Exec(query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete book : %v", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %v", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("book not found")
-	}
-
-	return nil
-}
-
-func (b *BookDB) ListBooks(where, groupBy, orderBy, limit, offset string) (interface{}, error) {
-	baseQuery := `
-        SELECT id, title, author, published_date, edition, 
-               description, genre, created_at, updated_at 
-        FROM books`
-
-	if groupBy != "" {
-		query := fmt.Sprintf(`
-            SELECT %s as group_key, COUNT(*) as count
-            FROM books
-            GROUP BY %s`, groupBy, groupBy)
-
-		rows, err := DB.Query(query)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list grouped books: %v", err)
-		}
-		defer rows.Close()
-
-		groups := make(map[string]int)
-		for rows.Next() {
-			var key string
-			var count int
-			if err := rows.Scan(&key, &count); err != nil {
-				return nil, fmt.Errorf("failed to scan book group: %v", err)
-			}
-			groups[key] = count
-		}
-		return groups, nil
-	}
-
-	if where != "" {
-		baseQuery += " WHERE " + where
-	}
-
-	if orderBy != "" {
-		baseQuery += " ORDER BY " + orderBy
-	} else {
-		baseQuery += " ORDER BY title" // Default ordering by title
-	}
-
-	if limit != "" {
-		baseQuery += " LIMIT " + limit
-	}
-
-	if offset != "" {
-		baseQuery += " OFFSET " + offset
-	}
-
-	rows, err := DB.Query(baseQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list books: %v", err)
-	}
-	defer rows.Close()
-
-	var books []models.Book
-	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(
-			&book.ID,
-			&book.Title,
-			&book.Author,
-			&book.PublishedDate,
-			&book.Edition,
-			&book.Description,
-			&book.Genre,
-			&book.CreatedAt,
-			&book.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan book: %v", err)
-		}
-		books = append(books, book)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error after scanning books: %v", err)
-	}
-
-	return books, nil
-}
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/patch"
+)
+
+const bookColumns = "id, title, author, published_date, edition, description, genre, isbn13, created_at, updated_at, version"
+
+// bookFields returns the scan destinations for bookColumns.
+func bookFields(book *models.Book) []interface{} {
+	return []interface{}{
+		&book.ID,
+		&book.Title,
+		&book.Author,
+		&book.PublishedDate,
+		&book.Edition,
+		nullText{&book.Description},
+		nullText{&book.Genre},
+		bookISBN{book},
+		&book.CreatedAt,
+		&book.UpdatedAt,
+		&book.Version,
+	}
+}
+
+// bookISBN scans the nullable isbn13 column into both ISBN fields of a book.
+type bookISBN struct {
+	book *models.Book
+}
+
+func (i bookISBN) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		i.book.ISBN13 = ""
+	case string:
+		i.book.ISBN13 = v
+	case []byte:
+		i.book.ISBN13 = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into an ISBN", value)
+	}
+	i.book.ISBN10 = models.ISBN13To10(i.book.ISBN13)
+	return nil
+}
+
+// nullText scans a nullable text column, such as a book's genre, into a
+// string, empty for NULL. Empty strings are written as NULL.
+type nullText struct {
+	s *string
+}
+
+func (t nullText) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t.s = ""
+	case string:
+		*t.s = v
+	case []byte:
+		*t.s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into text", value)
+	}
+	return nil
+}
+
+// checkISBN fails with ErrConflict if another book, in the trash or not,
+// has isbn. The unique index catches races; this gives the better message.
+func checkISBN(ctx context.Context, q querier, isbn string, id int) error {
+	if isbn == "" {
+		return nil
+	}
+	var other int
+	err := q.QueryRowContext(ctx, `SELECT id FROM books WHERE isbn13 = $1 AND id <> $2`, isbn, id).Scan(&other)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check isbn: %v", err)
+	}
+	return fmt.Errorf("isbn %s is already used by book %d: %w", isbn, other, ErrConflict)
+}
+
+type BookDB struct {
+	DB *sql.DB
+}
+
+func NewBook(db *sql.DB) *BookDB {
+	return &BookDB{DB: db}
+}
+
+func (b *BookDB) CreateBook(ctx context.Context, book *models.BookRequest) (*models.Book, error) {
+	var newBook *models.Book
+	err := inTx(ctx, b.DB, func(tx *sql.Tx) error {
+		var err error
+		newBook, err = createBook(ctx, tx, book)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newBook, nil
+}
+
+// createBook inserts book with its authors and revision through q, which
+// has to be a transaction.
+func createBook(ctx context.Context, q querier, book *models.BookRequest) (*models.Book, error) {
+	publishedDate, err := time.Parse("2006-01-02", book.PublishedDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid published date format: %w: %v", ErrValidation, err)
+	}
+	if err := checkISBN(ctx, q, book.ISBN(), 0); err != nil {
+		return nil, err
+	}
+	credits, err := resolveCredits(book, nil, sqlAuthorLookup(ctx, q))
+	if err != nil {
+		return nil, err
+	}
+
+	var newBook models.Book
+	query := `
+		INSERT INTO books (title, author, published_date, edition, description, genre, isbn13)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + bookColumns
+	err = q.QueryRowContext(ctx,
+		query,
+		book.Title,
+		book.Author,
+		publishedDate,
+		book.Edition,
+		optionalString(book.Description),
+		optionalString(book.Genre),
+		optionalString(book.ISBN()),
+	).Scan(bookFields(&newBook)...)
+	if err != nil {
+		return nil, dbError("failed to create book", err)
+	}
+	if err := setBookAuthors(ctx, q, newBook.ID, credits); err != nil {
+		return nil, err
+	}
+	newBook.Authors = credits
+	if err := recordRevision(ctx, q, "book", models.RevisionCreate, newBook.ID, newBook.Version, nil, &newBook); err != nil {
+		return nil, err
+	}
+	return &newBook, nil
+}
+
+func (b *BookDB) GetBook(ctx context.Context, id int) (*models.Book, error) {
+	return getBook(ctx, b.DB, id, "")
+}
+
+// GetBookByISBN returns the book with isbn, a normalized ISBN-13.
+func (b *BookDB) GetBookByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	var id int
+	err := b.DB.QueryRowContext(ctx, `SELECT id FROM books WHERE isbn13 = $1 AND deleted_at IS NULL`, isbn).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("book with isbn %s %w", isbn, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get book: %v", err)
+	}
+	return b.GetBook(ctx, id)
+}
+
+// getBook reads a book through q; lock is appended to the query, e.g.
+// "FOR UPDATE" inside a transaction.
+func getBook(ctx context.Context, q querier, id int, lock string) (*models.Book, error) {
+	var book models.Book
+	query := `
+	SELECT ` + bookColumns + `
+	FROM books
+	WHERE id = $1 AND deleted_at IS NULL
+	` + lock
+
+	err := q.QueryRowContext(ctx, query, id).Scan(bookFields(&book)...)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("book %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get book: %v", err)
+	}
+	if err := loadBookAuthors(ctx, q, &book); err != nil {
+		return nil, err
+	}
+
+	return &book, nil
+}
+
+func (b *BookDB) UpdateBook(ctx context.Context, id int, book *models.BookRequest, version int) (*models.Book, error) {
+	return b.changeBook(ctx, id, version, models.RevisionUpdate, func(*models.Book) (*models.BookRequest, error) {
+		return book, nil
+	})
+}
+
+// changeBook writes the fields change derives from the current book, with
+// a revision, while holding a row lock so concurrent writes cannot undo each
+// other.
+func (b *BookDB) changeBook(ctx context.Context, id, version int, action string, change func(current *models.Book) (*models.BookRequest, error)) (*models.Book, error) {
+	var changed *models.Book
+	err := inTx(ctx, b.DB, func(tx *sql.Tx) error {
+		current, err := getBook(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("book", current.Version, version); err != nil {
+			return err
+		}
+
+		book, err := change(current)
+		if err != nil {
+			return err
+		}
+		changed, err = updateBook(ctx, tx, current, book)
+		if err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, "book", action, id, changed.Version, current, changed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// updateBook overwrites current, read with its authors, with book.
+func updateBook(ctx context.Context, q querier, current *models.Book, book *models.BookRequest) (*models.Book, error) {
+	publishedDate, err := time.Parse("2006-01-02", book.PublishedDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid published date format: %w: %v", ErrValidation, err)
+	}
+	id, version := current.ID, current.Version
+	if err := checkISBN(ctx, q, book.ISBN(), id); err != nil {
+		return nil, err
+	}
+	credits, err := resolveCredits(book, current.Authors, sqlAuthorLookup(ctx, q))
+	if err != nil {
+		return nil, err
+	}
+
+	var updatedBook models.Book
+	query := `
+	UPDATE books
+	SET title = $1, author = $2, published_date = $3, edition = $4, 
+	    description = $5, genre = $6, isbn13 = $9, updated_at = CURRENT_TIMESTAMP,
+	    version = version + 1
+	WHERE id = $7 AND deleted_at IS NULL AND ($8 = 0 OR version = $8)
+	RETURNING ` + bookColumns
+	err = q.QueryRowContext(ctx,
+		query,
+		book.Title,
+		book.Author,
+		publishedDate,
+		book.Edition,
+		optionalString(book.Description),
+		optionalString(book.Genre),
+		id,
+		version,
+		optionalString(book.ISBN()),
+	).Scan(bookFields(&updatedBook)...)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, versionError(ctx, q, "books", "book", id, version)
+		}
+		return nil, dbError("failed to update book", err)
+	}
+	if err := setBookAuthors(ctx, q, id, credits); err != nil {
+		return nil, err
+	}
+	updatedBook.Authors = credits
+
+	return &updatedBook, nil
+}
+
+// PatchBook applies p to the current book.
+func (b *BookDB) PatchBook(ctx context.Context, id int, p patch.Patch, version int) (*models.Book, error) {
+	return b.changeBook(ctx, id, version, models.RevisionPatch, func(current *models.Book) (*models.BookRequest, error) {
+		return patchBook(current, p)
+	})
+}
+
+// bookPatchSchema lists the fields a PATCH may change.
+var bookPatchSchema = patch.Schema{
+	"title":          {Kind: patch.String},
+	"author":         {Kind: patch.String},
+	"authors":        {Kind: patch.Array},
+	"published_date": {Kind: patch.String},
+	"edition":        {Kind: patch.Integer},
+	"description":    {Kind: patch.String, Optional: true},
+	"genre":          {Kind: patch.String, Optional: true},
+	"isbn10":         {Kind: patch.String, Optional: true},
+	"isbn13":         {Kind: patch.String, Optional: true},
+}
+
+// patchBook applies p to the editable fields of current and validates the
+// result like a full update. A patch of author alone recredits the author
+// role and keeps the other roles; a patch of authors replaces all credits.
+// Likewise a patch of one ISBN form replaces the other.
+func patchBook(current *models.Book, p patch.Patch) (*models.BookRequest, error) {
+	doc, err := bookPatchSchema.Apply(p, patch.Document{
+		"title":          current.Title,
+		"author":         current.Author,
+		"authors":        authorsDocument(current.Authors),
+		"published_date": strings.Split(current.PublishedDate, "T")[0],
+		"edition":        current.Edition,
+		"description":    current.Description,
+		"genre":          current.Genre,
+		"isbn10":         current.ISBN10,
+		"isbn13":         current.ISBN13,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	book := &models.BookRequest{
+		Title:         doc.String("title"),
+		Author:        doc.String("author"),
+		PublishedDate: doc.String("published_date"),
+		Edition:       doc.Int("edition"),
+		Description:   doc.String("description"),
+		Genre:         doc.String("genre"),
+		ISBN10:        doc.String("isbn10"),
+		ISBN13:        doc.String("isbn13"),
+	}
+	switch isbn10Changed, isbn13Changed := book.ISBN10 != current.ISBN10, book.ISBN13 != current.ISBN13; {
+	case isbn10Changed && !isbn13Changed:
+		book.ISBN13 = ""
+	case isbn13Changed && !isbn10Changed:
+		book.ISBN10 = ""
+	}
+	authors, err := authorsFromDocument(doc["authors"])
+	if err != nil {
+		return nil, err
+	}
+	authorChanged := book.Author != current.Author
+	authorsChanged := !reflect.DeepEqual(authors, bookRequest(current).Authors)
+	switch {
+	case authorsChanged && len(authors) == 0:
+		return nil, &models.ValidationError{Errors: []models.FieldError{{Field: "authors", Message: "must credit at least one author"}}}
+	case authorsChanged:
+		book.Authors = authors
+		if !authorChanged {
+			book.Author = ""
+		}
+	case !authorChanged:
+		book.Authors = authors
+	}
+	if err := book.Validate(); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// bookRequest returns the editable fields of book. Its authors are
+// credited by ID and name, so an author deleted since is created again.
+func bookRequest(book *models.Book) *models.BookRequest {
+	var authors []models.BookAuthorRequest
+	for _, credit := range book.Authors {
+		authors = append(authors, models.BookAuthorRequest{ID: credit.ID, Name: credit.Name, Role: credit.Role})
+	}
+	return &models.BookRequest{
+		Title:         book.Title,
+		Author:        book.Author,
+		Authors:       authors,
+		PublishedDate: strings.Split(book.PublishedDate, "T")[0],
+		Edition:       book.Edition,
+		Description:   book.Description,
+		Genre:         book.Genre,
+		ISBN13:        book.ISBN13,
+	}
+}
+
+// DeleteBook moves the book to the trash. Its collection memberships are
+// kept, hidden, until it is restored or purged.
+func (b *BookDB) DeleteBook(ctx context.Context, id int, version int) error {
+	return inTx(ctx, b.DB, func(tx *sql.Tx) error {
+		current, err := getBook(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("book", current.Version, version); err != nil {
+			return err
+		}
+
+		deleted := *current
+		query := `
+		UPDATE books
+		SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1
+		RETURNING deleted_at, version`
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&deleted.DeletedAt, &deleted.Version); err != nil {
+			return dbError("failed to delete book", err)
+		}
+		return recordRevision(ctx, tx, "book", models.RevisionDelete, id, deleted.Version, current, &deleted)
+	})
+}
+
+// GroupBooks lists the books matching opts.Filter grouped by opts.GroupBy.
+func (b *BookDB) GroupBooks(ctx context.Context, opts ListOptions) ([]Group[models.Book], error) {
+	groups, err := groupRows(ctx, b.DB, "books", bookColumns, filter.BookSchema, "title", opts, bookRecord, bookFields)
+	if err != nil {
+		return nil, err
+	}
+	var books []*models.Book
+	for i := range groups {
+		books = append(books, bookPointers(groups[i].Items)...)
+	}
+	if err := loadBookAuthors(ctx, b.DB, books...); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// ListBooks returns one page of books. Pages are addressed by keyset cursors
+// over the sort columns, so deep pages cost the same as the first one.
+func (b *BookDB) ListBooks(ctx context.Context, opts ListOptions) (*Page[models.Book], error) {
+	terms := sortTerms(opts, "title")
+	cur, err := decodeCursor(opts.Cursor, filter.BookSchema, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := filter.NewSQLBuilder(filter.BookSchema, "")
+	conds := []string{"deleted_at IS NULL"}
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if cur != nil {
+		cond, err := sb.Keyset(terms, cur.Values, cur.Before)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+
+	query := `
+        SELECT ` + bookColumns + `
+        FROM books
+        WHERE ` + strings.Join(conds, " AND ")
+
+	orderBy, err := sb.OrderBy(terms, cur != nil && cur.Before)
+	if err != nil {
+		return nil, err
+	}
+	query += " ORDER BY " + orderBy
+	query += " LIMIT " + sb.Arg(opts.pageSize()+1)
+	if cur == nil && opts.Offset > 0 {
+		query += " OFFSET " + sb.Arg(opts.Offset)
+	}
+
+	rows, err := b.DB.QueryContext(ctx, query, sb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list books: %v", err)
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		var book models.Book
+		err := rows.Scan(bookFields(&book)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book: %v", err)
+		}
+		books = append(books, book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning books: %v", err)
+	}
+	if err := loadBookAuthors(ctx, b.DB, bookPointers(books)...); err != nil {
+		return nil, err
+	}
+
+	page := newPage(books, bookRecord, terms, cur, opts)
+	if opts.IncludeTotal {
+		total, err := countRows(ctx, b.DB, "books", filter.BookSchema, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+	return page, nil
+}
@@ -0,0 +1,532 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+
+	"github.com/lib/pq"
+)
+
+// Adding and removing books one by one and in bulk share addMember and
+// removeMember. A bulk change reads the order of the collection once, makes
+// all its changes in one transaction and saves the order once. Books that
+// are missing, or already where they should be, are reported rather than
+// failing the change.
+
+// errTransferSelf rejects a transfer of books to the collection they are
+// in.
+var errTransferSelf = &models.ValidationError{Errors: []models.FieldError{
+	{Field: "to", Message: "must be another collection"},
+}}
+
+func transferTargetError(to int) error {
+	v := &models.ValidationError{}
+	v.Add("to", fmt.Sprintf("collection %d does not exist", to))
+	return v
+}
+
+// memberStatus returns added when a book was added, or else present.
+func memberStatus(added bool) string {
+	if added {
+		return models.MemberAdded
+	}
+	return models.MemberPresent
+}
+
+// addMember adds the live book bookID to a collection read by
+// loadMemberOrder at position, and reports false if it was in the
+// collection already. A book is added to a smart collection by taking it
+// back if it was removed by hand, or else by adding it by hand. The caller
+// saves the order.
+func addMember(ctx context.Context, tx *sql.Tx, collection *models.Collection, o *memberOrder, bookID, position int) (bool, error) {
+	if collection.Kind == models.CollectionSmart {
+		matches, err := matchesQuery(ctx, tx, collection, bookID)
+		if err != nil {
+			return false, err
+		}
+		excluded, err := deleteExclusion(ctx, tx, collection.ID, bookID)
+		if err != nil {
+			return false, err
+		}
+		if matches || slices.Contains(o.ids, bookID) {
+			return excluded, nil
+		}
+	} else if slices.Contains(o.ids, bookID) {
+		return false, nil
+	}
+
+	query := `
+	INSERT INTO collection_books (collection_id, book_id, position)
+	VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, collection.ID, bookID, len(o.ids)+1); err != nil {
+		return false, dbError("failed to add book to collection", err)
+	}
+	o.live[bookID] = true
+	o.insert(bookID, position)
+	return true, nil
+}
+
+// memberState reports whether the live book bookID is in a collection read
+// by loadMemberOrder, and whether it matches the query of a smart
+// collection.
+func memberState(ctx context.Context, tx *sql.Tx, collection *models.Collection, o *memberOrder, bookID int) (in, matches bool, err error) {
+	if collection.Kind != models.CollectionSmart {
+		return o.live[bookID], false, nil
+	}
+	if matches, err = matchesQuery(ctx, tx, collection, bookID); err != nil {
+		return false, false, err
+	}
+	var excluded bool
+	query := `SELECT EXISTS (SELECT 1 FROM collection_exclusions WHERE collection_id = $1 AND book_id = $2)`
+	if err := tx.QueryRowContext(ctx, query, collection.ID, bookID).Scan(&excluded); err != nil {
+		return false, false, fmt.Errorf("failed to get collection exclusion: %v", err)
+	}
+	return (matches || o.live[bookID]) && !excluded, matches, nil
+}
+
+// removeMember removes the live book bookID from a collection read by
+// loadMemberOrder, and reports false if it was not in the collection. A
+// book matching the query of a smart collection is removed by excluding it.
+// The caller saves the order.
+func removeMember(ctx context.Context, tx *sql.Tx, collection *models.Collection, o *memberOrder, bookID int) (bool, error) {
+	in, matches, err := memberState(ctx, tx, collection, o, bookID)
+	if err != nil || !in {
+		return false, err
+	}
+
+	if matches {
+		query := `
+		INSERT INTO collection_exclusions (collection_id, book_id)
+		VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, query, collection.ID, bookID); err != nil {
+			return false, dbError("failed to remove book from collection", err)
+		}
+	}
+	if !o.live[bookID] {
+		return true, nil
+	}
+	query := `DELETE FROM collection_books WHERE collection_id = $1 AND book_id = $2`
+	if _, err := tx.ExecContext(ctx, query, collection.ID, bookID); err != nil {
+		return false, dbError("failed to remove book from collection", err)
+	}
+	o.remove(bookID)
+	return true, nil
+}
+
+// selectBooks returns the IDs of the selected books and which of them are
+// live books. Books matching a query are live books of the collection
+// within, or of all books when within is nil.
+func selectBooks(ctx context.Context, tx *sql.Tx, books models.BookSelection, within *models.Collection) ([]int, map[int]bool, error) {
+	var query string
+	var args []interface{}
+	if books.Query == nil {
+		ids := make([]int64, len(books.BookIDs))
+		for i, id := range books.BookIDs {
+			ids[i] = int64(id)
+		}
+		query, args = `SELECT id FROM books WHERE id = ANY($1) AND deleted_at IS NULL`, []interface{}{pq.Array(ids)}
+	} else {
+		f, terms, err := smartQuery(books.Query)
+		if err != nil {
+			return nil, nil, err
+		}
+		sb := filter.NewSQLBuilder(filter.BookSchema, "")
+		conds := []string{"deleted_at IS NULL"}
+		if f != nil {
+			cond, err := sb.Where(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			conds = append(conds, cond)
+		}
+		if within != nil {
+			cond, err := collectionCond(sb, within)
+			if err != nil {
+				return nil, nil, err
+			}
+			conds = append(conds, cond)
+		}
+		orderBy, err := sb.OrderBy(sortTerms(ListOptions{OrderBy: terms}, "title"), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		query = `
+		SELECT id
+		FROM books
+		WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY ` + orderBy
+		args = sb.Args()
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to select books: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	live := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan book: %v", err)
+		}
+		ids = append(ids, id)
+		live[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error after scanning books: %v", err)
+	}
+	if books.Query == nil {
+		ids = books.BookIDs
+	}
+	return ids, live, nil
+}
+
+// AddBooksToCollection adds the selected books at the end of the
+// collection, see models.BookSelection. Books matching a query are taken
+// from all books.
+func (c *CollectionDB) AddBooksToCollection(ctx context.Context, collectionID int, books models.BookSelection) (*models.MembershipReport, error) {
+	report := &models.MembershipReport{Books: []models.MemberResult{}}
+	err := inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		collection, o, err := loadMemberOrder(ctx, tx, collectionID)
+		if err != nil {
+			return err
+		}
+		ids, live, err := selectBooks(ctx, tx, books, nil)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if !live[id] {
+				report.Add(id, models.MemberMissing)
+				continue
+			}
+			added, err := addMember(ctx, tx, collection, o, id, 0)
+			if err != nil {
+				return err
+			}
+			report.Add(id, memberStatus(added))
+		}
+		return saveMemberOrder(ctx, tx, collectionID, o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RemoveBooksFromCollection removes the selected books from the collection.
+// Books matching a query are taken from the books of the collection.
+func (c *CollectionDB) RemoveBooksFromCollection(ctx context.Context, collectionID int, books models.BookSelection) (*models.MembershipReport, error) {
+	report := &models.MembershipReport{Books: []models.MemberResult{}}
+	err := inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		collection, o, err := loadMemberOrder(ctx, tx, collectionID)
+		if err != nil {
+			return err
+		}
+		ids, live, err := selectBooks(ctx, tx, books, collection)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if !live[id] {
+				report.Add(id, models.MemberMissing)
+				continue
+			}
+			removed, err := removeMember(ctx, tx, collection, o, id)
+			if err != nil {
+				return err
+			}
+			if removed {
+				report.Add(id, models.MemberRemoved)
+			} else {
+				report.Add(id, models.MemberAbsent)
+			}
+		}
+		return saveMemberOrder(ctx, tx, collectionID, o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// TransferBooks copies or moves the selected books of the collection to
+// another one, see models.BookTransfer. The results are those of adding the
+// books to the other collection.
+func (c *CollectionDB) TransferBooks(ctx context.Context, collectionID int, transfer models.BookTransfer) (*models.MembershipReport, error) {
+	if transfer.To == collectionID {
+		return nil, errTransferSelf
+	}
+	report := &models.MembershipReport{Books: []models.MemberResult{}}
+	err := inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		// The collections are locked in the order of their IDs, so transfers
+		// between them in both directions cannot deadlock.
+		collections := make(map[int]*models.Collection, 2)
+		orders := make(map[int]*memberOrder, 2)
+		for _, id := range []int{min(collectionID, transfer.To), max(collectionID, transfer.To)} {
+			collection, o, err := loadMemberOrder(ctx, tx, id)
+			if errors.Is(err, ErrNotFound) && id == transfer.To {
+				return transferTargetError(id)
+			}
+			if err != nil {
+				return err
+			}
+			collections[id], orders[id] = collection, o
+		}
+		source, from := collections[collectionID], orders[collectionID]
+		target, to := collections[transfer.To], orders[transfer.To]
+
+		ids, live, err := selectBooks(ctx, tx, transfer.BookSelection, source)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if !live[id] {
+				report.Add(id, models.MemberMissing)
+				continue
+			}
+			in, _, err := memberState(ctx, tx, source, from, id)
+			if err != nil {
+				return err
+			}
+			if !in {
+				report.Add(id, models.MemberAbsent)
+				continue
+			}
+			added, err := addMember(ctx, tx, target, to, id, 0)
+			if err != nil {
+				return err
+			}
+			if transfer.Mode == models.TransferMove {
+				if _, err := removeMember(ctx, tx, source, from, id); err != nil {
+					return err
+				}
+			}
+			report.Add(id, memberStatus(added))
+		}
+		if err := saveMemberOrder(ctx, tx, collectionID, from); err != nil {
+			return err
+		}
+		return saveMemberOrder(ctx, tx, transfer.To, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// addMember works like the function of the same name. The caller holds
+// m.mu for writing.
+func (m *MemoryStore) addMember(collection *models.Collection, bookID, position int) (bool, error) {
+	members := m.memberships[collection.ID]
+	if members == nil {
+		members = make(map[int]membership)
+		m.memberships[collection.ID] = members
+	}
+	_, in := members[bookID]
+	if collection.Kind == models.CollectionSmart {
+		matches, err := m.matchesQuery(collection, bookID)
+		if err != nil {
+			return false, err
+		}
+		excluded := m.exclusions[collection.ID][bookID]
+		delete(m.exclusions[collection.ID], bookID)
+		if in || matches {
+			return excluded, nil
+		}
+	} else if in {
+		return false, nil
+	}
+	o := m.memberOrder(collection.ID)
+	members[bookID] = membership{addedAt: time.Now()}
+	o.insert(bookID, position)
+	m.saveMemberOrder(collection.ID, o)
+	return true, nil
+}
+
+// memberState works like the function of the same name. The caller holds
+// m.mu.
+func (m *MemoryStore) memberState(collection *models.Collection, bookID int) (in, matches bool, err error) {
+	_, added := m.memberships[collection.ID][bookID]
+	if collection.Kind != models.CollectionSmart {
+		return added, false, nil
+	}
+	if matches, err = m.matchesQuery(collection, bookID); err != nil {
+		return false, false, err
+	}
+	return (added || matches) && !m.exclusions[collection.ID][bookID], matches, nil
+}
+
+// removeMember works like the function of the same name. The caller holds
+// m.mu for writing.
+func (m *MemoryStore) removeMember(collection *models.Collection, bookID int) (bool, error) {
+	in, matches, err := m.memberState(collection, bookID)
+	if err != nil || !in {
+		return false, err
+	}
+
+	if matches {
+		if m.exclusions[collection.ID] == nil {
+			m.exclusions[collection.ID] = make(map[int]bool)
+		}
+		m.exclusions[collection.ID][bookID] = true
+	}
+	if _, ok := m.memberships[collection.ID][bookID]; ok {
+		o := m.memberOrder(collection.ID)
+		delete(m.memberships[collection.ID], bookID)
+		o.remove(bookID)
+		m.saveMemberOrder(collection.ID, o)
+	}
+	return true, nil
+}
+
+// selectBooks works like the function of the same name. The caller holds
+// m.mu.
+func (m *MemoryStore) selectBooks(books models.BookSelection, within *models.Collection) ([]int, map[int]bool, error) {
+	live := make(map[int]bool)
+	if books.Query == nil {
+		for _, id := range books.BookIDs {
+			live[id] = m.books[id] != nil
+		}
+		return books.BookIDs, live, nil
+	}
+
+	f, terms, err := smartQuery(books.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	var candidates []models.Book
+	switch {
+	case within == nil:
+		for _, book := range m.books {
+			candidates = append(candidates, *book)
+		}
+	default:
+		if candidates, err = m.collectionBooks(within); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var ids []int
+	err = exportInMemory(candidates, ListOptions{Filter: f, OrderBy: terms}, func(book *models.Book) error {
+		ids = append(ids, book.ID)
+		live[book.ID] = true
+		return nil
+	})
+	return ids, live, err
+}
+
+func (m *MemoryStore) AddBooksToCollection(ctx context.Context, collectionID int, books models.BookSelection) (*models.MembershipReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[collectionID]
+	if !ok {
+		return nil, fmt.Errorf("collection %d %w", collectionID, ErrNotFound)
+	}
+	ids, live, err := m.selectBooks(books, nil)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.MembershipReport{Books: []models.MemberResult{}}
+	for _, id := range ids {
+		if !live[id] {
+			report.Add(id, models.MemberMissing)
+			continue
+		}
+		added, err := m.addMember(collection, id, 0)
+		if err != nil {
+			return nil, err
+		}
+		report.Add(id, memberStatus(added))
+	}
+	return report, nil
+}
+
+func (m *MemoryStore) RemoveBooksFromCollection(ctx context.Context, collectionID int, books models.BookSelection) (*models.MembershipReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[collectionID]
+	if !ok {
+		return nil, fmt.Errorf("collection %d %w", collectionID, ErrNotFound)
+	}
+	ids, live, err := m.selectBooks(books, collection)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.MembershipReport{Books: []models.MemberResult{}}
+	for _, id := range ids {
+		if !live[id] {
+			report.Add(id, models.MemberMissing)
+			continue
+		}
+		removed, err := m.removeMember(collection, id)
+		if err != nil {
+			return nil, err
+		}
+		if removed {
+			report.Add(id, models.MemberRemoved)
+		} else {
+			report.Add(id, models.MemberAbsent)
+		}
+	}
+	return report, nil
+}
+
+func (m *MemoryStore) TransferBooks(ctx context.Context, collectionID int, transfer models.BookTransfer) (*models.MembershipReport, error) {
+	if transfer.To == collectionID {
+		return nil, errTransferSelf
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source, ok := m.collections[collectionID]
+	if !ok {
+		return nil, fmt.Errorf("collection %d %w", collectionID, ErrNotFound)
+	}
+	target, ok := m.collections[transfer.To]
+	if !ok {
+		return nil, transferTargetError(transfer.To)
+	}
+	ids, live, err := m.selectBooks(transfer.BookSelection, source)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.MembershipReport{Books: []models.MemberResult{}}
+	for _, id := range ids {
+		if !live[id] {
+			report.Add(id, models.MemberMissing)
+			continue
+		}
+		in, _, err := m.memberState(source, id)
+		if err != nil {
+			return nil, err
+		}
+		if !in {
+			report.Add(id, models.MemberAbsent)
+			continue
+		}
+		added, err := m.addMember(target, id, 0)
+		if err != nil {
+			return nil, err
+		}
+		if transfer.Mode == models.TransferMove {
+			if _, err := m.removeMember(source, id); err != nil {
+				return nil, err
+			}
+		}
+		report.Add(id, memberStatus(added))
+	}
+	return report, nil
+}
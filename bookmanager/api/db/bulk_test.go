@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"bookmanager/api/models"
+)
+
+// bulkStore returns a store with the books Dune (1) and Hyperion (2) in
+// SciFi, The Hobbit (3) in Fantasy, and a trashed book (4).
+func bulkStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, req := range []models.BookRequest{
+		{Title: "Dune", Author: "Frank Herbert", PublishedDate: "1965-08-01", Edition: 1, Genre: "SciFi"},
+		{Title: "Hyperion", Author: "Dan Simmons", PublishedDate: "1989-05-26", Edition: 1, Genre: "SciFi"},
+		{Title: "The Hobbit", Author: "J. R. R. Tolkien", PublishedDate: "1937-09-21", Edition: 1, Genre: "Fantasy"},
+		{Title: "Trashed", Author: "Nobody", PublishedDate: "2000-01-01", Edition: 1, Genre: "SciFi"},
+	} {
+		if _, err := store.CreateBook(ctx, &req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteBook(ctx, 4, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func newCollection(t *testing.T, store *MemoryStore, req models.CollectionRequest) int {
+	t.Helper()
+	collection, err := store.CreateCollection(context.Background(), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return collection.ID
+}
+
+// checkReport compares the per-book results of a report and its counts.
+func checkReport(t *testing.T, report *models.MembershipReport, want ...models.MemberResult) {
+	t.Helper()
+	if !reflect.DeepEqual(report.Books, want) {
+		t.Errorf("report = %+v, want %+v", report.Books, want)
+	}
+	counts := map[string]int{}
+	for _, r := range want {
+		counts[r.Status]++
+	}
+	got := map[string]int{
+		models.MemberAdded: report.Added, models.MemberRemoved: report.Removed, models.MemberPresent: report.Present,
+		models.MemberAbsent: report.Absent, models.MemberMissing: report.Missing,
+	}
+	for status, n := range got {
+		if n != counts[status] {
+			t.Errorf("report counts %d %s, want %d", n, status, counts[status])
+		}
+	}
+}
+
+func TestAddBooksToCollection(t *testing.T) {
+	ctx := context.Background()
+	store := bulkStore(t)
+	id := newCollection(t, store, models.CollectionRequest{Name: "Reading List"})
+	if err := store.AddBookToCollection(ctx, id, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.AddBooksToCollection(ctx, id, models.BookSelection{BookIDs: []int{3, 2, 4, 99, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, report,
+		models.MemberResult{BookID: 3, Status: models.MemberAdded},
+		models.MemberResult{BookID: 2, Status: models.MemberPresent},
+		models.MemberResult{BookID: 4, Status: models.MemberMissing},
+		models.MemberResult{BookID: 99, Status: models.MemberMissing},
+		models.MemberResult{BookID: 1, Status: models.MemberAdded},
+	)
+	if got := bookOrder(t, store, id); !slices.Equal(got, []int{2, 3, 1}) {
+		t.Errorf("order = %v, want the added books at the end in the order given, [2 3 1]", got)
+	}
+
+	// Books selected by a query are added in its order.
+	other := newCollection(t, store, models.CollectionRequest{Name: "To Buy"})
+	report, err = store.AddBooksToCollection(ctx, other, models.BookSelection{Query: &models.SmartQuery{Genre: "SciFi", OrderBy: "title DESC"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, report,
+		models.MemberResult{BookID: 2, Status: models.MemberAdded},
+		models.MemberResult{BookID: 1, Status: models.MemberAdded},
+	)
+
+	if _, err := store.AddBooksToCollection(ctx, 99, models.BookSelection{BookIDs: []int{1}}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("adding to a missing collection: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRemoveBooksFromCollection(t *testing.T) {
+	ctx := context.Background()
+	store := bulkStore(t)
+	id := newCollection(t, store, models.CollectionRequest{Name: "Reading List"})
+	if _, err := store.AddBooksToCollection(ctx, id, models.BookSelection{BookIDs: []int{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.RemoveBooksFromCollection(ctx, id, models.BookSelection{BookIDs: []int{2, 3, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, report,
+		models.MemberResult{BookID: 2, Status: models.MemberRemoved},
+		models.MemberResult{BookID: 3, Status: models.MemberAbsent},
+		models.MemberResult{BookID: 4, Status: models.MemberMissing},
+	)
+	if got := bookOrder(t, store, id); !slices.Equal(got, []int{1}) {
+		t.Errorf("order = %v, want [1]", got)
+	}
+
+	// A query selects among the books of the collection only.
+	report, err = store.RemoveBooksFromCollection(ctx, id, models.BookSelection{Query: &models.SmartQuery{Genre: "SciFi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, report, models.MemberResult{BookID: 1, Status: models.MemberRemoved})
+}
+
+func TestBulkSmartCollection(t *testing.T) {
+	ctx := context.Background()
+	store := bulkStore(t)
+	id := newCollection(t, store, models.CollectionRequest{Name: "Science Fiction", Query: &models.SmartQuery{Genre: "SciFi"}})
+
+	steps := []struct {
+		name   string
+		change func() (*models.MembershipReport, error)
+		want   []models.MemberResult
+		books  []int
+	}{
+		{"matching books are present", func() (*models.MembershipReport, error) {
+			return store.AddBooksToCollection(ctx, id, models.BookSelection{BookIDs: []int{1, 2}})
+		}, []models.MemberResult{{BookID: 1, Status: models.MemberPresent}, {BookID: 2, Status: models.MemberPresent}}, []int{1, 2}},
+		{"removing a matching book excludes it", func() (*models.MembershipReport, error) {
+			return store.RemoveBooksFromCollection(ctx, id, models.BookSelection{BookIDs: []int{1}})
+		}, []models.MemberResult{{BookID: 1, Status: models.MemberRemoved}}, []int{2}},
+		{"an excluded book is absent", func() (*models.MembershipReport, error) {
+			return store.RemoveBooksFromCollection(ctx, id, models.BookSelection{BookIDs: []int{1, 3}})
+		}, []models.MemberResult{{BookID: 1, Status: models.MemberAbsent}, {BookID: 3, Status: models.MemberAbsent}}, []int{2}},
+		{"adding an excluded book takes it back", func() (*models.MembershipReport, error) {
+			return store.AddBooksToCollection(ctx, id, models.BookSelection{BookIDs: []int{1}})
+		}, []models.MemberResult{{BookID: 1, Status: models.MemberAdded}}, []int{1, 2}},
+		{"a book that does not match is added by hand", func() (*models.MembershipReport, error) {
+			return store.AddBooksToCollection(ctx, id, models.BookSelection{BookIDs: []int{3, 3}})
+		}, []models.MemberResult{{BookID: 3, Status: models.MemberAdded}, {BookID: 3, Status: models.MemberPresent}}, []int{1, 2, 3}},
+		{"removing a book added by hand", func() (*models.MembershipReport, error) {
+			return store.RemoveBooksFromCollection(ctx, id, models.BookSelection{BookIDs: []int{3}})
+		}, []models.MemberResult{{BookID: 3, Status: models.MemberRemoved}}, []int{1, 2}},
+		{"exclude by query", func() (*models.MembershipReport, error) {
+			return store.RemoveBooksFromCollection(ctx, id, models.BookSelection{Query: &models.SmartQuery{Author: "Dan Simmons"}})
+		}, []models.MemberResult{{BookID: 2, Status: models.MemberRemoved}}, []int{1}},
+		{"take back by query", func() (*models.MembershipReport, error) {
+			return store.AddBooksToCollection(ctx, id, models.BookSelection{Query: &models.SmartQuery{Genre: "SciFi"}})
+		}, []models.MemberResult{{BookID: 1, Status: models.MemberPresent}, {BookID: 2, Status: models.MemberAdded}}, []int{1, 2}},
+	}
+	for _, step := range steps {
+		report, err := step.change()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		t.Run(step.name, func(t *testing.T) {
+			checkReport(t, report, step.want...)
+			books, err := store.ListBooksInCollection(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, book := range books {
+				got = append(got, book.ID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, step.books) {
+				t.Errorf("books = %v, want %v", got, step.books)
+			}
+		})
+	}
+}
+
+func TestTransferBooks(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		source []int
+		want   []models.MemberResult
+		from   []int
+		to     []int
+	}{
+		{"copy", models.TransferCopy, []int{1, 3},
+			[]models.MemberResult{{BookID: 1, Status: models.MemberAdded}, {BookID: 2, Status: models.MemberAbsent}, {BookID: 3, Status: models.MemberPresent}, {BookID: 4, Status: models.MemberMissing}},
+			[]int{1, 3}, []int{3, 1}},
+		{"move", models.TransferMove, []int{1, 3},
+			[]models.MemberResult{{BookID: 1, Status: models.MemberAdded}, {BookID: 2, Status: models.MemberAbsent}, {BookID: 3, Status: models.MemberPresent}, {BookID: 4, Status: models.MemberMissing}},
+			[]int{}, []int{3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := bulkStore(t)
+			from := newCollection(t, store, models.CollectionRequest{Name: "Reading List"})
+			to := newCollection(t, store, models.CollectionRequest{Name: "Finished"})
+			if _, err := store.AddBooksToCollection(ctx, from, models.BookSelection{BookIDs: tt.source}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.AddBookToCollection(ctx, to, 3, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := store.TransferBooks(ctx, from, models.BookTransfer{
+				BookSelection: models.BookSelection{BookIDs: []int{1, 2, 3, 4}}, To: to, Mode: tt.mode,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkReport(t, report, tt.want...)
+			if got := bookOrder(t, store, from); !slices.Equal(got, tt.from) {
+				t.Errorf("source = %v, want %v", got, tt.from)
+			}
+			if got := bookOrder(t, store, to); !slices.Equal(got, tt.to) {
+				t.Errorf("target = %v, want %v", got, tt.to)
+			}
+		})
+	}
+}
+
+func TestTransferBooksErrors(t *testing.T) {
+	ctx := context.Background()
+	store := bulkStore(t)
+	id := newCollection(t, store, models.CollectionRequest{Name: "Reading List"})
+	selection := models.BookSelection{BookIDs: []int{1}}
+
+	var v *models.ValidationError
+	if _, err := store.TransferBooks(ctx, id, models.BookTransfer{BookSelection: selection, To: id, Mode: models.TransferCopy}); !errors.As(err, &v) || v.Errors[0].Field != "to" {
+		t.Errorf("transfer to itself: error = %v, want a validation error on to", err)
+	}
+	if _, err := store.TransferBooks(ctx, id, models.BookTransfer{BookSelection: selection, To: 99, Mode: models.TransferCopy}); !errors.As(err, &v) || v.Errors[0].Field != "to" {
+		t.Errorf("transfer to a missing collection: error = %v, want a validation error on to", err)
+	}
+	if _, err := store.TransferBooks(ctx, 99, models.BookTransfer{BookSelection: selection, To: id, Mode: models.TransferCopy}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("transfer from a missing collection: error = %v, want ErrNotFound", err)
+	}
+}
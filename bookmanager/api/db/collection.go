@@ -1,303 +1,735 @@
-package db
-
-import (
-	"bookmanager/api/models"
-	"database/sql"
-	"fmt"
-)
-
-type CollectionDB struct {
-	DB *sql.DB
-}
-
-func NewCollection(db *sql.DB) *CollectionDB {
-	return &CollectionDB{DB: db}
-}
-
-func (c* CollectionDB) CreateCollection(collection *models.CollectionRequest) (*models.Collection, error) {
-	var newCollection models.Collection
-	query := `
-	INSERT INTO collections (name, description)
-	VALUES ($1, $2)
-	RETURNING id, name, description, created_at, updated_at`
-
-	err := DB.QueryRow(
-		query,
-		collection.Name,
-		collection.Description,
-	).Scan(
-		&newCollection.ID,
-		&newCollection.Name,
-		&newCollection.Description,
-		&newCollection.CreatedAt,
-		&newCollection.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to create collection: %v", err)
-	}
-
-	return  &newCollection, nil
-}
-
-func (c* CollectionDB) GetCollection(id int) (*models.Collection, error) {
-	var collection models.Collection
-	query := `
-	SELECT id, name, description, created_at, updated_at
-	FROM collections
-	WHERE id = $1`
-	
-	err := DB.QueryRow(query, id).Scan(
-		&collection.ID,
-		&collection.Name,
-		&collection.Description,
-		&collection.CreatedAt,
-		&collection.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("collection not found")
-		}
-		return  nil, fmt.Errorf("failed to get collection :%v", err)
-	}
-
-	return &collection, nil
-}
-
-
-func (c* CollectionDB) UpdateCollection(id int, collection *models.CollectionRequest) (*models.Collection, error) {
-	var updatedCollection models.Collection
-	query := `
-	UPDATE collections
-	SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP
-	WHERE id = $3
-	RETURNING id, name, description, created_at, updated_at`
-
-	err := DB.QueryRow(
-		query,
-		collection.Name,
-		collection.Description,
-		id,
-	).Scan(
-		&updatedCollection.ID,
-		&updatedCollection.Name,
-		&updatedCollection.Description,
-		&updatedCollection.CreatedAt,
-		&updatedCollection.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("collection not found")
-		}
-		return nil, fmt.Errorf("failed to update collection: %v", err)
-	}
-	return  &updatedCollection, nil
-}
-
-func (c *CollectionDB) PatchCollection(id int, patch *models.CollectionRequest) (*models.Collection, error) {
-    current, err := c.GetCollection(id)
-    if err != nil {
-        return nil, fmt.Errorf("failed to get current collection: %w", err)
-    }
-
-    merged := mergeCollectionWithPatch(current, patch)
-    return c.UpdateCollection(id, merged)
-}
-
-func mergeCollectionWithPatch(current *models.Collection, patch *models.CollectionRequest) *models.CollectionRequest {
-    merged := &models.CollectionRequest{
-        Name:        current.Name,
-        Description: current.Description,
-    }
-
-    if patch.Name != "" {
-        merged.Name = patch.Name
-    }
-    if patch.Description != "" {
-        merged.Description = patch.Description
-    }
-
-    return merged
-}
-
-func (c* CollectionDB) DeleteCollection(id int) error {
-	query := `DELETE FROM collections WHERE id = $1`
-	result, err := DB.Exec(query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete collection: %v", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %v", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("collection not found")
-	}
-
-	return nil
-}
-
-func (c *CollectionDB) ListCollections(where, groupBy, orderBy, limit, offset string) (interface{}, error) {
-    baseQuery := `
-        SELECT id, name, description, created_at, updated_at
-        FROM collections`
-    
-    if groupBy != "" {
-        query := fmt.Sprintf(`
-            SELECT %s as group_key, COUNT(*) as count
-            FROM collections
-            GROUP BY %s`, groupBy, groupBy)
-        
-        rows, err := DB.Query(query)
-        if err != nil {
-            return nil, fmt.Errorf("failed to list grouped collections: %v", err)
-        }
-        defer rows.Close()
-
-        groups := make(map[string]int)
-        for rows.Next() {
-            var key string
-            var count int
-            if err := rows.Scan(&key, &count); err != nil {
-                return nil, fmt.Errorf("failed to scan group: %v", err)
-            }
-            groups[key] = count
-        }
-        return groups, nil
-    }
-    if where != "" {
-        baseQuery += " WHERE " + where
-    }
-    if orderBy != "" {
-        baseQuery += " ORDER BY " + orderBy
-    } else {
-        baseQuery += " ORDER BY name"
-    }
-    if limit != "" {
-        baseQuery += " LIMIT " + limit
-    }
-    if offset != "" {
-        baseQuery += " OFFSET " + offset
-    }
-
-    rows, err := DB.Query(baseQuery)
-    if err != nil {
-        return nil, fmt.Errorf("failed to list collections: %v", err)
-    }
-    defer rows.Close()
-
-    var collections []models.Collection
-    for rows.Next() {
-        var collection models.Collection
-        err := rows.Scan(
-            &collection.ID,
-            &collection.Name,
-            &collection.Description,
-            &collection.CreatedAt,
-            &collection.UpdatedAt,
-        )
-        if err != nil {
-            return nil, fmt.Errorf("failed to scan collection: %v", err)
-        }
-        collections = append(collections, collection)
-    }
-
-    if err = rows.Err(); err != nil {
-        return nil, fmt.Errorf("error after scanning collections: %v", err)
-    }
-
-    return collections, nil
-}
-
-func (c* CollectionDB) AddBookToCollection(collectionID, bookID int) error {
-	query := `
-	INSERT INTO collection_books (collection_id, book_id)
-	VALUES ($1, $2)
-	ON CONFLICT (collection_id, book_id) DO NOTHING`
-
-	result, err := DB.Exec(query, collectionID, bookID)
-	if err != nil {
-		return fmt.Errorf("failed to add book to collection: %v", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return  fmt.Errorf("failed to check rows affected: %v", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("book already exists in collection")
-	}
-
-	return nil
-}
-
-func (c* CollectionDB) RemoveBookFromCollection(collectionID, bookID int) error {
-	query :=  `
-	DELETE FROM collection_books
-	WHERE collection_id = $1 AND book_id = $2`
-
-	result, err := DB.Exec(query, collectionID, bookID)
-	if err != nil {
-		return fmt.Errorf("failed to remove book from collections: %v", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return  fmt.Errorf("failed to check rows affected: %v", err)
-	}
-
-	
-	if rowsAffected == 0 {
-		return fmt.Errorf("book not found in collection")
-	}
-
-	return nil
-
-}
-
-
-func (c* CollectionDB) ListBooksInCollection(collectionID int) ([]models.Book, error) {
-	query := `
-	SELECT b.id, b.title, b.author, b.published_date, b.edition, b.description, b.genre, b.created_at, b.updated_at
-	FROM books b
-	JOIN collection_books cb ON b.id = cb.book_id
-	WHERE cb.collection_id = $1
-	ORDER BY b.title`
-
-	rows, err := DB.Query(query, collectionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list books in collection: %v", err)
-	}
-	defer rows.Close()
-
-	var books []models.Book
-	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(
-			&book.ID,
-			&book.Title,
-			&book.Author,
-			&book.PublishedDate,
-			&book.Edition,
-			&book.Description,
-			&book.Genre,
-			&book.CreatedAt,
-			&book.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan book: %v", err)
-		}
-		books = append(books, book)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error after scanning books: %v", err)
-	}
-
-	return books, nil
-}
+package db
+
+import (
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/patch"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const collectionColumns = "id, name, description, kind, query, parent_id, created_at, updated_at, version"
+
+// collectionFields returns the scan destinations for collectionColumns.
+func collectionFields(collection *models.Collection) []interface{} {
+	return []interface{}{
+		&collection.ID,
+		&collection.Name,
+		&collection.Description,
+		&collection.Kind,
+		collectionQuery{collection},
+		&collection.ParentID,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+		&collection.Version,
+	}
+}
+
+// collectionQuery scans the nullable query column of a collection.
+type collectionQuery struct {
+	collection *models.Collection
+}
+
+func (q collectionQuery) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		q.collection.Query = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into a collection query", value)
+	}
+	q.collection.Query = &models.SmartQuery{}
+	return json.Unmarshal(data, q.collection.Query)
+}
+
+// queryArg returns the value written to the query column.
+func queryArg(q *models.SmartQuery) (interface{}, error) {
+	if q == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode collection query: %v", err)
+	}
+	return string(data), nil
+}
+
+type CollectionDB struct {
+	DB *sql.DB
+}
+
+func NewCollection(db *sql.DB) *CollectionDB {
+	return &CollectionDB{DB: db}
+}
+
+func (c *CollectionDB) CreateCollection(ctx context.Context, collection *models.CollectionRequest) (*models.Collection, error) {
+	if err := validateCollection(collection, nil); err != nil {
+		return nil, err
+	}
+
+	var newCollection *models.Collection
+	err := inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		var err error
+		newCollection, err = createCollection(ctx, tx, collection)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newCollection, nil
+}
+
+// createCollection creates a validated collection.
+func createCollection(ctx context.Context, tx *sql.Tx, collection *models.CollectionRequest) (*models.Collection, error) {
+	smartQuery, err := queryArg(collection.Query)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkParent(ctx, tx, 0, collection.ParentID); err != nil {
+		return nil, err
+	}
+
+	var newCollection models.Collection
+	query := `
+	INSERT INTO collections (name, description, kind, query, parent_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + collectionColumns
+	err = tx.QueryRowContext(ctx,
+		query,
+		collection.Name,
+		collection.Description,
+		collection.CollectionKind(),
+		smartQuery,
+		collection.ParentID,
+	).Scan(collectionFields(&newCollection)...)
+	if err != nil {
+		return nil, dbError("failed to create collection", err)
+	}
+	if err := recordRevision(ctx, tx, "collection", models.RevisionCreate, newCollection.ID, newCollection.Version, nil, &newCollection); err != nil {
+		return nil, err
+	}
+	return &newCollection, nil
+}
+
+func (c *CollectionDB) GetCollection(ctx context.Context, id int) (*models.Collection, error) {
+	return getCollection(ctx, c.DB, id, "")
+}
+
+func getCollection(ctx context.Context, q querier, id int, lock string) (*models.Collection, error) {
+	var collection models.Collection
+	query := `
+	SELECT ` + collectionColumns + `
+	FROM collections
+	WHERE id = $1 AND deleted_at IS NULL
+	` + lock
+
+	err := q.QueryRowContext(ctx, query, id).Scan(collectionFields(&collection)...)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("collection %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get collection :%v", err)
+	}
+
+	return &collection, nil
+}
+
+func (c *CollectionDB) UpdateCollection(ctx context.Context, id int, collection *models.CollectionRequest, version int) (*models.Collection, error) {
+	return c.changeCollection(ctx, id, version, models.RevisionUpdate, func(*models.Collection) (*models.CollectionRequest, error) {
+		return collection, nil
+	})
+}
+
+// changeCollection works like BookDB.changeBook. Any change may set a new
+// parent, so it takes the tree lock before the row lock, see lockTree.
+func (c *CollectionDB) changeCollection(ctx context.Context, id, version int, action string, change func(current *models.Collection) (*models.CollectionRequest, error)) (*models.Collection, error) {
+	var changed *models.Collection
+	err := inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		if err := lockTree(ctx, tx); err != nil {
+			return err
+		}
+		current, err := getCollection(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("collection", current.Version, version); err != nil {
+			return err
+		}
+
+		collection, err := change(current)
+		if err != nil {
+			return err
+		}
+		if err := validateCollection(collection, current); err != nil {
+			return err
+		}
+		changed, err = updateCollection(ctx, tx, id, collection, current.Version)
+		if err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, "collection", action, id, changed.Version, current, changed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// updateCollection overwrites collection id, checking its new parent with
+// checkParent; q is the transaction making the change.
+func updateCollection(ctx context.Context, q querier, id int, collection *models.CollectionRequest, version int) (*models.Collection, error) {
+	smartQuery, err := queryArg(collection.Query)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkParent(ctx, q, id, collection.ParentID); err != nil {
+		return nil, err
+	}
+
+	var updatedCollection models.Collection
+	query := `
+	UPDATE collections
+	SET name = $1, description = $2, query = $3, parent_id = $4, updated_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $5 AND deleted_at IS NULL AND ($6 = 0 OR version = $6)
+	RETURNING ` + collectionColumns
+
+	err = q.QueryRowContext(ctx,
+		query,
+		collection.Name,
+		collection.Description,
+		smartQuery,
+		collection.ParentID,
+		id,
+		version,
+	).Scan(collectionFields(&updatedCollection)...)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, versionError(ctx, q, "collections", "collection", id, version)
+		}
+		return nil, dbError("failed to update collection", err)
+	}
+	return &updatedCollection, nil
+}
+
+// PatchCollection applies p to the current collection.
+func (c *CollectionDB) PatchCollection(ctx context.Context, id int, p patch.Patch, version int) (*models.Collection, error) {
+	return c.changeCollection(ctx, id, version, models.RevisionPatch, func(current *models.Collection) (*models.CollectionRequest, error) {
+		return patchCollection(current, p)
+	})
+}
+
+var collectionPatchSchema = patch.Schema{
+	"name":        {Kind: patch.String},
+	"description": {Kind: patch.String, Optional: true},
+	"query":       {Kind: patch.Object, Optional: true},
+	"parent_id":   {Kind: patch.Integer, Optional: true},
+}
+
+// patchCollection applies p to the editable fields of current. A merge patch
+// of query changes only the query fields it names; null clears one.
+func patchCollection(current *models.Collection, p patch.Patch) (*models.CollectionRequest, error) {
+	doc, err := collectionPatchSchema.Apply(p, patch.Document{
+		"name":        current.Name,
+		"description": current.Description,
+		"query":       queryDocument(current.Query),
+		"parent_id":   parentDocument(current.ParentID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	collection := &models.CollectionRequest{
+		Name:        doc.String("name"),
+		Description: doc.String("description"),
+		Kind:        current.Kind,
+	}
+	if collection.Query, err = queryFromDocument(doc["query"]); err != nil {
+		return nil, err
+	}
+	if parentID, ok := doc["parent_id"].(int); ok {
+		collection.ParentID = &parentID
+	}
+	if err := collection.Validate(); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// queryDocument returns q as a patch document value.
+func queryDocument(q *models.SmartQuery) interface{} {
+	if q == nil {
+		return nil
+	}
+	doc := map[string]interface{}{}
+	for name, values := range q.Values() {
+		doc[name] = values[0]
+	}
+	return doc
+}
+
+// collectionRequest returns the request that writes c as it is.
+func collectionRequest(c *models.Collection) *models.CollectionRequest {
+	return &models.CollectionRequest{
+		Name:        c.Name,
+		Description: c.Description,
+		Kind:        c.Kind,
+		Query:       c.Query,
+		ParentID:    c.ParentID,
+	}
+}
+
+// parentDocument returns a parent ID as a patch document value.
+func parentDocument(parentID *int) interface{} {
+	if parentID == nil {
+		return nil
+	}
+	return *parentID
+}
+
+func queryFromDocument(value interface{}) (*models.SmartQuery, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %v", err)
+	}
+	var q models.SmartQuery
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, &models.ValidationError{Errors: []models.FieldError{
+			{Field: "query", Message: "must be an object of string fields"},
+		}}
+	}
+	return &q, nil
+}
+
+// DeleteCollection moves the collection to the trash, like BookDB.DeleteBook.
+// With cascade its sub-collections, all the way down, go to the trash with
+// it; otherwise they move up to its parent.
+func (c *CollectionDB) DeleteCollection(ctx context.Context, id int, version int, cascade bool) error {
+	return inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		if err := lockTree(ctx, tx); err != nil {
+			return err
+		}
+		current, err := getCollection(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("collection", current.Version, version); err != nil {
+			return err
+		}
+
+		if cascade {
+			descendants, err := subtree(ctx, tx, id, "FOR UPDATE")
+			if err != nil {
+				return err
+			}
+			for i := range descendants[1:] {
+				if err := trashCollection(ctx, tx, &descendants[i+1]); err != nil {
+					return err
+				}
+			}
+		} else if err := rehomeChildren(ctx, tx, current); err != nil {
+			return err
+		}
+		return trashCollection(ctx, tx, current)
+	})
+}
+
+// trashCollection moves a collection locked by the caller to the trash.
+func trashCollection(ctx context.Context, tx *sql.Tx, current *models.Collection) error {
+	deleted := *current
+	query := `
+	UPDATE collections
+	SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $1
+	RETURNING deleted_at, version`
+	if err := tx.QueryRowContext(ctx, query, current.ID).Scan(&deleted.DeletedAt, &deleted.Version); err != nil {
+		return dbError("failed to delete collection", err)
+	}
+	return recordRevision(ctx, tx, "collection", models.RevisionDelete, current.ID, deleted.Version, current, &deleted)
+}
+
+// GroupCollections lists the collections matching opts.Filter grouped by
+// opts.GroupBy.
+func (c *CollectionDB) GroupCollections(ctx context.Context, opts ListOptions) ([]Group[models.Collection], error) {
+	return groupRows(ctx, c.DB, "collections", collectionColumns, filter.CollectionSchema, "name", opts, collectionRecord, collectionFields)
+}
+
+// ListCollections returns one page of collections, see BookDB.ListBooks.
+func (c *CollectionDB) ListCollections(ctx context.Context, opts ListOptions) (*Page[models.Collection], error) {
+	terms := sortTerms(opts, "name")
+	cur, err := decodeCursor(opts.Cursor, filter.CollectionSchema, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := filter.NewSQLBuilder(filter.CollectionSchema, "")
+	conds := []string{"deleted_at IS NULL"}
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if cur != nil {
+		cond, err := sb.Keyset(terms, cur.Values, cur.Before)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+
+	query := `
+        SELECT ` + collectionColumns + `
+        FROM collections
+        WHERE ` + strings.Join(conds, " AND ")
+
+	orderBy, err := sb.OrderBy(terms, cur != nil && cur.Before)
+	if err != nil {
+		return nil, err
+	}
+	query += " ORDER BY " + orderBy
+	query += " LIMIT " + sb.Arg(opts.pageSize()+1)
+	if cur == nil && opts.Offset > 0 {
+		query += " OFFSET " + sb.Arg(opts.Offset)
+	}
+
+	rows, err := c.DB.QueryContext(ctx, query, sb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %v", err)
+	}
+	defer rows.Close()
+
+	var collections []models.Collection
+	for rows.Next() {
+		var collection models.Collection
+		err := rows.Scan(collectionFields(&collection)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %v", err)
+		}
+		collections = append(collections, collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning collections: %v", err)
+	}
+
+	page := newPage(collections, collectionRecord, terms, cur, opts)
+	if opts.IncludeTotal {
+		total, err := countRows(ctx, c.DB, "collections", filter.CollectionSchema, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+	return page, nil
+}
+
+// AddBookToCollection adds a book at a position, see
+// models.CollectionBookRequest.
+func (c *CollectionDB) AddBookToCollection(ctx context.Context, collectionID, bookID, position int) error {
+	return inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		collection, o, err := loadMemberOrder(ctx, tx, collectionID)
+		if err != nil {
+			return err
+		}
+		// Books in the trash still satisfy the foreign key, so the book is
+		// checked explicitly.
+		if _, err := getBook(ctx, tx, bookID, ""); errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("book %d %w", bookID, ErrNotFound)
+		} else if err != nil {
+			return err
+		}
+		if collection.Kind == models.CollectionSmart && position != 0 {
+			return errSmartPosition
+		}
+
+		added, err := addMember(ctx, tx, collection, o, bookID, position)
+		if err != nil {
+			return err
+		}
+		if !added {
+			return fmt.Errorf("book already exists in collection: %w", ErrConflict)
+		}
+		return saveMemberOrder(ctx, tx, collectionID, o)
+	})
+}
+
+// RemoveBookFromCollection removes a book and closes the gap it leaves.
+func (c *CollectionDB) RemoveBookFromCollection(ctx context.Context, collectionID, bookID int) error {
+	return inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		collection, o, err := loadMemberOrder(ctx, tx, collectionID)
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("book %w in collection", ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		removed, err := removeMember(ctx, tx, collection, o, bookID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("book %w in collection", ErrNotFound)
+		}
+		return saveMemberOrder(ctx, tx, collectionID, o)
+	})
+}
+
+// ListBooksInCollection lists the books of a collection in order, with
+// their positions. The books of a smart collection are in the order of its
+// query. A missing collection is ErrNotFound.
+func (c *CollectionDB) ListBooksInCollection(ctx context.Context, collectionID int) ([]models.Book, error) {
+	collection, err := getCollection(ctx, c.DB, collectionID, "")
+	if err != nil {
+		return nil, err
+	}
+	if collection.Kind == models.CollectionSmart {
+		books := []models.Book{}
+		err := exportBooks(ctx, c.DB, collection, ListOptions{}, func(book *models.Book) error {
+			book.Position = len(books) + 1
+			books = append(books, *book)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return books, nil
+	}
+
+	query := `
+	SELECT b.id, b.title, b.author, b.published_date, b.edition, b.description, b.genre, b.isbn13, b.created_at, b.updated_at, b.version
+	FROM books b
+	JOIN collection_books cb ON b.id = cb.book_id
+	WHERE cb.collection_id = $1 AND b.deleted_at IS NULL
+	ORDER BY cb.position`
+
+	rows, err := c.DB.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list books in collection: %v", err)
+	}
+	defer rows.Close()
+
+	books := []models.Book{}
+	for rows.Next() {
+		var book models.Book
+		err := rows.Scan(bookFields(&book)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book: %v", err)
+		}
+		book.Position = len(books) + 1
+		books = append(books, book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning books: %v", err)
+	}
+	if err := loadBookAuthors(ctx, c.DB, bookPointers(books)...); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// ListBookCollections returns one page of the collections a book is in,
+// see BookDB.ListBooks; opts.Filter and opts.OrderBy use the collection
+// schema and the default order is by name. A missing book is ErrNotFound.
+func (c *CollectionDB) ListBookCollections(ctx context.Context, bookID int, opts ListOptions) (*Page[models.BookCollection], error) {
+	if _, err := getBook(ctx, c.DB, bookID, ""); err != nil {
+		return nil, err
+	}
+	terms := sortTerms(opts, "name")
+	cur, err := decodeCursor(opts.Cursor, filter.CollectionSchema, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	_, collectionIDs, err := bookMemberships(ctx, c.DB, []int64{int64(bookID)})
+	if err != nil {
+		return nil, err
+	}
+
+	sb := filter.NewSQLBuilder(filter.CollectionSchema, "c")
+	from := `
+        FROM collections c
+        LEFT JOIN collection_books cb ON cb.collection_id = c.id AND cb.book_id = ` + sb.Arg(bookID) + `
+        WHERE `
+	conds := []string{"c.id = ANY(" + sb.Arg(pq.Array(collectionIDs)) + ")", "c.deleted_at IS NULL"}
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+
+	var total *int
+	if opts.IncludeTotal {
+		var n int
+		err := c.DB.QueryRowContext(ctx, "SELECT COUNT(*)"+from+strings.Join(conds, " AND "), sb.Args()...).Scan(&n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count collections of book: %v", err)
+		}
+		total = &n
+	}
+
+	if cur != nil {
+		cond, err := sb.Keyset(terms, cur.Values, cur.Before)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	orderBy, err := sb.OrderBy(terms, cur != nil && cur.Before)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        SELECT c.id, c.name, c.description, c.kind, c.query, c.parent_id, c.created_at, c.updated_at, c.version, cb.created_at` +
+		from + strings.Join(conds, " AND ") + `
+        ORDER BY ` + orderBy + `
+        LIMIT ` + sb.Arg(opts.pageSize()+1)
+	if cur == nil && opts.Offset > 0 {
+		query += " OFFSET " + sb.Arg(opts.Offset)
+	}
+
+	rows, err := c.DB.QueryContext(ctx, query, sb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections of book: %v", err)
+	}
+	defer rows.Close()
+
+	var collections []models.BookCollection
+	for rows.Next() {
+		var collection models.BookCollection
+		err := rows.Scan(append(collectionFields(&collection.Collection), &collection.AddedAt)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %v", err)
+		}
+		collections = append(collections, collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning collections: %v", err)
+	}
+
+	page := newPage(collections, bookCollectionRecord, terms, cur, opts)
+	page.Total = total
+	return page, nil
+}
+
+// LoadCollections sets the Collections of the books, by name, with one
+// query for all of them.
+func (b *BookDB) LoadCollections(ctx context.Context, books ...*models.Book) error {
+	return loadBookCollections(ctx, b.DB, books...)
+}
+
+func loadBookCollections(ctx context.Context, q querier, books ...*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	byID := make(map[int][]*models.Book, len(books))
+	ids := make([]int64, 0, len(books))
+	for _, book := range books {
+		book.Collections = []models.BookCollection{}
+		byID[book.ID] = append(byID[book.ID], book)
+		ids = append(ids, int64(book.ID))
+	}
+
+	bookIDs, collectionIDs, err := bookMemberships(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	rows, err := q.QueryContext(ctx, `
+	SELECT m.book_id, c.id, c.name, c.description, c.kind, c.query, c.parent_id, c.created_at, c.updated_at, c.version, cb.created_at
+	FROM unnest($1::bigint[], $2::bigint[]) AS m(book_id, collection_id)
+	JOIN collections c ON c.id = m.collection_id
+	LEFT JOIN collection_books cb ON cb.collection_id = m.collection_id AND cb.book_id = m.book_id
+	ORDER BY m.book_id, c.name, c.id`, pq.Array(bookIDs), pq.Array(collectionIDs))
+	if err != nil {
+		return fmt.Errorf("failed to list book collections: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var collection models.BookCollection
+		err := rows.Scan(append([]interface{}{&bookID}, append(collectionFields(&collection.Collection), &collection.AddedAt)...)...)
+		if err != nil {
+			return fmt.Errorf("failed to scan book collection: %v", err)
+		}
+		for _, book := range byID[bookID] {
+			book.Collections = append(book.Collections, collection)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error after scanning book collections: %v", err)
+	}
+	return nil
+}
+
+// bookMemberships returns the live collections the books are in as pairs
+// of a book and a collection: the manual collections holding them and the
+// smart collections whose books, see collectionCond, include them.
+func bookMemberships(ctx context.Context, q querier, ids []int64) (bookIDs, collectionIDs []int64, err error) {
+	smart, err := queryCollections(ctx, q, `
+	SELECT `+collectionColumns+`
+	FROM collections
+	WHERE kind = $1 AND deleted_at IS NULL`, models.CollectionSmart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sb := filter.NewSQLBuilder(filter.BookSchema, "")
+	books := sb.Arg(pq.Array(ids))
+	parts := []string{`
+	SELECT cb.book_id, cb.collection_id
+	FROM collection_books cb
+	JOIN collections c ON c.id = cb.collection_id
+	WHERE cb.book_id = ANY(` + books + `) AND c.kind = ` + sb.Arg(models.CollectionManual) + ` AND c.deleted_at IS NULL`}
+	for i := range smart {
+		cond, err := collectionCond(sb, &smart[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		parts = append(parts, `
+	SELECT id, `+sb.Arg(smart[i].ID)+`::integer
+	FROM books
+	WHERE id = ANY(`+books+`) AND COALESCE((`+cond+`), FALSE)`)
+	}
+
+	rows, err := q.QueryContext(ctx, strings.Join(parts, "\n\tUNION ALL"), sb.Args()...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list book collections: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bookID, collectionID int64
+		if err := rows.Scan(&bookID, &collectionID); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan book collection: %v", err)
+		}
+		bookIDs = append(bookIDs, bookID)
+		collectionIDs = append(collectionIDs, collectionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error after scanning book collections: %v", err)
+	}
+	return bookIDs, collectionIDs, nil
+}
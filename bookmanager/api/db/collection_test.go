@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookmanager/api/models"
+)
+
+func TestListBookCollectionsIncludesSmartCollections(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	book, err := store.CreateBook(ctx, &models.BookRequest{Title: "Dune", Author: "Frank Herbert", PublishedDate: "1965-08-01", Edition: 1, Genre: "SciFi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(req models.CollectionRequest) *models.Collection {
+		t.Helper()
+		collection, err := store.CreateCollection(ctx, &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return collection
+	}
+	manual := create(models.CollectionRequest{Name: "Reading List"})
+	matching := create(models.CollectionRequest{Name: "Science Fiction", Query: &models.SmartQuery{Genre: "SciFi"}})
+	added := create(models.CollectionRequest{Name: "Club Picks", Query: &models.SmartQuery{Genre: "Fantasy"}})
+	create(models.CollectionRequest{Name: "Fantasy", Query: &models.SmartQuery{Genre: "Fantasy"}})
+	for _, collection := range []*models.Collection{manual, added} {
+		if err := store.AddBookToCollection(ctx, collection.ID, book.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func() map[int]bool {
+		t.Helper()
+		page, err := store.ListBookCollections(ctx, book.ID, ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[int]bool)
+		for _, collection := range page.Items {
+			got[collection.ID] = collection.AddedAt != nil
+		}
+		return got
+	}
+
+	got := list()
+	want := map[int]bool{manual.ID: true, matching.ID: false, added.ID: true}
+	if len(got) != len(want) {
+		t.Fatalf("collections = %v, want %v (ID: has added_at)", got, want)
+	}
+	for id, hasAddedAt := range want {
+		if got[id] != hasAddedAt {
+			t.Errorf("collections = %v, want %v (ID: has added_at)", got, want)
+		}
+	}
+
+	if err := store.RemoveBookFromCollection(ctx, matching.ID, book.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list()[matching.ID]; ok {
+		t.Errorf("book removed from smart collection %d is still listed in it", matching.ID)
+	}
+}
+
+func TestListBooksInCollection(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	empty, err := store.CreateCollection(ctx, &models.CollectionRequest{Name: "Empty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smart, err := store.CreateCollection(ctx, &models.CollectionRequest{Name: "Nothing Yet", Query: &models.SmartQuery{Genre: "Poetry"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{empty.ID, smart.ID} {
+		books, err := store.ListBooksInCollection(ctx, id)
+		if err != nil || books == nil || len(books) != 0 {
+			t.Errorf("ListBooksInCollection(%d) = %v, %v; want no books", id, books, err)
+		}
+		books, err = store.ListBooksInCollectionTree(ctx, id)
+		if err != nil || books == nil || len(books) != 0 {
+			t.Errorf("ListBooksInCollectionTree(%d) = %v, %v; want no books", id, books, err)
+		}
+	}
+
+	if _, err := store.ListBooksInCollection(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListBooksInCollection of a missing collection: %v, want ErrNotFound", err)
+	}
+	if _, err := store.ListBooksInCollectionTree(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListBooksInCollectionTree of a missing collection: %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+// cursor is the decoded form of a page token. It holds the sort values of
+// the row at the edge of a page, so the next query can continue right after
+// (or, for a previous page, right before) that row without an OFFSET.
+type cursor struct {
+	Order  string        `json:"o"`
+	Values []interface{} `json:"v"`
+	Before bool          `json:"b,omitempty"`
+}
+
+// orderKey identifies an ordering, so a cursor is not reused with another one.
+func orderKey(terms []filter.OrderTerm) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term.Field.Name
+		if term.Desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(terms []filter.OrderTerm, rec filter.Record, before bool) string {
+	c := cursor{Order: orderKey(terms), Before: before}
+	for _, term := range terms {
+		v := rec(term.Field.Name)
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(time.RFC3339Nano)
+		}
+		c.Values = append(c.Values, v)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a page token for the given ordering. An empty token
+// yields a nil cursor. Invalid tokens are reported as a validation error on
+// the cursor parameter.
+func decodeCursor(token string, schema *filter.Schema, terms []filter.OrderTerm) (*cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	c, err := parseCursor(token, schema, terms)
+	if err != nil {
+		return nil, &models.ValidationError{Errors: []models.FieldError{{Field: "cursor", Message: err.Error()}}}
+	}
+	return c, nil
+}
+
+func parseCursor(token string, schema *filter.Schema, terms []filter.OrderTerm) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("is malformed")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var c cursor
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("is malformed")
+	}
+	if c.Order != orderKey(terms) || len(c.Values) != len(terms) {
+		return nil, fmt.Errorf("was issued for a different order_by")
+	}
+
+	for i, term := range terms {
+		def := schema.Fields[term.Field.Name]
+		v, err := cursorValue(c.Values[i], def)
+		if err != nil {
+			return nil, fmt.Errorf("has an invalid value for %s", term.Field.Name)
+		}
+		c.Values[i] = v
+	}
+	return &c, nil
+}
+
+// cursorValue converts a JSON decoded cursor value back to the type used for
+// the field in SQL arguments and filter.Record values.
+func cursorValue(raw interface{}, def filter.FieldDef) (interface{}, error) {
+	if raw == nil {
+		switch {
+		case !def.Nullable:
+			return nil, fmt.Errorf("unexpected null")
+		case def.Type == filter.TextField:
+			return "", nil
+		}
+		return nil, nil
+	}
+	switch def.Type {
+	case filter.IntField:
+		n, ok := raw.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return strconv.Atoi(n.String())
+	case filter.DateField, filter.TimeField:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a timestamp")
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	default:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string")
+		}
+		return s, nil
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+func TestListAuthorsPagesPastNullDates(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, author := range []models.AuthorRequest{
+		{Name: "Ursula K. Le Guin", BirthDate: "1929-10-21"},
+		{Name: "Homer"},
+		{Name: "Frank Herbert", BirthDate: "1920-10-08"},
+		{Name: "Anonymous"},
+	} {
+		if _, err := store.CreateAuthor(ctx, &author); err != nil {
+			t.Fatalf("CreateAuthor(%s): %v", author.Name, err)
+		}
+	}
+
+	tests := []struct {
+		orderBy string
+		want    []string
+	}{
+		{"birth_date", []string{"Frank Herbert", "Ursula K. Le Guin", "Homer", "Anonymous"}},
+		{"birth_date DESC", []string{"Homer", "Anonymous", "Ursula K. Le Guin", "Frank Herbert"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.orderBy, func(t *testing.T) {
+			terms, err := filter.ParseOrderBy(tt.orderBy, filter.AuthorSchema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Ties on birth_date are broken by id, the order of creation.
+			opts := ListOptions{OrderBy: terms, Limit: 1}
+
+			var names []string
+			for {
+				page, err := store.ListAuthors(ctx, opts)
+				if err != nil {
+					t.Fatalf("ListAuthors after %v: %v", names, err)
+				}
+				for _, author := range page.Items {
+					names = append(names, author.Name)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursorValueNull(t *testing.T) {
+	tests := []struct {
+		def     filter.FieldDef
+		want    interface{}
+		wantErr bool
+	}{
+		{filter.FieldDef{Type: filter.DateField, Nullable: true}, nil, false},
+		{filter.FieldDef{Type: filter.TextField, Nullable: true}, "", false},
+		{filter.FieldDef{Type: filter.DateField}, nil, true},
+		{filter.FieldDef{Type: filter.IntField}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := cursorValue(nil, tt.def)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("cursorValue(nil, %+v) = %v, %v; want %v, error %v", tt.def, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Errors returned by the stores are wrapped around one of these values, so
+// callers can tell them apart with errors.Is.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrValidation         = errors.New("validation failed")
+	ErrForeignKey         = errors.New("foreign key violation")
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqNotNullViolation    = "23502"
+	pqCheckViolation      = "23514"
+	pqStringTooLong       = "22001"
+	pqInvalidDatetime     = "22007"
+	pqDatetimeOverflow    = "22008"
+)
+
+// dbError wraps err with the sentinel matching its PostgreSQL error code.
+func dbError(op string, err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	var sentinel error
+	switch pqErr.Code {
+	case pqUniqueViolation:
+		sentinel = ErrConflict
+	case pqForeignKeyViolation:
+		sentinel = ErrForeignKey
+	case pqNotNullViolation, pqCheckViolation, pqStringTooLong, pqInvalidDatetime, pqDatetimeOverflow:
+		sentinel = ErrValidation
+	default:
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	return fmt.Errorf("%s: %w: %s", op, sentinel, pqErr.Message)
+}
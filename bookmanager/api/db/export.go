@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+// exportBatchSize is the number of books an export reads per query.
+const exportBatchSize = 500
+
+// ExportBooks calls fn with every book matching opts.Filter in the order of
+// opts.OrderBy. Books are read in batches continuing after the sort values of
+// the previous batch, so an export of any size holds one batch in memory.
+// Limit, Offset, Cursor and GroupBy are ignored. An error from fn stops the
+// export and is returned.
+func (b *BookDB) ExportBooks(ctx context.Context, opts ListOptions, fn func(*models.Book) error) error {
+	return exportBooks(ctx, b.DB, nil, opts, fn)
+}
+
+// ExportCollectionBooks works like ExportBooks for the books of a
+// collection. The books of a smart collection are in the order of its query
+// unless opts.OrderBy is set.
+func (c *CollectionDB) ExportCollectionBooks(ctx context.Context, collectionID int, opts ListOptions, fn func(*models.Book) error) error {
+	collection, err := getCollection(ctx, c.DB, collectionID, "")
+	if err != nil {
+		return err
+	}
+	return exportBooks(ctx, c.DB, collection, opts, fn)
+}
+
+// exportBooks exports the books of the collection, or all books if
+// collection is nil.
+func exportBooks(ctx context.Context, conn *sql.DB, collection *models.Collection, opts ListOptions, fn func(*models.Book) error) error {
+	if collection != nil && collection.Kind == models.CollectionSmart && len(opts.OrderBy) == 0 {
+		_, terms, err := smartQuery(collection.Query)
+		if err != nil {
+			return err
+		}
+		opts.OrderBy = terms
+	}
+	terms := sortTerms(opts, "title")
+	var after []interface{}
+	for {
+		sb := filter.NewSQLBuilder(filter.BookSchema, "")
+		conds := []string{"deleted_at IS NULL"}
+		if opts.Filter != nil {
+			cond, err := sb.Where(opts.Filter)
+			if err != nil {
+				return err
+			}
+			conds = append(conds, cond)
+		}
+		if collection != nil {
+			cond, err := collectionCond(sb, collection)
+			if err != nil {
+				return err
+			}
+			conds = append(conds, cond)
+		}
+		if after != nil {
+			cond, err := sb.Keyset(terms, after, false)
+			if err != nil {
+				return err
+			}
+			conds = append(conds, cond)
+		}
+		orderBy, err := sb.OrderBy(terms, false)
+		if err != nil {
+			return err
+		}
+
+		query := `
+        SELECT ` + bookColumns + `
+        FROM books
+        WHERE ` + strings.Join(conds, " AND ") + `
+        ORDER BY ` + orderBy + `
+        LIMIT ` + sb.Arg(exportBatchSize)
+
+		books, err := queryBooks(ctx, conn, query, sb.Args()...)
+		if err != nil {
+			return err
+		}
+		for i := range books {
+			if err := fn(&books[i]); err != nil {
+				return err
+			}
+		}
+		if len(books) < exportBatchSize {
+			return nil
+		}
+		after = keysetValues(terms, bookRecord(&books[len(books)-1]))
+	}
+}
+
+// queryBooks runs a query selecting bookColumns and loads the authors of
+// the books.
+func queryBooks(ctx context.Context, q querier, query string, args ...interface{}) ([]models.Book, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list books: %v", err)
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		var book models.Book
+		if err := rows.Scan(bookFields(&book)...); err != nil {
+			return nil, fmt.Errorf("failed to scan book: %v", err)
+		}
+		books = append(books, book)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning books: %v", err)
+	}
+	if err := loadBookAuthors(ctx, q, bookPointers(books)...); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// keysetValues returns the sort values of rec as SQLBuilder.Keyset expects
+// them, the way decodeCursor does for a page token.
+func keysetValues(terms []filter.OrderTerm, rec filter.Record) []interface{} {
+	values := make([]interface{}, len(terms))
+	for i, term := range terms {
+		values[i] = rec(term.Field.Name)
+		if values[i] == nil {
+			// Only nullable text fields are nil; they sort as "".
+			values[i] = ""
+		}
+	}
+	return values
+}
+
+// ExportBooks works like BookDB.ExportBooks.
+func (m *MemoryStore) ExportBooks(ctx context.Context, opts ListOptions, fn func(*models.Book) error) error {
+	return exportInMemory(m.allBooks(), opts, fn)
+}
+
+// ExportCollectionBooks works like CollectionDB.ExportCollectionBooks.
+func (m *MemoryStore) ExportCollectionBooks(ctx context.Context, collectionID int, opts ListOptions, fn func(*models.Book) error) error {
+	m.mu.RLock()
+	collection, ok := m.collections[collectionID]
+	if !ok {
+		m.mu.RUnlock()
+		return fmt.Errorf("collection %w", ErrNotFound)
+	}
+	var books []models.Book
+	if collection.Kind == models.CollectionSmart {
+		var terms []filter.OrderTerm
+		var err error
+		if books, terms, err = m.smartBooks(collection); err != nil {
+			m.mu.RUnlock()
+			return err
+		}
+		if len(opts.OrderBy) == 0 {
+			opts.OrderBy = terms
+		}
+	} else {
+		for bookID := range m.memberships[collectionID] {
+			if book, ok := m.books[bookID]; ok {
+				books = append(books, *book)
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	return exportInMemory(books, opts, fn)
+}
+
+// exportInMemory pages through books with listInMemory.
+func exportInMemory(books []models.Book, opts ListOptions, fn func(*models.Book) error) error {
+	opts.Limit, opts.Offset, opts.Cursor, opts.GroupBy, opts.IncludeTotal = MaxPageSize, 0, "", nil, false
+	for {
+		page, err := listInMemory(books, bookRecord, filter.BookSchema, "title", opts)
+		if err != nil {
+			return err
+		}
+		for i := range page.Items {
+			if err := fn(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
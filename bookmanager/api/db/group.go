@@ -0,0 +1,245 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"bookmanager/api/filter"
+)
+
+// Group is one group of a grouped list. Key holds the value of each group
+// key, nil for NULL, rendered as text. Items are the first rows of the group
+// in list order; NextCursor continues the list of the group when Filter is
+// used as the list filter.
+type Group[T any] struct {
+	Key        []*string
+	Count      int
+	Items      []T
+	NextCursor string
+	Filter     filter.Expr
+}
+
+// groupRows runs a grouped list over table. Groups are ordered by their
+// keys and paginated by opts.Limit and opts.Offset; window functions number
+// the groups and the rows within each group so only the requested rows are
+// returned. fields returns the scan destinations for columns.
+func groupRows[T any](ctx context.Context, conn *sql.DB, table, columns string, schema *filter.Schema, defaultOrder string, opts ListOptions,
+	record func(*T) filter.Record, fields func(*T) []interface{}) ([]Group[T], error) {
+	terms := sortTerms(opts, defaultOrder)
+	sb := filter.NewSQLBuilder(schema, "")
+
+	whereClause := " WHERE " + liveRows(table)
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		whereClause += " AND " + cond
+	}
+
+	keyExprs := make([]string, len(opts.GroupBy))
+	keyCols := make([]string, len(opts.GroupBy))
+	keyText := make([]string, len(opts.GroupBy))
+	for i, key := range opts.GroupBy {
+		expr, err := sb.GroupExpr(key)
+		if err != nil {
+			return nil, err
+		}
+		keyCols[i] = fmt.Sprintf("group_key_%d", i)
+		keyExprs[i] = expr + " AS " + keyCols[i]
+		keyText[i] = keyCols[i] + "::text"
+	}
+	orderBy, err := sb.OrderBy(terms, false)
+	if err != nil {
+		return nil, err
+	}
+	keys := strings.Join(keyCols, ", ")
+
+	// Every group returns at least one row, which carries its count.
+	rowsPerGroup := opts.GroupLimit
+	if rowsPerGroup < 1 {
+		rowsPerGroup = 1
+	}
+	query := fmt.Sprintf(`
+        WITH filtered AS (
+            SELECT %[1]s, %[2]s
+            FROM %[3]s%[4]s
+        ), numbered AS (
+            SELECT *,
+                   DENSE_RANK() OVER (ORDER BY %[5]s) AS group_no,
+                   COUNT(*) OVER (PARTITION BY %[5]s) AS group_count,
+                   ROW_NUMBER() OVER (PARTITION BY %[5]s ORDER BY %[6]s) AS row_no
+            FROM filtered
+        )
+        SELECT %[1]s, %[7]s, group_count
+        FROM numbered
+        WHERE group_no > %[8]s AND group_no <= %[9]s AND row_no <= %[10]s
+        ORDER BY group_no, row_no`,
+		columns, strings.Join(keyExprs, ", "), table, whereClause,
+		keys, orderBy, strings.Join(keyText, ", "),
+		sb.Arg(opts.Offset), sb.Arg(opts.Offset+opts.pageSize()), sb.Arg(rowsPerGroup))
+
+	rows, err := conn.QueryContext(ctx, query, sb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list grouped %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	var groups []Group[T]
+	for rows.Next() {
+		var item T
+		key := make([]sql.NullString, len(opts.GroupBy))
+		var count int
+		dest := fields(&item)
+		for i := range key {
+			dest = append(dest, &key[i])
+		}
+		dest = append(dest, &count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s group: %v", table, err)
+		}
+
+		if len(groups) == 0 || !sameKey(groups[len(groups)-1].Key, key) {
+			g := Group[T]{Count: count, Items: []T{}}
+			for _, k := range key {
+				if k.Valid {
+					v := k.String
+					g.Key = append(g.Key, &v)
+				} else {
+					g.Key = append(g.Key, nil)
+				}
+			}
+			groups = append(groups, g)
+		}
+		if opts.GroupLimit > 0 {
+			g := &groups[len(groups)-1]
+			g.Items = append(g.Items, item)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning %s groups: %v", table, err)
+	}
+
+	return finishGroups(groups, schema, terms, record, opts)
+}
+
+func sameKey(a []*string, b []sql.NullString) bool {
+	for i := range a {
+		if (a[i] == nil) != !b[i].Valid || (a[i] != nil && *a[i] != b[i].String) {
+			return false
+		}
+	}
+	return true
+}
+
+// finishGroups fills in the filter and continuation cursor of every group.
+func finishGroups[T any](groups []Group[T], schema *filter.Schema, terms []filter.OrderTerm, record func(*T) filter.Record, opts ListOptions) ([]Group[T], error) {
+	if groups == nil {
+		groups = []Group[T]{}
+	}
+	for i := range groups {
+		g := &groups[i]
+		exprs := []filter.Expr{opts.Filter}
+		for j, key := range opts.GroupBy {
+			expr, err := schema.GroupFilter(key, g.Key[j])
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, expr)
+		}
+		g.Filter = filter.And(exprs...)
+		if len(g.Items) > 0 && g.Count > len(g.Items) {
+			g.NextCursor = encodeCursor(terms, record(&g.Items[len(g.Items)-1]), false)
+		}
+	}
+	return groups, nil
+}
+
+// groupInMemory groups the matching items like groupRows does in SQL.
+func groupInMemory[T any](items []T, record func(*T) filter.Record, schema *filter.Schema, defaultOrder string, opts ListOptions) ([]Group[T], error) {
+	matched, err := matchInMemory(items, record, schema, opts)
+	if err != nil {
+		return nil, err
+	}
+	terms := sortTerms(opts, defaultOrder)
+	keyTypes := make([]filter.FieldType, len(opts.GroupBy))
+	for i, key := range opts.GroupBy {
+		def, ok := schema.Fields[key.Field.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown %s field %q", schema.Name, key.Field.Name)
+		}
+		keyTypes[i] = def.Type
+	}
+
+	compare := func(a, b filter.Record) int {
+		for _, key := range opts.GroupBy {
+			if c := filter.Compare(key.GroupValue(a), key.GroupValue(b)); c != 0 {
+				return c
+			}
+		}
+		a, b = schema.SortRecord(a), schema.SortRecord(b)
+		for _, term := range terms {
+			c := filter.Compare(a(term.Field.Name), b(term.Field.Name))
+			if term.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return compare(record(&matched[i]), record(&matched[j])) < 0
+	})
+
+	var groups []Group[T]
+	var last []interface{}
+	for i := range matched {
+		rec := record(&matched[i])
+		values := make([]interface{}, len(opts.GroupBy))
+		for j, key := range opts.GroupBy {
+			values[j] = key.GroupValue(rec)
+		}
+		if len(groups) == 0 || !sameValues(last, values) {
+			g := Group[T]{Items: []T{}}
+			for j, v := range values {
+				if v == nil {
+					g.Key = append(g.Key, nil)
+				} else {
+					text := groupKey(v, keyTypes[j])
+					g.Key = append(g.Key, &text)
+				}
+			}
+			groups = append(groups, g)
+			last = values
+		}
+		g := &groups[len(groups)-1]
+		g.Count++
+		if len(g.Items) < opts.GroupLimit {
+			g.Items = append(g.Items, matched[i])
+		}
+	}
+
+	if opts.Offset >= len(groups) {
+		groups = nil
+	} else {
+		groups = groups[opts.Offset:]
+	}
+	if limit := opts.pageSize(); limit < len(groups) {
+		groups = groups[:limit]
+	}
+	return finishGroups(groups, schema, terms, record, opts)
+}
+
+func sameValues(a, b []interface{}) bool {
+	for i := range a {
+		if filter.Compare(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
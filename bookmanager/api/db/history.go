@@ -0,0 +1,433 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"bookmanager/api/models"
+	"bookmanager/api/utils"
+)
+
+// revisionFields lists the fields compared between revisions, in the order
+// changes are reported.
+var revisionFields = map[string][]string{
+	"book":       {"title", "author", "authors", "published_date", "edition", "description", "genre", "isbn13", "deleted_at"},
+	"collection": {"name", "description", "query", "parent_id", "deleted_at"},
+}
+
+// newRevision describes the change from before to after, the records as
+// they are returned by the API. before is nil for a creation.
+func newRevision(ctx context.Context, kind, action string, version int, before, after interface{}) (*models.Revision, error) {
+	snapshot, err := json.Marshal(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s snapshot: %v", kind, err)
+	}
+	var old, current map[string]json.RawMessage
+	if before != nil {
+		data, err := json.Marshal(before)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s snapshot: %v", kind, err)
+		}
+		if err := json.Unmarshal(data, &old); err != nil {
+			return nil, fmt.Errorf("failed to decode %s snapshot: %v", kind, err)
+		}
+	}
+	if err := json.Unmarshal(snapshot, &current); err != nil {
+		return nil, fmt.Errorf("failed to decode %s snapshot: %v", kind, err)
+	}
+
+	changes := []models.FieldChange{}
+	for _, field := range revisionFields[kind] {
+		a, b := jsonOrNull(old[field]), jsonOrNull(current[field])
+		if !bytes.Equal(a, b) {
+			changes = append(changes, models.FieldChange{Field: field, Old: a, New: b})
+		}
+	}
+
+	actor := utils.Actor(ctx)
+	if actor == "" {
+		actor = "anonymous"
+	}
+	return &models.Revision{
+		Revision:  version,
+		Action:    action,
+		Actor:     actor,
+		RequestID: utils.RequestID(ctx),
+		CreatedAt: time.Now(),
+		Changes:   changes,
+		Snapshot:  snapshot,
+	}, nil
+}
+
+func jsonOrNull(v json.RawMessage) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	return v
+}
+
+// recordRevision writes the revision for a change of the book or
+// collection id through q, normally the transaction making the change.
+func recordRevision(ctx context.Context, q querier, kind, action string, id, version int, before, after interface{}) error {
+	rev, err := newRevision(ctx, kind, action, version, before, after)
+	if err != nil {
+		return err
+	}
+	return insertRevision(ctx, q, kind, id, rev)
+}
+
+func insertRevision(ctx context.Context, q querier, kind string, id int, rev *models.Revision) error {
+	changes, err := json.Marshal(rev.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s changes: %v", kind, err)
+	}
+
+	query := `
+	INSERT INTO revisions (entity_type, entity_id, revision, action, actor, request_id, reverted_from, snapshot, changes)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err = q.ExecContext(ctx, query,
+		kind, id, rev.Revision, rev.Action, rev.Actor, rev.RequestID, rev.RevertedFrom,
+		string(rev.Snapshot), string(changes))
+	if err != nil {
+		return dbError("failed to record "+kind+" revision", err)
+	}
+	return nil
+}
+
+// listRevisions returns the revisions of a book or collection, newest
+// first and without snapshots. Records that predate the history have none;
+// only unknown IDs are reported as not found.
+func listRevisions(ctx context.Context, conn *sql.DB, kind, table string, id int) ([]models.Revision, error) {
+	rows, err := conn.QueryContext(ctx, `
+	SELECT revision, action, actor, request_id, reverted_from, created_at, changes
+	FROM revisions
+	WHERE entity_type = $1 AND entity_id = $2
+	ORDER BY revision DESC`, kind, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s history: %v", kind, err)
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows.Scan, false)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning revisions: %v", err)
+	}
+
+	if len(revisions) == 0 {
+		var exists bool
+		err := conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %v", kind, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%s %w", kind, ErrNotFound)
+		}
+	}
+	return revisions, nil
+}
+
+// getRevision returns one revision with its snapshot.
+func getRevision(ctx context.Context, q querier, kind string, id, revision int) (*models.Revision, error) {
+	row := q.QueryRowContext(ctx, `
+	SELECT revision, action, actor, request_id, reverted_from, created_at, changes, snapshot
+	FROM revisions
+	WHERE entity_type = $1 AND entity_id = $2 AND revision = $3`, kind, id, revision)
+	rev, err := scanRevision(row.Scan, true)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s revision %d %w", kind, revision, ErrNotFound)
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+func scanRevision(scan func(...interface{}) error, withSnapshot bool) (*models.Revision, error) {
+	var rev models.Revision
+	var revertedFrom sql.NullInt64
+	var changes, snapshot []byte
+	dest := []interface{}{&rev.Revision, &rev.Action, &rev.Actor, &rev.RequestID, &revertedFrom, &rev.CreatedAt, &changes}
+	if withSnapshot {
+		dest = append(dest, &snapshot)
+	}
+	if err := scan(dest...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan revision: %v", err)
+	}
+
+	if revertedFrom.Valid {
+		n := int(revertedFrom.Int64)
+		rev.RevertedFrom = &n
+	}
+	if err := json.Unmarshal(changes, &rev.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode revision changes: %v", err)
+	}
+	if withSnapshot {
+		rev.Snapshot = json.RawMessage(snapshot)
+	}
+	return &rev, nil
+}
+
+func (b *BookDB) BookHistory(ctx context.Context, id int) ([]models.Revision, error) {
+	return listRevisions(ctx, b.DB, "book", "books", id)
+}
+
+func (b *BookDB) BookRevision(ctx context.Context, id, revision int) (*models.Revision, error) {
+	return getRevision(ctx, b.DB, "book", id, revision)
+}
+
+// RevertBook writes the fields of an earlier revision back to the book as a
+// new revision. Books in the trash have to be restored first.
+func (b *BookDB) RevertBook(ctx context.Context, id, revision, version int) (*models.Book, error) {
+	var reverted *models.Book
+	err := inTx(ctx, b.DB, func(tx *sql.Tx) error {
+		current, err := getBook(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("book", current.Version, version); err != nil {
+			return err
+		}
+		old, err := getRevision(ctx, tx, "book", id, revision)
+		if err != nil {
+			return err
+		}
+		req, err := bookSnapshot(old)
+		if err != nil {
+			return err
+		}
+
+		reverted, err = updateBook(ctx, tx, current, req)
+		if err != nil {
+			return err
+		}
+		rev, err := newRevision(ctx, "book", models.RevisionRevert, reverted.Version, current, reverted)
+		if err != nil {
+			return err
+		}
+		rev.RevertedFrom = &revision
+		return insertRevision(ctx, tx, "book", id, rev)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+// bookSnapshot returns the editable fields of the book in rev.
+func bookSnapshot(rev *models.Revision) (*models.BookRequest, error) {
+	var book models.Book
+	if err := json.Unmarshal(rev.Snapshot, &book); err != nil {
+		return nil, fmt.Errorf("failed to decode book snapshot: %v", err)
+	}
+	return bookRequest(&book), nil
+}
+
+func (c *CollectionDB) CollectionHistory(ctx context.Context, id int) ([]models.Revision, error) {
+	return listRevisions(ctx, c.DB, "collection", "collections", id)
+}
+
+func (c *CollectionDB) CollectionRevision(ctx context.Context, id, revision int) (*models.Revision, error) {
+	return getRevision(ctx, c.DB, "collection", id, revision)
+}
+
+// RevertCollection works like BookDB.RevertBook.
+func (c *CollectionDB) RevertCollection(ctx context.Context, id, revision, version int) (*models.Collection, error) {
+	var reverted *models.Collection
+	err := inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		// The old revision may have another parent, see changeCollection.
+		if err := lockTree(ctx, tx); err != nil {
+			return err
+		}
+		current, err := getCollection(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkVersion("collection", current.Version, version); err != nil {
+			return err
+		}
+		old, err := getRevision(ctx, tx, "collection", id, revision)
+		if err != nil {
+			return err
+		}
+		req, err := collectionSnapshot(old)
+		if err != nil {
+			return err
+		}
+
+		reverted, err = updateCollection(ctx, tx, id, req, current.Version)
+		if err != nil {
+			return err
+		}
+		rev, err := newRevision(ctx, "collection", models.RevisionRevert, reverted.Version, current, reverted)
+		if err != nil {
+			return err
+		}
+		rev.RevertedFrom = &revision
+		return insertRevision(ctx, tx, "collection", id, rev)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+func collectionSnapshot(rev *models.Revision) (*models.CollectionRequest, error) {
+	var collection models.Collection
+	if err := json.Unmarshal(rev.Snapshot, &collection); err != nil {
+		return nil, fmt.Errorf("failed to decode collection snapshot: %v", err)
+	}
+	return collectionRequest(&collection), nil
+}
+
+type revisionKey struct {
+	kind string
+	id   int
+}
+
+// recordRevision keeps the revision for a change; the caller holds m.mu.
+func (m *MemoryStore) recordRevision(ctx context.Context, kind, action string, id, version int, before, after interface{}) (*models.Revision, error) {
+	rev, err := newRevision(ctx, kind, action, version, before, after)
+	if err != nil {
+		return nil, err
+	}
+	key := revisionKey{kind, id}
+	m.revisions[key] = append(m.revisions[key], *rev)
+	return &m.revisions[key][len(m.revisions[key])-1], nil
+}
+
+func (m *MemoryStore) history(kind string, id int, exists bool) ([]models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored := m.revisions[revisionKey{kind, id}]
+	if len(stored) == 0 && !exists {
+		return nil, fmt.Errorf("%s %w", kind, ErrNotFound)
+	}
+	revisions := make([]models.Revision, 0, len(stored))
+	for _, rev := range stored {
+		rev.Snapshot = nil
+		revisions = append(revisions, rev)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision > revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// revision returns a copy of a stored revision; the caller holds m.mu.
+func (m *MemoryStore) revision(kind string, id, revision int) (*models.Revision, error) {
+	for _, rev := range m.revisions[revisionKey{kind, id}] {
+		if rev.Revision == revision {
+			return &rev, nil
+		}
+	}
+	return nil, fmt.Errorf("%s revision %d %w", kind, revision, ErrNotFound)
+}
+
+func (m *MemoryStore) BookHistory(ctx context.Context, id int) ([]models.Revision, error) {
+	m.mu.RLock()
+	exists := m.books[id] != nil || m.trashedBooks[id] != nil
+	m.mu.RUnlock()
+	return m.history("book", id, exists)
+}
+
+func (m *MemoryStore) BookRevision(ctx context.Context, id, revision int) (*models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.revision("book", id, revision)
+}
+
+func (m *MemoryStore) RevertBook(ctx context.Context, id, revision, version int) (*models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.books[id]
+	if !ok {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	if err := checkVersion("book", current.Version, version); err != nil {
+		return nil, err
+	}
+	old, err := m.revision("book", id, revision)
+	if err != nil {
+		return nil, err
+	}
+	req, err := bookSnapshot(old)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *current
+	reverted, err := m.updateBook(current, req)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := m.recordRevision(ctx, "book", models.RevisionRevert, id, reverted.Version, &before, reverted)
+	if err != nil {
+		return nil, err
+	}
+	rev.RevertedFrom = &revision
+	return reverted, nil
+}
+
+func (m *MemoryStore) CollectionHistory(ctx context.Context, id int) ([]models.Revision, error) {
+	m.mu.RLock()
+	exists := m.collections[id] != nil || m.trashedCollections[id] != nil
+	m.mu.RUnlock()
+	return m.history("collection", id, exists)
+}
+
+func (m *MemoryStore) CollectionRevision(ctx context.Context, id, revision int) (*models.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.revision("collection", id, revision)
+}
+
+func (m *MemoryStore) RevertCollection(ctx context.Context, id, revision, version int) (*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if err := checkVersion("collection", current.Version, version); err != nil {
+		return nil, err
+	}
+	old, err := m.revision("collection", id, revision)
+	if err != nil {
+		return nil, err
+	}
+	req, err := collectionSnapshot(old)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.checkParent(id, req.ParentID); err != nil {
+		return nil, err
+	}
+	before := *current
+	reverted := m.updateCollection(current, req)
+	rev, err := m.recordRevision(ctx, "collection", models.RevisionRevert, id, reverted.Version, &before, reverted)
+	if err != nil {
+		return nil, err
+	}
+	rev.RevertedFrom = &revision
+	return reverted, nil
+}
@@ -0,0 +1,305 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"bookmanager/api/models"
+)
+
+// Policies for imported books whose ISBN is already used by a book.
+const (
+	DuplicateFail   = "fail"
+	DuplicateSkip   = "skip"
+	DuplicateUpsert = "upsert"
+)
+
+// ImportOptions controls ImportBooks.
+type ImportOptions struct {
+	// DryRun reports what the import would do without writing anything.
+	DryRun bool
+	// OnDuplicate is DuplicateFail, DuplicateSkip or DuplicateUpsert.
+	OnDuplicate string
+}
+
+// ImportRow is one record of an import. Err is set when the record could
+// not be read into Book; the row then fails.
+type ImportRow struct {
+	Row  int
+	Book *models.BookRequest
+	Err  error
+}
+
+// bookImporter writes the rows of an import to one store.
+type bookImporter interface {
+	// findISBN returns the book using isbn, or 0 if there is none.
+	findISBN(isbn string) (id int, trashed bool, err error)
+	create(book *models.BookRequest) (*models.Book, error)
+	update(id int, book *models.BookRequest) (*models.Book, error)
+	// row runs fn so that whatever fn wrote is undone if it fails.
+	row(fn func() error) error
+}
+
+// importBooks validates and writes rows through imp and reports on each of
+// them. Rows failing with a validation error or one of the store's
+// sentinel errors are reported; any other error aborts the import.
+func importBooks(rows []ImportRow, opts ImportOptions, imp bookImporter) (*models.ImportReport, error) {
+	report := &models.ImportReport{DryRun: opts.DryRun, Rows: make([]models.ImportResult, 0, len(rows))}
+	for _, row := range rows {
+		result, err := importRow(row, opts, imp)
+		if err != nil {
+			return nil, err
+		}
+		report.Add(result)
+	}
+
+	report.Committed = !opts.DryRun && report.Failed == 0
+	if !report.Committed {
+		for i := range report.Rows {
+			if report.Rows[i].Status == models.ImportCreated {
+				report.Rows[i].ID = 0
+			}
+		}
+	}
+	return report, nil
+}
+
+func importRow(row ImportRow, opts ImportOptions, imp bookImporter) (models.ImportResult, error) {
+	result := models.ImportResult{Row: row.Row}
+	err := row.Err
+	if err == nil {
+		err = row.Book.Validate()
+	}
+	if err == nil {
+		err = imp.row(func() error {
+			isbn := row.Book.ISBN()
+			id, trashed, err := imp.findISBN(isbn)
+			if err != nil {
+				return err
+			}
+
+			var book *models.Book
+			switch {
+			case id == 0:
+				book, err = imp.create(row.Book)
+				result.Status = models.ImportCreated
+			case opts.OnDuplicate == DuplicateSkip:
+				book = &models.Book{ID: id}
+				result.Status = models.ImportSkipped
+				result.Message = fmt.Sprintf("isbn %s is already used by book %d", isbn, id)
+			case trashed:
+				err = fmt.Errorf("isbn %s is already used by book %d in the trash: %w", isbn, id, ErrConflict)
+			case opts.OnDuplicate == DuplicateUpsert:
+				book, err = imp.update(id, row.Book)
+				result.Status = models.ImportUpdated
+			default:
+				err = fmt.Errorf("isbn %s is already used by book %d: %w", isbn, id, ErrConflict)
+			}
+			if err != nil {
+				return err
+			}
+			result.ID = book.ID
+			return nil
+		})
+	}
+	if err == nil {
+		return result, nil
+	}
+
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		result.Errors = validationErr.Errors
+	case errors.Is(err, ErrConflict), errors.Is(err, ErrValidation), errors.Is(err, ErrForeignKey), errors.Is(err, ErrNotFound):
+	default:
+		return result, err
+	}
+	result.Status = models.ImportFailed
+	result.ID = 0
+	result.Message = err.Error()
+	return result, nil
+}
+
+// ImportBooks writes rows in a single transaction with a savepoint per
+// row, so every failing row can be reported before all of them are rolled
+// back.
+func (b *BookDB) ImportBooks(ctx context.Context, rows []ImportRow, opts ImportOptions) (*models.ImportReport, error) {
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	report, err := importBooks(rows, opts, &sqlImporter{ctx: ctx, tx: tx})
+	if err != nil {
+		return nil, err
+	}
+	if report.Committed {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+	}
+	return report, nil
+}
+
+type sqlImporter struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func (i *sqlImporter) findISBN(isbn string) (int, bool, error) {
+	if isbn == "" {
+		return 0, false, nil
+	}
+	var id int
+	var trashed bool
+	err := i.tx.QueryRowContext(i.ctx, `SELECT id, deleted_at IS NOT NULL FROM books WHERE isbn13 = $1`, isbn).Scan(&id, &trashed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to check isbn: %v", err)
+	}
+	return id, trashed, nil
+}
+
+func (i *sqlImporter) create(book *models.BookRequest) (*models.Book, error) {
+	return createBook(i.ctx, i.tx, book)
+}
+
+func (i *sqlImporter) update(id int, book *models.BookRequest) (*models.Book, error) {
+	current, err := getBook(i.ctx, i.tx, id, "FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := updateBook(i.ctx, i.tx, current, book)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordRevision(i.ctx, i.tx, "book", models.RevisionUpdate, id, changed.Version, current, changed); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+func (i *sqlImporter) row(fn func() error) error {
+	if _, err := i.tx.ExecContext(i.ctx, "SAVEPOINT import_row"); err != nil {
+		return fmt.Errorf("failed to create savepoint: %v", err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := i.tx.ExecContext(i.ctx, "ROLLBACK TO SAVEPOINT import_row"); rbErr != nil {
+			return fmt.Errorf("failed to roll back to savepoint: %v", rbErr)
+		}
+		return err
+	}
+	if _, err := i.tx.ExecContext(i.ctx, "RELEASE SAVEPOINT import_row"); err != nil {
+		return fmt.Errorf("failed to release savepoint: %v", err)
+	}
+	return nil
+}
+
+// ImportBooks works like BookDB.ImportBooks. The books, authors and
+// revisions are copied beforehand and put back unless the import is
+// committed.
+func (m *MemoryStore) ImportBooks(ctx context.Context, rows []ImportRow, opts ImportOptions) (*models.ImportReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := m.saveBooks()
+	report, err := importBooks(rows, opts, &memoryImporter{ctx: ctx, m: m})
+	if err != nil || !report.Committed {
+		m.restoreBooks(saved)
+	}
+	return report, err
+}
+
+// memoryBooks is a copy of everything writing live books changes.
+type memoryBooks struct {
+	books        map[int]models.Book
+	authors      map[int]models.Author
+	revisions    map[revisionKey][]models.Revision
+	nextBookID   int
+	nextAuthorID int
+}
+
+// saveBooks copies the live books; the caller holds m.mu.
+func (m *MemoryStore) saveBooks() *memoryBooks {
+	saved := &memoryBooks{
+		books:        make(map[int]models.Book, len(m.books)),
+		authors:      make(map[int]models.Author, len(m.authors)),
+		revisions:    make(map[revisionKey][]models.Revision, len(m.revisions)),
+		nextBookID:   m.nextBookID,
+		nextAuthorID: m.nextAuthorID,
+	}
+	for id, book := range m.books {
+		saved.books[id] = *book
+	}
+	for id, author := range m.authors {
+		saved.authors[id] = *author
+	}
+	// Revisions are only ever appended, so the slices can be shared.
+	for key, revisions := range m.revisions {
+		saved.revisions[key] = revisions
+	}
+	return saved
+}
+
+// restoreBooks puts back what saveBooks copied; the caller holds m.mu.
+func (m *MemoryStore) restoreBooks(saved *memoryBooks) {
+	m.books = make(map[int]*models.Book, len(saved.books))
+	for id, book := range saved.books {
+		book := book
+		m.books[id] = &book
+	}
+	m.authors = make(map[int]*models.Author, len(saved.authors))
+	for id, author := range saved.authors {
+		author := author
+		m.authors[id] = &author
+	}
+	m.revisions = saved.revisions
+	m.nextBookID = saved.nextBookID
+	m.nextAuthorID = saved.nextAuthorID
+}
+
+// memoryImporter writes to a MemoryStore whose lock is held. The stores
+// check everything before they write, so a failing row leaves nothing
+// behind.
+type memoryImporter struct {
+	ctx context.Context
+	m   *MemoryStore
+}
+
+func (i *memoryImporter) findISBN(isbn string) (int, bool, error) {
+	if isbn == "" {
+		return 0, false, nil
+	}
+	for _, book := range i.m.books {
+		if book.ISBN13 == isbn {
+			return book.ID, false, nil
+		}
+	}
+	for _, book := range i.m.trashedBooks {
+		if book.ISBN13 == isbn {
+			return book.ID, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (i *memoryImporter) create(book *models.BookRequest) (*models.Book, error) {
+	return i.m.createBook(i.ctx, book)
+}
+
+func (i *memoryImporter) update(id int, book *models.BookRequest) (*models.Book, error) {
+	current, ok := i.m.books[id]
+	if !ok {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	return i.m.changeBook(i.ctx, models.RevisionUpdate, current, book)
+}
+
+func (i *memoryImporter) row(fn func() error) error {
+	return fn()
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+const (
+	// DefaultPageSize is used when a list request does not set a limit.
+	DefaultPageSize = 50
+	// MaxPageSize is the largest limit a list request may ask for.
+	MaxPageSize = 200
+	// DefaultGroupSize is the number of rows listed per group by default.
+	DefaultGroupSize = 10
+)
+
+// ListOptions describes a validated list query. Filter, GroupBy and OrderBy
+// only ever reference whitelisted fields of the resource schema.
+type ListOptions struct {
+	Filter  filter.Expr
+	GroupBy []filter.GroupKey
+	OrderBy []filter.OrderTerm
+	// Limit and Offset count groups instead of rows when GroupBy is set.
+	Limit  int
+	Offset int
+	// GroupLimit is the number of rows listed per group; zero only counts.
+	GroupLimit int
+	// Cursor is an opaque token from a previous page's NextCursor or
+	// PrevCursor. It takes precedence over Offset.
+	Cursor string
+	// IncludeTotal asks for the number of matching rows across all pages.
+	IncludeTotal bool
+}
+
+// Page is one page of a list. The cursors are empty when there is no next
+// or previous page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	PrevCursor string
+	Total      *int
+}
+
+func (opts ListOptions) pageSize() int {
+	if opts.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if opts.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return opts.Limit
+}
+
+// sortTerms returns the effective ordering of a list: the requested terms, or
+// defaultOrder, always followed by id so that every row has a unique position.
+func sortTerms(opts ListOptions, defaultOrder string) []filter.OrderTerm {
+	terms := append([]filter.OrderTerm(nil), opts.OrderBy...)
+	if len(terms) == 0 {
+		terms = append(terms, filter.OrderTerm{Field: filter.Field(defaultOrder)})
+	}
+	return append(terms, filter.OrderTerm{Field: filter.Field("id")})
+}
+
+// newPage builds a page from rows fetched in query order with one row more
+// than the page size, which tells whether another page follows.
+func newPage[T any](rows []T, record func(*T) filter.Record, terms []filter.OrderTerm, cur *cursor, opts ListOptions) *Page[T] {
+	limit := opts.pageSize()
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	backward := cur != nil && cur.Before
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &Page[T]{Items: rows}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(rows) == 0 {
+		return page
+	}
+
+	hasNext, hasPrev := more, cur != nil || opts.Offset > 0
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		page.NextCursor = encodeCursor(terms, record(&rows[len(rows)-1]), false)
+	}
+	if hasPrev {
+		page.PrevCursor = encodeCursor(terms, record(&rows[0]), true)
+	}
+	return page
+}
+
+// countRows counts the rows of table matching the filter, for Page.Total.
+func countRows(ctx context.Context, conn *sql.DB, table string, schema *filter.Schema, f filter.Expr) (int, error) {
+	sb := filter.NewSQLBuilder(schema, "")
+	query := "SELECT COUNT(*) FROM " + table + " WHERE " + liveRows(table)
+	if f != nil {
+		cond, err := sb.Where(f)
+		if err != nil {
+			return 0, err
+		}
+		query += " AND " + cond
+	}
+
+	var total int
+	if err := conn.QueryRowContext(ctx, query, sb.Args()...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %v", table, err)
+	}
+	return total, nil
+}
+
+// liveRows is the condition that leaves out rows in the trash. Authors are
+// deleted for good, so all of their rows are live.
+func liveRows(table string) string {
+	if table == "authors" {
+		return "TRUE"
+	}
+	return "deleted_at IS NULL"
+}
+
+// bookRecord, collectionRecord, bookCollectionRecord and authorRecord expose rows to filter.Schema.Match and to
+// cursor encoding.
+func bookRecord(b *models.Book) filter.Record {
+	return func(field string) interface{} {
+		switch field {
+		case "id":
+			return b.ID
+		case "title":
+			return b.Title
+		case "author":
+			return b.Author
+		case "published_date":
+			t, err := time.Parse(time.RFC3339, b.PublishedDate)
+			if err != nil {
+				return nil
+			}
+			return t
+		case "edition":
+			return b.Edition
+		case "description":
+			if b.Description == "" {
+				return nil
+			}
+			return b.Description
+		case "genre":
+			if b.Genre == "" {
+				return nil
+			}
+			return b.Genre
+		case "isbn13":
+			if b.ISBN13 == "" {
+				return nil
+			}
+			return b.ISBN13
+		case "created_at":
+			return b.CreatedAt
+		case "updated_at":
+			return b.UpdatedAt
+		}
+		return nil
+	}
+}
+
+func collectionRecord(c *models.Collection) filter.Record {
+	return func(field string) interface{} {
+		switch field {
+		case "id":
+			return c.ID
+		case "name":
+			return c.Name
+		case "description":
+			return c.Description
+		case "kind":
+			return c.Kind
+		case "created_at":
+			return c.CreatedAt
+		case "updated_at":
+			return c.UpdatedAt
+		}
+		return nil
+	}
+}
+
+func bookCollectionRecord(c *models.BookCollection) filter.Record {
+	return collectionRecord(&c.Collection)
+}
+
+func authorRecord(a *models.Author) filter.Record {
+	date := func(s string) interface{} {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil
+		}
+		return t
+	}
+	return func(field string) interface{} {
+		switch field {
+		case "id":
+			return a.ID
+		case "name":
+			return a.Name
+		case "sort_name":
+			return a.SortName
+		case "birth_date":
+			return date(a.BirthDate)
+		case "death_date":
+			return date(a.DeathDate)
+		case "bio":
+			return a.Bio
+		case "created_at":
+			return a.CreatedAt
+		case "updated_at":
+			return a.UpdatedAt
+		}
+		return nil
+	}
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+func TestListBooksWithoutGenre(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, book := range []models.BookRequest{
+		{Title: "Dune", Author: "Frank Herbert", PublishedDate: "1965-08-01", Edition: 1, Genre: "SciFi"},
+		{Title: "Emma", Author: "Jane Austen", PublishedDate: "1815-12-23", Edition: 1},
+		{Title: "The Hobbit", Author: "J.R.R. Tolkien", PublishedDate: "1937-09-21", Edition: 1, Genre: "Fantasy"},
+	} {
+		if _, err := store.CreateBook(ctx, &book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter  string
+		orderBy string
+		want    []string
+	}{
+		// A missing genre is NULL, so NOT of a comparison with it is not true.
+		{"NOT genre = 'Fantasy'", "", []string{"Dune"}},
+		{"genre != 'Fantasy'", "", []string{"Dune"}},
+		{"genre IS NULL", "", []string{"Emma"}},
+		{"NOT genre = 'Fantasy' OR genre IS NULL", "", []string{"Dune", "Emma"}},
+		// NULL text sorts like "", as COALESCE(genre, '') does in SQL.
+		{"", "genre", []string{"Emma", "The Hobbit", "Dune"}},
+		{"", "genre DESC", []string{"Dune", "The Hobbit", "Emma"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter+tt.orderBy, func(t *testing.T) {
+			var opts ListOptions
+			if tt.filter != "" {
+				expr, err := filter.Parse(tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts.Filter = expr
+			}
+			if tt.orderBy != "" {
+				terms, err := filter.ParseOrderBy(tt.orderBy, filter.BookSchema)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts.OrderBy, opts.Limit = terms, 1
+			}
+
+			var titles []string
+			for {
+				page, err := store.ListBooks(ctx, opts)
+				if err != nil {
+					t.Fatalf("ListBooks after %v: %v", titles, err)
+				}
+				for _, book := range page.Items {
+					titles = append(titles, book.Title)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("got %v, want %v", titles, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,700 @@
+package db
+
+import (
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/patch"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory implementation of BookStore, CollectionStore
+// and AuthorStore. It mirrors the filtering, ordering and pagination of the
+// PostgreSQL stores so the API can be run without a database.
+type MemoryStore struct {
+	mu                 sync.RWMutex
+	books              map[int]*models.Book
+	collections        map[int]*models.Collection
+	authors            map[int]*models.Author
+	memberships        map[int]map[int]membership // collection ID -> book ID
+	exclusions         map[int]map[int]bool       // smart collection ID -> book ID
+	trashedBooks       map[int]*models.Book
+	trashedCollections map[int]*models.Collection
+	revisions          map[revisionKey][]models.Revision
+	nextBookID         int
+	nextCollectionID   int
+	nextAuthorID       int
+}
+
+type membership struct {
+	addedAt  time.Time
+	position int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		books:              make(map[int]*models.Book),
+		collections:        make(map[int]*models.Collection),
+		authors:            make(map[int]*models.Author),
+		memberships:        make(map[int]map[int]membership),
+		exclusions:         make(map[int]map[int]bool),
+		trashedBooks:       make(map[int]*models.Book),
+		trashedCollections: make(map[int]*models.Collection),
+		revisions:          make(map[revisionKey][]models.Revision),
+		nextBookID:         1,
+		nextCollectionID:   1,
+		nextAuthorID:       1,
+	}
+}
+
+// memoryDate formats a date the way it comes back from a PostgreSQL DATE column.
+func memoryDate(date string) (string, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+func (m *MemoryStore) CreateBook(ctx context.Context, book *models.BookRequest) (*models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createBook(ctx, book)
+}
+
+// createBook adds book; the caller holds m.mu.
+func (m *MemoryStore) createBook(ctx context.Context, book *models.BookRequest) (*models.Book, error) {
+	publishedDate, err := memoryDate(book.PublishedDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid published date format: %w: %v", ErrValidation, err)
+	}
+	if err := m.checkISBN(book.ISBN(), 0); err != nil {
+		return nil, err
+	}
+	credits, err := resolveCredits(book, nil, m.memoryAuthorLookup())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	newBook := &models.Book{
+		ID:            m.nextBookID,
+		Title:         book.Title,
+		Author:        book.Author,
+		Authors:       credits,
+		PublishedDate: publishedDate,
+		Edition:       book.Edition,
+		Description:   book.Description,
+		Genre:         book.Genre,
+		ISBN13:        book.ISBN(),
+		ISBN10:        models.ISBN13To10(book.ISBN()),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Version:       1,
+	}
+	result := *newBook
+	if _, err := m.recordRevision(ctx, "book", models.RevisionCreate, newBook.ID, newBook.Version, nil, &result); err != nil {
+		return nil, err
+	}
+	m.nextBookID++
+	m.books[newBook.ID] = newBook
+	return &result, nil
+}
+
+func (m *MemoryStore) GetBook(ctx context.Context, id int) (*models.Book, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	book, ok := m.books[id]
+	if !ok {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	result := *book
+	return &result, nil
+}
+
+func (m *MemoryStore) GetBookByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, book := range m.books {
+		if book.ISBN13 == isbn {
+			result := *book
+			return &result, nil
+		}
+	}
+	return nil, fmt.Errorf("book with isbn %s %w", isbn, ErrNotFound)
+}
+
+// checkISBN works like the PostgreSQL checkISBN; the caller holds m.mu.
+func (m *MemoryStore) checkISBN(isbn string, id int) error {
+	if isbn == "" {
+		return nil
+	}
+	for _, books := range []map[int]*models.Book{m.books, m.trashedBooks} {
+		for _, book := range books {
+			if book.ISBN13 == isbn && book.ID != id {
+				return fmt.Errorf("isbn %s is already used by book %d: %w", isbn, book.ID, ErrConflict)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateBook(ctx context.Context, id int, book *models.BookRequest, version int) (*models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.books[id]
+	if !ok {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	if err := checkVersion("book", current.Version, version); err != nil {
+		return nil, err
+	}
+	return m.changeBook(ctx, models.RevisionUpdate, current, book)
+}
+
+// changeBook updates current and records the revision; the caller holds m.mu.
+func (m *MemoryStore) changeBook(ctx context.Context, action string, current *models.Book, book *models.BookRequest) (*models.Book, error) {
+	before := *current
+	changed, err := m.updateBook(current, book)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.recordRevision(ctx, "book", action, current.ID, changed.Version, &before, changed); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// updateBook overwrites current with book; the caller holds m.mu.
+func (m *MemoryStore) updateBook(current *models.Book, book *models.BookRequest) (*models.Book, error) {
+	publishedDate, err := memoryDate(book.PublishedDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid published date format: %w: %v", ErrValidation, err)
+	}
+	if err := m.checkISBN(book.ISBN(), current.ID); err != nil {
+		return nil, err
+	}
+	credits, err := resolveCredits(book, current.Authors, m.memoryAuthorLookup())
+	if err != nil {
+		return nil, err
+	}
+
+	current.Title = book.Title
+	current.Author = book.Author
+	current.Authors = credits
+	current.PublishedDate = publishedDate
+	current.Edition = book.Edition
+	current.Description = book.Description
+	current.Genre = book.Genre
+	current.ISBN13 = book.ISBN()
+	current.ISBN10 = models.ISBN13To10(current.ISBN13)
+	current.UpdatedAt = time.Now()
+	current.Version++
+
+	result := *current
+	return &result, nil
+}
+
+func (m *MemoryStore) PatchBook(ctx context.Context, id int, p patch.Patch, version int) (*models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.books[id]
+	if !ok {
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	if err := checkVersion("book", current.Version, version); err != nil {
+		return nil, err
+	}
+	merged, err := patchBook(current, p)
+	if err != nil {
+		return nil, err
+	}
+	return m.changeBook(ctx, models.RevisionPatch, current, merged)
+}
+
+func (m *MemoryStore) DeleteBook(ctx context.Context, id int, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	book, ok := m.books[id]
+	if !ok {
+		return fmt.Errorf("book %w", ErrNotFound)
+	}
+	if err := checkVersion("book", book.Version, version); err != nil {
+		return err
+	}
+	// Memberships are kept while the book is in the trash.
+	before := *book
+	now := time.Now()
+	book.DeletedAt = &now
+	book.Version++
+	delete(m.books, id)
+	m.trashedBooks[id] = book
+	_, err := m.recordRevision(ctx, "book", models.RevisionDelete, id, book.Version, &before, book)
+	return err
+}
+
+func (m *MemoryStore) allBooks() []models.Book {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	books := make([]models.Book, 0, len(m.books))
+	for _, book := range m.books {
+		books = append(books, *book)
+	}
+	return books
+}
+
+func (m *MemoryStore) ListBooks(ctx context.Context, opts ListOptions) (*Page[models.Book], error) {
+	return listInMemory(m.allBooks(), bookRecord, filter.BookSchema, "title", opts)
+}
+
+func (m *MemoryStore) GroupBooks(ctx context.Context, opts ListOptions) ([]Group[models.Book], error) {
+	return groupInMemory(m.allBooks(), bookRecord, filter.BookSchema, "title", opts)
+}
+
+func (m *MemoryStore) CreateCollection(ctx context.Context, collection *models.CollectionRequest) (*models.Collection, error) {
+	if err := validateCollection(collection, nil); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createCollection(ctx, collection)
+}
+
+// createCollection works like the function of the same name. The caller
+// holds m.mu for writing.
+func (m *MemoryStore) createCollection(ctx context.Context, collection *models.CollectionRequest) (*models.Collection, error) {
+	if err := m.checkParent(0, collection.ParentID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	newCollection := &models.Collection{
+		ID:          m.nextCollectionID,
+		Name:        collection.Name,
+		Description: collection.Description,
+		Kind:        collection.CollectionKind(),
+		Query:       copyQuery(collection.Query),
+		ParentID:    copyParent(collection.ParentID),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+	}
+	result := *newCollection
+	if _, err := m.recordRevision(ctx, "collection", models.RevisionCreate, newCollection.ID, newCollection.Version, nil, &result); err != nil {
+		return nil, err
+	}
+	m.nextCollectionID++
+	m.collections[newCollection.ID] = newCollection
+	return &result, nil
+}
+
+func (m *MemoryStore) GetCollection(ctx context.Context, id int) (*models.Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, ok := m.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	result := *collection
+	return &result, nil
+}
+
+func (m *MemoryStore) UpdateCollection(ctx context.Context, id int, collection *models.CollectionRequest, version int) (*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if err := checkVersion("collection", current.Version, version); err != nil {
+		return nil, err
+	}
+	return m.changeCollection(ctx, models.RevisionUpdate, current, collection)
+}
+
+// changeCollection updates current and records the revision; the caller
+// holds m.mu.
+func (m *MemoryStore) changeCollection(ctx context.Context, action string, current *models.Collection, collection *models.CollectionRequest) (*models.Collection, error) {
+	if err := validateCollection(collection, current); err != nil {
+		return nil, err
+	}
+	if err := m.checkParent(current.ID, collection.ParentID); err != nil {
+		return nil, err
+	}
+	before := *current
+	changed := m.updateCollection(current, collection)
+	if _, err := m.recordRevision(ctx, "collection", action, current.ID, changed.Version, &before, changed); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// updateCollection overwrites current with collection; the caller holds m.mu.
+func (m *MemoryStore) updateCollection(current *models.Collection, collection *models.CollectionRequest) *models.Collection {
+	current.Name = collection.Name
+	current.Description = collection.Description
+	current.Query = copyQuery(collection.Query)
+	current.ParentID = copyParent(collection.ParentID)
+	current.UpdatedAt = time.Now()
+	current.Version++
+
+	result := *current
+	return &result
+}
+
+// copyQuery keeps the stored query apart from the request it came from.
+func copyQuery(q *models.SmartQuery) *models.SmartQuery {
+	if q == nil {
+		return nil
+	}
+	c := *q
+	return &c
+}
+
+func copyParent(parentID *int) *int {
+	if parentID == nil {
+		return nil
+	}
+	id := *parentID
+	return &id
+}
+
+func (m *MemoryStore) PatchCollection(ctx context.Context, id int, p patch.Patch, version int) (*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if err := checkVersion("collection", current.Version, version); err != nil {
+		return nil, err
+	}
+	merged, err := patchCollection(current, p)
+	if err != nil {
+		return nil, err
+	}
+	return m.changeCollection(ctx, models.RevisionPatch, current, merged)
+}
+
+func (m *MemoryStore) DeleteCollection(ctx context.Context, id int, version int, cascade bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[id]
+	if !ok {
+		return fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if err := checkVersion("collection", collection.Version, version); err != nil {
+		return err
+	}
+	descendants, err := m.subtree(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, c := range descendants[1:] {
+		child := m.collections[c.ID]
+		if cascade {
+			if err := m.trashCollection(ctx, child, now); err != nil {
+				return err
+			}
+		} else if child.ParentID != nil && *child.ParentID == id {
+			moved := collectionRequest(child)
+			moved.ParentID = collection.ParentID
+			if _, err := m.changeCollection(ctx, models.RevisionMove, child, moved); err != nil {
+				return err
+			}
+		}
+	}
+	return m.trashCollection(ctx, collection, now)
+}
+
+// trashCollection moves a collection to the trash; the caller holds m.mu.
+func (m *MemoryStore) trashCollection(ctx context.Context, collection *models.Collection, now time.Time) error {
+	before := *collection
+	collection.DeletedAt = &now
+	collection.Version++
+	delete(m.collections, collection.ID)
+	m.trashedCollections[collection.ID] = collection
+	_, err := m.recordRevision(ctx, "collection", models.RevisionDelete, collection.ID, collection.Version, &before, collection)
+	return err
+}
+
+func (m *MemoryStore) allCollections() []models.Collection {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collections := make([]models.Collection, 0, len(m.collections))
+	for _, collection := range m.collections {
+		collections = append(collections, *collection)
+	}
+	return collections
+}
+
+func (m *MemoryStore) ListCollections(ctx context.Context, opts ListOptions) (*Page[models.Collection], error) {
+	return listInMemory(m.allCollections(), collectionRecord, filter.CollectionSchema, "name", opts)
+}
+
+func (m *MemoryStore) GroupCollections(ctx context.Context, opts ListOptions) ([]Group[models.Collection], error) {
+	return groupInMemory(m.allCollections(), collectionRecord, filter.CollectionSchema, "name", opts)
+}
+
+func (m *MemoryStore) AddBookToCollection(ctx context.Context, collectionID, bookID, position int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[collectionID]
+	if !ok {
+		return fmt.Errorf("collection %d %w", collectionID, ErrNotFound)
+	}
+	if _, ok := m.books[bookID]; !ok {
+		return fmt.Errorf("book %d %w", bookID, ErrNotFound)
+	}
+	if collection.Kind == models.CollectionSmart && position != 0 {
+		return errSmartPosition
+	}
+
+	added, err := m.addMember(collection, bookID, position)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return fmt.Errorf("book already exists in collection: %w", ErrConflict)
+	}
+	return nil
+}
+
+func (m *MemoryStore) RemoveBookFromCollection(ctx context.Context, collectionID, bookID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, live := m.collections[collectionID]
+	if !live || m.books[bookID] == nil {
+		return fmt.Errorf("book %w in collection", ErrNotFound)
+	}
+	removed, err := m.removeMember(collection, bookID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("book %w in collection", ErrNotFound)
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListBooksInCollection(ctx context.Context, collectionID int) ([]models.Book, error) {
+	m.mu.RLock()
+	collection, ok := m.collections[collectionID]
+	if !ok {
+		m.mu.RUnlock()
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if collection.Kind == models.CollectionSmart {
+		books, terms, err := m.smartBooks(collection)
+		m.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		listed := []models.Book{}
+		err = exportInMemory(books, ListOptions{OrderBy: terms}, func(book *models.Book) error {
+			book.Position = len(listed) + 1
+			listed = append(listed, *book)
+			return nil
+		})
+		return listed, err
+	}
+	defer m.mu.RUnlock()
+
+	books := []models.Book{}
+	for _, bookID := range m.memberOrder(collectionID).ids {
+		if book, ok := m.books[bookID]; ok {
+			books = append(books, *book)
+			books[len(books)-1].Position = len(books)
+		}
+	}
+	return books, nil
+}
+
+func (m *MemoryStore) ListBookCollections(ctx context.Context, bookID int, opts ListOptions) (*Page[models.BookCollection], error) {
+	m.mu.RLock()
+	if _, ok := m.books[bookID]; !ok {
+		m.mu.RUnlock()
+		return nil, fmt.Errorf("book %w", ErrNotFound)
+	}
+	collections, err := m.bookCollections(bookID)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return listInMemory(collections, bookCollectionRecord, filter.CollectionSchema, "name", opts)
+}
+
+// LoadCollections works like BookDB.LoadCollections.
+func (m *MemoryStore) LoadCollections(ctx context.Context, books ...*models.Book) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, book := range books {
+		collections, err := m.bookCollections(book.ID)
+		if err != nil {
+			return err
+		}
+		book.Collections = collections
+	}
+	return nil
+}
+
+// bookCollections returns the collections a book is in, by name, smart
+// collections included as in bookMemberships. The caller holds m.mu.
+func (m *MemoryStore) bookCollections(bookID int) ([]models.BookCollection, error) {
+	_, live := m.books[bookID]
+	collections := []models.BookCollection{}
+	for _, collection := range m.collections {
+		in := models.BookCollection{Collection: *collection}
+		if member, ok := m.memberships[collection.ID][bookID]; ok {
+			in.AddedAt = &member.addedAt
+		} else if collection.Kind != models.CollectionSmart || !live || m.exclusions[collection.ID][bookID] {
+			continue
+		} else if matches, err := m.matchesQuery(collection, bookID); err != nil {
+			return nil, err
+		} else if !matches {
+			continue
+		}
+		collections = append(collections, in)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		if collections[i].Name != collections[j].Name {
+			return collections[i].Name < collections[j].Name
+		}
+		return collections[i].ID < collections[j].ID
+	})
+	return collections, nil
+}
+
+// matchInMemory returns the items matching opts.Filter.
+func matchInMemory[T any](items []T, record func(*T) filter.Record, schema *filter.Schema, opts ListOptions) ([]T, error) {
+	var matched []T
+	for i := range items {
+		if opts.Filter != nil {
+			ok, err := schema.Match(opts.Filter, record(&items[i]))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, items[i])
+	}
+	return matched, nil
+}
+
+// listInMemory applies filtering, ordering and pagination the same way the
+// SQL stores do. Items are ordered by defaultOrder and then by id when no
+// order is given.
+func listInMemory[T any](items []T, record func(*T) filter.Record, schema *filter.Schema, defaultOrder string, opts ListOptions) (*Page[T], error) {
+	matched, err := matchInMemory(items, record, schema, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := sortTerms(opts, defaultOrder)
+	for _, term := range terms {
+		if _, ok := schema.Fields[term.Field.Name]; !ok {
+			return nil, fmt.Errorf("unknown %s field %q", schema.Name, term.Field.Name)
+		}
+	}
+	cur, err := decodeCursor(opts.Cursor, schema, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	// compare orders a record relative to the given sort values.
+	compare := func(rec filter.Record, values func(i int) interface{}) int {
+		rec = schema.SortRecord(rec)
+		for i, term := range terms {
+			c := filter.Compare(rec(term.Field.Name), values(i))
+			if c == 0 {
+				continue
+			}
+			if term.Desc {
+				return -c
+			}
+			return c
+		}
+		return 0
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		b := schema.SortRecord(record(&matched[j]))
+		return compare(record(&matched[i]), func(k int) interface{} { return b(terms[k].Field.Name) }) < 0
+	})
+
+	total := len(matched)
+	switch {
+	case cur != nil && cur.Before:
+		// Walk backwards from the cursor, like the reversed ORDER BY in SQL.
+		var before []T
+		for i := len(matched) - 1; i >= 0; i-- {
+			if compare(record(&matched[i]), func(k int) interface{} { return cur.Values[k] }) < 0 {
+				before = append(before, matched[i])
+			}
+		}
+		matched = before
+	case cur != nil:
+		var after []T
+		for i := range matched {
+			if compare(record(&matched[i]), func(k int) interface{} { return cur.Values[k] }) > 0 {
+				after = append(after, matched[i])
+			}
+		}
+		matched = after
+	case opts.Offset > 0:
+		if opts.Offset >= len(matched) {
+			matched = nil
+		} else {
+			matched = matched[opts.Offset:]
+		}
+	}
+	if limit := opts.pageSize() + 1; limit < len(matched) {
+		matched = matched[:limit]
+	}
+
+	page := newPage(matched, record, terms, cur, opts)
+	if opts.IncludeTotal {
+		page.Total = &total
+	}
+	return page, nil
+}
+
+// groupKey renders a value the way PostgreSQL casts it to text.
+func groupKey(v interface{}, t filter.FieldType) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case int:
+		return strconv.Itoa(value)
+	case time.Time:
+		if t == filter.DateField {
+			return value.Format("2006-01-02")
+		}
+		return value.Format(filter.PostgresTimeLayout)
+	case string:
+		return value
+	}
+	return fmt.Sprint(v)
+}
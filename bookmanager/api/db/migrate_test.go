@@ -0,0 +1,116 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// migrationFS returns a file system holding the given scripts under
+// migrations/, each containing its own file name.
+func migrationFS(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte("-- " + name + "\n")}
+	}
+	return fsys
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFS(
+		"0010_tree.up.sql", "0010_tree.down.sql",
+		"0002_search.down.sql", "0002_search.up.sql",
+		"0001_initial.up.sql", "0001_initial.down.sql",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		version int
+		name    string
+		prefix  string
+	}{{1, "initial", "0001"}, {2, "search", "0002"}, {10, "tree", "0010"}}
+	if len(migrations) != len(want) {
+		t.Fatalf("loaded %d migrations, want %d", len(migrations), len(want))
+	}
+	for i, m := range migrations {
+		w := want[i]
+		if m.Version != w.version || m.Name != w.name {
+			t.Errorf("migrations[%d] = %d %s, want %d %s", i, m.Version, m.Name, w.version, w.name)
+			continue
+		}
+		up := "-- " + w.prefix + "_" + w.name + ".up.sql\n"
+		down := "-- " + w.prefix + "_" + w.name + ".down.sql\n"
+		if m.Up != up || m.Down != down {
+			t.Errorf("migration %d scripts = %q, %q, want %q, %q", m.Version, m.Up, m.Down, up, down)
+		}
+		sum := sha256.Sum256([]byte(up))
+		if m.Checksum != hex.EncodeToString(sum[:]) {
+			t.Errorf("migration %d checksum = %s, want the sha256 of its up script", m.Version, m.Checksum)
+		}
+	}
+}
+
+func TestLoadMigrationsChecksum(t *testing.T) {
+	load := func(up, down string) string {
+		t.Helper()
+		migrations, err := loadMigrations(fstest.MapFS{
+			"migrations/0001_initial.up.sql":   {Data: []byte(up)},
+			"migrations/0001_initial.down.sql": {Data: []byte(down)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return migrations[0].Checksum
+	}
+	sum := load("CREATE TABLE t ();", "DROP TABLE t;")
+	if load("CREATE TABLE t ();", "DROP TABLE IF EXISTS t;") != sum {
+		t.Error("editing the down script changed the checksum")
+	}
+	if load("CREATE TABLE t (id int);", "DROP TABLE t;") == sum {
+		t.Error("editing the up script kept the checksum")
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"invalid file name", []string{"0001_initial.up.sql", "0001_initial.down.sql", "README.md"}, `invalid migration file name "README.md"`},
+		{"no version", []string{"initial.up.sql"}, "invalid migration file name"},
+		{"missing down", []string{"0001_initial.up.sql", "0002_search.up.sql", "0002_search.down.sql"}, "migration 1 (initial) needs both an up and a down script"},
+		{"missing up", []string{"0001_initial.down.sql"}, "migration 1 (initial) needs both an up and a down script"},
+		{"conflicting names", []string{"0001_initial.up.sql", "0001_initial.down.sql", "0001_search.up.sql", "0001_search.down.sql"}, `migration 1 has conflicting names "initial" and "search"`},
+		{"duplicate version", []string{"0001_initial.up.sql", "0001_initial.down.sql", "1_initial.up.sql"}, "migration 1 has more than one up script"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(migrationFS(tt.files...))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadMigrations error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadMigrations(fstest.MapFS{}); err == nil {
+		t.Error("loadMigrations succeeded without a migrations directory")
+	}
+}
+
+// TestEmbeddedMigrations checks the migrations shipped with the server.
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+ALTER TABLE books ADD COLUMN search_vector tsvector;
+
+-- Title matches rank highest, then author, genre and description.
+CREATE FUNCTION books_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', COALESCE(NEW.title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(NEW.author, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(NEW.genre, '')), 'C') ||
+        setweight(to_tsvector('english', COALESCE(NEW.description, '')), 'D');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_search_vector_trigger
+    BEFORE INSERT OR UPDATE OF title, author, genre, description ON books
+    FOR EACH ROW EXECUTE FUNCTION books_search_vector_update();
+
+-- Fill the column for existing rows; the trigger fires on the title update.
+UPDATE books SET title = title;
+
+CREATE INDEX idx_books_search_vector ON books USING GIN (search_vector);
@@ -0,0 +1,7 @@
+DELETE FROM collections WHERE deleted_at IS NOT NULL;
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_collections_deleted_at;
+DROP INDEX IF EXISTS idx_books_deleted_at;
+ALTER TABLE collections DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,15 @@
+-- Revisions outlive the books and collections they describe, so there is no
+-- foreign key to them.
+CREATE TABLE IF NOT EXISTS revisions (
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    actor VARCHAR(128) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    reverted_from INTEGER,
+    snapshot JSONB NOT NULL,
+    changes JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entity_type, entity_id, revision)
+);
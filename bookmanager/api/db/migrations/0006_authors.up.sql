@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS authors (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    sort_name VARCHAR(255) NOT NULL,
+    birth_date DATE,
+    death_date DATE,
+    bio TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- books.author stays as the names credited with the author role, joined
+-- with ' & ', so filters, search and stats on author keep working.
+CREATE TABLE IF NOT EXISTS book_authors (
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE RESTRICT,
+    role VARCHAR(20) NOT NULL DEFAULT 'author'
+        CHECK (role IN ('author', 'translator', 'illustrator', 'editor')),
+    position INTEGER NOT NULL,
+    PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_authors_author_id ON book_authors(author_id);
+
+-- Split the existing author strings: "A & B", "A and B" and "A; B" all
+-- credit two authors.
+INSERT INTO authors (name, sort_name)
+SELECT DISTINCT name, regexp_replace(name, '^(.*\S)\s+(\S+)$', '\2, \1')
+FROM (
+    SELECT btrim(regexp_split_to_table(author, '\s*(;|&|\sand\s)\s*')) AS name
+    FROM books
+) names
+WHERE name <> ''
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO book_authors (book_id, author_id, role, position)
+SELECT b.id, a.id, 'author', min(n.position)
+FROM books b
+CROSS JOIN LATERAL regexp_split_to_table(b.author, '\s*(;|&|\sand\s)\s*') WITH ORDINALITY AS n(name, position)
+JOIN authors a ON a.name = btrim(n.name)
+GROUP BY b.id, a.id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_books_isbn13;
+
+ALTER TABLE books DROP COLUMN IF EXISTS isbn13;
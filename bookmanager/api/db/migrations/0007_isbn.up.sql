@@ -0,0 +1,5 @@
+-- Only the ISBN-13 is stored; the ISBN-10 is derived from it. The index also
+-- covers books in the trash, so restoring a book never clashes.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn13 CHAR(13);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_books_isbn13 ON books(isbn13);
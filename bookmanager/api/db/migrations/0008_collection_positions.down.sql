@@ -0,0 +1,3 @@
+ALTER TABLE collection_books DROP CONSTRAINT IF EXISTS collection_books_position_key;
+
+ALTER TABLE collection_books DROP COLUMN IF EXISTS position;
@@ -0,0 +1,21 @@
+-- The books of a collection are numbered 1..n in the order they are listed.
+-- Existing collections keep the order they were listed in, by title.
+ALTER TABLE collection_books ADD COLUMN IF NOT EXISTS position INTEGER;
+
+UPDATE collection_books cb
+SET position = ordered.position
+FROM (
+    SELECT cb.collection_id, cb.book_id,
+           row_number() OVER (PARTITION BY cb.collection_id ORDER BY b.title, b.id) AS position
+    FROM collection_books cb
+    JOIN books b ON b.id = cb.book_id
+) ordered
+WHERE cb.collection_id = ordered.collection_id AND cb.book_id = ordered.book_id;
+
+ALTER TABLE collection_books ALTER COLUMN position SET NOT NULL;
+
+-- Renumbering moves books through positions other books still hold, so
+-- uniqueness is only checked at commit.
+ALTER TABLE collection_books
+    ADD CONSTRAINT collection_books_position_key UNIQUE (collection_id, position)
+    DEFERRABLE INITIALLY DEFERRED;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS collection_exclusions;
+
+ALTER TABLE collections DROP CONSTRAINT IF EXISTS collections_query_check;
+ALTER TABLE collections DROP COLUMN IF EXISTS query;
+ALTER TABLE collections DROP COLUMN IF EXISTS kind;
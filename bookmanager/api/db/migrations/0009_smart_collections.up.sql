@@ -0,0 +1,16 @@
+-- A smart collection holds the books matching its saved query, plus the
+-- books added to it by hand (rows of collection_books) and minus the books
+-- removed from it by hand (rows of collection_exclusions).
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS kind VARCHAR(10) NOT NULL DEFAULT 'manual'
+    CHECK (kind IN ('manual', 'smart'));
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS query JSONB;
+
+ALTER TABLE collections
+    ADD CONSTRAINT collections_query_check CHECK ((kind = 'smart') = (query IS NOT NULL));
+
+CREATE TABLE IF NOT EXISTS collection_exclusions (
+    collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, book_id)
+);
@@ -0,0 +1,7 @@
+-- Collections form a tree: parent_id is the collection a collection is a
+-- sub-collection of, NULL for a top-level one. The application keeps it free
+-- of cycles. A purged parent leaves its sub-collections at the top level.
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS parent_id INTEGER
+    REFERENCES collections(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_collections_parent_id ON collections(parent_id);
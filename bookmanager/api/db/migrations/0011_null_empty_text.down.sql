@@ -0,0 +1,3 @@
+UPDATE books
+SET description = COALESCE(description, ''), genre = COALESCE(genre, '')
+WHERE description IS NULL OR genre IS NULL;
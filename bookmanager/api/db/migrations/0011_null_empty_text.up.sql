@@ -0,0 +1,5 @@
+-- A book without a description or genre stores NULL, as it does for a
+-- missing ISBN, so filters treat them the same in SQL and in memory.
+UPDATE books
+SET description = NULLIF(description, ''), genre = NULLIF(genre, '')
+WHERE description = '' OR genre = '';
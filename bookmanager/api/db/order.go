@@ -0,0 +1,273 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+
+	"bookmanager/api/models"
+
+	"github.com/lib/pq"
+)
+
+// The books of a collection are numbered 1..n by collection_books.position.
+// Books in the trash keep their place, so a restored book is back where it
+// was, but the positions clients see and send count only the books that are
+// listed, as ListBooksInCollection numbers them.
+
+// errSmartOrder rejects changes to the order of a smart collection.
+var errSmartOrder = fmt.Errorf("smart collections are ordered by their query: %w", ErrConflict)
+
+// memberOrder is the books of a collection in order, including books in the
+// trash.
+type memberOrder struct {
+	ids  []int
+	live map[int]bool
+}
+
+// index returns the index in ids of the book listed at position, or
+// len(ids) when position is 0 or past the end.
+func (o *memberOrder) index(position int) int {
+	n := 0
+	for i, id := range o.ids {
+		if o.live[id] {
+			n++
+			if n == position {
+				return i
+			}
+		}
+	}
+	return len(o.ids)
+}
+
+func (o *memberOrder) insert(bookID, position int) {
+	o.ids = slices.Insert(o.ids, o.index(position), bookID)
+}
+
+func (o *memberOrder) remove(bookID int) {
+	if i := slices.Index(o.ids, bookID); i >= 0 {
+		o.ids = slices.Delete(o.ids, i, i+1)
+	}
+}
+
+// move applies a validated BookMove.
+func (o *memberOrder) move(bookID int, move models.BookMove) error {
+	if !o.live[bookID] {
+		return fmt.Errorf("book %w in collection", ErrNotFound)
+	}
+	field, other := "before", move.Before
+	if move.After != 0 {
+		field, other = "after", move.After
+	}
+	if other != 0 {
+		v := &models.ValidationError{}
+		if other == bookID {
+			v.Add(field, "must be another book")
+		} else if !o.live[other] {
+			v.Add(field, fmt.Sprintf("book %d is not in the collection", other))
+		}
+		if err := v.Err(); err != nil {
+			return err
+		}
+	}
+
+	o.remove(bookID)
+	switch {
+	case move.Before != 0:
+		i := slices.Index(o.ids, move.Before)
+		o.ids = slices.Insert(o.ids, i, bookID)
+	case move.After != 0:
+		i := slices.Index(o.ids, move.After)
+		o.ids = slices.Insert(o.ids, i+1, bookID)
+	default:
+		o.insert(bookID, move.Position)
+	}
+	return nil
+}
+
+// reorder puts the books in the given order into the places they hold
+// between them. The other books keep their places.
+func (o *memberOrder) reorder(bookIDs []int) error {
+	v := &models.ValidationError{}
+	listed := make(map[int]bool, len(bookIDs))
+	for _, id := range bookIDs {
+		if !o.live[id] {
+			v.Add("book_ids", fmt.Sprintf("book %d is not in the collection", id))
+		} else if listed[id] {
+			// Repeating a book would put it in two places and drop another.
+			v.Add("book_ids", fmt.Sprintf("lists book %d more than once", id))
+		}
+		listed[id] = true
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	next := 0
+	for i, id := range o.ids {
+		if listed[id] {
+			o.ids[i] = bookIDs[next]
+			next++
+		}
+	}
+	return nil
+}
+
+// loadMemberOrder locks the collection, which serializes the changes to its
+// order, and reads the order of its books. For a smart collection these are
+// the books added to it by hand.
+func loadMemberOrder(ctx context.Context, tx *sql.Tx, collectionID int) (*models.Collection, *memberOrder, error) {
+	collection, err := getCollection(ctx, tx, collectionID, "FOR UPDATE")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT cb.book_id, b.deleted_at IS NULL
+	FROM collection_books cb
+	JOIN books b ON b.id = cb.book_id
+	WHERE cb.collection_id = $1
+	ORDER BY cb.position`, collectionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read collection order: %v", err)
+	}
+	defer rows.Close()
+
+	o := &memberOrder{live: make(map[int]bool)}
+	for rows.Next() {
+		var id int
+		var live bool
+		if err := rows.Scan(&id, &live); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan collection order: %v", err)
+		}
+		o.ids = append(o.ids, id)
+		o.live[id] = live
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error after scanning collection order: %v", err)
+	}
+	return collection, o, nil
+}
+
+// saveMemberOrder numbers the books 1..n, writing the rows whose position
+// changed.
+func saveMemberOrder(ctx context.Context, tx *sql.Tx, collectionID int, o *memberOrder) error {
+	ids := make([]int64, len(o.ids))
+	positions := make([]int64, len(o.ids))
+	for i, id := range o.ids {
+		ids[i], positions[i] = int64(id), int64(i+1)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+	UPDATE collection_books cb
+	SET position = v.position
+	FROM unnest($2::int[], $3::int[]) AS v(book_id, position)
+	WHERE cb.collection_id = $1 AND cb.book_id = v.book_id AND cb.position <> v.position`,
+		collectionID, pq.Array(ids), pq.Array(positions))
+	if err != nil {
+		return dbError("failed to save collection order", err)
+	}
+	return nil
+}
+
+// MoveBookInCollection moves a book to another place in the collection.
+func (c *CollectionDB) MoveBookInCollection(ctx context.Context, collectionID, bookID int, move models.BookMove) error {
+	return inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		collection, o, err := loadMemberOrder(ctx, tx, collectionID)
+		if err != nil {
+			return err
+		}
+		if collection.Kind == models.CollectionSmart {
+			return errSmartOrder
+		}
+		if err := o.move(bookID, move); err != nil {
+			return err
+		}
+		return saveMemberOrder(ctx, tx, collectionID, o)
+	})
+}
+
+// ReorderCollection puts the books in the given order, see
+// models.CollectionOrderRequest. Either all of them move or, if one is not
+// in the collection, none.
+func (c *CollectionDB) ReorderCollection(ctx context.Context, collectionID int, bookIDs []int) error {
+	return inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		collection, o, err := loadMemberOrder(ctx, tx, collectionID)
+		if err != nil {
+			return err
+		}
+		if collection.Kind == models.CollectionSmart {
+			return errSmartOrder
+		}
+		if err := o.reorder(bookIDs); err != nil {
+			return err
+		}
+		return saveMemberOrder(ctx, tx, collectionID, o)
+	})
+}
+
+// memberOrder reads the order of the books of a collection. The caller
+// holds m.mu.
+func (m *MemoryStore) memberOrder(collectionID int) *memberOrder {
+	members := m.memberships[collectionID]
+	o := &memberOrder{live: make(map[int]bool, len(members))}
+	for id := range members {
+		o.ids = append(o.ids, id)
+		o.live[id] = m.books[id] != nil
+	}
+	sort.Slice(o.ids, func(i, j int) bool {
+		return members[o.ids[i]].position < members[o.ids[j]].position
+	})
+	return o
+}
+
+// saveMemberOrder numbers the books of a collection 1..n. The caller holds
+// m.mu for writing.
+func (m *MemoryStore) saveMemberOrder(collectionID int, o *memberOrder) {
+	members := m.memberships[collectionID]
+	for i, id := range o.ids {
+		member := members[id]
+		member.position = i + 1
+		members[id] = member
+	}
+}
+
+func (m *MemoryStore) MoveBookInCollection(ctx context.Context, collectionID, bookID int, move models.BookMove) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[collectionID]
+	if !ok {
+		return fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if collection.Kind == models.CollectionSmart {
+		return errSmartOrder
+	}
+	o := m.memberOrder(collectionID)
+	if err := o.move(bookID, move); err != nil {
+		return err
+	}
+	m.saveMemberOrder(collectionID, o)
+	return nil
+}
+
+func (m *MemoryStore) ReorderCollection(ctx context.Context, collectionID int, bookIDs []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[collectionID]
+	if !ok {
+		return fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if collection.Kind == models.CollectionSmart {
+		return errSmartOrder
+	}
+	o := m.memberOrder(collectionID)
+	if err := o.reorder(bookIDs); err != nil {
+		return err
+	}
+	m.saveMemberOrder(collectionID, o)
+	return nil
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"bookmanager/api/models"
+)
+
+// orderedCollection returns a store with a manual collection holding
+// books 1..n in that order.
+func orderedCollection(t *testing.T, n int) (*MemoryStore, int) {
+	t.Helper()
+	ctx := context.Background()
+	store := NewMemoryStore()
+	collection, err := store.CreateCollection(ctx, &models.CollectionRequest{Name: "Reading List"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		book, err := store.CreateBook(ctx, &models.BookRequest{Title: fmt.Sprintf("Book %d", i), Author: "Frank Herbert", PublishedDate: "1965-08-01", Edition: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.AddBookToCollection(ctx, collection.ID, book.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, collection.ID
+}
+
+// bookOrder returns the IDs of the books listed in a collection.
+func bookOrder(t *testing.T, store *MemoryStore, collectionID int) []int {
+	t.Helper()
+	books, err := store.ListBooksInCollection(context.Background(), collectionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, len(books))
+	for i, book := range books {
+		if book.Position != i+1 {
+			t.Errorf("book %d has position %d, want %d", book.ID, book.Position, i+1)
+		}
+		ids[i] = book.ID
+	}
+	return ids
+}
+
+func TestMoveBookInCollection(t *testing.T) {
+	tests := []struct {
+		name string
+		book int
+		move models.BookMove
+		want []int
+	}{
+		{"to the front", 3, models.BookMove{Position: 1}, []int{3, 1, 2, 4}},
+		{"to the middle", 1, models.BookMove{Position: 3}, []int{2, 3, 1, 4}},
+		{"to the end", 1, models.BookMove{Position: 4}, []int{2, 3, 4, 1}},
+		{"past the end", 2, models.BookMove{Position: 10}, []int{1, 3, 4, 2}},
+		{"to its own position", 2, models.BookMove{Position: 2}, []int{1, 2, 3, 4}},
+		{"before", 4, models.BookMove{Before: 2}, []int{1, 4, 2, 3}},
+		{"before the first", 3, models.BookMove{Before: 1}, []int{3, 1, 2, 4}},
+		{"after", 1, models.BookMove{After: 3}, []int{2, 3, 1, 4}},
+		{"after the last", 2, models.BookMove{After: 4}, []int{1, 3, 4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, collectionID := orderedCollection(t, 4)
+			if err := store.MoveBookInCollection(context.Background(), collectionID, tt.book, tt.move); err != nil {
+				t.Fatal(err)
+			}
+			if got := bookOrder(t, store, collectionID); !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveBookInCollectionErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		book  int
+		move  models.BookMove
+		field string // "" for ErrNotFound
+	}{
+		{"book not in collection", 9, models.BookMove{Position: 1}, ""},
+		{"before itself", 2, models.BookMove{Before: 2}, "before"},
+		{"after itself", 2, models.BookMove{After: 2}, "after"},
+		{"before a book not in collection", 2, models.BookMove{Before: 9}, "before"},
+		{"after a book not in collection", 2, models.BookMove{After: 9}, "after"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, collectionID := orderedCollection(t, 3)
+			err := store.MoveBookInCollection(context.Background(), collectionID, tt.book, tt.move)
+			var v *models.ValidationError
+			switch {
+			case tt.field == "" && !errors.Is(err, ErrNotFound):
+				t.Errorf("error = %v, want ErrNotFound", err)
+			case tt.field != "" && (!errors.As(err, &v) || v.Errors[0].Field != tt.field):
+				t.Errorf("error = %v, want a validation error on %s", err, tt.field)
+			}
+			if got := bookOrder(t, store, collectionID); !slices.Equal(got, []int{1, 2, 3}) {
+				t.Errorf("failed move changed the order to %v", got)
+			}
+		})
+	}
+}
+
+func TestReorderCollection(t *testing.T) {
+	tests := []struct {
+		name    string
+		bookIDs []int
+		want    []int
+	}{
+		{"all books", []int{4, 2, 5, 3, 1}, []int{4, 2, 5, 3, 1}},
+		{"reversed", []int{5, 4, 3, 2, 1}, []int{5, 4, 3, 2, 1}},
+		{"some books keep the others in place", []int{4, 2}, []int{1, 4, 3, 2, 5}},
+		{"one book", []int{3}, []int{1, 2, 3, 4, 5}},
+		{"unchanged", []int{1, 2, 3, 4, 5}, []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, collectionID := orderedCollection(t, 5)
+			if err := store.ReorderCollection(context.Background(), collectionID, tt.bookIDs); err != nil {
+				t.Fatal(err)
+			}
+			if got := bookOrder(t, store, collectionID); !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderCollectionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		bookIDs []int
+		want    []string
+	}{
+		{"book not in collection", []int{2, 9, 1}, []string{"book 9 is not in the collection"}},
+		{"duplicate", []int{1, 1, 3}, []string{"lists book 1 more than once"}},
+		{"every problem", []int{3, 8, 3, 9}, []string{"book 8 is not in the collection", "lists book 3 more than once", "book 9 is not in the collection"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, collectionID := orderedCollection(t, 3)
+			err := store.ReorderCollection(context.Background(), collectionID, tt.bookIDs)
+			var v *models.ValidationError
+			if !errors.As(err, &v) {
+				t.Fatalf("error = %v, want a validation error", err)
+			}
+			var got []string
+			for _, e := range v.Errors {
+				got = append(got, e.Message)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+			if got := bookOrder(t, store, collectionID); !slices.Equal(got, []int{1, 2, 3}) {
+				t.Errorf("failed reorder changed the order to %v", got)
+			}
+		})
+	}
+}
+
+// TestOrderWithTrashedBooks checks that positions count listed books only
+// and that a restored book is back in its place.
+func TestOrderWithTrashedBooks(t *testing.T) {
+	ctx := context.Background()
+	store, collectionID := orderedCollection(t, 4)
+	if err := store.DeleteBook(ctx, 2, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	if got := bookOrder(t, store, collectionID); !slices.Equal(got, []int{1, 3, 4}) {
+		t.Fatalf("order = %v, want [1 3 4]", got)
+	}
+
+	if err := store.MoveBookInCollection(ctx, collectionID, 4, models.BookMove{Position: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var v *models.ValidationError
+	if err := store.MoveBookInCollection(ctx, collectionID, 1, models.BookMove{Before: 2}); !errors.As(err, &v) {
+		t.Errorf("moving before a trashed book: error = %v, want a validation error", err)
+	}
+	if err := store.ReorderCollection(ctx, collectionID, []int{2, 1}); !errors.As(err, &v) {
+		t.Errorf("reordering a trashed book: error = %v, want a validation error", err)
+	}
+
+	if _, err := store.RestoreBook(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := bookOrder(t, store, collectionID); !slices.Equal(got, []int{1, 2, 4, 3}) {
+		t.Errorf("order after restore = %v, want [1 2 4 3]", got)
+	}
+}
+
+func TestReorderSmartCollection(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	collection, err := store.CreateCollection(ctx, &models.CollectionRequest{Name: "Science Fiction", Query: &models.SmartQuery{Genre: "SciFi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ReorderCollection(ctx, collection.ID, []int{1}); !errors.Is(err, ErrConflict) {
+		t.Errorf("ReorderCollection error = %v, want ErrConflict", err)
+	}
+	if err := store.MoveBookInCollection(ctx, collection.ID, 1, models.BookMove{Position: 1}); !errors.Is(err, ErrConflict) {
+		t.Errorf("MoveBookInCollection error = %v, want ErrConflict", err)
+	}
+}
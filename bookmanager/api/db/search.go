@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/search"
+)
+
+const (
+	// headlineOptions highlight every hit of the short fields.
+	headlineOptions = "StartSel=" + search.HighlightStart + ", StopSel=" + search.HighlightStop + ", HighlightAll=true"
+	// snippetOptions cut descriptions down to the fragments around the hits.
+	snippetOptions = "StartSel=" + search.HighlightStart + ", StopSel=" + search.HighlightStop +
+		", MaxFragments=2, MinWords=5, MaxWords=20, FragmentDelimiter=\" ... \""
+	snippetWords = 20
+)
+
+// SearchBooks returns the books matching the query, best match first, and
+// the total number of matches. opts.Filter further restricts the books;
+// opts.Limit and opts.Offset select the page.
+func (b *BookDB) SearchBooks(ctx context.Context, q *search.Query, opts ListOptions) ([]models.BookSearchResult, int, error) {
+	sb := filter.NewSQLBuilder(filter.BookSchema, "b")
+	tsquery := sb.Arg(q.TSQuery())
+
+	whereClause := "b.search_vector @@ query AND b.deleted_at IS NULL"
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		whereClause += " AND " + cond
+	}
+
+	// Headlines are expensive, so they are computed for the page only.
+	query := fmt.Sprintf(`
+        SELECT id, title, author, published_date, edition, description, genre,
+               isbn13, created_at, updated_at, version, rank, total,
+               ts_headline('english', %[7]s, query, %[1]s),
+               ts_headline('english', %[8]s, query, %[1]s),
+               ts_headline('english', %[9]s, query, %[2]s)
+        FROM (
+            SELECT b.*, query, ts_rank_cd(b.search_vector, query) AS rank, COUNT(*) OVER () AS total
+            FROM books b, to_tsquery('english', %[3]s) query
+            WHERE %[4]s
+            ORDER BY rank DESC, b.id
+            LIMIT %[5]s OFFSET %[6]s
+        ) matches
+        ORDER BY rank DESC, id`,
+		sb.Arg(headlineOptions), sb.Arg(snippetOptions), tsquery, whereClause,
+		sb.Arg(opts.pageSize()), sb.Arg(opts.Offset),
+		htmlEscape("title"), htmlEscape("author"), htmlEscape("COALESCE(description, '')"))
+
+	rows, err := b.DB.QueryContext(ctx, query, sb.Args()...)
+	if err != nil {
+		return nil, 0, dbError("failed to search books", err)
+	}
+	defer rows.Close()
+
+	results := []models.BookSearchResult{}
+	total := 0
+	for rows.Next() {
+		var r models.BookSearchResult
+		var title, author, description string
+		err := rows.Scan(append(bookFields(&r.Book),
+			&r.Rank,
+			&total,
+			&title,
+			&author,
+			&description,
+		)...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan search result: %v", err)
+		}
+		r.Highlights = highlights(title, author, description)
+		results = append(results, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error after scanning search results: %v", err)
+	}
+	books := make([]*models.Book, len(results))
+	for i := range results {
+		books[i] = &results[i].Book
+	}
+	if err := loadBookAuthors(ctx, b.DB, books...); err != nil {
+		return nil, 0, err
+	}
+
+	// COUNT(*) OVER () is only available when the page has rows.
+	if len(results) == 0 && opts.Offset > 0 {
+		total, err = b.countSearch(ctx, q, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return results, total, nil
+}
+
+func (b *BookDB) countSearch(ctx context.Context, q *search.Query, opts ListOptions) (int, error) {
+	sb := filter.NewSQLBuilder(filter.BookSchema, "b")
+	query := "SELECT COUNT(*) FROM books b WHERE b.deleted_at IS NULL AND b.search_vector @@ to_tsquery('english', " + sb.Arg(q.TSQuery()) + ")"
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return 0, err
+		}
+		query += " AND " + cond
+	}
+
+	var total int
+	if err := b.DB.QueryRowContext(ctx, query, sb.Args()...).Scan(&total); err != nil {
+		return 0, dbError("failed to count search results", err)
+	}
+	return total, nil
+}
+
+// htmlEscape returns a SQL expression escaping the text expr for HTML like
+// html.EscapeString, so the markers ts_headline adds are the only markup
+// in highlights.
+func htmlEscape(expr string) string {
+	return "replace(replace(replace(replace(replace(" + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '''', '&#39;'), '"', '&#34;')`
+}
+
+// highlights keeps the fields that contain a hit.
+func highlights(title, author, description string) map[string]string {
+	h := make(map[string]string)
+	for field, text := range map[string]string{"title": title, "author": author, "description": description} {
+		if strings.Contains(text, search.HighlightStart) {
+			h[field] = text
+		}
+	}
+	return h
+}
+
+// SearchBooks ranks the books like BookDB.SearchBooks, but matches words
+// exactly instead of by their English stem.
+func (m *MemoryStore) SearchBooks(ctx context.Context, q *search.Query, opts ListOptions) ([]models.BookSearchResult, int, error) {
+	books, err := matchInMemory(m.allBooks(), bookRecord, filter.BookSchema, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []models.BookSearchResult
+	for _, book := range books {
+		rank := q.Rank(
+			search.Field{Text: book.Title, Weight: 1.0},
+			search.Field{Text: book.Author, Weight: 0.4},
+			search.Field{Text: book.Genre, Weight: 0.2},
+			search.Field{Text: book.Description, Weight: 0.1},
+		)
+		if rank == 0 {
+			continue
+		}
+		results = append(results, models.BookSearchResult{
+			Book: book,
+			Rank: rank,
+			Highlights: highlights(
+				q.Highlight(book.Title),
+				q.Highlight(book.Author),
+				search.Snippet(q.Highlight(book.Description), snippetWords),
+			),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	total := len(results)
+	if opts.Offset >= len(results) {
+		results = nil
+	} else {
+		results = results[opts.Offset:]
+	}
+	if limit := opts.pageSize(); limit < len(results) {
+		results = results[:limit]
+	}
+	if results == nil {
+		results = []models.BookSearchResult{}
+	}
+	return results, total, nil
+}
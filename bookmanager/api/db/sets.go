@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+// A set expression over collections, see filter.ParseSet, selects books the
+// way collectionCond does for each of its collections, smart collections
+// included: UNION, INTERSECT and EXCEPT become OR, AND and AND NOT of their
+// conditions.
+
+// setCollections reads the collections of a set expression. Collections
+// that do not exist are reported on expr.
+func setCollections(ctx context.Context, q querier, set filter.SetExpr) (map[int]*models.Collection, error) {
+	collections := make(map[int]*models.Collection)
+	v := &models.ValidationError{}
+	for _, id := range filter.SetIDs(set) {
+		collection, err := getCollection(ctx, q, id, "")
+		if errors.Is(err, ErrNotFound) {
+			v.Add("expr", fmt.Sprintf("collection %d does not exist", id))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		collections[id] = collection
+	}
+	return collections, v.Err()
+}
+
+// setCond returns the condition selecting the books of a set expression
+// from books.
+func setCond(sb *filter.SQLBuilder, set filter.SetExpr, collections map[int]*models.Collection) (string, error) {
+	switch n := set.(type) {
+	case *filter.SetRef:
+		cond, err := collectionCond(sb, collections[n.ID])
+		if err != nil {
+			return "", err
+		}
+		// The query of a smart collection is NULL for books with NULL
+		// fields, which must not turn EXCEPT into a match.
+		return "COALESCE((" + cond + "), FALSE)", nil
+	case *filter.SetOp:
+		left, err := setCond(sb, n.Left, collections)
+		if err != nil {
+			return "", err
+		}
+		right, err := setCond(sb, n.Right, collections)
+		if err != nil {
+			return "", err
+		}
+		switch n.Op {
+		case "UNION":
+			return "(" + left + " OR " + right + ")", nil
+		case "INTERSECT":
+			return "(" + left + " AND " + right + ")", nil
+		default:
+			return "(" + left + " AND NOT " + right + ")", nil
+		}
+	}
+	return "", fmt.Errorf("unsupported set expression %T", set)
+}
+
+// ListCollectionSetBooks returns one page of the books of a set expression
+// over collections, see BookDB.ListBooks.
+func (c *CollectionDB) ListCollectionSetBooks(ctx context.Context, set filter.SetExpr, opts ListOptions) (*Page[models.Book], error) {
+	collections, err := setCollections(ctx, c.DB, set)
+	if err != nil {
+		return nil, err
+	}
+	terms := sortTerms(opts, "title")
+	cur, err := decodeCursor(opts.Cursor, filter.BookSchema, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := filter.NewSQLBuilder(filter.BookSchema, "")
+	cond, err := setCond(sb, set, collections)
+	if err != nil {
+		return nil, err
+	}
+	conds := []string{"deleted_at IS NULL", cond}
+	if opts.Filter != nil {
+		cond, err := sb.Where(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+
+	var total *int
+	if opts.IncludeTotal {
+		var n int
+		query := "SELECT COUNT(*) FROM books WHERE " + strings.Join(conds, " AND ")
+		if err := c.DB.QueryRowContext(ctx, query, sb.Args()...).Scan(&n); err != nil {
+			return nil, fmt.Errorf("failed to count books: %v", err)
+		}
+		total = &n
+	}
+
+	if cur != nil {
+		cond, err := sb.Keyset(terms, cur.Values, cur.Before)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	orderBy, err := sb.OrderBy(terms, cur != nil && cur.Before)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        SELECT ` + bookColumns + `
+        FROM books
+        WHERE ` + strings.Join(conds, " AND ") + `
+        ORDER BY ` + orderBy + `
+        LIMIT ` + sb.Arg(opts.pageSize()+1)
+	if cur == nil && opts.Offset > 0 {
+		query += " OFFSET " + sb.Arg(opts.Offset)
+	}
+
+	books, err := queryBooks(ctx, c.DB, query, sb.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	page := newPage(books, bookRecord, terms, cur, opts)
+	page.Total = total
+	return page, nil
+}
+
+// CreateCollectionFromSet creates a manual collection holding the books of
+// a set expression in the order of orderBy, by title without.
+func (c *CollectionDB) CreateCollectionFromSet(ctx context.Context, set filter.SetExpr, orderBy []filter.OrderTerm, collection *models.CollectionRequest) (*models.Collection, error) {
+	if err := validateCollection(collection, nil); err != nil {
+		return nil, err
+	}
+
+	var newCollection *models.Collection
+	err := inTx(ctx, c.DB, func(tx *sql.Tx) error {
+		collections, err := setCollections(ctx, tx, set)
+		if err != nil {
+			return err
+		}
+		if newCollection, err = createCollection(ctx, tx, collection); err != nil {
+			return err
+		}
+
+		sb := filter.NewSQLBuilder(filter.BookSchema, "")
+		cond, err := setCond(sb, set, collections)
+		if err != nil {
+			return err
+		}
+		order, err := sb.OrderBy(sortTerms(ListOptions{OrderBy: orderBy}, "title"), false)
+		if err != nil {
+			return err
+		}
+		query := `
+		INSERT INTO collection_books (collection_id, book_id, position)
+		SELECT ` + sb.Arg(newCollection.ID) + `, id, row_number() OVER (ORDER BY ` + order + `)
+		FROM books
+		WHERE deleted_at IS NULL AND ` + cond
+		if _, err := tx.ExecContext(ctx, query, sb.Args()...); err != nil {
+			return dbError("failed to add books to collection", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newCollection, nil
+}
+
+// collectionBooks returns the live books of a collection, unordered. The
+// caller holds m.mu.
+func (m *MemoryStore) collectionBooks(collection *models.Collection) ([]models.Book, error) {
+	if collection.Kind == models.CollectionSmart {
+		books, _, err := m.smartBooks(collection)
+		return books, err
+	}
+	var books []models.Book
+	for id := range m.memberships[collection.ID] {
+		if book, ok := m.books[id]; ok {
+			books = append(books, *book)
+		}
+	}
+	return books, nil
+}
+
+// setBooks returns the live books of a set expression, unordered. The
+// caller holds m.mu.
+func (m *MemoryStore) setBooks(set filter.SetExpr) ([]models.Book, error) {
+	v := &models.ValidationError{}
+	sets := make(map[int]map[int]bool)
+	for _, id := range filter.SetIDs(set) {
+		collection, ok := m.collections[id]
+		if !ok {
+			v.Add("expr", fmt.Sprintf("collection %d does not exist", id))
+			continue
+		}
+		books, err := m.collectionBooks(collection)
+		if err != nil {
+			return nil, err
+		}
+		sets[id] = make(map[int]bool, len(books))
+		for _, book := range books {
+			sets[id][book.ID] = true
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	var eval func(filter.SetExpr) map[int]bool
+	eval = func(e filter.SetExpr) map[int]bool {
+		if ref, ok := e.(*filter.SetRef); ok {
+			return sets[ref.ID]
+		}
+		op := e.(*filter.SetOp)
+		left, right := eval(op.Left), eval(op.Right)
+		result := make(map[int]bool)
+		for id := range left {
+			if op.Op == "UNION" || right[id] == (op.Op == "INTERSECT") {
+				result[id] = true
+			}
+		}
+		if op.Op == "UNION" {
+			for id := range right {
+				result[id] = true
+			}
+		}
+		return result
+	}
+
+	var books []models.Book
+	for id := range eval(set) {
+		books = append(books, *m.books[id])
+	}
+	return books, nil
+}
+
+// ListCollectionSetBooks works like CollectionDB.ListCollectionSetBooks.
+func (m *MemoryStore) ListCollectionSetBooks(ctx context.Context, set filter.SetExpr, opts ListOptions) (*Page[models.Book], error) {
+	m.mu.RLock()
+	books, err := m.setBooks(set)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return listInMemory(books, bookRecord, filter.BookSchema, "title", opts)
+}
+
+// CreateCollectionFromSet works like CollectionDB.CreateCollectionFromSet.
+func (m *MemoryStore) CreateCollectionFromSet(ctx context.Context, set filter.SetExpr, orderBy []filter.OrderTerm, collection *models.CollectionRequest) (*models.Collection, error) {
+	if err := validateCollection(collection, nil); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	books, err := m.setBooks(set)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	err = exportInMemory(books, ListOptions{OrderBy: orderBy}, func(book *models.Book) error {
+		ids = append(ids, book.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newCollection, err := m.createCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[int]membership, len(ids))
+	now := newCollection.CreatedAt
+	for i, id := range ids {
+		members[id] = membership{addedAt: now, position: i + 1}
+	}
+	m.memberships[newCollection.ID] = members
+	return newCollection, nil
+}
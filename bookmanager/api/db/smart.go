@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+// BookFilter turns the book specific convenience filters (author, genre,
+// isbn, published_after, published_before) into filter expressions.
+func BookFilter(query url.Values) (filter.Expr, error) {
+	var exprs []filter.Expr
+	v := &models.ValidationError{}
+
+	if author := query.Get("author"); author != "" {
+		exprs = append(exprs, &filter.Like{
+			Field:           filter.Field("author"),
+			Pattern:         filter.String("%" + escapeLike(author) + "%"),
+			CaseInsensitive: true,
+		})
+	}
+	if genre := query.Get("genre"); genre != "" {
+		exprs = append(exprs, &filter.Comparison{Field: filter.Field("genre"), Op: "=", Value: filter.String(genre)})
+	}
+	if raw := query.Get("isbn"); raw != "" {
+		if isbn, err := models.ParseISBN(raw); err != nil {
+			v.Add("isbn", err.Error())
+		} else {
+			exprs = append(exprs, &filter.Comparison{Field: filter.Field("isbn13"), Op: "=", Value: filter.String(isbn)})
+		}
+	}
+	for _, p := range []struct{ param, op string }{
+		{"published_after", ">="},
+		{"published_before", "<="},
+	} {
+		value := query.Get(p.param)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			v.Add(p.param, "must be a date in YYYY-MM-DD format")
+			continue
+		}
+		exprs = append(exprs, &filter.Comparison{Field: filter.Field("published_date"), Op: p.op, Value: filter.String(value)})
+	}
+
+	return filter.And(exprs...), v.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike makes user input match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// smartQuery compiles the saved query of a smart collection. Problems are
+// reported as a *models.ValidationError on the fields of query, e.g.
+// query.where.
+func smartQuery(q *models.SmartQuery) (filter.Expr, []filter.OrderTerm, error) {
+	v := &models.ValidationError{}
+	var where filter.Expr
+	if q.Where != "" {
+		expr, err := filter.Parse(q.Where)
+		if err == nil {
+			err = filter.BookSchema.Validate(expr)
+		}
+		if err != nil {
+			v.Add("query.where", err.Error())
+		}
+		where = expr
+	}
+	var terms []filter.OrderTerm
+	if q.OrderBy != "" {
+		var err error
+		if terms, err = filter.ParseOrderBy(q.OrderBy, filter.BookSchema); err != nil {
+			v.Add("query.order_by", err.Error())
+		}
+	}
+	extra, err := BookFilter(q.Values())
+	var fieldErr *models.ValidationError
+	if errors.As(err, &fieldErr) {
+		for _, e := range fieldErr.Errors {
+			v.Add("query."+e.Field, e.Message)
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, nil, err
+	}
+	return filter.And(where, extra), terms, nil
+}
+
+// errSmartPosition rejects a position when adding a book to a smart
+// collection.
+var errSmartPosition = &models.ValidationError{Errors: []models.FieldError{
+	{Field: "position", Message: "cannot be set for a smart collection, which is ordered by its query"},
+}}
+
+// validateCollection checks the query of a smart collection, which
+// CollectionRequest.Validate cannot, and that an existing collection keeps
+// its kind.
+func validateCollection(collection *models.CollectionRequest, current *models.Collection) error {
+	v := &models.ValidationError{}
+	if current != nil && collection.CollectionKind() != current.Kind {
+		if current.Kind == models.CollectionSmart && collection.Kind == "" {
+			v.Add("query", "is required for a smart collection")
+		} else {
+			v.Add("kind", "cannot be changed")
+		}
+		return v
+	}
+	if collection.Query == nil {
+		return nil
+	}
+	_, _, err := smartQuery(collection.Query)
+	return err
+}
+
+// collectionCond returns the condition selecting the books of collection c
+// from books.
+func collectionCond(sb *filter.SQLBuilder, c *models.Collection) (string, error) {
+	added := "id IN (SELECT book_id FROM collection_books WHERE collection_id = " + sb.Arg(c.ID) + ")"
+	if c.Kind != models.CollectionSmart {
+		return added, nil
+	}
+	f, _, err := smartQuery(c.Query)
+	if err != nil {
+		return "", err
+	}
+	matching := "TRUE"
+	if f != nil {
+		if matching, err = sb.Where(f); err != nil {
+			return "", err
+		}
+	}
+	return "((" + matching + ") OR " + added + ") AND id NOT IN (SELECT book_id FROM collection_exclusions WHERE collection_id = " + sb.Arg(c.ID) + ")", nil
+}
+
+// matchesQuery reports whether the live book bookID matches the query of
+// the smart collection c.
+func matchesQuery(ctx context.Context, q querier, c *models.Collection, bookID int) (bool, error) {
+	f, _, err := smartQuery(c.Query)
+	if err != nil {
+		return false, err
+	}
+	sb := filter.NewSQLBuilder(filter.BookSchema, "")
+	cond := "TRUE"
+	if f != nil {
+		if cond, err = sb.Where(f); err != nil {
+			return false, err
+		}
+	}
+
+	var matches bool
+	query := "SELECT EXISTS (SELECT 1 FROM books WHERE id = " + sb.Arg(bookID) + " AND deleted_at IS NULL AND " + cond + ")"
+	if err := q.QueryRowContext(ctx, query, sb.Args()...).Scan(&matches); err != nil {
+		return false, fmt.Errorf("failed to match book: %v", err)
+	}
+	return matches, nil
+}
+
+// deleteExclusion takes back the removal of a book from a smart collection
+// and reports whether it had been removed.
+func deleteExclusion(ctx context.Context, tx *sql.Tx, collectionID, bookID int) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM collection_exclusions WHERE collection_id = $1 AND book_id = $2`, collectionID, bookID)
+	if err != nil {
+		return false, dbError("failed to add book to collection", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, dbError("failed to add book to collection", err)
+	}
+	return n > 0, nil
+}
+
+// smartBooks returns the books of the smart collection c, unordered, and
+// the order of its query. The caller holds m.mu.
+func (m *MemoryStore) smartBooks(c *models.Collection) ([]models.Book, []filter.OrderTerm, error) {
+	f, terms, err := smartQuery(c.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	added, excluded := m.memberships[c.ID], m.exclusions[c.ID]
+	var books []models.Book
+	for id, book := range m.books {
+		if excluded[id] {
+			continue
+		}
+		if _, ok := added[id]; !ok && f != nil {
+			matches, err := filter.BookSchema.Match(f, bookRecord(book))
+			if err != nil {
+				return nil, nil, err
+			}
+			if !matches {
+				continue
+			}
+		}
+		books = append(books, *book)
+	}
+	return books, terms, nil
+}
+
+// matchesQuery works like the function of the same name. The caller holds
+// m.mu.
+func (m *MemoryStore) matchesQuery(c *models.Collection, bookID int) (bool, error) {
+	f, _, err := smartQuery(c.Query)
+	if err != nil || f == nil {
+		return err == nil, err
+	}
+	return filter.BookSchema.Match(f, bookRecord(m.books[bookID]))
+}
@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+)
+
+// StatsStore answers aggregate queries over books and their collections.
+type StatsStore interface {
+	BookStats(ctx context.Context, f filter.Expr) (*models.BookStats, error)
+	// CountBooks counts the books matching f per combination of keys,
+	// ordered by the keys with NULL last.
+	CountBooks(ctx context.Context, f filter.Expr, keys []filter.GroupKey) ([]KeyCount, error)
+}
+
+// KeyCount is the number of rows sharing the key values, rendered as text
+// (nil for NULL) like Group.Key.
+type KeyCount struct {
+	Key   []*string
+	Count int
+}
+
+var (
+	_ StatsStore = (*BookDB)(nil)
+	_ StatsStore = (*MemoryStore)(nil)
+)
+
+func (b *BookDB) BookStats(ctx context.Context, f filter.Expr) (*models.BookStats, error) {
+	sb := filter.NewSQLBuilder(filter.BookSchema, "b")
+	whereClause := " WHERE b.deleted_at IS NULL"
+	if f != nil {
+		cond, err := sb.Where(f)
+		if err != nil {
+			return nil, err
+		}
+		whereClause += " AND " + cond
+	}
+
+	query := `
+        SELECT COUNT(*), COUNT(DISTINCT b.author), COUNT(DISTINCT NULLIF(b.genre, '')),
+               MIN(b.edition), MAX(b.edition), AVG(b.edition)::float8,
+               MIN(b.published_date), MAX(b.published_date),
+               MIN(b.created_at), MAX(b.created_at),
+               COUNT(m.book_id), COALESCE(AVG(COALESCE(m.collections, 0))::float8, 0)
+        FROM books b
+        LEFT JOIN (
+            SELECT cb.book_id, COUNT(*) AS collections
+            FROM collection_books cb
+            JOIN collections c ON c.id = cb.collection_id AND c.deleted_at IS NULL
+            GROUP BY cb.book_id
+        ) m ON m.book_id = b.id` + whereClause
+
+	var stats models.BookStats
+	var minEdition, maxEdition sql.NullInt64
+	var avgEdition sql.NullFloat64
+	var minPublished, maxPublished, minCreated, maxCreated sql.NullTime
+	err := b.DB.QueryRowContext(ctx, query, sb.Args()...).Scan(
+		&stats.Count,
+		&stats.Authors,
+		&stats.Genres,
+		&minEdition,
+		&maxEdition,
+		&avgEdition,
+		&minPublished,
+		&maxPublished,
+		&minCreated,
+		&maxCreated,
+		&stats.InCollections,
+		&stats.AvgCollections,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute book stats: %v", err)
+	}
+
+	if stats.Count > 0 {
+		stats.Edition = &models.NumberStats{Min: int(minEdition.Int64), Max: int(maxEdition.Int64), Avg: avgEdition.Float64}
+		stats.PublishedDate = &models.Range[string]{
+			Min: minPublished.Time.Format("2006-01-02"),
+			Max: maxPublished.Time.Format("2006-01-02"),
+		}
+		stats.CreatedAt = &models.Range[time.Time]{Min: minCreated.Time, Max: maxCreated.Time}
+	}
+	return &stats, nil
+}
+
+func (b *BookDB) CountBooks(ctx context.Context, f filter.Expr, keys []filter.GroupKey) ([]KeyCount, error) {
+	sb := filter.NewSQLBuilder(filter.BookSchema, "")
+	whereClause := " WHERE deleted_at IS NULL"
+	if f != nil {
+		cond, err := sb.Where(f)
+		if err != nil {
+			return nil, err
+		}
+		whereClause += " AND " + cond
+	}
+
+	exprs := make([]string, len(keys))
+	cols := make([]string, len(keys))
+	texts := make([]string, len(keys))
+	for i, key := range keys {
+		expr, err := sb.GroupExpr(key)
+		if err != nil {
+			return nil, err
+		}
+		cols[i] = fmt.Sprintf("k%d", i)
+		exprs[i] = expr + " AS " + cols[i]
+		texts[i] = cols[i] + "::text"
+	}
+	query := fmt.Sprintf(`
+        SELECT %s, COUNT(*)
+        FROM (SELECT %s FROM books%s) keyed
+        GROUP BY %s
+        ORDER BY %s`,
+		strings.Join(texts, ", "), strings.Join(exprs, ", "), whereClause,
+		strings.Join(cols, ", "), strings.Join(cols, ", "))
+
+	rows, err := b.DB.QueryContext(ctx, query, sb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count books: %v", err)
+	}
+	defer rows.Close()
+
+	counts := []KeyCount{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(keys))
+		var kc KeyCount
+		dest := make([]interface{}, 0, len(keys)+1)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &kc.Count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan book count: %v", err)
+		}
+		for _, v := range values {
+			if v.Valid {
+				text := v.String
+				kc.Key = append(kc.Key, &text)
+			} else {
+				kc.Key = append(kc.Key, nil)
+			}
+		}
+		counts = append(counts, kc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning book counts: %v", err)
+	}
+	return counts, nil
+}
+
+func (m *MemoryStore) BookStats(ctx context.Context, f filter.Expr) (*models.BookStats, error) {
+	books, err := matchInMemory(m.allBooks(), bookRecord, filter.BookSchema, ListOptions{Filter: f})
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	memberships := make(map[int]int)
+	for collectionID, members := range m.memberships {
+		if _, ok := m.collections[collectionID]; !ok {
+			continue
+		}
+		for bookID := range members {
+			memberships[bookID]++
+		}
+	}
+	m.mu.RUnlock()
+
+	stats := &models.BookStats{Count: len(books)}
+	if len(books) == 0 {
+		return stats, nil
+	}
+
+	authors := make(map[string]bool)
+	genres := make(map[string]bool)
+	editions := 0
+	collections := 0
+	stats.Edition = &models.NumberStats{Min: books[0].Edition, Max: books[0].Edition}
+	stats.PublishedDate = &models.Range[string]{Min: books[0].PublishedDate, Max: books[0].PublishedDate}
+	stats.CreatedAt = &models.Range[time.Time]{Min: books[0].CreatedAt, Max: books[0].CreatedAt}
+	for _, book := range books {
+		authors[book.Author] = true
+		if book.Genre != "" {
+			genres[book.Genre] = true
+		}
+		editions += book.Edition
+		stats.Edition.Min = min(stats.Edition.Min, book.Edition)
+		stats.Edition.Max = max(stats.Edition.Max, book.Edition)
+		stats.PublishedDate.Min = min(stats.PublishedDate.Min, book.PublishedDate)
+		stats.PublishedDate.Max = max(stats.PublishedDate.Max, book.PublishedDate)
+		if book.CreatedAt.Before(stats.CreatedAt.Min) {
+			stats.CreatedAt.Min = book.CreatedAt
+		}
+		if book.CreatedAt.After(stats.CreatedAt.Max) {
+			stats.CreatedAt.Max = book.CreatedAt
+		}
+		if n := memberships[book.ID]; n > 0 {
+			stats.InCollections++
+			collections += n
+		}
+	}
+	stats.Authors = len(authors)
+	stats.Genres = len(genres)
+	stats.Edition.Avg = float64(editions) / float64(len(books))
+	stats.AvgCollections = float64(collections) / float64(len(books))
+	// Dates are kept in RFC 3339 form in memory.
+	stats.PublishedDate.Min = stats.PublishedDate.Min[:len("2006-01-02")]
+	stats.PublishedDate.Max = stats.PublishedDate.Max[:len("2006-01-02")]
+	return stats, nil
+}
+
+func (m *MemoryStore) CountBooks(ctx context.Context, f filter.Expr, keys []filter.GroupKey) ([]KeyCount, error) {
+	books, err := matchInMemory(m.allBooks(), bookRecord, filter.BookSchema, ListOptions{Filter: f})
+	if err != nil {
+		return nil, err
+	}
+
+	type bucket struct {
+		values []interface{}
+		count  int
+	}
+	var buckets []*bucket
+	for i := range books {
+		rec := bookRecord(&books[i])
+		values := make([]interface{}, len(keys))
+		for j, key := range keys {
+			values[j] = key.GroupValue(rec)
+		}
+		var found *bucket
+		for _, b := range buckets {
+			if sameValues(b.values, values) {
+				found = b
+				break
+			}
+		}
+		if found == nil {
+			found = &bucket{values: values}
+			buckets = append(buckets, found)
+		}
+		found.count++
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		for k := range keys {
+			if c := filter.Compare(buckets[i].values[k], buckets[j].values[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	counts := []KeyCount{}
+	for _, b := range buckets {
+		kc := KeyCount{Count: b.count}
+		for j, v := range b.values {
+			if v == nil {
+				kc.Key = append(kc.Key, nil)
+				continue
+			}
+			text := groupKey(v, filter.BookSchema.Fields[keys[j].Field.Name].Type)
+			kc.Key = append(kc.Key, &text)
+		}
+		counts = append(counts, kc)
+	}
+	return counts, nil
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/patch"
+	"bookmanager/api/search"
+	"context"
+)
+
+// BookStore is implemented by BookDB (PostgreSQL) and MemoryStore. Update,
+// Patch, Delete and Revert fail with ErrPreconditionFailed unless version is
+// AnyVersion or the current version of the book. Every write records a
+// revision in the book's history.
+type BookStore interface {
+	CreateBook(ctx context.Context, book *models.BookRequest) (*models.Book, error)
+	GetBook(ctx context.Context, id int) (*models.Book, error)
+	GetBookByISBN(ctx context.Context, isbn string) (*models.Book, error)
+	UpdateBook(ctx context.Context, id int, book *models.BookRequest, version int) (*models.Book, error)
+	PatchBook(ctx context.Context, id int, p patch.Patch, version int) (*models.Book, error)
+	DeleteBook(ctx context.Context, id int, version int) error
+	ListBooks(ctx context.Context, opts ListOptions) (*Page[models.Book], error)
+	GroupBooks(ctx context.Context, opts ListOptions) ([]Group[models.Book], error)
+	SearchBooks(ctx context.Context, q *search.Query, opts ListOptions) ([]models.BookSearchResult, int, error)
+	BookHistory(ctx context.Context, id int) ([]models.Revision, error)
+	BookRevision(ctx context.Context, id, revision int) (*models.Revision, error)
+	RevertBook(ctx context.Context, id, revision, version int) (*models.Book, error)
+	ImportBooks(ctx context.Context, rows []ImportRow, opts ImportOptions) (*models.ImportReport, error)
+	ExportBooks(ctx context.Context, opts ListOptions, fn func(*models.Book) error) error
+	LoadCollections(ctx context.Context, books ...*models.Book) error
+}
+
+// CollectionStore is implemented by CollectionDB (PostgreSQL) and
+// MemoryStore. The version arguments work as in BookStore.
+type CollectionStore interface {
+	CreateCollection(ctx context.Context, collection *models.CollectionRequest) (*models.Collection, error)
+	GetCollection(ctx context.Context, id int) (*models.Collection, error)
+	UpdateCollection(ctx context.Context, id int, collection *models.CollectionRequest, version int) (*models.Collection, error)
+	PatchCollection(ctx context.Context, id int, p patch.Patch, version int) (*models.Collection, error)
+	DeleteCollection(ctx context.Context, id int, version int, cascade bool) error
+	ListCollections(ctx context.Context, opts ListOptions) (*Page[models.Collection], error)
+	GroupCollections(ctx context.Context, opts ListOptions) ([]Group[models.Collection], error)
+	AddBookToCollection(ctx context.Context, collectionID, bookID, position int) error
+	RemoveBookFromCollection(ctx context.Context, collectionID, bookID int) error
+	AddBooksToCollection(ctx context.Context, collectionID int, books models.BookSelection) (*models.MembershipReport, error)
+	RemoveBooksFromCollection(ctx context.Context, collectionID int, books models.BookSelection) (*models.MembershipReport, error)
+	TransferBooks(ctx context.Context, collectionID int, transfer models.BookTransfer) (*models.MembershipReport, error)
+	MoveBookInCollection(ctx context.Context, collectionID, bookID int, move models.BookMove) error
+	ReorderCollection(ctx context.Context, collectionID int, bookIDs []int) error
+	ListBooksInCollection(ctx context.Context, collectionID int) ([]models.Book, error)
+	ListBooksInCollectionTree(ctx context.Context, collectionID int) ([]models.Book, error)
+	CollectionTree(ctx context.Context, id int) ([]models.CollectionNode, error)
+	CollectionBreadcrumb(ctx context.Context, id int) ([]models.Collection, error)
+	MoveCollection(ctx context.Context, id int, parentID *int, version int) (*models.Collection, error)
+	ListBookCollections(ctx context.Context, bookID int, opts ListOptions) (*Page[models.BookCollection], error)
+	ListCollectionSetBooks(ctx context.Context, set filter.SetExpr, opts ListOptions) (*Page[models.Book], error)
+	CreateCollectionFromSet(ctx context.Context, set filter.SetExpr, orderBy []filter.OrderTerm, collection *models.CollectionRequest) (*models.Collection, error)
+	ExportCollectionBooks(ctx context.Context, collectionID int, opts ListOptions, fn func(*models.Book) error) error
+	CollectionHistory(ctx context.Context, id int) ([]models.Revision, error)
+	CollectionRevision(ctx context.Context, id, revision int) (*models.Revision, error)
+	RevertCollection(ctx context.Context, id, revision, version int) (*models.Collection, error)
+}
+
+// AuthorStore is implemented by AuthorDB (PostgreSQL) and MemoryStore. The
+// version arguments work as in BookStore. Authors are not kept in the trash
+// and have no history; an author still credited on a book cannot be
+// deleted.
+type AuthorStore interface {
+	CreateAuthor(ctx context.Context, author *models.AuthorRequest) (*models.Author, error)
+	GetAuthor(ctx context.Context, id int) (*models.Author, error)
+	UpdateAuthor(ctx context.Context, id int, author *models.AuthorRequest, version int) (*models.Author, error)
+	PatchAuthor(ctx context.Context, id int, p patch.Patch, version int) (*models.Author, error)
+	DeleteAuthor(ctx context.Context, id int, version int) error
+	ListAuthors(ctx context.Context, opts ListOptions) (*Page[models.Author], error)
+	GroupAuthors(ctx context.Context, opts ListOptions) ([]Group[models.Author], error)
+	ListBooksByAuthor(ctx context.Context, authorID int) ([]models.Book, error)
+}
+
+var (
+	_ BookStore       = (*BookDB)(nil)
+	_ CollectionStore = (*CollectionDB)(nil)
+	_ AuthorStore     = (*AuthorDB)(nil)
+	_ BookStore       = (*MemoryStore)(nil)
+	_ CollectionStore = (*MemoryStore)(nil)
+	_ AuthorStore     = (*MemoryStore)(nil)
+)
@@ -0,0 +1,391 @@
+package db
+
+import (
+	"bookmanager/api/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TrashStore is implemented by TrashDB (PostgreSQL) and MemoryStore.
+// DeleteBook and DeleteCollection move rows to the trash, where they keep
+// their collection memberships until they are restored or purged.
+type TrashStore interface {
+	ListTrash(ctx context.Context) (*models.Trash, error)
+	RestoreBook(ctx context.Context, id int) (*models.Book, error)
+	RestoreCollection(ctx context.Context, id int) (*models.Collection, error)
+	PurgeTrash(ctx context.Context, deletedBefore time.Time) (*models.PurgeResult, error)
+}
+
+var (
+	_ TrashStore = (*TrashDB)(nil)
+	_ TrashStore = (*MemoryStore)(nil)
+)
+
+type TrashDB struct {
+	DB *sql.DB
+}
+
+func NewTrash(db *sql.DB) *TrashDB {
+	return &TrashDB{DB: db}
+}
+
+func (t *TrashDB) ListTrash(ctx context.Context) (*models.Trash, error) {
+	trash := &models.Trash{Books: []models.Book{}, Collections: []models.Collection{}}
+
+	rows, err := t.DB.QueryContext(ctx, `
+	SELECT `+bookColumns+`, deleted_at
+	FROM books
+	WHERE deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted books: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book models.Book
+		err := rows.Scan(append(bookFields(&book), &book.DeletedAt)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan book: %v", err)
+		}
+		trash.Books = append(trash.Books, book)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning books: %v", err)
+	}
+	if err := loadBookAuthors(ctx, t.DB, bookPointers(trash.Books)...); err != nil {
+		return nil, err
+	}
+
+	rows, err = t.DB.QueryContext(ctx, `
+	SELECT `+collectionColumns+`, deleted_at
+	FROM collections
+	WHERE deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted collections: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var collection models.Collection
+		err := rows.Scan(append(collectionFields(&collection), &collection.DeletedAt)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %v", err)
+		}
+		trash.Collections = append(trash.Collections, collection)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning collections: %v", err)
+	}
+
+	return trash, nil
+}
+
+// RestoreBook takes the book out of the trash, together with its memberships
+// of collections that are not in the trash themselves.
+func (t *TrashDB) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	var book models.Book
+	err := inTx(ctx, t.DB, func(tx *sql.Tx) error {
+		var deletedAt time.Time
+		err := tx.QueryRowContext(ctx, `SELECT deleted_at FROM books WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`, id).Scan(&deletedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("book %w in trash", ErrNotFound)
+			}
+			return fmt.Errorf("failed to get book: %v", err)
+		}
+
+		query := `
+		UPDATE books
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1
+		RETURNING ` + bookColumns
+		err = tx.QueryRowContext(ctx, query, id).Scan(bookFields(&book)...)
+		if err != nil {
+			return dbError("failed to restore book", err)
+		}
+		if err := loadBookAuthors(ctx, tx, &book); err != nil {
+			return err
+		}
+
+		deleted := book
+		deleted.DeletedAt = &deletedAt
+		return recordRevision(ctx, tx, "book", models.RevisionRestore, id, book.Version, &deleted, &book)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// RestoreCollection takes a collection out of the trash together with the
+// sub-collections that went to the trash with it. A collection whose parent
+// is still in the trash is ErrConflict.
+func (t *TrashDB) RestoreCollection(ctx context.Context, id int) (*models.Collection, error) {
+	var collection *models.Collection
+	err := inTx(ctx, t.DB, func(tx *sql.Tx) error {
+		if err := lockTree(ctx, tx); err != nil {
+			return err
+		}
+		var deletedAt time.Time
+		var parentID *int
+		err := tx.QueryRowContext(ctx, `SELECT deleted_at, parent_id FROM collections WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`, id).Scan(&deletedAt, &parentID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("collection %w in trash", ErrNotFound)
+			}
+			return fmt.Errorf("failed to get collection: %v", err)
+		}
+		if parentID != nil {
+			if _, err := getCollection(ctx, tx, *parentID, ""); errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("restore its parent collection %d first: %w", *parentID, ErrConflict)
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if collection, err = restoreCollection(ctx, tx, id, deletedAt); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id FROM collections WHERE parent_id = $1 AND deleted_at = $2
+			UNION
+			SELECT c.id FROM collections c JOIN tree t ON c.parent_id = t.id WHERE c.deleted_at = $2
+		)
+		SELECT id FROM tree ORDER BY id`, id, deletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to list sub-collections: %v", err)
+		}
+		var descendants []int
+		for rows.Next() {
+			var descendant int
+			if err := rows.Scan(&descendant); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan sub-collection: %v", err)
+			}
+			descendants = append(descendants, descendant)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error after scanning sub-collections: %v", err)
+		}
+		for _, descendant := range descendants {
+			if _, err := restoreCollection(ctx, tx, descendant, deletedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// PurgeTrash permanently deletes what was moved to the trash before
+// deletedBefore. Memberships go with them through ON DELETE CASCADE, and
+// the collections the purged books were in are numbered again.
+func (t *TrashDB) PurgeTrash(ctx context.Context, deletedBefore time.Time) (*models.PurgeResult, error) {
+	var result models.PurgeResult
+	err := inTx(ctx, t.DB, func(tx *sql.Tx) error {
+		// The collections are locked like for any change to their order.
+		_, err := tx.ExecContext(ctx, `
+		SELECT id FROM collections
+		WHERE id IN (
+			SELECT cb.collection_id
+			FROM collection_books cb
+			JOIN books b ON b.id = cb.book_id
+			WHERE b.deleted_at < $1
+		)
+		ORDER BY id
+		FOR UPDATE`, deletedBefore)
+		if err != nil {
+			return fmt.Errorf("failed to lock collections: %v", err)
+		}
+
+		books, err := tx.ExecContext(ctx, `DELETE FROM books WHERE deleted_at < $1`, deletedBefore)
+		if err != nil {
+			return dbError("failed to purge books", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+		UPDATE collection_books cb
+		SET position = ordered.position
+		FROM (
+			SELECT collection_id, book_id,
+			       row_number() OVER (PARTITION BY collection_id ORDER BY position) AS position
+			FROM collection_books
+		) ordered
+		WHERE cb.collection_id = ordered.collection_id AND cb.book_id = ordered.book_id
+		  AND cb.position <> ordered.position`)
+		if err != nil {
+			return dbError("failed to renumber collections", err)
+		}
+		collections, err := tx.ExecContext(ctx, `DELETE FROM collections WHERE deleted_at < $1`, deletedBefore)
+		if err != nil {
+			return dbError("failed to purge collections", err)
+		}
+
+		n, err := books.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check rows affected: %v", err)
+		}
+		result.Books = int(n)
+		n, err = collections.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check rows affected: %v", err)
+		}
+		result.Collections = int(n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (m *MemoryStore) ListTrash(ctx context.Context) (*models.Trash, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	trash := &models.Trash{Books: []models.Book{}, Collections: []models.Collection{}}
+	for _, book := range m.trashedBooks {
+		trash.Books = append(trash.Books, *book)
+	}
+	for _, collection := range m.trashedCollections {
+		trash.Collections = append(trash.Collections, *collection)
+	}
+
+	sort.Slice(trash.Books, func(i, j int) bool {
+		return deletedFirst(trash.Books[i].DeletedAt, trash.Books[j].DeletedAt, trash.Books[i].ID, trash.Books[j].ID)
+	})
+	sort.Slice(trash.Collections, func(i, j int) bool {
+		a, b := trash.Collections[i], trash.Collections[j]
+		return deletedFirst(a.DeletedAt, b.DeletedAt, a.ID, b.ID)
+	})
+	return trash, nil
+}
+
+// deletedFirst orders by deletion time, newest first, then by ID.
+func deletedFirst(a, b *time.Time, idA, idB int) bool {
+	if !a.Equal(*b) {
+		return a.After(*b)
+	}
+	return idA < idB
+}
+
+func (m *MemoryStore) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	book, ok := m.trashedBooks[id]
+	if !ok {
+		return nil, fmt.Errorf("book %w in trash", ErrNotFound)
+	}
+	before := *book
+	book.DeletedAt = nil
+	book.UpdatedAt = time.Now()
+	book.Version++
+
+	result := *book
+	if _, err := m.recordRevision(ctx, "book", models.RevisionRestore, id, book.Version, &before, &result); err != nil {
+		return nil, err
+	}
+	delete(m.trashedBooks, id)
+	m.books[id] = book
+	return &result, nil
+}
+
+func (m *MemoryStore) RestoreCollection(ctx context.Context, id int) (*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.trashedCollections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %w in trash", ErrNotFound)
+	}
+	if p := collection.ParentID; p != nil && m.collections[*p] == nil {
+		return nil, fmt.Errorf("restore its parent collection %d first: %w", *p, ErrConflict)
+	}
+	deletedAt := *collection.DeletedAt
+	result, err := m.restoreCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	// The sub-collections that went to the trash with it come back with it,
+	// parents first.
+	for restored := true; restored; {
+		restored = false
+		for _, c := range m.trashedCollections {
+			if c.ParentID != nil && m.collections[*c.ParentID] != nil && c.DeletedAt.Equal(deletedAt) {
+				if _, err := m.restoreCollection(ctx, c); err != nil {
+					return nil, err
+				}
+				restored = true
+			}
+		}
+	}
+	return result, nil
+}
+
+// restoreCollection takes a collection out of the trash; the caller holds
+// m.mu.
+func (m *MemoryStore) restoreCollection(ctx context.Context, collection *models.Collection) (*models.Collection, error) {
+	before := *collection
+	collection.DeletedAt = nil
+	collection.UpdatedAt = time.Now()
+	collection.Version++
+
+	result := *collection
+	if _, err := m.recordRevision(ctx, "collection", models.RevisionRestore, collection.ID, collection.Version, &before, &result); err != nil {
+		return nil, err
+	}
+	delete(m.trashedCollections, collection.ID)
+	m.collections[collection.ID] = collection
+	return &result, nil
+}
+
+func (m *MemoryStore) PurgeTrash(ctx context.Context, deletedBefore time.Time) (*models.PurgeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result models.PurgeResult
+	for id, book := range m.trashedBooks {
+		if book.DeletedAt.Before(deletedBefore) {
+			delete(m.trashedBooks, id)
+			for collectionID, members := range m.memberships {
+				if _, ok := members[id]; ok {
+					o := m.memberOrder(collectionID)
+					delete(members, id)
+					o.remove(id)
+					m.saveMemberOrder(collectionID, o)
+				}
+			}
+			for _, excluded := range m.exclusions {
+				delete(excluded, id)
+			}
+			result.Books++
+		}
+	}
+	for id, collection := range m.trashedCollections {
+		if collection.DeletedAt.Before(deletedBefore) {
+			delete(m.trashedCollections, id)
+			delete(m.memberships, id)
+			delete(m.exclusions, id)
+			for _, others := range []map[int]*models.Collection{m.collections, m.trashedCollections} {
+				for _, other := range others {
+					if other.ParentID != nil && *other.ParentID == id {
+						other.ParentID = nil
+					}
+				}
+			}
+			result.Collections++
+		}
+	}
+	return &result, nil
+}
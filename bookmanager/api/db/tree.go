@@ -0,0 +1,418 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"bookmanager/api/models"
+)
+
+// Collections form a tree through parent_id. Changes that set a parent or
+// move sub-collections take an advisory lock for the rest of their
+// transaction, so two of them cannot make a cycle between them or hang a
+// collection under one that is being deleted. Writers that also lock
+// collection rows take the tree lock first, so they cannot deadlock with
+// each other. Live collections only ever have live parents: deleting a
+// collection moves its sub-collections up or takes them to the trash with
+// it.
+
+// collectionTreeLockKey is the pg_advisory_xact_lock key serializing
+// changes to the collection tree.
+const collectionTreeLockKey int64 = 0x636f6c74726565 // "coltree"
+
+func lockTree(ctx context.Context, q querier) error {
+	if _, err := q.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, collectionTreeLockKey); err != nil {
+		return fmt.Errorf("failed to lock collection tree: %v", err)
+	}
+	return nil
+}
+
+// checkParent checks that parentID, when set, can be the parent of
+// collection id, 0 for a new collection: a collection that is neither id
+// nor one of its sub-collections.
+func checkParent(ctx context.Context, q querier, id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	if err := lockTree(ctx, q); err != nil {
+		return err
+	}
+
+	var exists, cycle bool
+	err := q.QueryRowContext(ctx, `
+	WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM collections WHERE id = $1 AND deleted_at IS NULL
+		UNION
+		SELECT c.id, c.parent_id FROM collections c JOIN ancestors a ON c.id = a.parent_id
+	)
+	SELECT EXISTS (SELECT 1 FROM ancestors), EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`,
+		*parentID, id).Scan(&exists, &cycle)
+	if err != nil {
+		return fmt.Errorf("failed to check parent collection: %v", err)
+	}
+	return parentError(*parentID, exists, cycle)
+}
+
+func parentError(parentID int, exists, cycle bool) error {
+	v := &models.ValidationError{}
+	switch {
+	case !exists:
+		v.Add("parent_id", fmt.Sprintf("collection %d does not exist", parentID))
+	case cycle:
+		v.Add("parent_id", "cannot be the collection itself or one of its sub-collections")
+	}
+	return v.Err()
+}
+
+// queryCollections runs a query selecting collectionColumns.
+func queryCollections(ctx context.Context, q querier, query string, args ...interface{}) ([]models.Collection, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %v", err)
+	}
+	defer rows.Close()
+
+	var collections []models.Collection
+	for rows.Next() {
+		var collection models.Collection
+		if err := rows.Scan(collectionFields(&collection)...); err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %v", err)
+		}
+		collections = append(collections, collection)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning collections: %v", err)
+	}
+	return collections, nil
+}
+
+// subtree returns collection id and its sub-collections, all the way down,
+// in the order of flattenTree.
+func subtree(ctx context.Context, q querier, id int, lock string) ([]models.Collection, error) {
+	collections, err := queryCollections(ctx, q, `
+	WITH RECURSIVE tree AS (
+		SELECT id FROM collections WHERE id = $1 AND deleted_at IS NULL
+		UNION
+		SELECT c.id FROM collections c JOIN tree t ON c.parent_id = t.id WHERE c.deleted_at IS NULL
+	)
+	SELECT `+collectionColumns+`
+	FROM collections
+	WHERE id IN (SELECT id FROM tree)
+	`+lock, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(collections) == 0 {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	return flattenTree(buildTree(collections)), nil
+}
+
+// buildTree arranges collections into trees. The collections whose parent
+// is not among them are the roots; siblings are ordered by name.
+func buildTree(collections []models.Collection) []models.CollectionNode {
+	sort.Slice(collections, func(i, j int) bool {
+		if collections[i].Name != collections[j].Name {
+			return collections[i].Name < collections[j].Name
+		}
+		return collections[i].ID < collections[j].ID
+	})
+	present := make(map[int]bool, len(collections))
+	for _, c := range collections {
+		present[c.ID] = true
+	}
+	children := make(map[int][]models.Collection)
+	var roots []models.Collection
+	for _, c := range collections {
+		if c.ParentID != nil && present[*c.ParentID] {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var build func([]models.Collection) []models.CollectionNode
+	build = func(collections []models.Collection) []models.CollectionNode {
+		nodes := make([]models.CollectionNode, 0, len(collections))
+		for _, c := range collections {
+			nodes = append(nodes, models.CollectionNode{Collection: c, Children: build(children[c.ID])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+// flattenTree lists the collections of trees depth first, each before its
+// sub-collections.
+func flattenTree(nodes []models.CollectionNode) []models.Collection {
+	var collections []models.Collection
+	for _, node := range nodes {
+		collections = append(collections, node.Collection)
+		collections = append(collections, flattenTree(node.Children)...)
+	}
+	return collections
+}
+
+// mergeBooks lists the books of collections, in the order of the
+// collections and then of their books. A book in several of them is listed
+// where it comes first. Positions number the merged list.
+func mergeBooks(collections []models.Collection, list func(collectionID int) ([]models.Book, error)) ([]models.Book, error) {
+	books := []models.Book{}
+	seen := make(map[int]bool)
+	for _, c := range collections {
+		// A sub-collection deleted since collections were read is skipped.
+		listed, err := list(c.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		for _, book := range listed {
+			if !seen[book.ID] {
+				seen[book.ID] = true
+				book.Position = len(books) + 1
+				books = append(books, book)
+			}
+		}
+	}
+	return books, nil
+}
+
+// breadcrumb orders the ancestors of collection id from the top-level
+// collection down to it.
+func breadcrumb(id int, ancestors []models.Collection) []models.Collection {
+	byID := make(map[int]models.Collection, len(ancestors))
+	for _, c := range ancestors {
+		byID[c.ID] = c
+	}
+	var path []models.Collection
+	c, ok := byID[id]
+	for ok && len(path) < len(ancestors) {
+		path = append(path, c)
+		if c.ParentID == nil {
+			break
+		}
+		c, ok = byID[*c.ParentID]
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// CollectionTree returns the tree of collection id, or with id 0 the trees
+// of all top-level collections.
+func (c *CollectionDB) CollectionTree(ctx context.Context, id int) ([]models.CollectionNode, error) {
+	if id != 0 {
+		collections, err := subtree(ctx, c.DB, id, "")
+		if err != nil {
+			return nil, err
+		}
+		return buildTree(collections), nil
+	}
+	collections, err := queryCollections(ctx, c.DB, `
+	SELECT `+collectionColumns+`
+	FROM collections
+	WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	return buildTree(collections), nil
+}
+
+// CollectionBreadcrumb returns the collections from the top-level
+// collection down to collection id.
+func (c *CollectionDB) CollectionBreadcrumb(ctx context.Context, id int) ([]models.Collection, error) {
+	ancestors, err := queryCollections(ctx, c.DB, `
+	WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM collections WHERE id = $1 AND deleted_at IS NULL
+		UNION
+		SELECT c.id, c.parent_id FROM collections c JOIN ancestors a ON c.id = a.parent_id
+	)
+	SELECT `+collectionColumns+`
+	FROM collections
+	WHERE id IN (SELECT id FROM ancestors)`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(ancestors) == 0 {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	return breadcrumb(id, ancestors), nil
+}
+
+// MoveCollection makes the collection a sub-collection of parentID, or a
+// top-level collection when parentID is nil. Its sub-collections move with
+// it.
+func (c *CollectionDB) MoveCollection(ctx context.Context, id int, parentID *int, version int) (*models.Collection, error) {
+	return c.changeCollection(ctx, id, version, models.RevisionMove, func(current *models.Collection) (*models.CollectionRequest, error) {
+		collection := collectionRequest(current)
+		collection.ParentID = parentID
+		return collection, nil
+	})
+}
+
+// ListBooksInCollectionTree lists the books of a collection and of its
+// sub-collections, all the way down, see mergeBooks: first those of the
+// collection, then those of each sub-collection depth first.
+func (c *CollectionDB) ListBooksInCollectionTree(ctx context.Context, collectionID int) ([]models.Book, error) {
+	collections, err := subtree(ctx, c.DB, collectionID, "")
+	if err != nil {
+		return nil, err
+	}
+	return mergeBooks(collections, func(id int) ([]models.Book, error) {
+		return c.ListBooksInCollection(ctx, id)
+	})
+}
+
+// rehomeChildren moves the sub-collections of current up to its parent.
+// The caller holds the tree lock.
+func rehomeChildren(ctx context.Context, tx *sql.Tx, current *models.Collection) error {
+	children, err := queryCollections(ctx, tx, `
+	SELECT `+collectionColumns+`
+	FROM collections
+	WHERE parent_id = $1 AND deleted_at IS NULL
+	ORDER BY id
+	FOR UPDATE`, current.ID)
+	if err != nil {
+		return err
+	}
+	for i := range children {
+		child := &children[i]
+		collection := collectionRequest(child)
+		collection.ParentID = current.ParentID
+		moved, err := updateCollection(ctx, tx, child.ID, collection, child.Version)
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, "collection", models.RevisionMove, child.ID, moved.Version, child, moved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreCollection takes a collection that went to the trash at deletedAt
+// out of it.
+func restoreCollection(ctx context.Context, tx *sql.Tx, id int, deletedAt time.Time) (*models.Collection, error) {
+	var collection models.Collection
+	query := `
+	UPDATE collections
+	SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $1
+	RETURNING ` + collectionColumns
+	if err := tx.QueryRowContext(ctx, query, id).Scan(collectionFields(&collection)...); err != nil {
+		return nil, dbError("failed to restore collection", err)
+	}
+
+	deleted := collection
+	deleted.DeletedAt = &deletedAt
+	if err := recordRevision(ctx, tx, "collection", models.RevisionRestore, id, collection.Version, &deleted, &collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// checkParent works like the function of the same name. The caller holds
+// m.mu.
+func (m *MemoryStore) checkParent(id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	_, exists := m.collections[*parentID]
+	cycle := false
+	for p := parentID; exists && p != nil; {
+		if *p == id {
+			cycle = true
+			break
+		}
+		parent, ok := m.collections[*p]
+		if !ok {
+			break
+		}
+		p = parent.ParentID
+	}
+	return parentError(*parentID, exists, cycle)
+}
+
+// subtree works like the function of the same name. The caller holds m.mu.
+func (m *MemoryStore) subtree(id int) ([]models.Collection, error) {
+	if _, ok := m.collections[id]; !ok {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	in := map[int]bool{id: true}
+	for grown := true; grown; {
+		grown = false
+		for _, c := range m.collections {
+			if c.ParentID != nil && in[*c.ParentID] && !in[c.ID] {
+				in[c.ID], grown = true, true
+			}
+		}
+	}
+	collections := make([]models.Collection, 0, len(in))
+	for collectionID := range in {
+		collections = append(collections, *m.collections[collectionID])
+	}
+	return flattenTree(buildTree(collections)), nil
+}
+
+func (m *MemoryStore) CollectionTree(ctx context.Context, id int) ([]models.CollectionNode, error) {
+	if id == 0 {
+		return buildTree(m.allCollections()), nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collections, err := m.subtree(id)
+	if err != nil {
+		return nil, err
+	}
+	return buildTree(collections), nil
+}
+
+func (m *MemoryStore) CollectionBreadcrumb(ctx context.Context, id int) ([]models.Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ancestors []models.Collection
+	for p := &id; p != nil; {
+		c, ok := m.collections[*p]
+		if !ok {
+			break
+		}
+		ancestors = append(ancestors, *c)
+		p = c.ParentID
+	}
+	if len(ancestors) == 0 {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	return breadcrumb(id, ancestors), nil
+}
+
+func (m *MemoryStore) MoveCollection(ctx context.Context, id int, parentID *int, version int) (*models.Collection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.collections[id]
+	if !ok {
+		return nil, fmt.Errorf("collection %w", ErrNotFound)
+	}
+	if err := checkVersion("collection", current.Version, version); err != nil {
+		return nil, err
+	}
+	collection := collectionRequest(current)
+	collection.ParentID = parentID
+	return m.changeCollection(ctx, models.RevisionMove, current, collection)
+}
+
+func (m *MemoryStore) ListBooksInCollectionTree(ctx context.Context, collectionID int) ([]models.Book, error) {
+	m.mu.RLock()
+	collections, err := m.subtree(collectionID)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return mergeBooks(collections, func(id int) ([]models.Book, error) {
+		return m.ListBooksInCollection(ctx, id)
+	})
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Books, collections and authors carry a version that is incremented on every write.
+// The update, patch and delete methods take the version the caller expects
+// the row to be at; AnyVersion skips the check.
+const AnyVersion = 0
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// checkVersion fails with ErrPreconditionFailed when want is set and differs
+// from the current version.
+func checkVersion(kind string, current, want int) error {
+	if want != AnyVersion && want != current {
+		return fmt.Errorf("%w: %s is at version %d", ErrPreconditionFailed, kind, current)
+	}
+	return nil
+}
+
+// versionError explains why a conditional write on table matched no row:
+// either the row is gone or it is at another version than want.
+func versionError(ctx context.Context, q querier, table, kind string, id, want int) error {
+	var current int
+	err := q.QueryRowContext(ctx, "SELECT version FROM "+table+" WHERE id = $1 AND "+liveRows(table), id).Scan(&current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s %w", kind, ErrNotFound)
+		}
+		return fmt.Errorf("failed to get %s version: %v", kind, err)
+	}
+	return checkVersion(kind, current, want)
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package filter
+
+import "strconv"
+
+// Expr is a node of a parsed filter expression.
+type Expr interface {
+	exprNode()
+}
+
+type Ident struct {
+	Name string
+	Pos  int
+}
+
+type LiteralKind int
+
+const (
+	StringLiteral LiteralKind = iota
+	NumberLiteral
+	BoolLiteral
+)
+
+type Literal struct {
+	Kind LiteralKind
+	Text string
+	Pos  int
+}
+
+// Logical joins two expressions with AND or OR.
+type Logical struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+type Not struct {
+	X Expr
+}
+
+// Comparison is `field op value` where op is one of =, !=, <, <=, >, >=.
+type Comparison struct {
+	Field Ident
+	Op    string
+	Value Literal
+}
+
+type In struct {
+	Field   Ident
+	Values  []Literal
+	Negated bool
+}
+
+type Like struct {
+	Field           Ident
+	Pattern         Literal
+	Negated         bool
+	CaseInsensitive bool
+}
+
+type Between struct {
+	Field   Ident
+	Low     Literal
+	High    Literal
+	Negated bool
+}
+
+type IsNull struct {
+	Field   Ident
+	Negated bool
+}
+
+func (*Logical) exprNode()    {}
+func (*Not) exprNode()        {}
+func (*Comparison) exprNode() {}
+func (*In) exprNode()         {}
+func (*Like) exprNode()       {}
+func (*Between) exprNode()    {}
+func (*IsNull) exprNode()     {}
+
+// And combines the given expressions with AND, skipping nil ones. It returns
+// nil when there is nothing to combine.
+func And(exprs ...Expr) Expr {
+	var result Expr
+	for _, e := range exprs {
+		if e == nil {
+			continue
+		}
+		if result == nil {
+			result = e
+		} else {
+			result = &Logical{Op: "AND", Left: result, Right: e}
+		}
+	}
+	return result
+}
+
+func Field(name string) Ident {
+	return Ident{Name: name}
+}
+
+func String(value string) Literal {
+	return Literal{Kind: StringLiteral, Text: value}
+}
+
+func Number(value int) Literal {
+	return Literal{Kind: NumberLiteral, Text: strconv.Itoa(value)}
+}
@@ -0,0 +1,22 @@
+package filter
+
+import "fmt"
+
+// Error reports a problem with a filter, order or group expression. Pos is the
+// zero-based byte offset of the offending token in the original input.
+type Error struct {
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+	}
+	return fmt.Sprintf("%s at position %d near %q", e.Msg, e.Pos+1, e.Token)
+}
+
+func errorf(pos int, token, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Token: token, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,227 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Record returns the value of a field for in-memory evaluation. Values are
+// string, int or time.Time; nil stands for NULL.
+type Record func(field string) interface{}
+
+// Match evaluates the expression against a record with the same semantics as
+// the SQL produced by SQLBuilder: comparisons involving NULL are unknown, as
+// is NOT of unknown, and a record only matches when the whole expression is
+// true.
+func (s *Schema) Match(e Expr, rec Record) (bool, error) {
+	t, err := s.eval(e, rec)
+	return t == truthTrue, err
+}
+
+// truth is a value of SQL's three-valued logic.
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthUnknown
+	truthTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+func (s *Schema) eval(e Expr, rec Record) (truth, error) {
+	switch n := e.(type) {
+	case *Logical:
+		left, err := s.eval(n.Left, rec)
+		if err != nil {
+			return truthFalse, err
+		}
+		right, err := s.eval(n.Right, rec)
+		if err != nil {
+			return truthFalse, err
+		}
+		// With false < unknown < true, AND is the minimum and OR the maximum.
+		if n.Op == "AND" {
+			return min(left, right), nil
+		}
+		return max(left, right), nil
+	case *Not:
+		x, err := s.eval(n.X, rec)
+		return truthTrue - x, err
+	case *Comparison:
+		def, err := s.lookup(n.Field)
+		if err != nil {
+			return truthFalse, err
+		}
+		want, err := s.value(n.Field, def, n.Value)
+		if err != nil {
+			return truthFalse, err
+		}
+		got := rec(n.Field.Name)
+		if got == nil {
+			return truthUnknown, nil
+		}
+		c := Compare(got, want)
+		switch n.Op {
+		case "=":
+			return truthOf(c == 0), nil
+		case "!=":
+			return truthOf(c != 0), nil
+		case "<":
+			return truthOf(c < 0), nil
+		case "<=":
+			return truthOf(c <= 0), nil
+		case ">":
+			return truthOf(c > 0), nil
+		case ">=":
+			return truthOf(c >= 0), nil
+		}
+		return truthFalse, fmt.Errorf("unsupported operator %q", n.Op)
+	case *In:
+		def, err := s.lookup(n.Field)
+		if err != nil {
+			return truthFalse, err
+		}
+		got := rec(n.Field.Name)
+		found := false
+		for _, lit := range n.Values {
+			want, err := s.value(n.Field, def, lit)
+			if err != nil {
+				return truthFalse, err
+			}
+			if got != nil && Compare(got, want) == 0 {
+				found = true
+			}
+		}
+		if got == nil {
+			return truthUnknown, nil
+		}
+		return truthOf(found != n.Negated), nil
+	case *Like:
+		def, err := s.lookup(n.Field)
+		if err != nil {
+			return truthFalse, err
+		}
+		if def.Type != TextField {
+			return truthFalse, errorf(n.Field.Pos, n.Field.Name, "LIKE is only supported on string fields")
+		}
+		re, err := likeRegexp(n.Pattern.Text, n.CaseInsensitive)
+		if err != nil {
+			return truthFalse, err
+		}
+		got, ok := rec(n.Field.Name).(string)
+		if !ok {
+			return truthUnknown, nil
+		}
+		return truthOf(re.MatchString(got) != n.Negated), nil
+	case *Between:
+		def, err := s.lookup(n.Field)
+		if err != nil {
+			return truthFalse, err
+		}
+		low, err := s.value(n.Field, def, n.Low)
+		if err != nil {
+			return truthFalse, err
+		}
+		high, err := s.value(n.Field, def, n.High)
+		if err != nil {
+			return truthFalse, err
+		}
+		got := rec(n.Field.Name)
+		if got == nil {
+			return truthUnknown, nil
+		}
+		in := Compare(got, low) >= 0 && Compare(got, high) <= 0
+		return truthOf(in != n.Negated), nil
+	case *IsNull:
+		if _, err := s.lookup(n.Field); err != nil {
+			return truthFalse, err
+		}
+		isNull := rec(n.Field.Name) == nil
+		return truthOf(isNull != n.Negated), nil
+	default:
+		return truthFalse, fmt.Errorf("unsupported expression %T", e)
+	}
+}
+
+// SortRecord returns rec with NULL text fields read as "", which is how
+// SQLBuilder orders nullable text columns.
+func (s *Schema) SortRecord(rec Record) Record {
+	return func(field string) interface{} {
+		v := rec(field)
+		if v == nil {
+			if def, ok := s.Fields[field]; ok && def.Nullable && def.Type == TextField {
+				return ""
+			}
+		}
+		return v
+	}
+}
+
+// Compare orders two values of the same field type. NULL (nil) sorts last,
+// as it does in PostgreSQL.
+func Compare(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+	switch av := a.(type) {
+	case int:
+		bv, _ := b.(int)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case time.Time:
+		bv, _ := b.(time.Time)
+		return av.Compare(bv)
+	case string:
+		bv, _ := b.(string)
+		return strings.Compare(av, bv)
+	}
+	return 0
+}
+
+// likeRegexp translates a LIKE pattern (% and _ wildcards, backslash escape)
+// into an anchored regular expression.
+func likeRegexp(pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	if caseInsensitive {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
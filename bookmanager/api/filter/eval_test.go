@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchNull(t *testing.T) {
+	// The book has no genre, description or ISBN, which are NULL.
+	book := map[string]interface{}{
+		"id":             1,
+		"title":          "Dune",
+		"author":         "Frank Herbert",
+		"published_date": time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+		"edition":        3,
+	}
+	rec := func(field string) interface{} { return book[field] }
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"genre = 'SciFi'", false},
+		{"NOT genre = 'SciFi'", false},
+		{"genre != 'SciFi'", false},
+		{"NOT genre != 'SciFi'", false},
+		{"genre IN ('SciFi', 'Fantasy')", false},
+		{"genre NOT IN ('SciFi', 'Fantasy')", false},
+		{"NOT genre IN ('SciFi')", false},
+		{"description LIKE '%'", false},
+		{"description NOT ILIKE 'a%'", false},
+		{"NOT description LIKE 'a%'", false},
+		{"isbn13 BETWEEN '9780000000000' AND '9799999999999'", false},
+		{"isbn13 NOT BETWEEN '9780000000000' AND '9799999999999'", false},
+		{"genre IS NULL", true},
+		{"NOT genre IS NULL", false},
+		{"genre IS NOT NULL", false},
+		{"NOT genre IS NOT NULL", true},
+		// Unknown AND true is unknown, and so is NOT of it.
+		{"genre = 'SciFi' AND title = 'Dune'", false},
+		{"NOT (genre = 'SciFi' AND title = 'Dune')", false},
+		// Unknown AND false is false.
+		{"NOT (genre = 'SciFi' AND title = 'Emma')", true},
+		// Unknown OR true is true; unknown OR false is unknown.
+		{"genre = 'SciFi' OR title = 'Dune'", true},
+		{"NOT (genre = 'SciFi' OR title = 'Dune')", false},
+		{"NOT (genre = 'SciFi' OR title = 'Emma')", false},
+		{"NOT NOT genre = 'SciFi'", false},
+		{"NOT genre = 'SciFi' OR genre IS NULL", true},
+		{"edition NOT BETWEEN 1 AND 2", true},
+		{"NOT edition = 1 AND NOT title LIKE 'E%'", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			got, err := BookSchema.Match(expr, rec)
+			if err != nil {
+				t.Fatalf("Match(%q): %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	book["genre"] = "Fantasy"
+	expr, err := Parse("NOT genre = 'SciFi'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := BookSchema.Match(expr, rec); err != nil || !got {
+		t.Errorf("NOT genre = 'SciFi' on a Fantasy book = %v, %v; want true", got, err)
+	}
+}
+
+func TestSortRecord(t *testing.T) {
+	rec := BookSchema.SortRecord(func(string) interface{} { return nil })
+	if got := rec("genre"); got != "" {
+		t.Errorf("genre sorts as %#v, want \"\"", got)
+	}
+	if got := rec("published_date"); got != nil {
+		t.Errorf("published_date sorts as %#v, want nil", got)
+	}
+}
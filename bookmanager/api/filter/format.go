@@ -0,0 +1,51 @@
+package filter
+
+import "strings"
+
+// Format renders an expression in the filter language, so that it can be
+// handed back to clients as a where parameter. Parse(Format(e)) yields an
+// equivalent expression.
+func Format(e Expr) string {
+	switch n := e.(type) {
+	case *Logical:
+		return "(" + Format(n.Left) + " " + n.Op + " " + Format(n.Right) + ")"
+	case *Not:
+		return "NOT " + Format(n.X)
+	case *Comparison:
+		return n.Field.Name + " " + n.Op + " " + formatLiteral(n.Value)
+	case *In:
+		values := make([]string, len(n.Values))
+		for i, v := range n.Values {
+			values[i] = formatLiteral(v)
+		}
+		return n.Field.Name + negated(n.Negated, " NOT") + " IN (" + strings.Join(values, ", ") + ")"
+	case *Like:
+		op := " LIKE "
+		if n.CaseInsensitive {
+			op = " ILIKE "
+		}
+		return n.Field.Name + negated(n.Negated, " NOT") + op + formatLiteral(n.Pattern)
+	case *Between:
+		return n.Field.Name + negated(n.Negated, " NOT") + " BETWEEN " + formatLiteral(n.Low) + " AND " + formatLiteral(n.High)
+	case *IsNull:
+		return n.Field.Name + " IS" + negated(n.Negated, " NOT") + " NULL"
+	}
+	return ""
+}
+
+func negated(neg bool, s string) string {
+	if neg {
+		return s
+	}
+	return ""
+}
+
+func formatLiteral(lit Literal) string {
+	switch lit.Kind {
+	case StringLiteral:
+		return "'" + strings.ReplaceAll(lit.Text, "'", "''") + "'"
+	case BoolLiteral:
+		return strings.ToUpper(lit.Text)
+	}
+	return lit.Text
+}
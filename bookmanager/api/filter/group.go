@@ -0,0 +1,165 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Buckets a date or time field can be grouped by, as in decade(published_date).
+var buckets = map[string]bool{"year": true, "month": true, "decade": true}
+
+// GroupKey is one term of a group_by list: a field, or a date field bucketed
+// by year, month or decade.
+type GroupKey struct {
+	Field  Ident
+	Bucket string
+}
+
+func (k GroupKey) String() string {
+	if k.Bucket == "" {
+		return k.Field.Name
+	}
+	return k.Bucket + "(" + k.Field.Name + ")"
+}
+
+// ParseGroupBy parses a comma separated list of group keys, e.g.
+// "genre, decade(published_date)".
+func ParseGroupBy(input string, schema *Schema) ([]GroupKey, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var keys []GroupKey
+	for {
+		tok := p.next()
+		if tok.kind != tokIdent {
+			return nil, errorf(tok.pos, tok.raw, "expected field name")
+		}
+
+		var key GroupKey
+		if p.peek().kind == tokLParen {
+			if !buckets[tok.text] {
+				return nil, errorf(tok.pos, tok.raw, "unknown group function, expected year, month or decade")
+			}
+			key.Bucket = tok.text
+			p.next()
+			tok = p.next()
+			if tok.kind != tokIdent {
+				return nil, errorf(tok.pos, tok.raw, "expected field name")
+			}
+			if closing := p.next(); closing.kind != tokRParen {
+				return nil, errorf(closing.pos, closing.raw, "expected )")
+			}
+		}
+		def, ok := schema.Fields[tok.text]
+		if !ok {
+			return nil, errorf(tok.pos, tok.raw, "unknown %s field", schema.Name)
+		}
+		if key.Bucket != "" && def.Type != DateField && def.Type != TimeField {
+			return nil, errorf(tok.pos, tok.raw, "%s() needs a date field", key.Bucket)
+		}
+		key.Field = Ident{Name: tok.text, Pos: tok.pos}
+		keys = append(keys, key)
+
+		tok = p.next()
+		if tok.kind == tokEOF {
+			return keys, nil
+		}
+		if tok.kind != tokComma {
+			return nil, errorf(tok.pos, tok.raw, "expected , or end of group_by")
+		}
+	}
+}
+
+// GroupExpr returns the SQL expression computing the key of a row. Buckets
+// are integers (year, decade) or 'YYYY-MM' strings (month).
+func (b *SQLBuilder) GroupExpr(k GroupKey) (string, error) {
+	col, err := b.Column(k.Field)
+	if err != nil {
+		return "", err
+	}
+	switch k.Bucket {
+	case "year":
+		return "EXTRACT(YEAR FROM " + col + ")::int", nil
+	case "decade":
+		return "(EXTRACT(YEAR FROM " + col + ")::int / 10 * 10)", nil
+	case "month":
+		return "to_char(" + col + ", 'YYYY-MM')", nil
+	}
+	return col, nil
+}
+
+// GroupValue computes the key of a record the way GroupExpr does in SQL.
+func (k GroupKey) GroupValue(rec Record) interface{} {
+	v := rec(k.Field.Name)
+	t, ok := v.(time.Time)
+	if !ok || k.Bucket == "" {
+		return v
+	}
+	switch k.Bucket {
+	case "year":
+		return t.Year()
+	case "decade":
+		return t.Year() / 10 * 10
+	}
+	return t.Format("2006-01")
+}
+
+// GroupFilter returns the expression selecting the rows of one group. value
+// is the key as PostgreSQL renders it as text, nil for NULL.
+func (s *Schema) GroupFilter(k GroupKey, value *string) (Expr, error) {
+	def, err := s.lookup(k.Field)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return &IsNull{Field: k.Field}, nil
+	}
+	between := func(from, to time.Time) Expr {
+		layout := "2006-01-02"
+		return And(
+			&Comparison{Field: k.Field, Op: ">=", Value: String(from.Format(layout))},
+			&Comparison{Field: k.Field, Op: "<", Value: String(to.Format(layout))},
+		)
+	}
+
+	switch k.Bucket {
+	case "year", "decade":
+		year, err := strconv.Atoi(*value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s key %q", k, *value)
+		}
+		from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		if k.Bucket == "year" {
+			return between(from, from.AddDate(1, 0, 0)), nil
+		}
+		return between(from, from.AddDate(10, 0, 0)), nil
+	case "month":
+		from, err := time.Parse("2006-01", *value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s key %q", k, *value)
+		}
+		return between(from, from.AddDate(0, 1, 0)), nil
+	}
+
+	switch def.Type {
+	case IntField:
+		n, err := strconv.Atoi(*value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s key %q", k, *value)
+		}
+		return &Comparison{Field: k.Field, Op: "=", Value: Number(n)}, nil
+	case TimeField:
+		t, err := time.Parse(PostgresTimeLayout, *value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s key %q", k, *value)
+		}
+		return &Comparison{Field: k.Field, Op: "=", Value: String(t.Format(time.RFC3339Nano))}, nil
+	}
+	return &Comparison{Field: k.Field, Op: "=", Value: String(*value)}, nil
+}
+
+// PostgresTimeLayout is how PostgreSQL casts a timestamptz to text.
+const PostgresTimeLayout = "2006-01-02 15:04:05.999999-07"
@@ -0,0 +1,136 @@
+package filter
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+	tokLParen
+	tokRParen
+	tokComma
+	tokKeyword
+)
+
+var keywords = map[string]bool{
+	"AND":     true,
+	"OR":      true,
+	"NOT":     true,
+	"IN":      true,
+	"LIKE":    true,
+	"ILIKE":   true,
+	"BETWEEN": true,
+	"IS":      true,
+	"NULL":    true,
+	"TRUE":    true,
+	"FALSE":   true,
+	"ASC":     true,
+	"DESC":    true,
+}
+
+type token struct {
+	kind tokenKind
+	text string // keywords are upper-cased, strings are unquoted
+	raw  string // the token as written, used in error messages
+	pos  int
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", raw: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", raw: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", raw: ",", pos: i})
+			i++
+		case c == '\'' || c == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(input) {
+				if input[i] == c {
+					// A doubled quote is an escaped quote, as in SQL.
+					if i+1 < len(input) && input[i+1] == c {
+						sb.WriteByte(c)
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				sb.WriteByte(input[i])
+				i++
+			}
+			if !closed {
+				return nil, errorf(start, input[start:], "unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), raw: input[start:i], pos: start})
+		case c == '=' || c == '<' || c == '>' || c == '!':
+			start := i
+			op := string(c)
+			if i+1 < len(input) {
+				two := input[i : i+2]
+				if two == "<=" || two == ">=" || two == "!=" || two == "<>" {
+					op = two
+				}
+			}
+			if op == "!" {
+				return nil, errorf(start, op, "unexpected character")
+			}
+			if op == "<>" {
+				op = "!="
+			}
+			i += len(op)
+			tokens = append(tokens, token{kind: tokOperator, text: op, raw: input[start:i], pos: start})
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			for i < len(input) && (input[i] >= '0' && input[i] <= '9' || input[i] == '.') {
+				i++
+			}
+			text := input[start:i]
+			if text == "-" {
+				return nil, errorf(start, text, "unexpected character")
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, raw: text, pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && isIdentPart(input[i]) {
+				i++
+			}
+			word := input[start:i]
+			if upper := strings.ToUpper(word); keywords[upper] {
+				tokens = append(tokens, token{kind: tokKeyword, text: upper, raw: word, pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: strings.ToLower(word), raw: word, pos: start})
+			}
+		default:
+			return nil, errorf(i, string(c), "unexpected character")
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(input)})
+	return tokens, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
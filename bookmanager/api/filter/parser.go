@@ -0,0 +1,262 @@
+package filter
+
+// Parse turns a filter expression such as
+//
+//	genre = 'Fantasy' AND (edition > 1 OR title LIKE '%Hobbit%')
+//
+// into an Expr. Field names are not checked here; use Schema.Validate or
+// compile the expression against a schema for that.
+func Parse(input string) (Expr, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(0, "", "empty expression")
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, errorf(tok.pos, tok.raw, "unexpected token")
+	}
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) acceptKeyword(kw string) bool {
+	if tok := p.peek(); tok.kind == tokKeyword && tok.text == kw {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		tok := p.peek()
+		return errorf(tok.pos, tok.raw, "expected %s", kw)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokLParen:
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, errorf(closing.pos, closing.raw, "expected )")
+		}
+		return expr, nil
+	case tokIdent:
+		return p.parsePredicate()
+	case tokEOF:
+		return nil, errorf(tok.pos, "", "unexpected end of expression")
+	default:
+		return nil, errorf(tok.pos, tok.raw, "expected field name")
+	}
+}
+
+func (p *parser) parsePredicate() (Expr, error) {
+	fieldTok := p.next()
+	field := Ident{Name: fieldTok.text, Pos: fieldTok.pos}
+
+	tok := p.peek()
+	if tok.kind == tokOperator {
+		p.next()
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &Comparison{Field: field, Op: tok.text, Value: value}, nil
+	}
+
+	if p.acceptKeyword("IS") {
+		negated := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &IsNull{Field: field, Negated: negated}, nil
+	}
+
+	negated := p.acceptKeyword("NOT")
+	tok = p.next()
+	if tok.kind != tokKeyword {
+		return nil, errorf(tok.pos, tok.raw, "expected operator after field %q", fieldTok.raw)
+	}
+
+	switch tok.text {
+	case "IN":
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &In{Field: field, Values: values, Negated: negated}, nil
+	case "LIKE", "ILIKE":
+		pattern, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if pattern.Kind != StringLiteral {
+			return nil, errorf(pattern.Pos, pattern.Text, "%s pattern must be a string", tok.text)
+		}
+		return &Like{Field: field, Pattern: pattern, Negated: negated, CaseInsensitive: tok.text == "ILIKE"}, nil
+	case "BETWEEN":
+		low, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &Between{Field: field, Low: low, High: high, Negated: negated}, nil
+	default:
+		return nil, errorf(tok.pos, tok.raw, "expected operator after field %q", fieldTok.raw)
+	}
+}
+
+func (p *parser) parseList() ([]Literal, error) {
+	if open := p.next(); open.kind != tokLParen {
+		return nil, errorf(open.pos, open.raw, "expected ( after IN")
+	}
+	var values []Literal
+	for {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		tok := p.next()
+		if tok.kind == tokRParen {
+			return values, nil
+		}
+		if tok.kind != tokComma {
+			return nil, errorf(tok.pos, tok.raw, "expected , or )")
+		}
+	}
+}
+
+func (p *parser) parseLiteral() (Literal, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokString:
+		return Literal{Kind: StringLiteral, Text: tok.text, Pos: tok.pos}, nil
+	case tok.kind == tokNumber:
+		return Literal{Kind: NumberLiteral, Text: tok.text, Pos: tok.pos}, nil
+	case tok.kind == tokKeyword && (tok.text == "TRUE" || tok.text == "FALSE"):
+		return Literal{Kind: BoolLiteral, Text: tok.text, Pos: tok.pos}, nil
+	case tok.kind == tokEOF:
+		return Literal{}, errorf(tok.pos, "", "expected value, found end of expression")
+	default:
+		return Literal{}, errorf(tok.pos, tok.raw, "expected value")
+	}
+}
+
+// OrderTerm is a single `field [ASC|DESC]` entry of an order_by clause.
+type OrderTerm struct {
+	Field Ident
+	Desc  bool
+}
+
+// ParseOrderBy parses a comma separated list of fields with an optional
+// ASC/DESC direction and checks every field against the schema.
+func ParseOrderBy(input string, schema *Schema) ([]OrderTerm, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var terms []OrderTerm
+	for {
+		tok := p.next()
+		if tok.kind != tokIdent {
+			return nil, errorf(tok.pos, tok.raw, "expected field name")
+		}
+		if _, ok := schema.Fields[tok.text]; !ok {
+			return nil, errorf(tok.pos, tok.raw, "unknown %s field", schema.Name)
+		}
+		term := OrderTerm{Field: Ident{Name: tok.text, Pos: tok.pos}}
+		if p.acceptKeyword("DESC") {
+			term.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+		terms = append(terms, term)
+
+		tok = p.next()
+		if tok.kind == tokEOF {
+			return terms, nil
+		}
+		if tok.kind != tokComma {
+			return nil, errorf(tok.pos, tok.raw, "expected , or end of order_by")
+		}
+	}
+}
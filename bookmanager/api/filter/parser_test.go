@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"edition = 1", "edition = 1"},
+		{"a = 1 OR b = 2 AND c = 3", "(a = 1 OR (b = 2 AND c = 3))"},
+		{"a = 1 AND b = 2 OR c = 3", "((a = 1 AND b = 2) OR c = 3)"},
+		{"(a = 1 OR b = 2) AND c = 3", "((a = 1 OR b = 2) AND c = 3)"},
+		{"a = 1 OR b = 2 OR c = 3", "((a = 1 OR b = 2) OR c = 3)"},
+		{"NOT a = 1 AND b = 2", "(NOT a = 1 AND b = 2)"},
+		{"NOT (a = 1 OR b = 2)", "NOT (a = 1 OR b = 2)"},
+		{"a BETWEEN 1 AND 5 AND b = 2", "(a BETWEEN 1 AND 5 AND b = 2)"},
+		{"genre not in ('Fantasy', 'SciFi')", "genre NOT IN ('Fantasy', 'SciFi')"},
+		{"title ilike '%hobbit%'", "title ILIKE '%hobbit%'"},
+		{"genre IS NOT NULL", "genre IS NOT NULL"},
+		{"NOT genre IS NULL", "NOT genre IS NULL"},
+		{"NOT genre = 'X' OR genre IS NULL", "(NOT genre = 'X' OR genre IS NULL)"},
+		{"NOT (genre = 'X' AND title = 'Dune')", "NOT (genre = 'X' AND title = 'Dune')"},
+		{"title = 'It''s'", "title = 'It''s'"},
+		{"edition <> 2", "edition != 2"},
+		{"Title = \"Dune\"", "title = 'Dune'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if got := Format(expr); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"", 0, "empty expression"},
+		{"a = 1 AND", 9, "unexpected end of expression"},
+		{"(a = 1", 6, "expected )"},
+		{"a = 1 b = 2", 6, "unexpected token"},
+		{"a LIKE 5", 7, "LIKE pattern must be a string"},
+		{"a IN 1", 5, "expected ( after IN"},
+		{"title = 'open", 8, "unterminated string"},
+		{"a ! 1", 2, "unexpected character"},
+		{"= 1", 0, "expected field name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var ferr *Error
+			if !errors.As(err, &ferr) {
+				t.Fatalf("Parse(%q) error = %v, want a *filter.Error", tt.input, err)
+			}
+			if ferr.Pos != tt.pos || ferr.Msg != tt.msg {
+				t.Errorf("Parse(%q) error = %q at %d, want %q at %d", tt.input, ferr.Msg, ferr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	terms, err := ParseOrderBy("genre, published_date DESC, title asc", BookSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OrderTerm{{Field: Ident{Name: "genre"}}, {Field: Ident{Name: "published_date"}, Desc: true}, {Field: Ident{Name: "title"}}}
+	if len(terms) != len(want) {
+		t.Fatalf("ParseOrderBy() = %+v, want %+v", terms, want)
+	}
+	for i := range want {
+		if terms[i].Field.Name != want[i].Field.Name || terms[i].Desc != want[i].Desc {
+			t.Errorf("term %d = %+v, want %+v", i, terms[i], want[i])
+		}
+	}
+
+	for _, input := range []string{"", "nope", "title,", "title DESC DESC"} {
+		if _, err := ParseOrderBy(input, BookSchema); err == nil {
+			t.Errorf("ParseOrderBy(%q) succeeded, want an error", input)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package filter
+
+import (
+	"strconv"
+	"time"
+)
+
+type FieldType int
+
+const (
+	TextField FieldType = iota
+	IntField
+	DateField
+	TimeField
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case IntField:
+		return "integer"
+	case DateField:
+		return "date (YYYY-MM-DD)"
+	case TimeField:
+		return "timestamp"
+	default:
+		return "string"
+	}
+}
+
+type FieldDef struct {
+	Column   string
+	Type     FieldType
+	Nullable bool
+}
+
+// Schema is the whitelist of fields a resource can be filtered, ordered and
+// grouped by.
+type Schema struct {
+	Name   string
+	Fields map[string]FieldDef
+}
+
+var BookSchema = &Schema{
+	Name: "book",
+	Fields: map[string]FieldDef{
+		"id":             {Column: "id", Type: IntField},
+		"title":          {Column: "title", Type: TextField},
+		"author":         {Column: "author", Type: TextField},
+		"published_date": {Column: "published_date", Type: DateField},
+		"edition":        {Column: "edition", Type: IntField},
+		"description":    {Column: "description", Type: TextField, Nullable: true},
+		"genre":          {Column: "genre", Type: TextField, Nullable: true},
+		"isbn13":         {Column: "isbn13", Type: TextField, Nullable: true},
+		"created_at":     {Column: "created_at", Type: TimeField},
+		"updated_at":     {Column: "updated_at", Type: TimeField},
+	},
+}
+
+var CollectionSchema = &Schema{
+	Name: "collection",
+	Fields: map[string]FieldDef{
+		"id":          {Column: "id", Type: IntField},
+		"name":        {Column: "name", Type: TextField},
+		"description": {Column: "description", Type: TextField, Nullable: true},
+		"kind":        {Column: "kind", Type: TextField},
+		"created_at":  {Column: "created_at", Type: TimeField},
+		"updated_at":  {Column: "updated_at", Type: TimeField},
+	},
+}
+
+var AuthorSchema = &Schema{
+	Name: "author",
+	Fields: map[string]FieldDef{
+		"id":         {Column: "id", Type: IntField},
+		"name":       {Column: "name", Type: TextField},
+		"sort_name":  {Column: "sort_name", Type: TextField},
+		"birth_date": {Column: "birth_date", Type: DateField, Nullable: true},
+		"death_date": {Column: "death_date", Type: DateField, Nullable: true},
+		"bio":        {Column: "bio", Type: TextField},
+		"created_at": {Column: "created_at", Type: TimeField},
+		"updated_at": {Column: "updated_at", Type: TimeField},
+	},
+}
+
+func (s *Schema) lookup(field Ident) (FieldDef, error) {
+	def, ok := s.Fields[field.Name]
+	if !ok {
+		return FieldDef{}, errorf(field.Pos, field.Name, "unknown %s field", s.Name)
+	}
+	return def, nil
+}
+
+// value converts a literal into the Go value matching the field type.
+func (s *Schema) value(field Ident, def FieldDef, lit Literal) (interface{}, error) {
+	mismatch := func() error {
+		return errorf(lit.Pos, lit.Text, "%s expects a %s value", field.Name, def.Type)
+	}
+
+	switch def.Type {
+	case IntField:
+		if lit.Kind != NumberLiteral {
+			return nil, mismatch()
+		}
+		n, err := strconv.Atoi(lit.Text)
+		if err != nil {
+			return nil, mismatch()
+		}
+		return n, nil
+	case DateField:
+		if lit.Kind != StringLiteral {
+			return nil, mismatch()
+		}
+		t, err := time.Parse("2006-01-02", lit.Text)
+		if err != nil {
+			return nil, mismatch()
+		}
+		return t, nil
+	case TimeField:
+		if lit.Kind != StringLiteral {
+			return nil, mismatch()
+		}
+		if t, err := time.Parse(time.RFC3339, lit.Text); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse("2006-01-02", lit.Text)
+		if err != nil {
+			return nil, mismatch()
+		}
+		return t, nil
+	default:
+		if lit.Kind != StringLiteral {
+			return nil, mismatch()
+		}
+		return lit.Text, nil
+	}
+}
+
+// Validate checks that every field in the expression exists in the schema and
+// that every value fits its field.
+func (s *Schema) Validate(e Expr) error {
+	_, err := NewSQLBuilder(s, "").Where(e)
+	return err
+}
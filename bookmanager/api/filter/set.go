@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SetExpr is a node of a parsed set expression over collections.
+type SetExpr interface {
+	setNode()
+}
+
+// SetRef is the set of books of a collection.
+type SetRef struct {
+	ID  int
+	Pos int
+}
+
+// SetOp combines two sets with UNION, INTERSECT or EXCEPT.
+type SetOp struct {
+	Op    string
+	Left  SetExpr
+	Right SetExpr
+}
+
+func (*SetRef) setNode() {}
+func (*SetOp) setNode()  {}
+
+// ParseSet turns a set expression over collection IDs such as
+//
+//	(1 UNION 2) EXCEPT 3
+//
+// into a SetExpr. As in SQL, INTERSECT binds tighter than UNION and EXCEPT,
+// which apply from left to right. Collections are not checked here.
+func ParseSet(input string) (SetExpr, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(0, "", "empty expression")
+	}
+	expr, err := p.parseUnion()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, errorf(tok.pos, tok.raw, "unexpected token")
+	}
+	return expr, nil
+}
+
+// SetIDs returns the collections a set expression refers to, each once, in
+// the order they first appear.
+func SetIDs(e SetExpr) []int {
+	var ids []int
+	var walk func(SetExpr)
+	walk = func(e SetExpr) {
+		switch n := e.(type) {
+		case *SetRef:
+			if !slices.Contains(ids, n.ID) {
+				ids = append(ids, n.ID)
+			}
+		case *SetOp:
+			walk(n.Left)
+			walk(n.Right)
+		}
+	}
+	walk(e)
+	return ids
+}
+
+// acceptSetOp consumes one of ops, which the tokenizer reads as
+// identifiers.
+func (p *parser) acceptSetOp(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == strings.ToLower(op) {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseUnion() (SetExpr, error) {
+	left, err := p.parseIntersect()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptSetOp("UNION", "EXCEPT")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseIntersect()
+		if err != nil {
+			return nil, err
+		}
+		left = &SetOp{Op: op, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseIntersect() (SetExpr, error) {
+	left, err := p.parseSetPrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptSetOp("INTERSECT"); !ok {
+			return left, nil
+		}
+		right, err := p.parseSetPrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = &SetOp{Op: "INTERSECT", Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseSetPrimary() (SetExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		expr, err := p.parseUnion()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, errorf(closing.pos, closing.raw, "expected )")
+		}
+		return expr, nil
+	case tokNumber:
+		id, err := strconv.Atoi(tok.text)
+		if err != nil || id <= 0 {
+			return nil, errorf(tok.pos, tok.raw, "expected collection ID")
+		}
+		return &SetRef{ID: id, Pos: tok.pos}, nil
+	case tokEOF:
+		return nil, errorf(tok.pos, "", "unexpected end of expression")
+	default:
+		return nil, errorf(tok.pos, tok.raw, "expected collection ID")
+	}
+}
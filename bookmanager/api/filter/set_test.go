@@ -0,0 +1,86 @@
+package filter
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// formatSet writes e with every operation parenthesized.
+func formatSet(e SetExpr) string {
+	switch n := e.(type) {
+	case *SetRef:
+		return strconv.Itoa(n.ID)
+	case *SetOp:
+		return "(" + formatSet(n.Left) + " " + n.Op + " " + formatSet(n.Right) + ")"
+	}
+	return "?"
+}
+
+func TestParseSetPrecedence(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1", "1"},
+		{"1 UNION 2 INTERSECT 3", "(1 UNION (2 INTERSECT 3))"},
+		{"1 INTERSECT 2 UNION 3", "((1 INTERSECT 2) UNION 3)"},
+		{"1 EXCEPT 2 INTERSECT 3", "(1 EXCEPT (2 INTERSECT 3))"},
+		{"1 EXCEPT 2 UNION 3", "((1 EXCEPT 2) UNION 3)"},
+		{"1 UNION 2 EXCEPT 3", "((1 UNION 2) EXCEPT 3)"},
+		{"1 INTERSECT 2 INTERSECT 3", "((1 INTERSECT 2) INTERSECT 3)"},
+		{"1 EXCEPT (2 UNION 3)", "(1 EXCEPT (2 UNION 3))"},
+		{"(1 UNION 2) INTERSECT 3", "((1 UNION 2) INTERSECT 3)"},
+		{"1 union 2 intersect 3", "(1 UNION (2 INTERSECT 3))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := ParseSet(tt.input)
+			if err != nil {
+				t.Fatalf("ParseSet(%q): %v", tt.input, err)
+			}
+			if got := formatSet(expr); got != tt.want {
+				t.Errorf("ParseSet(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSetErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"", 0, "empty expression"},
+		{"1 UNION", 7, "unexpected end of expression"},
+		{"0", 0, "expected collection ID"},
+		{"1 UNION books", 8, "expected collection ID"},
+		{"(1 UNION 2", 10, "expected )"},
+		{"1 2", 2, "unexpected token"},
+		{"1 OR 2", 2, "unexpected token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseSet(tt.input)
+			var ferr *Error
+			if !errors.As(err, &ferr) {
+				t.Fatalf("ParseSet(%q) error = %v, want a *filter.Error", tt.input, err)
+			}
+			if ferr.Pos != tt.pos || ferr.Msg != tt.msg {
+				t.Errorf("ParseSet(%q) error = %q at %d, want %q at %d", tt.input, ferr.Msg, ferr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+func TestSetIDs(t *testing.T) {
+	expr, err := ParseSet("(3 UNION 1) EXCEPT 3 INTERSECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := SetIDs(expr), []int{3, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("SetIDs() = %v, want %v", got, want)
+	}
+}
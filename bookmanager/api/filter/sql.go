@@ -0,0 +1,235 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLBuilder compiles expressions into parameterized SQL. Values are never
+// interpolated into the query; they are collected as positional arguments.
+type SQLBuilder struct {
+	schema *Schema
+	alias  string
+	args   []interface{}
+}
+
+// NewSQLBuilder returns a builder for the schema. When alias is not empty,
+// columns are qualified with it (e.g. "b.title").
+func NewSQLBuilder(schema *Schema, alias string) *SQLBuilder {
+	return &SQLBuilder{schema: schema, alias: alias}
+}
+
+// Arg registers a positional argument and returns its placeholder.
+func (b *SQLBuilder) Arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *SQLBuilder) Args() []interface{} {
+	return b.args
+}
+
+func (b *SQLBuilder) column(def FieldDef) string {
+	if b.alias == "" {
+		return def.Column
+	}
+	return b.alias + "." + def.Column
+}
+
+// Column returns the qualified column for a whitelisted field name.
+func (b *SQLBuilder) Column(field Ident) (string, error) {
+	def, err := b.schema.lookup(field)
+	if err != nil {
+		return "", err
+	}
+	return b.column(def), nil
+}
+
+// Where compiles the expression into a SQL boolean expression.
+func (b *SQLBuilder) Where(e Expr) (string, error) {
+	switch n := e.(type) {
+	case *Logical:
+		left, err := b.Where(n.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := b.Where(n.Right)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, n.Op, right), nil
+	case *Not:
+		x, err := b.Where(n.X)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(NOT %s)", x), nil
+	case *Comparison:
+		def, err := b.schema.lookup(n.Field)
+		if err != nil {
+			return "", err
+		}
+		v, err := b.schema.value(n.Field, def, n.Value)
+		if err != nil {
+			return "", err
+		}
+		op := n.Op
+		if op == "!=" {
+			op = "<>"
+		}
+		return fmt.Sprintf("%s %s %s", b.column(def), op, b.Arg(v)), nil
+	case *In:
+		def, err := b.schema.lookup(n.Field)
+		if err != nil {
+			return "", err
+		}
+		placeholders := make([]string, len(n.Values))
+		for i, lit := range n.Values {
+			v, err := b.schema.value(n.Field, def, lit)
+			if err != nil {
+				return "", err
+			}
+			placeholders[i] = b.Arg(v)
+		}
+		op := "IN"
+		if n.Negated {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", b.column(def), op, strings.Join(placeholders, ", ")), nil
+	case *Like:
+		def, err := b.schema.lookup(n.Field)
+		if err != nil {
+			return "", err
+		}
+		if def.Type != TextField {
+			return "", errorf(n.Field.Pos, n.Field.Name, "LIKE is only supported on string fields")
+		}
+		op := "LIKE"
+		if n.CaseInsensitive {
+			op = "ILIKE"
+		}
+		if n.Negated {
+			op = "NOT " + op
+		}
+		return fmt.Sprintf("%s %s %s", b.column(def), op, b.Arg(n.Pattern.Text)), nil
+	case *Between:
+		def, err := b.schema.lookup(n.Field)
+		if err != nil {
+			return "", err
+		}
+		low, err := b.schema.value(n.Field, def, n.Low)
+		if err != nil {
+			return "", err
+		}
+		high, err := b.schema.value(n.Field, def, n.High)
+		if err != nil {
+			return "", err
+		}
+		op := "BETWEEN"
+		if n.Negated {
+			op = "NOT BETWEEN"
+		}
+		return fmt.Sprintf("%s %s %s AND %s", b.column(def), op, b.Arg(low), b.Arg(high)), nil
+	case *IsNull:
+		def, err := b.schema.lookup(n.Field)
+		if err != nil {
+			return "", err
+		}
+		if n.Negated {
+			return b.column(def) + " IS NOT NULL", nil
+		}
+		return b.column(def) + " IS NULL", nil
+	default:
+		return "", fmt.Errorf("unsupported expression %T", e)
+	}
+}
+
+// sortColumn is the expression used for ordering and keyset comparisons.
+// Nullable text columns are coalesced so NULLs sort like empty strings and
+// keyset conditions never compare against NULL.
+func (b *SQLBuilder) sortColumn(field Ident) (string, error) {
+	def, err := b.schema.lookup(field)
+	if err != nil {
+		return "", err
+	}
+	if def.Nullable && def.Type == TextField {
+		return "COALESCE(" + b.column(def) + ", '')", nil
+	}
+	return b.column(def), nil
+}
+
+// OrderBy compiles order terms into a comma separated ORDER BY list. With
+// reverse set every direction is flipped, which is used to page backwards.
+func (b *SQLBuilder) OrderBy(terms []OrderTerm, reverse bool) (string, error) {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		col, err := b.sortColumn(term.Field)
+		if err != nil {
+			return "", err
+		}
+		if term.Desc != reverse {
+			col += " DESC"
+		}
+		parts[i] = col
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// Keyset returns a condition selecting the rows that come after (or, with
+// before set, before) the row whose sort values are given, in the order
+// described by terms:
+//
+//	(a > $1) OR (a = $1 AND b > $2) OR ...
+//
+// Nullable columns other than text ones are not coalesced: a nil value
+// stands for NULL, which sorts after every other value as in PostgreSQL.
+func (b *SQLBuilder) Keyset(terms []OrderTerm, values []interface{}, before bool) (string, error) {
+	if len(terms) != len(values) {
+		return "", fmt.Errorf("keyset needs %d values, got %d", len(terms), len(values))
+	}
+	cols := make([]string, len(terms))
+	args := make([]string, len(terms))
+	for i, term := range terms {
+		col, err := b.sortColumn(term.Field)
+		if err != nil {
+			return "", err
+		}
+		cols[i] = col
+		if values[i] != nil {
+			args[i] = b.Arg(values[i])
+		}
+	}
+
+	var alternatives []string
+	for i, term := range terms {
+		var parts []string
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				parts = append(parts, cols[j]+" IS NULL")
+			} else {
+				parts = append(parts, cols[j]+" = "+args[j])
+			}
+		}
+		after := term.Desc == before
+		switch {
+		case values[i] == nil && after:
+			// Nothing sorts after NULL.
+			continue
+		case values[i] == nil:
+			parts = append(parts, cols[i]+" IS NOT NULL")
+		case after:
+			cond := cols[i] + " > " + args[i]
+			if def, _ := b.schema.lookup(term.Field); def.Nullable && def.Type != TextField {
+				cond = "(" + cond + " OR " + cols[i] + " IS NULL)"
+			}
+			parts = append(parts, cond)
+		default:
+			parts = append(parts, cols[i]+" < "+args[i])
+		}
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	if len(alternatives) == 0 {
+		return "FALSE", nil
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", nil
+}
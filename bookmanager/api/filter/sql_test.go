@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeysetNullable(t *testing.T) {
+	born := time.Date(1929, 10, 21, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		desc   bool
+		before bool
+		value  interface{}
+		want   string
+		args   []interface{}
+	}{
+		{"after a date", false, false, born,
+			"(((birth_date > $1 OR birth_date IS NULL)) OR (birth_date = $1 AND id > $2))", []interface{}{born, 7}},
+		{"before a date", false, true, born,
+			"((birth_date < $1) OR (birth_date = $1 AND id < $2))", []interface{}{born, 7}},
+		{"after NULL", false, false, nil,
+			"((birth_date IS NULL AND id > $1))", []interface{}{7}},
+		{"before NULL", false, true, nil,
+			"((birth_date IS NOT NULL) OR (birth_date IS NULL AND id < $1))", []interface{}{7}},
+		{"after NULL descending", true, false, nil,
+			"((birth_date IS NOT NULL) OR (birth_date IS NULL AND id > $1))", []interface{}{7}},
+		{"after a date descending", true, false, born,
+			"((birth_date < $1) OR (birth_date = $1 AND id > $2))", []interface{}{born, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewSQLBuilder(AuthorSchema, "")
+			terms := []OrderTerm{{Field: Field("birth_date"), Desc: tt.desc}, {Field: Field("id")}}
+			got, err := b.Keyset(terms, []interface{}{tt.value, 7}, tt.before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Keyset() = %s\nwant %s", got, tt.want)
+			}
+			if !slices.Equal(b.Args(), tt.args) {
+				t.Errorf("Args() = %v, want %v", b.Args(), tt.args)
+			}
+		})
+	}
+}
+
+func TestKeysetCoalescesNullableText(t *testing.T) {
+	b := NewSQLBuilder(BookSchema, "b")
+	got, err := b.Keyset([]OrderTerm{{Field: Field("genre")}, {Field: Field("id")}}, []interface{}{"", 3}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "((COALESCE(b.genre, '') > $1) OR (COALESCE(b.genre, '') = $1 AND b.id > $2))"
+	if got != want {
+		t.Errorf("Keyset() = %s\nwant %s", got, want)
+	}
+}
+
+func TestWhereParameterizes(t *testing.T) {
+	published := time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input string
+		want  string
+		args  []interface{}
+	}{
+		{"title = 'x''; DROP TABLE books; --'", "title = $1", []interface{}{"x'; DROP TABLE books; --"}},
+		{"edition != 2 OR genre = 'SciFi' AND published_date < '1950-01-01'",
+			"(edition <> $1 OR (genre = $2 AND published_date < $3))", []interface{}{2, "SciFi", published}},
+		{"NOT (genre IN ('a', 'b') OR title NOT LIKE '%x%')",
+			"(NOT (genre IN ($1, $2) OR title NOT LIKE $3))", []interface{}{"a", "b", "%x%"}},
+		{"edition NOT BETWEEN 1 AND 3 AND description IS NULL",
+			"(edition NOT BETWEEN $1 AND $2 AND description IS NULL)", []interface{}{1, 3}},
+		{"title ILIKE '%dune%'", "title ILIKE $1", []interface{}{"%dune%"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := NewSQLBuilder(BookSchema, "")
+			got, err := b.Where(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Where() = %s\nwant %s", got, tt.want)
+			}
+			if !slices.Equal(b.Args(), tt.args) {
+				t.Errorf("Args() = %v, want %v", b.Args(), tt.args)
+			}
+		})
+	}
+}
+
+func TestWhereQualifiesColumns(t *testing.T) {
+	expr, err := Parse("title = 'Dune' AND edition > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewSQLBuilder(BookSchema, "b")
+	b.Arg("taken")
+	got, err := b.Where(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(b.title = $2 AND b.edition > $3)"; got != want {
+		t.Errorf("Where() = %s, want %s", got, want)
+	}
+}
+
+func TestWhereRejects(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"pages = 1", "unknown book field"},
+		{"edition = 'one'", "edition expects a integer value"},
+		{"published_date = '1 Jan 1950'", "published_date expects a date (YYYY-MM-DD) value"},
+		{"edition LIKE '1%'", "LIKE is only supported on string fields"},
+		{"title = 1", "title expects a string value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = BookSchema.Validate(expr)
+			if err == nil || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("Validate(%q) = %v, want %q", tt.input, err, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"encoding/json"
+	"net/http"
+)
+
+type AuthorHandler struct {
+	db db.AuthorStore
+}
+
+func NewAuthorHandler(store db.AuthorStore) *AuthorHandler {
+	return &AuthorHandler{db: store}
+}
+
+// HandleAuthorBooks serves GET /api/v1/authors/{id}/books.
+func (h *AuthorHandler) HandleAuthorBooks(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid author ID")
+	if !ok {
+		return
+	}
+
+	books, err := h.db.ListBooksByAuthor(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"books": books})
+}
+
+// CreateAuthor serves POST /api/v1/authors.
+func (h *AuthorHandler) CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	var authorReq models.AuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&authorReq); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := authorReq.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	author, err := h.db.CreateAuthor(r.Context(), &authorReq)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, author.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(author)
+}
+
+// ListAuthors serves GET /api/v1/authors.
+func (h *AuthorHandler) ListAuthors(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r.URL.Query(), filter.AuthorSchema)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(opts.GroupBy) > 0 {
+		groups, err := h.db.GroupAuthors(r.Context(), opts)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		json.NewEncoder(w).Encode(groupsResponse("authors", opts.GroupBy, groups))
+		return
+	}
+
+	page, err := h.db.ListAuthors(r.Context(), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setPageLinks(w, r, page.NextCursor, page.PrevCursor)
+	json.NewEncoder(w).Encode(pageResponse("authors", page.Items, page.NextCursor, page.PrevCursor, page.Total))
+}
+
+// GetAuthor serves GET /api/v1/authors/{id}.
+func (h *AuthorHandler) GetAuthor(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid author ID")
+	if !ok {
+		return
+	}
+
+	author, err := h.db.GetAuthor(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setETag(w, author.Version)
+	if notModified(w, r, author.Version) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(author)
+}
+
+// UpdateAuthor serves PUT /api/v1/authors/{id}.
+func (h *AuthorHandler) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid author ID")
+	if !ok {
+		return
+	}
+
+	var authorReq models.AuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&authorReq); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := authorReq.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	author, err := h.db.UpdateAuthor(r.Context(), id, &authorReq, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, author.Version)
+	json.NewEncoder(w).Encode(author)
+}
+
+// PatchAuthor serves PATCH /api/v1/authors/{id}.
+func (h *AuthorHandler) PatchAuthor(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid author ID")
+	if !ok {
+		return
+	}
+
+	p := readPatch(w, r)
+	if p == nil {
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	author, err := h.db.PatchAuthor(r.Context(), id, p, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, author.Version)
+	json.NewEncoder(w).Encode(author)
+}
+
+// DeleteAuthor serves DELETE /api/v1/authors/{id}.
+func (h *AuthorHandler) DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid author ID")
+	if !ok {
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.db.DeleteAuthor(r.Context(), id, version); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,207 +1,279 @@
-package handlers
-
-import (
-	"bookmanager/api/db"
-	"bookmanager/api/models"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"strconv"
-	"strings"
-	"time"
-)
-
-type BookHandler struct {
-	db *db.BookDB
-}
-
-func NewBookHandler(db *db.BookDB) *BookHandler {
-	return &BookHandler{db: db}
-}
-
-func (h *BookHandler) HandleBooks(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		h.listBooks(w, r)
-	case http.MethodPost:
-		h.createBook(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (h *BookHandler) HandleBook(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Path[len("/api/v1/books/"):])
-	if err != nil {
-		http.Error(w, "Invalid book ID", http.StatusBadRequest)
-		return
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		h.getBook(w, r, id)
-	case http.MethodPut:
-		h.updateBook(w, r, id)
-	case http.MethodPatch:
-		h.patchBook(w, r, id)
-	case http.MethodDelete:
-		h.deleteBook(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (h *BookHandler) createBook(w http.ResponseWriter, r *http.Request) {
-	var bookReq models.BookRequest
-	if err := json.NewDecoder(r.Body).Decode(&bookReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if err := bookReq.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	book, err := h.db.CreateBook(&bookReq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(book)
-}
-
-func (h *BookHandler) listBooks(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	where := query.Get("where")
-	groupBy := query.Get("group_by")
-	orderBy := query.Get("order_by")
-	limit := query.Get("limit")
-	offset := query.Get("offset")
-
-	// Extract additional book-specific filters
-	author := query.Get("author")
-	genre := query.Get("genre")
-	publishedAfter := query.Get("published_after")
-	publishedBefore := query.Get("published_before")
-
-	// Build where clause combining manual where and filters
-	var whereClauses []string
-	if where != "" {
-		whereClauses = append(whereClauses, "("+where+")")
-	}
-	if author != "" {
-		whereClauses = append(whereClauses, fmt.Sprintf("author ILIKE '%%%s%%'", author))
-	}
-	if genre != "" {
-		whereClauses = append(whereClauses, fmt.Sprintf("genre = '%s'", genre))
-	}
-	if publishedAfter != "" {
-		whereClauses = append(whereClauses, fmt.Sprintf("published_date >= '%s'", publishedAfter))
-	}
-	if publishedBefore != "" {
-		whereClauses = append(whereClauses, fmt.Sprintf("published_date <= '%s'", publishedBefore))
-	}
-
-	combinedWhere := strings.Join(whereClauses, " AND ")
-
-	books, err := h.db.ListBooks(combinedWhere, groupBy, orderBy, limit, offset)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-
-	if groupBy != "" {
-		// Handle grouped response
-		if groups, ok := books.(map[string]int); ok {
-			json.NewEncoder(w).Encode(map[string]interface{}{"groups": groups})
-		} else {
-			http.Error(w, "unexpected group response type", http.StatusInternalServerError)
-		}
-	} else {
-		// Handle normal book list response
-		if bookList, ok := books.([]models.Book); ok {
-			json.NewEncoder(w).Encode(map[string]interface{}{"books": bookList})
-		} else {
-			http.Error(w, "unexpected book response type", http.StatusInternalServerError)
-		}
-	}
-}
-
-func (h *BookHandler) getBook(w http.ResponseWriter, r *http.Request, id int) {
-	book, err := h.db.GetBook(id)
-	if err != nil {
-		if err.Error() == "book not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(book)
-}
-
-func (h *BookHandler) updateBook(w http.ResponseWriter, r *http.Request, id int) {
-	var bookReq models.BookRequest
-	if err := json.NewDecoder(r.Body).Decode(&bookReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if err := bookReq.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	book, err := h.db.UpdateBook(id, &bookReq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(book)
-}
-
-func (h *BookHandler) patchBook(w http.ResponseWriter, r *http.Request, id int) {
-	var patch models.BookRequest
-	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if patch.PublishedDate != "" {
-		if _, err := time.Parse("2006-01-02", patch.PublishedDate); err != nil {
-			http.Error(w, "invalid published_date format", http.StatusBadRequest)
-			return
-		}
-	}
-
-	book, err := h.db.PatchBook(id, &patch)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(book)
-}
-
-func (h *BookHandler) deleteBook(w http.ResponseWriter, r *http.Request, id int) {
-	err := h.db.DeleteBook(id)
-	if err != nil {
-		if err.Error() == "book not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
-}
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"encoding/json"
+	"net/http"
+)
+
+type BookHandler struct {
+	db db.BookStore
+}
+
+func NewBookHandler(store db.BookStore) *BookHandler {
+	return &BookHandler{db: store}
+}
+
+// CreateBook serves POST /api/v1/books.
+func (h *BookHandler) CreateBook(w http.ResponseWriter, r *http.Request) {
+	var bookReq models.BookRequest
+	if err := json.NewDecoder(r.Body).Decode(&bookReq); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := bookReq.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	book, err := h.db.CreateBook(r.Context(), &bookReq)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, book.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(book)
+}
+
+// ListBooks serves GET /api/v1/books.
+func (h *BookHandler) ListBooks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts, err := parseListOptions(query, filter.BookSchema)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Combine the where expression with the book-specific filters
+	extra, err := db.BookFilter(query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts.Filter = filter.And(opts.Filter, extra)
+	include, err := parseInclude(query, "collections")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if len(opts.GroupBy) > 0 {
+		groups, err := h.db.GroupBooks(r.Context(), opts)
+		if err == nil && include["collections"] {
+			var books []*models.Book
+			for i := range groups {
+				books = append(books, bookPointers(groups[i].Items)...)
+			}
+			err = h.db.LoadCollections(r.Context(), books...)
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(groupsResponse("books", opts.GroupBy, groups))
+		return
+	}
+
+	page, err := h.db.ListBooks(r.Context(), opts)
+	if err == nil && include["collections"] {
+		err = h.db.LoadCollections(r.Context(), bookPointers(page.Items)...)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setPageLinks(w, r, page.NextCursor, page.PrevCursor)
+	json.NewEncoder(w).Encode(pageResponse("books", page.Items, page.NextCursor, page.PrevCursor, page.Total))
+}
+
+// SearchBooks serves GET /api/v1/books/search?q=, the full-text search.
+func (h *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q, opts, err := parseSearchOptions(query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	extra, err := db.BookFilter(query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts.Filter = filter.And(opts.Filter, extra)
+
+	results, total, err := h.db.SearchBooks(r.Context(), q, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results, "total": total})
+}
+
+// GetBook serves GET /api/v1/books/{id}.
+func (h *BookHandler) GetBook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid book ID")
+	if !ok {
+		return
+	}
+
+	include, err := parseInclude(r.URL.Query(), "collections")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	book, err := h.db.GetBook(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeBook(w, r, book, include)
+}
+
+// GetBookByISBN serves GET /api/v1/books/isbn/{isbn}. Either ISBN form is
+// accepted, with or without hyphens.
+func (h *BookHandler) GetBookByISBN(w http.ResponseWriter, r *http.Request) {
+	isbn, err := models.ParseISBN(r.PathValue("isbn"))
+	if err != nil {
+		v := &models.ValidationError{}
+		v.Add("isbn", err.Error())
+		writeError(w, r, v)
+		return
+	}
+	include, err := parseInclude(r.URL.Query(), "collections")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	book, err := h.db.GetBookByISBN(r.Context(), isbn)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeBook(w, r, book, include)
+}
+
+// writeBook answers a read of a single book. With include=collections the
+// book's collections are embedded; as they do not change the book's
+// version, such a response has no ETag.
+func (h *BookHandler) writeBook(w http.ResponseWriter, r *http.Request, book *models.Book, include map[string]bool) {
+	if include["collections"] {
+		if err := h.db.LoadCollections(r.Context(), book); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	} else {
+		setETag(w, book.Version)
+		if notModified(w, r, book.Version) {
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// UpdateBook serves PUT /api/v1/books/{id}.
+func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid book ID")
+	if !ok {
+		return
+	}
+
+	var bookReq models.BookRequest
+	if err := json.NewDecoder(r.Body).Decode(&bookReq); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := bookReq.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	book, err := h.db.UpdateBook(r.Context(), id, &bookReq, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, book.Version)
+	json.NewEncoder(w).Encode(book)
+}
+
+// PatchBook serves PATCH /api/v1/books/{id}.
+func (h *BookHandler) PatchBook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid book ID")
+	if !ok {
+		return
+	}
+
+	p := readPatch(w, r)
+	if p == nil {
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	book, err := h.db.PatchBook(r.Context(), id, p, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, book.Version)
+	json.NewEncoder(w).Encode(book)
+}
+
+// DeleteBook serves DELETE /api/v1/books/{id}.
+func (h *BookHandler) DeleteBook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid book ID")
+	if !ok {
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.DeleteBook(r.Context(), id, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bookPointers returns pointers to the books, so they can be changed in
+// place.
+func bookPointers(books []models.Book) []*models.Book {
+	ptrs := make([]*models.Book, len(books))
+	for i := range books {
+		ptrs[i] = &books[i]
+	}
+	return ptrs
+}
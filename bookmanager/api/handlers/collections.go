@@ -1,295 +1,618 @@
-package handlers
-
-import (
-	"bookmanager/api/db"
-	"bookmanager/api/models"
-	"encoding/json"
-	"net/http"
-	"strconv"
-	"strings"
-)
-
-type CollectionHandler struct {
-	db *db.CollectionDB
-}
-
-func NewCollectionHandler(db *db.CollectionDB) *CollectionHandler {
-	return &CollectionHandler{db: db}
-}
-
-func (h *CollectionHandler) HandleCollections(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		h.listCollections(w, r)
-	case http.MethodPost:
-		h.createCollection(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (h *CollectionHandler) HandleCollection(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Path[len("/api/v1/collections/"):])
-	if err != nil {
-		http.Error(w, "Invalid collection ID", http.StatusBadRequest)
-		return
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		h.getCollection(w, r, id)
-	case http.MethodPut:
-		h.updateCollection(w, r, id)
-	case http.MethodPatch:
-		h.patchCollection(w, r, id)
-	case http.MethodDelete:
-		h.deleteCollection(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-
-}
-
-func (h *CollectionHandler) HandleCollectionBooks(w http.ResponseWriter, r *http.Request) {
-
-	basePath := "/api/v1/collections-books/"
-	if !strings.HasPrefix(r.URL.Path, basePath) {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
-		return
-	}
-	path := strings.TrimPrefix(r.URL.Path, basePath)
-	parts := strings.Split(path, "/")
-	if len(parts) < 1 || parts[0] == "" {
-		http.Error(w, "Collection ID required", http.StatusBadRequest)
-		return
-	}
-
-	collectionID, err := strconv.Atoi(parts[0])
-	if err != nil {
-		http.Error(w, "Invalid collection ID", http.StatusBadRequest)
-		return
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		h.listBooksInCollection(w, r, collectionID)
-	case http.MethodPost:
-		h.addBookToCollection(w, r, collectionID)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (h *CollectionHandler) HandleCollectionBook(w http.ResponseWriter, r *http.Request) {
-
-	basePath := "/api/v1/collections-books/"
-	if !strings.HasPrefix(r.URL.Path, basePath) {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
-		return
-	}
-
-	path := strings.TrimPrefix(r.URL.Path, basePath)
-	parts := strings.Split(path, "/")
-	if len(parts) != 2 {
-		http.Error(w, "Invalid URL path format: expected /collections-books/{collection_id}/{book_id}", http.StatusBadRequest)
-		return
-	}
-
-	collectionID, err := strconv.Atoi(parts[0])
-	if err != nil {
-		http.Error(w, "Invalid collection ID: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	bookID, err := strconv.Atoi(parts[1])
-	if err != nil {
-		http.Error(w, "Invalid book ID: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if r.Method == http.MethodDelete {
-		h.removeBookFromCollection(w, r, collectionID, bookID)
-	} else {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (h *CollectionHandler) createCollection(w http.ResponseWriter, r *http.Request) {
-	var collectionReq models.CollectionRequest
-	if err := json.NewDecoder(r.Body).Decode(&collectionReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-	if err := collectionReq.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	collection, err := h.db.CreateCollection(&collectionReq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(collection)
-}
-
-func (h *CollectionHandler) listCollections(w http.ResponseWriter, r *http.Request) {
-
-	query := r.URL.Query()
-	where := query.Get("where")
-	groupBy := query.Get("group_by")
-	orderBy := query.Get("order_by")
-	limit := query.Get("limit")
-	offset := query.Get("offset")
-
-	collections, err := h.db.ListCollections(where, groupBy, orderBy, limit, offset)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if groupBy != "" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"groups": collections})
-	} else {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"collections": collections})
-	}
-}
-
-func (h *CollectionHandler) getCollection(w http.ResponseWriter, r *http.Request, id int) {
-	collection, err := h.db.GetCollection(id)
-	if err != nil {
-		if err.Error() == "collection not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collection)
-}
-
-func (h *CollectionHandler) updateCollection(w http.ResponseWriter, r *http.Request, id int) {
-	var collectionReq models.CollectionRequest
-	if err := json.NewDecoder(r.Body).Decode(&collectionReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if collectionReq.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
-		return
-	}
-
-	collection, err := h.db.UpdateCollection(id, &collectionReq)
-	if err != nil {
-		if err.Error() == "collection not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collection)
-}
-
-func (h *CollectionHandler) patchCollection(w http.ResponseWriter, r *http.Request, id int) {
-	var patch models.CollectionRequest
-	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	collection, err := h.db.PatchCollection(id, &patch)
-	if err != nil {
-		if err.Error() == "collection not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collection)
-}
-
-func (h *CollectionHandler) deleteCollection(w http.ResponseWriter, r *http.Request, id int) {
-	err := h.db.DeleteCollection(id)
-	if err != nil {
-		if err.Error() == "collection not found" {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (h *CollectionHandler) addBookToCollection(w http.ResponseWriter, r *http.Request, collectionID int) {
-	var req struct {
-		BookID int `json:"book_id"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.BookID == 0 {
-		http.Error(w, "book_id is required", http.StatusBadRequest)
-		return
-	}
-
-	err := h.db.AddBookToCollection(collectionID, req.BookID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(&req)
-	w.WriteHeader(http.StatusCreated)
-}
-
-func (h *CollectionHandler) removeBookFromCollection(w http.ResponseWriter, r *http.Request, collectionID, bookID int) {
-	err := h.db.RemoveBookFromCollection(collectionID, bookID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (h *CollectionHandler) listBooksInCollection(w http.ResponseWriter, r *http.Request, collectionID int) {
-	books, err := h.db.ListBooksInCollection(collectionID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"books": books})
-}
-
-func (h *CollectionHandler) HandleCollectionBooksRoutes(w http.ResponseWriter, r *http.Request) {
-	cleanPath := strings.TrimPrefix(r.URL.Path, "/api/v1/collections-books")
-	cleanPath = strings.TrimPrefix(cleanPath, "/")
-	parts := strings.Split(cleanPath, "/")
-
-	if len(parts) >= 2 && parts[1] != "" {
-		h.HandleCollectionBook(w, r)
-	} else if len(parts) == 1 && parts[0] != "" {
-		h.HandleCollectionBooks(w, r)
-	} else {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
-	}
-}
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type CollectionHandler struct {
+	db db.CollectionStore
+}
+
+func NewCollectionHandler(store db.CollectionStore) *CollectionHandler {
+	return &CollectionHandler{db: store}
+}
+
+// CreateCollection serves POST /api/v1/collections.
+func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	var collectionReq models.CollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&collectionReq); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := collectionReq.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	collection, err := h.db.CreateCollection(r.Context(), &collectionReq)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, collection.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// ListCollections serves GET /api/v1/collections.
+func (h *CollectionHandler) ListCollections(w http.ResponseWriter, r *http.Request) {
+
+	opts, err := parseListOptions(r.URL.Query(), filter.CollectionSchema)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(opts.GroupBy) > 0 {
+		groups, err := h.db.GroupCollections(r.Context(), opts)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		json.NewEncoder(w).Encode(groupsResponse("collections", opts.GroupBy, groups))
+		return
+	}
+
+	page, err := h.db.ListCollections(r.Context(), opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setPageLinks(w, r, page.NextCursor, page.PrevCursor)
+	json.NewEncoder(w).Encode(pageResponse("collections", page.Items, page.NextCursor, page.PrevCursor, page.Total))
+}
+
+// GetCollection serves GET /api/v1/collections/{id}.
+func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	collection, err := h.db.GetCollection(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setETag(w, collection.Version)
+	if notModified(w, r, collection.Version) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collection)
+}
+
+// UpdateCollection serves PUT /api/v1/collections/{id}.
+func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	var collectionReq models.CollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&collectionReq); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := collectionReq.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	collection, err := h.db.UpdateCollection(r.Context(), id, &collectionReq, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, collection.Version)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// PatchCollection serves PATCH /api/v1/collections/{id}.
+func (h *CollectionHandler) PatchCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	p := readPatch(w, r)
+	if p == nil {
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	collection, err := h.db.PatchCollection(r.Context(), id, p, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, collection.Version)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// DeleteCollection serves DELETE /api/v1/collections/{id}. The children
+// parameter says what happens to its sub-collections: rehome (the default)
+// moves them up to its parent, cascade moves them to the trash with it.
+func (h *CollectionHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	cascade := false
+	switch r.URL.Query().Get("children") {
+	case "", models.DeleteRehome:
+	case models.DeleteCascade:
+		cascade = true
+	default:
+		writeError(w, r, &models.ValidationError{Errors: []models.FieldError{
+			{Field: "children", Message: "must be rehome or cascade"},
+		}})
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.DeleteCollection(r.Context(), id, version, cascade)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddBookToCollection serves POST /api/v1/collections/{id}/books.
+func (h *CollectionHandler) AddBookToCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	var req models.CollectionBookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err := h.db.AddBookToCollection(r.Context(), collectionID, req.BookID, req.Position)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&req)
+}
+
+// RemoveBookFromCollection serves DELETE
+// /api/v1/collections/{id}/books/{bookId}.
+func (h *CollectionHandler) RemoveBookFromCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+	bookID, ok := pathInt(w, r, "bookId", "Invalid book ID")
+	if !ok {
+		return
+	}
+
+	err := h.db.RemoveBookFromCollection(r.Context(), collectionID, bookID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddBooksToCollection serves POST /api/v1/collections/{id}/books/bulk-add
+// and answers with the outcome for each book.
+func (h *CollectionHandler) AddBooksToCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	var books models.BookSelection
+	if err := json.NewDecoder(r.Body).Decode(&books); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := books.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	report, err := h.db.AddBooksToCollection(r.Context(), collectionID, books)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RemoveBooksFromCollection serves POST
+// /api/v1/collections/{id}/books/bulk-remove and answers with the outcome
+// for each book.
+func (h *CollectionHandler) RemoveBooksFromCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	var books models.BookSelection
+	if err := json.NewDecoder(r.Body).Decode(&books); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := books.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	report, err := h.db.RemoveBooksFromCollection(r.Context(), collectionID, books)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// TransferBooks serves POST /api/v1/collections/{id}/books/transfer, which
+// copies or moves books to another collection, and answers with the
+// outcome for each book.
+func (h *CollectionHandler) TransferBooks(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	var transfer models.BookTransfer
+	if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := transfer.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	report, err := h.db.TransferBooks(r.Context(), collectionID, transfer)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// MoveBookInCollection serves POST
+// /api/v1/collections/{id}/books/{bookId}/move and answers with the books
+// of the collection in their new order.
+func (h *CollectionHandler) MoveBookInCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+	bookID, ok := pathInt(w, r, "bookId", "Invalid book ID")
+	if !ok {
+		return
+	}
+
+	var move models.BookMove
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := move.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.db.MoveBookInCollection(r.Context(), collectionID, bookID, move); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeCollectionBooks(w, r, collectionID)
+}
+
+// ReorderCollectionBooks serves PUT /api/v1/collections/{id}/books/order
+// and answers with the books of the collection in their new order.
+func (h *CollectionHandler) ReorderCollectionBooks(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	var req models.CollectionOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.db.ReorderCollection(r.Context(), collectionID, req.BookIDs); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeCollectionBooks(w, r, collectionID)
+}
+
+// ListBooksInCollection serves GET /api/v1/collections/{id}/books. With
+// recursive=true the books of its sub-collections follow its own, each book
+// once.
+func (h *CollectionHandler) ListBooksInCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	recursive := false
+	if raw := r.URL.Query().Get("recursive"); raw != "" {
+		var err error
+		recursive, err = strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, r, &models.ValidationError{Errors: []models.FieldError{
+				{Field: "recursive", Message: "must be true or false"},
+			}})
+			return
+		}
+	}
+	if !recursive {
+		h.writeCollectionBooks(w, r, collectionID)
+		return
+	}
+
+	books, err := h.db.ListBooksInCollectionTree(r.Context(), collectionID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"books": books})
+}
+
+// writeCollectionBooks answers with the books of a collection, in order.
+func (h *CollectionHandler) writeCollectionBooks(w http.ResponseWriter, r *http.Request, collectionID int) {
+	books, err := h.db.ListBooksInCollection(r.Context(), collectionID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"books": books})
+}
+
+// ListBookCollections serves GET /api/v1/books/{id}/collections, which
+// takes the list parameters of GET /api/v1/collections except group_by.
+func (h *CollectionHandler) ListBookCollections(w http.ResponseWriter, r *http.Request) {
+	bookID, ok := pathInt(w, r, "id", "Invalid book ID")
+	if !ok {
+		return
+	}
+	opts, err := parseListOptions(r.URL.Query(), filter.CollectionSchema)
+	if err == nil && len(opts.GroupBy) > 0 {
+		err = &models.ValidationError{Errors: []models.FieldError{
+			{Field: "group_by", Message: "is not supported here"},
+		}}
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, err := h.db.ListBookCollections(r.Context(), bookID, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setPageLinks(w, r, page.NextCursor, page.PrevCursor)
+	json.NewEncoder(w).Encode(pageResponse("collections", page.Items, page.NextCursor, page.PrevCursor, page.Total))
+}
+
+// ListCollectionSet serves GET /api/v1/collections/set, the books of the
+// set expression expr over collections, e.g. "(1 UNION 2) EXCEPT 3". It
+// takes the list parameters of GET /api/v1/books except group_by.
+func (h *CollectionHandler) ListCollectionSet(w http.ResponseWriter, r *http.Request) {
+	v := &models.ValidationError{}
+	set := parseSet(r.URL.Query().Get("expr"), v)
+	if err := v.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	opts, err := parseListOptions(r.URL.Query(), filter.BookSchema)
+	if err == nil && len(opts.GroupBy) > 0 {
+		err = &models.ValidationError{Errors: []models.FieldError{
+			{Field: "group_by", Message: "is not supported here"},
+		}}
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page, err := h.db.ListCollectionSetBooks(r.Context(), set, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setPageLinks(w, r, page.NextCursor, page.PrevCursor)
+	json.NewEncoder(w).Encode(pageResponse("books", page.Items, page.NextCursor, page.PrevCursor, page.Total))
+}
+
+// CreateCollectionFromSet serves POST /api/v1/collections/set, which saves
+// the books of a set expression as a new manual collection.
+func (h *CollectionHandler) CreateCollectionFromSet(w http.ResponseWriter, r *http.Request) {
+	var setReq models.CollectionSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&setReq); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := setReq.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	v := &models.ValidationError{}
+	set := parseSet(setReq.Expr, v)
+	var orderBy []filter.OrderTerm
+	if setReq.OrderBy != "" {
+		terms, err := filter.ParseOrderBy(setReq.OrderBy, filter.BookSchema)
+		if err != nil {
+			v.Add("order_by", err.Error())
+		}
+		orderBy = terms
+	}
+	if err := v.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	collection, err := h.db.CreateCollectionFromSet(r.Context(), set, orderBy, &setReq.CollectionRequest)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, collection.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// parseSet parses the set expression expr, reporting problems on expr.
+func parseSet(expr string, v *models.ValidationError) filter.SetExpr {
+	if expr == "" {
+		v.Add("expr", "is required")
+		return nil
+	}
+	set, err := filter.ParseSet(expr)
+	if err != nil {
+		v.Add("expr", err.Error())
+		return nil
+	}
+	return set
+}
+
+// CollectionTree serves GET /api/v1/collections/tree, all collections as a
+// forest of top-level collections and their sub-collections.
+func (h *CollectionHandler) CollectionTree(w http.ResponseWriter, r *http.Request) {
+	nodes, err := h.db.CollectionTree(r.Context(), 0)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"collections": nodes})
+}
+
+// GetCollectionTree serves GET /api/v1/collections/{id}/tree, a collection
+// with its sub-collections.
+func (h *CollectionHandler) GetCollectionTree(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	nodes, err := h.db.CollectionTree(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nodes[0])
+}
+
+// CollectionBreadcrumb serves GET /api/v1/collections/{id}/breadcrumb, the
+// path from the top-level collection down to the collection itself.
+func (h *CollectionHandler) CollectionBreadcrumb(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	path, err := h.db.CollectionBreadcrumb(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"collections": path})
+}
+
+// MoveCollection serves POST /api/v1/collections/{id}/move, which makes
+// the collection a sub-collection of another one or a top-level one.
+func (h *CollectionHandler) MoveCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	var move models.CollectionMove
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := move.Validate(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	collection, err := h.db.MoveCollection(r.Context(), id, move.ParentID, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, collection.Version)
+	json.NewEncoder(w).Encode(collection)
+}
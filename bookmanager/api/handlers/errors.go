@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/patch"
+	"bookmanager/api/utils"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []models.FieldError `json:"errors,omitempty"`
+}
+
+const problemTypePrefix = "urn:bookmanager:problem:"
+
+var problemTypes = map[int]string{
+	http.StatusBadRequest:            "bad-request",
+	http.StatusNotFound:              "not-found",
+	http.StatusMethodNotAllowed:      "method-not-allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition-failed",
+	http.StatusRequestEntityTooLarge: "too-large",
+	http.StatusUnsupportedMediaType:  "unsupported-media-type",
+	http.StatusUnprocessableEntity:   "constraint-violation",
+	http.StatusInternalServerError:   "internal-error",
+}
+
+// errorStatus maps errors returned by the stores to HTTP status codes.
+func errorStatus(err error) int {
+	var filterErr *filter.Error
+	var validationErr *models.ValidationError
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrConflict), errors.Is(err, patch.ErrTestFailed):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, patch.ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, db.ErrValidation), errors.Is(err, db.ErrForeignKey):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &filterErr), errors.As(err, &validationErr):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError renders err as a problem document. Validation errors list
+// every offending field; unexpected errors are logged and not exposed.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	p := newProblem(r, status, err.Error())
+
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		p.Type = problemTypePrefix + "validation-error"
+		p.Title = "Validation failed"
+		p.Errors = validationErr.Errors
+	}
+	if status == http.StatusInternalServerError {
+		slog.Error("Request failed", "request_id", p.RequestID, "method", r.Method, "path", r.URL.Path, "error", err)
+		p.Detail = "An unexpected error occurred"
+	}
+	writeProblem(w, p)
+}
+
+// writeErrorStatus renders a problem with an explicit status and detail, for
+// errors detected by the handlers themselves.
+func writeErrorStatus(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, newProblem(r, status, detail))
+}
+
+func newProblem(r *http.Request, status int, detail string) *Problem {
+	p := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.RequestURI(),
+		RequestID: utils.RequestID(r.Context()),
+	}
+	if t, ok := problemTypes[status]; ok {
+		p.Type = problemTypePrefix + t
+	}
+	return p
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
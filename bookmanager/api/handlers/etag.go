@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Books and collections are tagged with their version: the ETag of version 3
+// is "3". Clients send it back in If-Match to make a write conditional, or in
+// If-None-Match to revalidate a cached read.
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion returns the version a write requires through If-Match:
+// db.AnyVersion when the header is missing or "*", and -1 for a tag that
+// names no version (including weak tags), which never matches.
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return db.AnyVersion, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, &models.ValidationError{Errors: []models.FieldError{
+			{Field: "If-Match", Message: "must be a single entity tag"},
+		}}
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return -1, nil
+	}
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version < 1 {
+		return -1, nil
+	}
+	return version, nil
+}
+
+// notModified answers with 304 Not Modified when If-None-Match lists the
+// current tag. Reads compare tags weakly, so W/"3" matches "3".
+func notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/utils"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// exportFormat is a format books can be exported in.
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer) bookWriter
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":    {"text/csv; charset=utf-8", ".csv", newCSVWriter},
+	"json":   {"application/json", ".json", newJSONWriter},
+	"ndjson": {"application/x-ndjson", ".ndjson", newNDJSONWriter},
+	"bibtex": {"application/x-bibtex; charset=utf-8", ".bib", newBibTeXWriter},
+	"ris":    {"application/x-research-info-systems; charset=utf-8", ".ris", newRISWriter},
+}
+
+// ExportBooks serves GET /api/v1/books/export?format=, which exports the
+// books matching the filters of GET /api/v1/books, without pagination.
+func (h *BookHandler) ExportBooks(w http.ResponseWriter, r *http.Request) {
+
+	format, opts, err := parseExportOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeExport(w, r, format, "books", func(fn func(*models.Book) error) error {
+		return h.db.ExportBooks(r.Context(), opts, fn)
+	})
+}
+
+// ExportCollectionBooks serves GET /api/v1/collections/{id}/books/export,
+// which works like ExportBooks for the books of a collection.
+func (h *CollectionHandler) ExportCollectionBooks(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	format, opts, err := parseExportOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	collection, err := h.db.GetCollection(r.Context(), collectionID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	name := exportFilename(collection.Name)
+	if name == "" {
+		name = fmt.Sprintf("collection-%d", collectionID)
+	}
+	writeExport(w, r, format, name, func(fn func(*models.Book) error) error {
+		return h.db.ExportCollectionBooks(r.Context(), collectionID, opts, fn)
+	})
+}
+
+// parseExportOptions reads format and the filters of a book list: where,
+// order_by and the book specific filters.
+func parseExportOptions(query url.Values) (string, db.ListOptions, error) {
+	var opts db.ListOptions
+	v := &models.ValidationError{}
+
+	format := query.Get("format")
+	if _, ok := exportFormats[format]; !ok {
+		v.Add("format", "must be csv, json, ndjson, bibtex or ris")
+	}
+	opts.Filter = parseWhere(query, filter.BookSchema, v)
+	opts.OrderBy = parseOrderBy(query, filter.BookSchema, v)
+	if err := v.Err(); err != nil {
+		return "", opts, err
+	}
+
+	extra, err := db.BookFilter(query)
+	if err != nil {
+		return "", opts, err
+	}
+	opts.Filter = filter.And(opts.Filter, extra)
+	return format, opts, nil
+}
+
+// exportFilename turns a name into a file name without an extension.
+func exportFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, name)
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+	return strings.Trim(name, "-")
+}
+
+// writeExport streams the books passed to fn by export as an attachment.
+// The response starts with the first book, so an error before it is
+// reported as usual; after it, the connection is cut short so the client
+// does not take a partial export for a complete one.
+func writeExport(w http.ResponseWriter, r *http.Request, format, name string, export func(fn func(*models.Book) error) error) {
+	f := exportFormats[format]
+	var out bookWriter
+	start := func() {
+		w.Header().Set("Content-Type", f.contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + f.extension}))
+		// Large exports can take longer than the server's write timeout.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		out = f.newWriter(w)
+	}
+
+	err := export(func(book *models.Book) error {
+		if out == nil {
+			start()
+		}
+		return out.WriteBook(book)
+	})
+	if err != nil && out == nil {
+		writeError(w, r, err)
+		return
+	}
+	if err == nil {
+		if out == nil {
+			start()
+		}
+		err = out.Close()
+	}
+	if err != nil {
+		if r.Context().Err() == nil {
+			slog.Error("Export failed", "request_id", utils.RequestID(r.Context()), "method", r.Method, "path", r.URL.Path, "error", err)
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// bookPeople returns the names of the people credited with role, falling
+// back to Book.Author for the authors of books without credits.
+func bookPeople(book *models.Book, role string) []string {
+	var names []string
+	for _, a := range book.Authors {
+		if a.Role == role {
+			names = append(names, a.Name)
+		}
+	}
+	if len(book.Authors) == 0 && role == models.RoleAuthor && book.Author != "" {
+		names = strings.Split(book.Author, " & ")
+	}
+	return names
+}
+
+// publishedDate returns the YYYY-MM-DD date a book was published.
+func publishedDate(book *models.Book) string {
+	if len(book.PublishedDate) < len("2006-01-02") {
+		return book.PublishedDate
+	}
+	return book.PublishedDate[:len("2006-01-02")]
+}
+
+// oneLine collapses the whitespace of s, including line breaks.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// editionString returns the edition, or "" when it is not known.
+func editionString(book *models.Book) string {
+	if book.Edition <= 0 {
+		return ""
+	}
+	return strconv.Itoa(book.Edition)
+}
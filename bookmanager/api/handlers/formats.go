@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bookmanager/api/models"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// bookWriter writes the books of an export one at a time. Close finishes
+// the file; it has to be called even if no book was written.
+type bookWriter interface {
+	WriteBook(book *models.Book) error
+	Close() error
+}
+
+// csvWriter writes a header row and a row per book. The columns are read
+// back by the CSV import.
+type csvWriter struct {
+	w   *csv.Writer
+	err error
+}
+
+var csvColumns = []string{"id", "title", "author", "published_date", "edition", "description", "genre", "isbn13", "isbn10"}
+
+func newCSVWriter(w io.Writer) bookWriter {
+	c := &csvWriter{w: csv.NewWriter(w)}
+	c.err = c.w.Write(csvColumns)
+	return c
+}
+
+func (c *csvWriter) WriteBook(book *models.Book) error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(book.ID),
+		book.Title,
+		book.Author,
+		publishedDate(book),
+		editionString(book),
+		book.Description,
+		book.Genre,
+		book.ISBN13,
+		book.ISBN10,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonWriter writes a JSON array of books as returned by GET
+// /api/v1/books/{id}.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONWriter(w io.Writer) bookWriter {
+	return &jsonWriter{w: w}
+}
+
+func (j *jsonWriter) WriteBook(book *models.Book) error {
+	data, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if j.count == 0 {
+		sep = "[\n"
+	}
+	j.count++
+	_, err = fmt.Fprintf(j.w, "%s%s", sep, data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
+
+// ndjsonWriter writes a book per line.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) bookWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) WriteBook(book *models.Book) error {
+	return n.enc.Encode(book)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// bibtexWriter writes a @book entry per book, using the biblatex fields for
+// translators, illustrators and the full date. Entry keys are made of the
+// first author's last name, the year and the book ID, e.g. herbert1965-1.
+type bibtexWriter struct {
+	w *bufio.Writer
+}
+
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+func newBibTeXWriter(w io.Writer) bookWriter {
+	return &bibtexWriter{w: bufio.NewWriter(w)}
+}
+
+func (b *bibtexWriter) WriteBook(book *models.Book) error {
+	date := publishedDate(book)
+	year := date
+	if len(year) > 4 {
+		year = year[:4]
+	}
+
+	fmt.Fprintf(b.w, "@book{%s,\n", bibtexKey(book, year))
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(b.w, "  %s = {%s},\n", name, bibtexEscaper.Replace(oneLine(value)))
+		}
+	}
+	names := func(name, role string) {
+		people := bookPeople(book, role)
+		for i, p := range people {
+			people[i] = bibtexEscaper.Replace(oneLine(p))
+			// "and" separates names, so a name containing it is kept whole.
+			if strings.Contains(" "+strings.ToLower(people[i])+" ", " and ") {
+				people[i] = "{" + people[i] + "}"
+			}
+		}
+		if len(people) > 0 {
+			fmt.Fprintf(b.w, "  %s = {%s},\n", name, strings.Join(people, " and "))
+		}
+	}
+
+	field("title", book.Title)
+	names("author", models.RoleAuthor)
+	names("editor", models.RoleEditor)
+	names("translator", models.RoleTranslator)
+	names("illustrator", models.RoleIllustrator)
+	field("date", date)
+	field("year", year)
+	field("edition", editionString(book))
+	field("isbn", book.ISBN13)
+	field("keywords", book.Genre)
+	field("abstract", book.Description)
+	_, err := b.w.WriteString("}\n\n")
+	return err
+}
+
+func (b *bibtexWriter) Close() error {
+	return b.w.Flush()
+}
+
+// bibtexKey builds the entry key of a book from ASCII letters only, which
+// is what BibTeX accepts everywhere.
+func bibtexKey(book *models.Book, year string) string {
+	name := "book"
+	if authors := bookPeople(book, models.RoleAuthor); len(authors) > 0 {
+		words := strings.Fields(authors[0])
+		if len(words) > 0 {
+			last := strings.Map(func(r rune) rune {
+				switch {
+				case r >= 'a' && r <= 'z':
+					return r
+				case r >= 'A' && r <= 'Z':
+					return r + 'a' - 'A'
+				}
+				return -1
+			}, words[len(words)-1])
+			if last != "" {
+				name = last
+			}
+		}
+	}
+	return fmt.Sprintf("%s%s-%d", name, year, book.ID)
+}
+
+// risWriter writes a BOOK record per book in the RIS format, with the CRLF
+// line endings it prescribes. RIS has no tag for illustrators.
+type risWriter struct {
+	w *bufio.Writer
+}
+
+func newRISWriter(w io.Writer) bookWriter {
+	return &risWriter{w: bufio.NewWriter(w)}
+}
+
+func (r *risWriter) WriteBook(book *models.Book) error {
+	tag := func(tag, value string) {
+		if value = oneLine(value); value != "" {
+			fmt.Fprintf(r.w, "%s  - %s\r\n", tag, value)
+		}
+	}
+	people := func(t, role string) {
+		for _, name := range bookPeople(book, role) {
+			tag(t, name)
+		}
+	}
+
+	date := publishedDate(book)
+	tag("TY", "BOOK")
+	tag("ID", strconv.Itoa(book.ID))
+	tag("TI", book.Title)
+	people("AU", models.RoleAuthor)
+	people("ED", models.RoleEditor)
+	people("A4", models.RoleTranslator)
+	if len(date) == len("2006-01-02") {
+		tag("PY", date[:4])
+		tag("DA", strings.ReplaceAll(date, "-", "/"))
+	}
+	tag("ET", editionString(book))
+	tag("SN", book.ISBN13)
+	tag("KW", book.Genre)
+	tag("AB", book.Description)
+	_, err := r.w.WriteString("ER  - \r\n\r\n")
+	return err
+}
+
+func (r *risWriter) Close() error {
+	return r.w.Flush()
+}
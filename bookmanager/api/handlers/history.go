@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bookmanager/api/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// HandleBookHistory serves GET /api/v1/books/{id}/history.
+func (h *BookHandler) HandleBookHistory(w http.ResponseWriter, r *http.Request) {
+	serveHistory(w, r, "book", h.db.BookHistory)
+}
+
+// HandleBookRevision serves GET /api/v1/books/{id}/history/{rev}.
+func (h *BookHandler) HandleBookRevision(w http.ResponseWriter, r *http.Request) {
+	serveRevision(w, r, "book", h.db.BookRevision)
+}
+
+// RevertBook serves POST /api/v1/books/{id}/history/{rev}/revert.
+func (h *BookHandler) RevertBook(w http.ResponseWriter, r *http.Request) {
+	serveRevert(w, r, "book", h.db.RevertBook, func(book *models.Book) int { return book.Version })
+}
+
+// HandleCollectionHistory serves GET /api/v1/collections/{id}/history.
+func (h *CollectionHandler) HandleCollectionHistory(w http.ResponseWriter, r *http.Request) {
+	serveHistory(w, r, "collection", h.db.CollectionHistory)
+}
+
+// HandleCollectionRevision serves GET /api/v1/collections/{id}/history/{rev}.
+func (h *CollectionHandler) HandleCollectionRevision(w http.ResponseWriter, r *http.Request) {
+	serveRevision(w, r, "collection", h.db.CollectionRevision)
+}
+
+// RevertCollection serves POST /api/v1/collections/{id}/history/{rev}/revert.
+func (h *CollectionHandler) RevertCollection(w http.ResponseWriter, r *http.Request) {
+	serveRevert(w, r, "collection", h.db.RevertCollection, func(c *models.Collection) int { return c.Version })
+}
+
+func serveHistory(w http.ResponseWriter, r *http.Request, kind string, list func(context.Context, int) ([]models.Revision, error)) {
+	id, ok := pathInt(w, r, "id", "Invalid "+kind+" ID")
+	if !ok {
+		return
+	}
+
+	revisions, err := list(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"revisions": revisions})
+}
+
+func serveRevision(w http.ResponseWriter, r *http.Request, kind string, get func(context.Context, int, int) (*models.Revision, error)) {
+	id, ok := pathInt(w, r, "id", "Invalid "+kind+" ID")
+	if !ok {
+		return
+	}
+	rev, ok := pathInt(w, r, "rev", "Invalid revision")
+	if !ok {
+		return
+	}
+
+	revision, err := get(r.Context(), id, rev)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// serveRevert reverts to the revision in the path. If-Match guards against
+// reverting over changes made since the history was read.
+func serveRevert[T any](w http.ResponseWriter, r *http.Request, kind string, revert func(context.Context, int, int, int) (T, error), version func(T) int) {
+	id, ok := pathInt(w, r, "id", "Invalid "+kind+" ID")
+	if !ok {
+		return
+	}
+	rev, ok := pathInt(w, r, "rev", "Invalid revision")
+	if !ok {
+		return
+	}
+	ifVersion, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	reverted, err := revert(r.Context(), id, rev, ifVersion)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, version(reverted))
+	json.NewEncoder(w).Encode(reverted)
+}
+
+// pathInt parses the named path value, answering 400 with invalid if it is
+// not a number.
+func pathInt(w http.ResponseWriter, r *http.Request, name, invalid string) (int, bool) {
+	n, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		writeErrorStatus(w, r, http.StatusBadRequest, invalid)
+		return 0, false
+	}
+	return n, true
+}
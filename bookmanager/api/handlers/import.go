@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/models"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize bounds the body of an import, which is read as a whole.
+const maxImportSize = 32 << 20
+
+// importFormats maps the media types an import is recognised by to its
+// format.
+var importFormats = map[string]string{
+	"text/csv":             "csv",
+	"application/json":     "json",
+	"application/x-ndjson": "ndjson",
+	"application/ndjson":   "ndjson",
+}
+
+// importFields are the book fields a CSV column can be mapped to; isbn
+// takes either ISBN form.
+var importFields = map[string]bool{
+	"title":          true,
+	"author":         true,
+	"published_date": true,
+	"edition":        true,
+	"description":    true,
+	"genre":          true,
+	"isbn":           true,
+	"isbn10":         true,
+	"isbn13":         true,
+}
+
+// ImportBooks serves POST /api/v1/books/import. The body is CSV with a
+// header, a JSON array of books or NDJSON, one book per line.
+func (h *BookHandler) ImportBooks(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	v := &models.ValidationError{}
+	opts := db.ImportOptions{OnDuplicate: db.DuplicateFail}
+	if raw := query.Get("dry_run"); raw != "" {
+		dryRun, err := strconv.ParseBool(raw)
+		if err != nil {
+			v.Add("dry_run", "must be true or false")
+		}
+		opts.DryRun = dryRun
+	}
+	switch raw := query.Get("on_duplicate"); raw {
+	case "":
+	case db.DuplicateFail, db.DuplicateSkip, db.DuplicateUpsert:
+		opts.OnDuplicate = raw
+	default:
+		v.Add("on_duplicate", "must be fail, skip or upsert")
+	}
+
+	format := query.Get("format")
+	switch format {
+	case "":
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format = importFormats[mediaType]
+		if format == "" {
+			writeErrorStatus(w, r, http.StatusUnsupportedMediaType,
+				"Content-Type must be text/csv, application/json or application/x-ndjson, or the format parameter must be set")
+			return
+		}
+	case "csv", "json", "ndjson":
+	default:
+		v.Add("format", "must be csv, json or ndjson")
+	}
+
+	mapping, err := parseMapping(query.Get("mapping"))
+	if err != nil {
+		v.Add("mapping", err.Error())
+	} else if len(mapping) > 0 && format != "csv" {
+		v.Add("mapping", "only applies to csv")
+	}
+	if err := v.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	rows, err := readImport(http.MaxBytesReader(w, r.Body, maxImportSize), format, mapping)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeErrorStatus(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import must not exceed %d MB", maxImportSize>>20))
+			return
+		}
+		writeErrorStatus(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		writeErrorStatus(w, r, http.StatusBadRequest, "Import contains no books")
+		return
+	}
+
+	report, err := h.db.ImportBooks(r.Context(), rows, opts)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Failed > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseMapping reads a CSV column mapping such as "Book Title:title,
+// Writer:author". Columns are matched case-insensitively.
+func parseMapping(raw string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(raw) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		i := strings.LastIndex(pair, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("%q must be column:field", strings.TrimSpace(pair))
+		}
+		column, field := strings.ToLower(strings.TrimSpace(pair[:i])), strings.TrimSpace(pair[i+1:])
+		if !importFields[field] {
+			return nil, fmt.Errorf("unknown book field %q", field)
+		}
+		mapping[column] = field
+	}
+	return mapping, nil
+}
+
+func readImport(r io.Reader, format string, mapping map[string]string) ([]db.ImportRow, error) {
+	switch format {
+	case "csv":
+		return readCSVImport(r, mapping)
+	case "json":
+		return readJSONImport(r)
+	default:
+		return readNDJSONImport(r)
+	}
+}
+
+// readCSVImport reads CSV with a header row. Columns are mapped to fields by
+// mapping, or else by their name, e.g. "Published Date" to published_date;
+// other columns are ignored.
+func readCSVImport(r io.Reader, mapping map[string]string) ([]db.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	fields := make([]string, len(header))
+	mapped := make(map[string]string)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		field, ok := mapping[column]
+		if !ok {
+			field = strings.NewReplacer(" ", "_", "-", "_").Replace(column)
+		}
+		if !importFields[field] {
+			continue
+		}
+		if other, ok := mapped[field]; ok {
+			return nil, fmt.Errorf("columns %q and %q are both mapped to %s", other, header[i], field)
+		}
+		mapped[field] = header[i]
+		fields[i] = field
+	}
+	for column := range mapping {
+		found := false
+		for _, h := range header {
+			found = found || strings.EqualFold(strings.TrimSpace(h), column)
+		}
+		if !found {
+			return nil, fmt.Errorf("mapped column %q is not in the header", column)
+		}
+	}
+
+	var rows []db.ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		rows = append(rows, csvBook(len(rows)+1, fields, record))
+	}
+}
+
+func csvBook(row int, fields, record []string) db.ImportRow {
+	book := &models.BookRequest{}
+	v := &models.ValidationError{}
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		if i >= len(fields) || value == "" {
+			continue
+		}
+		switch fields[i] {
+		case "title":
+			book.Title = value
+		case "author":
+			book.Author = value
+		case "published_date":
+			book.PublishedDate = value
+		case "edition":
+			edition, err := strconv.Atoi(value)
+			if err != nil {
+				v.Add("edition", "must be an integer")
+			}
+			book.Edition = edition
+		case "description":
+			book.Description = value
+		case "genre":
+			book.Genre = value
+		case "isbn":
+			if len(models.NormalizeISBN(value)) == 10 {
+				book.ISBN10 = value
+			} else {
+				book.ISBN13 = value
+			}
+		case "isbn10":
+			book.ISBN10 = value
+		case "isbn13":
+			book.ISBN13 = value
+		}
+	}
+	return db.ImportRow{Row: row, Book: book, Err: v.Err()}
+}
+
+// readJSONImport reads a JSON array of books in the format of POST
+// /api/v1/books.
+func readJSONImport(r io.Reader) ([]db.ImportRow, error) {
+	var records []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid JSON: must be an array of books: %v", err)
+	}
+	rows := make([]db.ImportRow, len(records))
+	for i, record := range records {
+		rows[i] = jsonBook(i+1, record)
+	}
+	return rows, nil
+}
+
+// readNDJSONImport reads one book per line; blank lines are skipped.
+func readNDJSONImport(r io.Reader) ([]db.ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var rows []db.ImportRow
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rows = append(rows, jsonBook(len(rows)+1, line))
+	}
+	if err := scanner.Err(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid NDJSON: %v", err)
+	}
+	return rows, nil
+}
+
+func jsonBook(row int, data []byte) db.ImportRow {
+	book := &models.BookRequest{}
+	err := json.Unmarshal(data, book)
+	if err != nil {
+		v := &models.ValidationError{}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			v.Add(typeErr.Field, "must be of type "+typeErr.Type.String())
+		} else {
+			v.Add("book", "must be a JSON object")
+		}
+		err = v
+	}
+	return db.ImportRow{Row: row, Book: book, Err: err}
+}
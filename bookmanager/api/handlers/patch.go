@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bookmanager/api/patch"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// acceptPatch lists the media types PATCH accepts, see RFC 5789.
+const acceptPatch = patch.MergePatchType + ", " + patch.JSONPatchType
+
+// maxPatchSize bounds the body of a PATCH request, which is read as a whole.
+const maxPatchSize = 1 << 20
+
+// readPatch parses the body of a PATCH request. On failure it writes the
+// error response and returns nil.
+func readPatch(w http.ResponseWriter, r *http.Request) patch.Patch {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeErrorStatus(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Patch must not exceed %d MB", maxPatchSize>>20))
+			return nil
+		}
+		writeErrorStatus(w, r, http.StatusBadRequest, "Invalid request body")
+		return nil
+	}
+
+	p, err := patch.Parse(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		if errors.Is(err, patch.ErrUnsupportedMediaType) {
+			w.Header().Set("Accept-Patch", acceptPatch)
+		}
+		writeError(w, r, err)
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"bookmanager/api/search"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parseListOptions reads the generic list parameters (where, group_by,
+// group_limit, order_by, limit, offset, cursor, include_total) and validates them against
+// the schema. Problems are reported per parameter as a *models.ValidationError.
+func parseListOptions(query url.Values, schema *filter.Schema) (db.ListOptions, error) {
+	var opts db.ListOptions
+	v := &models.ValidationError{}
+
+	opts.Filter = parseWhere(query, schema, v)
+
+	if groupBy := query.Get("group_by"); groupBy != "" {
+		keys, err := filter.ParseGroupBy(groupBy, schema)
+		if err != nil {
+			v.Add("group_by", err.Error())
+		} else {
+			opts.GroupBy = keys
+		}
+	}
+	opts.GroupLimit = db.DefaultGroupSize
+	if query.Has("group_limit") {
+		opts.GroupLimit = parseNonNegative(query, "group_limit", v)
+		if opts.GroupLimit > db.MaxPageSize {
+			v.Add("group_limit", fmt.Sprintf("must not exceed %d", db.MaxPageSize))
+		}
+	}
+
+	opts.OrderBy = parseOrderBy(query, schema, v)
+
+	opts.Limit = parseLimit(query, v)
+	opts.Offset = parseNonNegative(query, "offset", v)
+
+	opts.Cursor = query.Get("cursor")
+	if opts.Cursor != "" && opts.Offset > 0 {
+		v.Add("cursor", "cannot be combined with offset")
+	}
+	if opts.Cursor != "" && len(opts.GroupBy) > 0 {
+		v.Add("cursor", "cannot be combined with group_by")
+	}
+
+	if raw := query.Get("include_total"); raw != "" {
+		includeTotal, err := strconv.ParseBool(raw)
+		if err != nil {
+			v.Add("include_total", "must be true or false")
+		}
+		opts.IncludeTotal = includeTotal
+	}
+
+	return opts, v.Err()
+}
+
+// parseSearchOptions reads the parameters of GET /api/v1/books/search: q,
+// where, limit and offset.
+func parseSearchOptions(query url.Values) (*search.Query, db.ListOptions, error) {
+	var opts db.ListOptions
+	var q *search.Query
+	v := &models.ValidationError{}
+
+	if raw := query.Get("q"); raw == "" {
+		v.Add("q", "is required")
+	} else if parsed, err := search.Parse(raw); err != nil {
+		v.Add("q", err.Error())
+	} else {
+		q = parsed
+	}
+
+	opts.Filter = parseWhere(query, filter.BookSchema, v)
+	opts.Limit = parseLimit(query, v)
+	opts.Offset = parseNonNegative(query, "offset", v)
+
+	return q, opts, v.Err()
+}
+
+func parseWhere(query url.Values, schema *filter.Schema, v *models.ValidationError) filter.Expr {
+	where := query.Get("where")
+	if where == "" {
+		return nil
+	}
+	expr, err := filter.Parse(where)
+	if err == nil {
+		err = schema.Validate(expr)
+	}
+	if err != nil {
+		v.Add("where", err.Error())
+		return nil
+	}
+	return expr
+}
+
+func parseOrderBy(query url.Values, schema *filter.Schema, v *models.ValidationError) []filter.OrderTerm {
+	orderBy := query.Get("order_by")
+	if orderBy == "" {
+		return nil
+	}
+	terms, err := filter.ParseOrderBy(orderBy, schema)
+	if err != nil {
+		v.Add("order_by", err.Error())
+		return nil
+	}
+	return terms
+}
+
+func parseLimit(query url.Values, v *models.ValidationError) int {
+	limit := parseNonNegative(query, "limit", v)
+	if limit > db.MaxPageSize {
+		v.Add("limit", fmt.Sprintf("must not exceed %d", db.MaxPageSize))
+	}
+	return limit
+}
+
+func parseNonNegative(query url.Values, name string, v *models.ValidationError) int {
+	raw := query.Get(name)
+	if raw == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		v.Add(name, "must be a non-negative integer")
+		return 0
+	}
+	return n
+}
+
+// parseInclude reads include, a comma separated list of related resources
+// to embed in the response, which have to be among allowed.
+func parseInclude(query url.Values, allowed ...string) (map[string]bool, error) {
+	include := make(map[string]bool)
+	for _, name := range strings.Split(query.Get("include"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(allowed, name) {
+			v := &models.ValidationError{}
+			v.Add("include", "must list only "+strings.Join(allowed, ", "))
+			return nil, v
+		}
+		include[name] = true
+	}
+	return include, nil
+}
+
+// setPageLinks advertises the neighbouring pages in a Link header (RFC 8288).
+// The links repeat the request's query with the cursor replaced.
+func setPageLinks(w http.ResponseWriter, r *http.Request, next, prev string) {
+	var links []string
+	for _, l := range []struct{ rel, cursor string }{{"next", next}, {"prev", prev}} {
+		if l.cursor == "" {
+			continue
+		}
+		query := r.URL.Query()
+		query.Del("offset")
+		query.Set("cursor", l.cursor)
+		u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), l.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Add("Link", strings.Join(links, ", "))
+	}
+}
+
+// pageResponse renders a page as {key: items, next_cursor, prev_cursor, total}.
+func pageResponse(key string, items interface{}, next, prev string, total *int) map[string]interface{} {
+	resp := map[string]interface{}{key: items}
+	if next != "" {
+		resp["next_cursor"] = next
+	}
+	if prev != "" {
+		resp["prev_cursor"] = prev
+	}
+	if total != nil {
+		resp["total"] = *total
+	}
+	return resp
+}
+
+// groupsResponse renders grouped lists. Each group carries the where
+// expression and cursor to fetch the rest of its rows from the list endpoint.
+func groupsResponse[T any](key string, keys []filter.GroupKey, groups []db.Group[T]) map[string]interface{} {
+	out := make([]map[string]interface{}, len(groups))
+	for i, g := range groups {
+		keyValues := make(map[string]*string, len(keys))
+		for j, k := range keys {
+			keyValues[k.String()] = g.Key[j]
+		}
+		group := map[string]interface{}{
+			"key":   keyValues,
+			"count": g.Count,
+			"where": filter.Format(g.Filter),
+		}
+		if len(g.Items) > 0 {
+			group[key] = g.Items
+		}
+		if g.NextCursor != "" {
+			group["next_cursor"] = g.NextCursor
+		}
+		out[i] = group
+	}
+	return map[string]interface{}{"groups": out}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Router routes requests by method and path with patterns such as
+// "GET /api/v1/books/{id}", whose wildcards handlers read with
+// r.PathValue. It differs from http.ServeMux in three ways:
+//
+//   - A literal segment takes precedence over a wildcard in the same place,
+//     segment by segment from the left, so "GET /api/v1/books/isbn/{isbn}"
+//     and "GET /api/v1/books/{id}/history" can both be routed.
+//   - A path that has routes, but none for the request's method, gets 405
+//     Method Not Allowed as a problem document with an Allow header.
+//   - A trailing slash is ignored.
+type Router struct {
+	routes []*route
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.Handler
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// HandleFunc registers handler for pattern, which is a method, a space and
+// a path. Wildcards such as {id} match a whole segment. It panics if the
+// pattern is malformed or already registered.
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.Handle(pattern, handler)
+}
+
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || method == "" || !strings.HasPrefix(path, "/") {
+		panic("router: pattern must be \"METHOD /path\": " + pattern)
+	}
+	r := &route{method: method, segments: splitPath(path), handler: handler}
+	for _, seg := range r.segments {
+		if strings.HasPrefix(seg, "{") != strings.HasSuffix(seg, "}") || seg == "{}" {
+			panic("router: malformed wildcard in " + pattern)
+		}
+	}
+	for _, other := range rt.routes {
+		if other.method == r.method && slices.Equal(shape(other.segments), shape(r.segments)) {
+			panic("router: " + pattern + " is already registered")
+		}
+	}
+	rt.routes = append(rt.routes, r)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.EscapedPath())
+	for i, seg := range segments {
+		if s, err := url.PathUnescape(seg); err == nil {
+			segments[i] = s
+		}
+	}
+
+	var best *route
+	var allowed []string
+	for _, route := range rt.routes {
+		if !route.matches(segments) {
+			continue
+		}
+		if !slices.Contains(allowed, route.method) {
+			allowed = append(allowed, route.method)
+		}
+		method := route.method == r.Method || (route.method == http.MethodGet && r.Method == http.MethodHead)
+		if method && (best == nil || route.precedes(best)) {
+			best = route
+		}
+	}
+
+	switch {
+	case best != nil:
+		for i, seg := range best.segments {
+			if name, ok := wildcard(seg); ok {
+				r.SetPathValue(name, segments[i])
+			}
+		}
+		best.handler.ServeHTTP(w, r)
+	case len(allowed) > 0:
+		if slices.Contains(allowed, http.MethodGet) {
+			allowed = append(allowed, http.MethodHead)
+		}
+		slices.Sort(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeErrorStatus(w, r, http.StatusMethodNotAllowed, "Method not allowed, use "+strings.Join(allowed, ", "))
+	default:
+		writeErrorStatus(w, r, http.StatusNotFound, "No such endpoint")
+	}
+}
+
+func (route *route) matches(segments []string) bool {
+	if len(segments) != len(route.segments) {
+		return false
+	}
+	for i, seg := range route.segments {
+		if _, ok := wildcard(seg); !ok && seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// precedes reports whether route is more specific than other, which matches
+// the same paths: at the first segment where only one of them has a
+// wildcard, route has a literal.
+func (route *route) precedes(other *route) bool {
+	for i, seg := range route.segments {
+		_, wild := wildcard(seg)
+		_, otherWild := wildcard(other.segments[i])
+		if wild != otherWild {
+			return otherWild
+		}
+	}
+	return false
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func wildcard(seg string) (string, bool) {
+	if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+		return seg[1 : len(seg)-1], true
+	}
+	return "", false
+}
+
+// shape replaces the wildcards of segments by "{}", so patterns differing
+// only in wildcard names compare equal.
+func shape(segments []string) []string {
+	s := make([]string, len(segments))
+	for i, seg := range segments {
+		if _, ok := wildcard(seg); ok {
+			seg = "{}"
+		}
+		s[i] = seg
+	}
+	return s
+}
+
+// Deprecated marks the responses of handler as deprecated since the given
+// time (RFC 9745), linking to the successor path, in which wildcards such
+// as {id} are filled in from the request's path values.
+func Deprecated(since time.Time, successor string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		segments := splitPath(successor)
+		for i, seg := range segments {
+			if name, ok := wildcard(seg); ok {
+				segments[i] = url.PathEscape(r.PathValue(name))
+			}
+		}
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(since.Unix(), 10))
+		w.Header().Add("Link", "</"+strings.Join(segments, "/")+">; rel=\"successor-version\"")
+		handler(w, r)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testRouter() *Router {
+	rt := NewRouter()
+	for _, pattern := range []string{
+		"GET /api/v1/books",
+		"POST /api/v1/books",
+		"GET /api/v1/books/{id}",
+		"DELETE /api/v1/books/{id}",
+		"GET /api/v1/books/search",
+		"GET /api/v1/books/isbn/{isbn}",
+		"GET /api/v1/books/{id}/history",
+		"GET /api/v1/books/{id}/history/{rev}",
+		"GET /api/v1/collections/{id}/books/{bookId}",
+		"PUT /api/v1/collections/{id}/books/order",
+	} {
+		rt.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route", pattern)
+			w.Header().Set("X-Values", r.PathValue("id")+"|"+r.PathValue("isbn")+"|"+r.PathValue("rev")+"|"+r.PathValue("bookId"))
+		})
+	}
+	return rt
+}
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		status int
+		route  string
+		values string
+		allow  string
+	}{
+		{"GET", "/api/v1/books", 200, "GET /api/v1/books", "|||", ""},
+		{"GET", "/api/v1/books/", 200, "GET /api/v1/books", "|||", ""},
+		{"GET", "/api/v1/books/12", 200, "GET /api/v1/books/{id}", "12|||", ""},
+		{"GET", "/api/v1/books/12/", 200, "GET /api/v1/books/{id}", "12|||", ""},
+		{"GET", "/api/v1/books/search", 200, "GET /api/v1/books/search", "|||", ""},
+		{"GET", "/api/v1/books/isbn/978-0", 200, "GET /api/v1/books/isbn/{isbn}", "|978-0||", ""},
+		{"GET", "/api/v1/books/isbn/history", 200, "GET /api/v1/books/isbn/{isbn}", "|history||", ""},
+		{"GET", "/api/v1/books/12/history/3", 200, "GET /api/v1/books/{id}/history/{rev}", "12||3|", ""},
+		{"GET", "/api/v1/books/a%2Fb", 200, "GET /api/v1/books/{id}", "a/b|||", ""},
+		{"PUT", "/api/v1/collections/4/books/order", 200, "PUT /api/v1/collections/{id}/books/order", "4|||", ""},
+		{"GET", "/api/v1/collections/4/books/order", 200, "GET /api/v1/collections/{id}/books/{bookId}", "4|||order", ""},
+		{"HEAD", "/api/v1/books/12", 200, "GET /api/v1/books/{id}", "12|||", ""},
+		{"PUT", "/api/v1/books/12", 405, "", "", "DELETE, GET, HEAD"},
+		{"DELETE", "/api/v1/books", 405, "", "", "GET, HEAD, POST"},
+		{"DELETE", "/api/v1/books/search", 200, "DELETE /api/v1/books/{id}", "search|||", ""},
+		{"POST", "/api/v1/books/search", 405, "", "", "DELETE, GET, HEAD"},
+		{"DELETE", "/api/v1/collections/4/books/order", 405, "", "", "GET, HEAD, PUT"},
+		{"GET", "/api/v1/authors", 404, "", "", ""},
+		{"GET", "/api/v1/books/12/history/3/x", 404, "", "", ""},
+	}
+	rt := testRouter()
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("X-Route"); got != tt.route {
+				t.Errorf("route = %q, want %q", got, tt.route)
+			}
+			if got := w.Header().Get("X-Values"); got != tt.values {
+				t.Errorf("path values = %q, want %q", got, tt.values)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestRouterPanics(t *testing.T) {
+	for _, patterns := range [][]string{
+		{"/api/v1/books"},
+		{"GET api/v1/books"},
+		{"GET /api/v1/books/{id"},
+		{"GET /api/v1/books/{}"},
+		{"GET /api/v1/books/{id}", "GET /api/v1/books/{bookId}"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q did not panic", patterns)
+				}
+			}()
+			rt := NewRouter()
+			for _, pattern := range patterns {
+				rt.HandleFunc(pattern, func(http.ResponseWriter, *http.Request) {})
+			}
+		}()
+	}
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/filter"
+	"bookmanager/api/models"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type StatsHandler struct {
+	db db.StatsStore
+}
+
+func NewStatsHandler(store db.StatsStore) *StatsHandler {
+	return &StatsHandler{db: store}
+}
+
+type bucketCount struct {
+	Key   *string `json:"key"`
+	Count int     `json:"count"`
+}
+
+type histogram struct {
+	Key     string        `json:"key"`
+	Buckets []bucketCount `json:"buckets"`
+}
+
+// pivotTable counts books per row and column key; Counts[i][j] belongs to
+// Rows[i] and Columns[j].
+type pivotTable struct {
+	RowKey       string    `json:"row_key"`
+	ColumnKey    string    `json:"column_key"`
+	Rows         []*string `json:"rows"`
+	Columns      []*string `json:"columns"`
+	Counts       [][]int   `json:"counts"`
+	RowTotals    []int     `json:"row_totals"`
+	ColumnTotals []int     `json:"column_totals"`
+}
+
+// HandleBookStats serves GET /api/v1/stats/books. The summary is always
+// included; histogram=<key> and pivot=<row key>,<column key> add a
+// histogram and a pivot table. Keys use the group_by syntax.
+func (h *StatsHandler) HandleBookStats(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	v := &models.ValidationError{}
+	where := parseWhere(query, filter.BookSchema, v)
+	histogramKey := parseStatsKeys(query, "histogram", 1, v)
+	pivotKeys := parseStatsKeys(query, "pivot", 2, v)
+	if err := v.Err(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	extra, err := db.BookFilter(query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	f := filter.And(where, extra)
+
+	summary, err := h.db.BookStats(r.Context(), f)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	resp := map[string]interface{}{"summary": summary}
+
+	if histogramKey != nil {
+		counts, err := h.db.CountBooks(r.Context(), f, histogramKey)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp["histogram"] = newHistogram(histogramKey[0], counts)
+	}
+
+	if pivotKeys != nil {
+		counts, err := h.db.CountBooks(r.Context(), f, pivotKeys)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		resp["pivot"] = newPivotTable(pivotKeys, counts)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseStatsKeys parses a parameter that takes exactly n group keys.
+func parseStatsKeys(query url.Values, name string, n int, v *models.ValidationError) []filter.GroupKey {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil
+	}
+	keys, err := filter.ParseGroupBy(raw, filter.BookSchema)
+	if err != nil {
+		v.Add(name, err.Error())
+		return nil
+	}
+	if len(keys) != n {
+		if n == 1 {
+			v.Add(name, "expects a single key")
+		} else {
+			v.Add(name, "expects two comma separated keys")
+		}
+		return nil
+	}
+	return keys
+}
+
+// newHistogram turns counts into buckets. Year, decade and month buckets are
+// contiguous: missing periods between the first and last one count zero.
+func newHistogram(key filter.GroupKey, counts []db.KeyCount) histogram {
+	hist := histogram{Key: key.String(), Buckets: []bucketCount{}}
+	for i, c := range counts {
+		if i > 0 && c.Key[0] != nil && counts[i-1].Key[0] != nil {
+			for _, gap := range missingBuckets(key.Bucket, *counts[i-1].Key[0], *c.Key[0]) {
+				hist.Buckets = append(hist.Buckets, bucketCount{Key: &gap})
+			}
+		}
+		hist.Buckets = append(hist.Buckets, bucketCount{Key: c.Key[0], Count: c.Count})
+	}
+	return hist
+}
+
+// missingBuckets lists the bucket keys strictly between from and to.
+func missingBuckets(bucket, from, to string) []string {
+	var gaps []string
+	switch bucket {
+	case "year", "decade":
+		step := 1
+		if bucket == "decade" {
+			step = 10
+		}
+		start, err1 := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		for y := start + step; y < end; y += step {
+			gaps = append(gaps, strconv.Itoa(y))
+		}
+	case "month":
+		start, err1 := time.Parse("2006-01", from)
+		end, err2 := time.Parse("2006-01", to)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		for m := start.AddDate(0, 1, 0); m.Before(end); m = m.AddDate(0, 1, 0) {
+			gaps = append(gaps, m.Format("2006-01"))
+		}
+	}
+	return gaps
+}
+
+func newPivotTable(keys []filter.GroupKey, counts []db.KeyCount) pivotTable {
+	t := pivotTable{RowKey: keys[0].String(), ColumnKey: keys[1].String()}
+	rowIndex := make(map[string]int)
+	colIndex := make(map[string]int)
+	index := func(key *string, seen map[string]int, list *[]*string) int {
+		k := "\x00null"
+		if key != nil {
+			k = *key
+		}
+		if i, ok := seen[k]; ok {
+			return i
+		}
+		seen[k] = len(*list)
+		*list = append(*list, key)
+		return seen[k]
+	}
+
+	// Counts are ordered by row key, so rows come out sorted; columns are
+	// sorted afterwards.
+	for _, c := range counts {
+		index(c.Key[0], rowIndex, &t.Rows)
+		index(c.Key[1], colIndex, &t.Columns)
+	}
+	sortKeys(keys[1], t.Columns)
+	for i, col := range t.Columns {
+		k := "\x00null"
+		if col != nil {
+			k = *col
+		}
+		colIndex[k] = i
+	}
+
+	t.Counts = make([][]int, len(t.Rows))
+	for i := range t.Counts {
+		t.Counts[i] = make([]int, len(t.Columns))
+	}
+	t.RowTotals = make([]int, len(t.Rows))
+	t.ColumnTotals = make([]int, len(t.Columns))
+	for _, c := range counts {
+		i := index(c.Key[0], rowIndex, &t.Rows)
+		j := index(c.Key[1], colIndex, &t.Columns)
+		t.Counts[i][j] += c.Count
+		t.RowTotals[i] += c.Count
+		t.ColumnTotals[j] += c.Count
+	}
+	if t.Rows == nil {
+		t.Rows, t.Columns = []*string{}, []*string{}
+	}
+	return t
+}
+
+// sortKeys orders key values like PostgreSQL orders the key: numerically for
+// numbers, years and decades, as text otherwise, NULL last.
+func sortKeys(key filter.GroupKey, values []*string) {
+	numeric := key.Bucket == "year" || key.Bucket == "decade" ||
+		(key.Bucket == "" && filter.BookSchema.Fields[key.Field.Name].Type == filter.IntField)
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if a == nil || b == nil {
+			return a != nil
+		}
+		if numeric {
+			x, _ := strconv.Atoi(*a)
+			y, _ := strconv.Atoi(*b)
+			return x < y
+		}
+		return *a < *b
+	})
+}
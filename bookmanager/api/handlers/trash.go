@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bookmanager/api/db"
+	"bookmanager/api/models"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type TrashHandler struct {
+	db        db.TrashStore
+	retention time.Duration
+}
+
+// NewTrashHandler returns a handler that purges items older than retention
+// unless a purge request asks for another age.
+func NewTrashHandler(store db.TrashStore, retention time.Duration) *TrashHandler {
+	return &TrashHandler{db: store, retention: retention}
+}
+
+// HandleTrash serves GET /api/v1/trash.
+func (h *TrashHandler) HandleTrash(w http.ResponseWriter, r *http.Request) {
+
+	trash, err := h.db.ListTrash(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trash)
+}
+
+// HandlePurge serves POST /api/v1/trash/purge. Items deleted more than
+// older_than ago (a duration such as 24h, default the retention period) are
+// deleted for good; older_than=0s empties the trash.
+func (h *TrashHandler) HandlePurge(w http.ResponseWriter, r *http.Request) {
+
+	olderThan := h.retention
+	if value := r.URL.Query().Get("older_than"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			v := &models.ValidationError{}
+			v.Add("older_than", "must be a duration such as 24h or 30m")
+			writeError(w, r, v.Err())
+			return
+		}
+		olderThan = d
+	}
+
+	result, err := h.db.PurgeTrash(r.Context(), time.Now().Add(-olderThan))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RestoreBook serves POST /api/v1/books/{id}/restore.
+func (h *TrashHandler) RestoreBook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid book ID")
+	if !ok {
+		return
+	}
+
+	book, err := h.db.RestoreBook(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, book.Version)
+	json.NewEncoder(w).Encode(book)
+}
+
+// RestoreCollection serves POST /api/v1/collections/{id}/restore.
+func (h *TrashHandler) RestoreCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathInt(w, r, "id", "Invalid collection ID")
+	if !ok {
+		return
+	}
+
+	collection, err := h.db.RestoreCollection(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, collection.Version)
+	json.NewEncoder(w).Encode(collection)
+}
@@ -1,50 +1,211 @@
-package main
-
-import (
-	"bookmanager/api/db"
-	"bookmanager/api/handlers"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-)
-
-func main() {
-	dbConn, err:= db.InitDB()
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer dbConn.Close()
-
-	bookHandler := handlers.NewBookHandler(&db.BookDB{})
-	collectionHandler := handlers.NewCollectionHandler(&db.CollectionDB{})
-
-	http.HandleFunc("/api/v1/books", bookHandler.HandleBooks)
-	http.HandleFunc("/api/v1/books/", bookHandler.HandleBook)
-	http.HandleFunc("/api/v1/collections", collectionHandler.HandleCollections)
-	http.HandleFunc("/api/v1/collections/{id}", collectionHandler.HandleCollection)
-	http.HandleFunc("/api/v1/collections-books/", collectionHandler.HandleCollectionBooksRoutes)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	server := &http.Server {
-		Addr: fmt.Sprintf(":%s", port),
-	}
-
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		log.Printf("Server starting on port %s", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed{
-			log.Fatalf("Server error: %v", err)
-		}
-	}()
-	<-done
-	log.Println("Server stopped")
-}
+package main
+
+import (
+	"bookmanager/api/config"
+	"bookmanager/api/db"
+	"bookmanager/api/handlers"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if opts.PrintConfig {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		fmt.Print(out)
+		if err := cfg.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	level, _ := cfg.Log.SlogLevel()
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	if len(opts.Args) > 0 && opts.Args[0] == "migrate" {
+		runMigrate(cfg, opts.Args[1:])
+		return
+	}
+
+	var bookStore db.BookStore
+	var collectionStore db.CollectionStore
+	var statsStore db.StatsStore
+	var trashStore db.TrashStore
+	var authorStore db.AuthorStore
+
+	if cfg.Features.InMemoryStore {
+		slog.Info("Using in-memory storage")
+		memoryStore := db.NewMemoryStore()
+		bookStore = memoryStore
+		collectionStore = memoryStore
+		statsStore = memoryStore
+		trashStore = memoryStore
+		authorStore = memoryStore
+	} else {
+		dbConn, err := db.InitDB(cfg.Database, cfg.Features.AutoMigrate)
+		if err != nil {
+			fatal("Failed to initialize database", "error", err)
+		}
+		defer dbConn.Close()
+
+		bookStore = db.NewBook(dbConn)
+		collectionStore = db.NewCollection(dbConn)
+		statsStore = db.NewBook(dbConn)
+		trashStore = db.NewTrash(dbConn)
+		authorStore = db.NewAuthor(dbConn)
+	}
+
+	bookHandler := handlers.NewBookHandler(bookStore)
+	collectionHandler := handlers.NewCollectionHandler(collectionStore)
+	statsHandler := handlers.NewStatsHandler(statsStore)
+	authorHandler := handlers.NewAuthorHandler(authorStore)
+	trashHandler := handlers.NewTrashHandler(trashStore, time.Duration(cfg.Trash.Retention))
+
+	router := handlers.NewRouter()
+	router.HandleFunc("GET /api/v1/books", bookHandler.ListBooks)
+	router.HandleFunc("POST /api/v1/books", bookHandler.CreateBook)
+	router.HandleFunc("GET /api/v1/books/search", bookHandler.SearchBooks)
+	router.HandleFunc("POST /api/v1/books/import", bookHandler.ImportBooks)
+	router.HandleFunc("GET /api/v1/books/export", bookHandler.ExportBooks)
+	router.HandleFunc("GET /api/v1/books/isbn/{isbn}", bookHandler.GetBookByISBN)
+	router.HandleFunc("GET /api/v1/books/{id}", bookHandler.GetBook)
+	router.HandleFunc("PUT /api/v1/books/{id}", bookHandler.UpdateBook)
+	router.HandleFunc("PATCH /api/v1/books/{id}", bookHandler.PatchBook)
+	router.HandleFunc("DELETE /api/v1/books/{id}", bookHandler.DeleteBook)
+	router.HandleFunc("POST /api/v1/books/{id}/restore", trashHandler.RestoreBook)
+	router.HandleFunc("GET /api/v1/books/{id}/collections", collectionHandler.ListBookCollections)
+	router.HandleFunc("GET /api/v1/books/{id}/history", bookHandler.HandleBookHistory)
+	router.HandleFunc("GET /api/v1/books/{id}/history/{rev}", bookHandler.HandleBookRevision)
+	router.HandleFunc("POST /api/v1/books/{id}/history/{rev}/revert", bookHandler.RevertBook)
+
+	router.HandleFunc("GET /api/v1/collections", collectionHandler.ListCollections)
+	router.HandleFunc("POST /api/v1/collections", collectionHandler.CreateCollection)
+	router.HandleFunc("GET /api/v1/collections/tree", collectionHandler.CollectionTree)
+	router.HandleFunc("GET /api/v1/collections/set", collectionHandler.ListCollectionSet)
+	router.HandleFunc("POST /api/v1/collections/set", collectionHandler.CreateCollectionFromSet)
+	router.HandleFunc("GET /api/v1/collections/{id}", collectionHandler.GetCollection)
+	router.HandleFunc("PUT /api/v1/collections/{id}", collectionHandler.UpdateCollection)
+	router.HandleFunc("PATCH /api/v1/collections/{id}", collectionHandler.PatchCollection)
+	router.HandleFunc("DELETE /api/v1/collections/{id}", collectionHandler.DeleteCollection)
+	router.HandleFunc("POST /api/v1/collections/{id}/restore", trashHandler.RestoreCollection)
+	router.HandleFunc("GET /api/v1/collections/{id}/tree", collectionHandler.GetCollectionTree)
+	router.HandleFunc("GET /api/v1/collections/{id}/breadcrumb", collectionHandler.CollectionBreadcrumb)
+	router.HandleFunc("POST /api/v1/collections/{id}/move", collectionHandler.MoveCollection)
+	router.HandleFunc("GET /api/v1/collections/{id}/books", collectionHandler.ListBooksInCollection)
+	router.HandleFunc("POST /api/v1/collections/{id}/books", collectionHandler.AddBookToCollection)
+	router.HandleFunc("POST /api/v1/collections/{id}/books/bulk-add", collectionHandler.AddBooksToCollection)
+	router.HandleFunc("POST /api/v1/collections/{id}/books/bulk-remove", collectionHandler.RemoveBooksFromCollection)
+	router.HandleFunc("POST /api/v1/collections/{id}/books/transfer", collectionHandler.TransferBooks)
+	router.HandleFunc("GET /api/v1/collections/{id}/books/export", collectionHandler.ExportCollectionBooks)
+	router.HandleFunc("DELETE /api/v1/collections/{id}/books/{bookId}", collectionHandler.RemoveBookFromCollection)
+	router.HandleFunc("PUT /api/v1/collections/{id}/books/order", collectionHandler.ReorderCollectionBooks)
+	router.HandleFunc("POST /api/v1/collections/{id}/books/{bookId}/move", collectionHandler.MoveBookInCollection)
+	router.HandleFunc("GET /api/v1/collections/{id}/history", collectionHandler.HandleCollectionHistory)
+	router.HandleFunc("GET /api/v1/collections/{id}/history/{rev}", collectionHandler.HandleCollectionRevision)
+	router.HandleFunc("POST /api/v1/collections/{id}/history/{rev}/revert", collectionHandler.RevertCollection)
+
+	// The collection books routes before they were nested under collections.
+	deprecated := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	router.HandleFunc("GET /api/v1/collections-books/{id}",
+		handlers.Deprecated(deprecated, "/api/v1/collections/{id}/books", collectionHandler.ListBooksInCollection))
+	router.HandleFunc("POST /api/v1/collections-books/{id}",
+		handlers.Deprecated(deprecated, "/api/v1/collections/{id}/books", collectionHandler.AddBookToCollection))
+	router.HandleFunc("GET /api/v1/collections-books/{id}/export",
+		handlers.Deprecated(deprecated, "/api/v1/collections/{id}/books/export", collectionHandler.ExportCollectionBooks))
+	router.HandleFunc("DELETE /api/v1/collections-books/{id}/{bookId}",
+		handlers.Deprecated(deprecated, "/api/v1/collections/{id}/books/{bookId}", collectionHandler.RemoveBookFromCollection))
+
+	router.HandleFunc("GET /api/v1/authors", authorHandler.ListAuthors)
+	router.HandleFunc("POST /api/v1/authors", authorHandler.CreateAuthor)
+	router.HandleFunc("GET /api/v1/authors/{id}", authorHandler.GetAuthor)
+	router.HandleFunc("PUT /api/v1/authors/{id}", authorHandler.UpdateAuthor)
+	router.HandleFunc("PATCH /api/v1/authors/{id}", authorHandler.PatchAuthor)
+	router.HandleFunc("DELETE /api/v1/authors/{id}", authorHandler.DeleteAuthor)
+	router.HandleFunc("GET /api/v1/authors/{id}/books", authorHandler.HandleAuthorBooks)
+
+	router.HandleFunc("GET /api/v1/stats/books", statsHandler.HandleBookStats)
+	router.HandleFunc("GET /api/v1/trash", trashHandler.HandleTrash)
+	router.HandleFunc("POST /api/v1/trash/purge", trashHandler.HandlePurge)
+
+	server := &http.Server{
+		Addr:         cfg.Server.ListenAddress,
+		Handler:      handlers.RequestID(handlers.LogRequests(handlers.Actor(router))),
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+	}
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		slog.Info("Server starting", "address", cfg.Server.ListenAddress)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("Server error", "error", err)
+		}
+	}()
+	stopPurge := make(chan struct{})
+	if cfg.Trash.PurgeInterval > 0 {
+		go purgeTrash(trashStore, time.Duration(cfg.Trash.Retention), time.Duration(cfg.Trash.PurgeInterval), stopPurge)
+	}
+	<-done
+	close(stopPurge)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server shutdown error", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// purgeTrash deletes trash older than retention every interval until stop is
+// closed.
+func purgeTrash(store db.TrashStore, retention, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			result, err := store.PurgeTrash(context.Background(), time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("Failed to purge trash", "error", err)
+				continue
+			}
+			if result.Books > 0 || result.Collections > 0 {
+				slog.Info("Purged the trash", "books", result.Books, "collections", result.Collections)
+			}
+		}
+	}
+}
+
+// fatal logs msg as an error and exits, once logging is set up.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bookmanager/api/config"
+	"bookmanager/api/db"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) < 1 {
+		printMigrateHelp()
+		os.Exit(1)
+	}
+
+	dbConn, err := db.Connect(cfg.Database)
+	if err != nil {
+		fatal("Failed to connect to database", "error", err)
+	}
+	defer dbConn.Close()
+
+	migrator, err := db.NewMigrator(dbConn)
+	if err != nil {
+		fatal("Failed to load migrations", "error", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx)
+	case "to":
+		if len(args) < 2 {
+			fatal("Target version is required")
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil || version < 0 {
+			fatal("Invalid version", "version", args[1])
+		}
+		err = migrator.To(ctx, version)
+	case "status":
+		var statuses []db.MigrationStatus
+		statuses, err = migrator.Status(ctx)
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Printf("%04d  %-30s applied %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("%04d  %-30s pending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		printMigrateHelp()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fatal("Migration failed", "error", err)
+	}
+}
+
+func printMigrateHelp() {
+	fmt.Println(`Usage: api [flags] migrate <command>
+
+Commands:
+  up            Apply all pending migrations
+  down          Roll back the most recently applied migration
+  to <version>  Migrate up or down to the given version (0 rolls back everything)
+  status        Show applied and pending migrations`)
+}
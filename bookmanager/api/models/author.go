@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Roles a person can have on a book.
+const (
+	RoleAuthor      = "author"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+	RoleEditor      = "editor"
+)
+
+var bookRoles = map[string]bool{RoleAuthor: true, RoleTranslator: true, RoleIllustrator: true, RoleEditor: true}
+
+type Author struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	SortName string `json:"sort_name"`
+	// BirthDate and DeathDate are YYYY-MM-DD, empty when unknown.
+	BirthDate string    `json:"birth_date,omitempty"`
+	DeathDate string    `json:"death_date,omitempty"`
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+}
+
+type AuthorRequest struct {
+	Name string `json:"name,omitempty"`
+	// SortName defaults to "Last, First" derived from Name.
+	SortName  string `json:"sort_name,omitempty"`
+	BirthDate string `json:"birth_date,omitempty"`
+	DeathDate string `json:"death_date,omitempty"`
+	Bio       string `json:"bio,omitempty"`
+}
+
+func (a *AuthorRequest) Validate() error {
+	v := &ValidationError{}
+	if strings.TrimSpace(a.Name) == "" {
+		v.Add("name", "is required")
+	}
+	var birth, death time.Time
+	var err error
+	if a.BirthDate != "" {
+		if birth, err = time.Parse("2006-01-02", a.BirthDate); err != nil {
+			v.Add("birth_date", "must be a date in YYYY-MM-DD format")
+		}
+	}
+	if a.DeathDate != "" {
+		if death, err = time.Parse("2006-01-02", a.DeathDate); err != nil {
+			v.Add("death_date", "must be a date in YYYY-MM-DD format")
+		}
+	}
+	if !birth.IsZero() && !death.IsZero() && death.Before(birth) {
+		v.Add("death_date", "must not be before birth_date")
+	}
+	return v.Err()
+}
+
+// SortNameOf returns "Last, First" for a name, e.g. "Tolkien, J.R.R." for
+// "J.R.R. Tolkien". Single word names are returned as they are.
+func SortNameOf(name string) string {
+	name = strings.TrimSpace(name)
+	i := strings.LastIndexAny(name, " \t")
+	if i < 0 {
+		return name
+	}
+	return name[i+1:] + ", " + strings.TrimSpace(name[:i])
+}
+
+// BookAuthor is a person credited on a book, in credit order.
+type BookAuthor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// BookAuthorRequest credits an existing author by ID or an author by name,
+// who is created if there is none of that name yet. Role defaults to
+// author.
+type BookAuthorRequest struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Role string `json:"role,omitempty"`
+}
+
+// authorSeparator splits an author string into names: "A & B", "A and B"
+// and "A; B" all credit two authors.
+var authorSeparator = regexp.MustCompile(`\s*(?:;|&|\sand\s)\s*`)
+
+// SplitAuthors returns the names in an author string.
+func SplitAuthors(author string) []string {
+	var names []string
+	for _, name := range authorSeparator.Split(author, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// JoinAuthors returns the author string of a book: the names credited with
+// the author role, joined with " & ".
+func JoinAuthors(authors []BookAuthor) string {
+	var names []string
+	for _, a := range authors {
+		if a.Role == RoleAuthor {
+			names = append(names, a.Name)
+		}
+	}
+	return strings.Join(names, " & ")
+}
+
+func validateBookAuthors(authors []BookAuthorRequest, v *ValidationError) {
+	hasAuthor := false
+	seen := make(map[string]bool)
+	for i, a := range authors {
+		field := fmt.Sprintf("authors[%d]", i)
+		if a.ID == 0 && strings.TrimSpace(a.Name) == "" {
+			v.Add(field, "needs an id or a name")
+		}
+		role := a.Role
+		if role == "" {
+			role = RoleAuthor
+		}
+		if !bookRoles[role] {
+			v.Add(field+".role", "must be author, translator, illustrator or editor")
+		}
+		hasAuthor = hasAuthor || role == RoleAuthor
+
+		key := fmt.Sprintf("%d/%s/%s", a.ID, strings.TrimSpace(a.Name), role)
+		if seen[key] {
+			v.Add(field, "is listed twice")
+		}
+		seen[key] = true
+	}
+	if !hasAuthor {
+		v.Add("authors", "must credit at least one author")
+	}
+}
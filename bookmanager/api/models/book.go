@@ -1,46 +1,103 @@
-package models
-
-import (
-	"fmt"
-	"time"
-)
-
-type Book struct {
-	ID            int       `json:"id"`
-	Title         string    `json:"title"`
-	Author        string    `json:"author"`
-	PublishedDate string    `json:"published_date"`
-	Edition       int       `json:"edition"`
-	Description   string    `json:"description"`
-	Genre         string    `json:"genre"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-}
-
-type BookRequest struct {
-	Title         string `json:"title,omitempty"`
-	Author        string `json:"author,omitempty"`
-	PublishedDate string `json:"published_date,omitempty"`
-	Edition       int    `json:"edition,omitempty"`
-	Description   string `json:"description,omitempty"`
-	Genre         string `json:"genre,omitempty"`
-}
-
-func (b *BookRequest) Validate() error {
-	if b.Title == "" {
-		return fmt.Errorf("title is required")
-	}
-	if b.Author == "" {
-		return fmt.Errorf("author is required")
-	}
-	if b.PublishedDate == "" {
-		return fmt.Errorf("published_date is required")
-	}
-
-	if b.PublishedDate != "" {
-		if _, err := time.Parse("2006-01-02", b.PublishedDate); err != nil {
-			return fmt.Errorf("invalid published_date format")
-		}
-	}
-	return nil
-}
+package models
+
+import "time"
+
+// Book.Author holds the names in Authors credited with the author role,
+// joined with " & ", for clients that predate Authors. ISBN10 is derived from
+// ISBN13 and empty for ISBNs starting with 979.
+type Book struct {
+	ID            int          `json:"id"`
+	Title         string       `json:"title"`
+	Author        string       `json:"author"`
+	Authors       []BookAuthor `json:"authors"`
+	PublishedDate string       `json:"published_date"`
+	Edition       int          `json:"edition"`
+	Description   string       `json:"description"`
+	Genre         string       `json:"genre"`
+	ISBN13        string       `json:"isbn13,omitempty"`
+	ISBN10        string       `json:"isbn10,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	Version       int          `json:"version"`
+	// DeletedAt is set for books in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Collections is only set when asked for with include=collections.
+	Collections []BookCollection `json:"collections,omitempty"`
+	// Position is only set when listing the books of a collection; 1 is
+	// the first place.
+	Position int `json:"position,omitempty"`
+}
+
+// BookRequest credits the book's people either with Authors or, as before
+// authors were records of their own, with an Author string such as
+// "Terry Pratchett & Neil Gaiman". When both are given they have to agree.
+// The ISBN may be given in either form, with or without hyphens; see ISBN.
+type BookRequest struct {
+	Title         string              `json:"title,omitempty"`
+	Author        string              `json:"author,omitempty"`
+	Authors       []BookAuthorRequest `json:"authors,omitempty"`
+	PublishedDate string              `json:"published_date,omitempty"`
+	Edition       int                 `json:"edition,omitempty"`
+	Description   string              `json:"description,omitempty"`
+	Genre         string              `json:"genre,omitempty"`
+	ISBN10        string              `json:"isbn10,omitempty"`
+	ISBN13        string              `json:"isbn13,omitempty"`
+}
+
+func (b *BookRequest) Validate() error {
+	v := &ValidationError{}
+	if b.Title == "" {
+		v.Add("title", "is required")
+	}
+	if len(b.Authors) > 0 {
+		validateBookAuthors(b.Authors, v)
+	} else if b.Author == "" {
+		v.Add("author", "is required")
+	}
+	if b.PublishedDate == "" {
+		v.Add("published_date", "is required")
+	} else if _, err := time.Parse("2006-01-02", b.PublishedDate); err != nil {
+		v.Add("published_date", "must be a date in YYYY-MM-DD format")
+	}
+	if b.Edition < 0 {
+		v.Add("edition", "must not be negative")
+	}
+	isbn10, isbn13 := NormalizeISBN(b.ISBN10), NormalizeISBN(b.ISBN13)
+	valid := true
+	if b.ISBN10 != "" {
+		if err := checkISBN10(isbn10); err != nil {
+			v.Add("isbn10", err.Error())
+			valid = false
+		}
+	}
+	if b.ISBN13 != "" {
+		if err := checkISBN13(isbn13); err != nil {
+			v.Add("isbn13", err.Error())
+			valid = false
+		}
+	}
+	if valid && b.ISBN10 != "" && b.ISBN13 != "" && ISBN10To13(isbn10) != isbn13 {
+		v.Add("isbn10", "does not match isbn13")
+	}
+	return v.Err()
+}
+
+// ISBN returns the normalized ISBN-13 of a validated request, converting an
+// ISBN-10 if only that was given, or "" without an ISBN.
+func (b *BookRequest) ISBN() string {
+	if b.ISBN13 != "" {
+		return NormalizeISBN(b.ISBN13)
+	}
+	if b.ISBN10 != "" {
+		return ISBN10To13(NormalizeISBN(b.ISBN10))
+	}
+	return ""
+}
+
+// BookSearchResult is a book found by full-text search. Highlights holds the
+// matched fields with the hits wrapped in <mark></mark>.
+type BookSearchResult struct {
+	Book
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
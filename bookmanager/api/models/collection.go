@@ -1,31 +1,340 @@
-package models
-
-import (
-	"fmt"
-	"time"
-)
-
-type Collection struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time    `json:"updated_at"`
-}
-
-type CollectionRequest struct {
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-}
-
-func (c *CollectionRequest) Validate() error {
-	if c.Name == "" {
-		return fmt.Errorf("name is required")
-	}
-	return nil
-}
-
-type CollectionBook struct {
-	CollectionID int `json:"collection_id"`
-	BookID int `json:"book_id"`
-}
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Collection kinds. A manual collection holds the books added to it. A
+// smart collection holds the books matching its Query when it is read,
+// plus the books added to it and minus the books removed from it by hand.
+const (
+	CollectionManual = "manual"
+	CollectionSmart  = "smart"
+)
+
+type Collection struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Kind        string      `json:"kind"`
+	Query       *SmartQuery `json:"query,omitempty"`
+	// ParentID is the collection this one is a sub-collection of, nil for
+	// a top-level collection.
+	ParentID  *int      `json:"parent_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+	// DeletedAt is set for collections in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// SmartQuery is the saved query of a smart collection. The fields work like
+// the query parameters of the same names of GET /api/v1/books; without an
+// order the books are ordered by title.
+type SmartQuery struct {
+	Where           string `json:"where,omitempty"`
+	OrderBy         string `json:"order_by,omitempty"`
+	Author          string `json:"author,omitempty"`
+	Genre           string `json:"genre,omitempty"`
+	PublishedAfter  string `json:"published_after,omitempty"`
+	PublishedBefore string `json:"published_before,omitempty"`
+}
+
+// Values returns the query as the query parameters of GET /api/v1/books.
+func (q *SmartQuery) Values() url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"where":            q.Where,
+		"order_by":         q.OrderBy,
+		"author":           q.Author,
+		"genre":            q.Genre,
+		"published_after":  q.PublishedAfter,
+		"published_before": q.PublishedBefore,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	return values
+}
+
+// CollectionNode is a collection with its sub-collections, by name.
+type CollectionNode struct {
+	Collection
+	Children []CollectionNode `json:"children"`
+}
+
+// BookCollection is a collection a book is in. AddedAt is when the book
+// was added to it, nil for a smart collection the book is in because it
+// matches the query.
+type BookCollection struct {
+	Collection
+	AddedAt *time.Time `json:"added_at,omitempty"`
+}
+
+// CollectionRequest creates a smart collection when it has a Query; Kind
+// may be left out. The kind of a collection cannot be changed. Without a
+// ParentID the collection is a top-level one.
+type CollectionRequest struct {
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Kind        string      `json:"kind,omitempty"`
+	Query       *SmartQuery `json:"query,omitempty"`
+	ParentID    *int        `json:"parent_id,omitempty"`
+}
+
+func (c *CollectionRequest) Validate() error {
+	v := &ValidationError{}
+	c.validate(v)
+	return v.Err()
+}
+
+func (c *CollectionRequest) validate(v *ValidationError) {
+	if c.Name == "" {
+		v.Add("name", "is required")
+	}
+	switch c.Kind {
+	case "", CollectionManual, CollectionSmart:
+	default:
+		v.Add("kind", "must be manual or smart")
+	}
+	if c.Kind == CollectionSmart && c.Query == nil {
+		v.Add("query", "is required for a smart collection")
+	}
+	if c.Kind == CollectionManual && c.Query != nil {
+		v.Add("query", "is only allowed for a smart collection")
+	}
+	if c.ParentID != nil && *c.ParentID <= 0 {
+		v.Add("parent_id", "must be a collection ID")
+	}
+}
+
+// CollectionKind returns Kind, or the kind implied by Query when it is not
+// set.
+func (c *CollectionRequest) CollectionKind() string {
+	switch {
+	case c.Kind != "":
+		return c.Kind
+	case c.Query != nil:
+		return CollectionSmart
+	}
+	return CollectionManual
+}
+
+// CollectionSetRequest creates a manual collection holding the books of
+// the set expression Expr over collections, e.g. "(1 UNION 2) EXCEPT 3",
+// in the order of OrderBy, by title without.
+type CollectionSetRequest struct {
+	CollectionRequest
+	Expr    string `json:"expr"`
+	OrderBy string `json:"order_by,omitempty"`
+}
+
+func (c *CollectionSetRequest) Validate() error {
+	v := &ValidationError{}
+	c.validate(v)
+	if c.Expr == "" {
+		v.Add("expr", "is required")
+	}
+	if c.CollectionKind() != CollectionManual {
+		v.Add("kind", "must be manual")
+	}
+	return v.Err()
+}
+
+// CollectionMove makes a collection a sub-collection of ParentID, or a
+// top-level collection when ParentID is nil.
+type CollectionMove struct {
+	ParentID *int `json:"parent_id"`
+}
+
+func (m *CollectionMove) Validate() error {
+	v := &ValidationError{}
+	if m.ParentID != nil && *m.ParentID <= 0 {
+		v.Add("parent_id", "must be a collection ID")
+	}
+	return v.Err()
+}
+
+// What happens to the sub-collections of a deleted collection: they move up
+// to its parent, or go to the trash with it.
+const (
+	DeleteRehome  = "rehome"
+	DeleteCascade = "cascade"
+)
+
+type CollectionBook struct {
+	CollectionID int `json:"collection_id"`
+	BookID       int `json:"book_id"`
+}
+
+// CollectionBookRequest adds a book to a collection at Position, where 1 is
+// the first place. Without a position, or one past the end, the book is
+// added at the end.
+type CollectionBookRequest struct {
+	BookID   int `json:"book_id"`
+	Position int `json:"position,omitempty"`
+}
+
+func (c *CollectionBookRequest) Validate() error {
+	v := &ValidationError{}
+	if c.BookID == 0 {
+		v.Add("book_id", "is required")
+	}
+	if c.Position < 0 {
+		v.Add("position", "must not be negative")
+	}
+	return v.Err()
+}
+
+// BookMove moves a book within a collection to a position, or right before
+// or after another book of the collection. Exactly one of them is set.
+type BookMove struct {
+	Position int `json:"position,omitempty"`
+	Before   int `json:"before,omitempty"`
+	After    int `json:"after,omitempty"`
+}
+
+func (m *BookMove) Validate() error {
+	v := &ValidationError{}
+	set := 0
+	for _, n := range []int{m.Position, m.Before, m.After} {
+		if n != 0 {
+			set++
+		}
+	}
+	if set != 1 {
+		v.Add("position", "exactly one of position, before and after is required")
+	}
+	if m.Position < 0 {
+		v.Add("position", "must not be negative")
+	}
+	return v.Err()
+}
+
+// BookSelection selects books by ID or, with Query, all books matching it.
+// Exactly one of them is set. Books by ID are taken in the order given,
+// books matching a query in the order of the query.
+type BookSelection struct {
+	BookIDs []int       `json:"book_ids,omitempty"`
+	Query   *SmartQuery `json:"query,omitempty"`
+}
+
+func (s *BookSelection) Validate() error {
+	v := &ValidationError{}
+	s.validate(v)
+	return v.Err()
+}
+
+func (s *BookSelection) validate(v *ValidationError) {
+	if (len(s.BookIDs) == 0) == (s.Query == nil) {
+		v.Add("book_ids", "exactly one of book_ids and query is required")
+	}
+	seen := make(map[int]bool, len(s.BookIDs))
+	for _, id := range s.BookIDs {
+		if id <= 0 {
+			v.Add("book_ids", "must be book IDs")
+		} else if seen[id] {
+			v.Add("book_ids", fmt.Sprintf("lists book %d more than once", id))
+		}
+		seen[id] = true
+	}
+}
+
+// Modes of a BookTransfer.
+const (
+	TransferCopy = "copy"
+	TransferMove = "move"
+)
+
+// BookTransfer adds the selected books of a collection to collection To.
+// With TransferMove they leave the collection, whether they were added to
+// To or were in it already. Books selected by Query are those of the
+// collection matching it.
+type BookTransfer struct {
+	BookSelection
+	To   int    `json:"to"`
+	Mode string `json:"mode"`
+}
+
+func (t *BookTransfer) Validate() error {
+	v := &ValidationError{}
+	t.validate(v)
+	if t.To <= 0 {
+		v.Add("to", "is required")
+	}
+	switch t.Mode {
+	case TransferCopy, TransferMove:
+	default:
+		v.Add("mode", "must be copy or move")
+	}
+	return v.Err()
+}
+
+// Outcomes for a book of a bulk change to the books of a collection.
+// Present is for a book that was in the collection already, Absent for one
+// that was not in it, and Missing for a book that does not exist or is in
+// the trash.
+const (
+	MemberAdded   = "added"
+	MemberRemoved = "removed"
+	MemberPresent = "present"
+	MemberAbsent  = "absent"
+	MemberMissing = "missing"
+)
+
+type MemberResult struct {
+	BookID int    `json:"book_id"`
+	Status string `json:"status"`
+}
+
+// MembershipReport describes a bulk change to the books of a collection
+// book by book, in the order the books were selected.
+type MembershipReport struct {
+	Added   int            `json:"added"`
+	Removed int            `json:"removed"`
+	Present int            `json:"present"`
+	Absent  int            `json:"absent"`
+	Missing int            `json:"missing"`
+	Books   []MemberResult `json:"books"`
+}
+
+func (r *MembershipReport) Add(bookID int, status string) {
+	switch status {
+	case MemberAdded:
+		r.Added++
+	case MemberRemoved:
+		r.Removed++
+	case MemberPresent:
+		r.Present++
+	case MemberAbsent:
+		r.Absent++
+	case MemberMissing:
+		r.Missing++
+	}
+	r.Books = append(r.Books, MemberResult{BookID: bookID, Status: status})
+}
+
+// CollectionOrderRequest lists books of a collection in their new order.
+// They take the places they hold between them, so listing all books of the
+// collection orders it fully and listing some only reorders those.
+type CollectionOrderRequest struct {
+	BookIDs []int `json:"book_ids"`
+}
+
+func (c *CollectionOrderRequest) Validate() error {
+	v := &ValidationError{}
+	if len(c.BookIDs) == 0 {
+		v.Add("book_ids", "is required")
+	}
+	seen := make(map[int]bool, len(c.BookIDs))
+	for _, id := range c.BookIDs {
+		if seen[id] {
+			v.Add("book_ids", fmt.Sprintf("lists book %d more than once", id))
+		}
+		seen[id] = true
+	}
+	return v.Err()
+}
@@ -0,0 +1,48 @@
+package models
+
+// Outcomes of a row of a book import.
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportResult is the outcome of one row of a book import. Row counts the
+// records of the file from 1, without a CSV header. ID is the book created,
+// updated or skipped; it is left out for books that were not created after
+// all because the import was not committed.
+type ImportResult struct {
+	Row     int          `json:"row"`
+	Status  string       `json:"status"`
+	ID      int          `json:"id,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// ImportReport describes a book import row by row. An import is all or
+// nothing: nothing is written unless Committed, which it is not for a dry run
+// or when a row failed.
+type ImportReport struct {
+	DryRun    bool           `json:"dry_run"`
+	Committed bool           `json:"committed"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Skipped   int            `json:"skipped"`
+	Failed    int            `json:"failed"`
+	Rows      []ImportResult `json:"rows"`
+}
+
+func (r *ImportReport) Add(result ImportResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportSkipped:
+		r.Skipped++
+	case ImportFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// NormalizeISBN strips hyphens and spaces from isbn and upper-cases a
+// trailing x, without checking it.
+func NormalizeISBN(isbn string) string {
+	isbn = strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn))
+	return strings.ToUpper(isbn)
+}
+
+// ParseISBN returns isbn, an ISBN-10 or ISBN-13 with or without hyphens, in
+// its normalized 13 digit form.
+func ParseISBN(isbn string) (string, error) {
+	isbn = NormalizeISBN(isbn)
+	switch len(isbn) {
+	case 10:
+		if err := checkISBN10(isbn); err != nil {
+			return "", err
+		}
+		return ISBN10To13(isbn), nil
+	case 13:
+		if err := checkISBN13(isbn); err != nil {
+			return "", err
+		}
+		return isbn, nil
+	}
+	return "", errors.New("must be an ISBN-10 or ISBN-13")
+}
+
+// checkISBN10 reports whether isbn is a normalized ISBN-10 with a valid
+// check digit.
+func checkISBN10(isbn string) error {
+	if len(isbn) != 10 {
+		return errors.New("must have 10 digits, the last may be X")
+	}
+	sum := 0
+	for i, c := range isbn {
+		d := int(c - '0')
+		switch {
+		case c == 'X' && i == 9:
+			d = 10
+		case c < '0' || c > '9':
+			return errors.New("must have 10 digits, the last may be X")
+		}
+		sum += (10 - i) * d
+	}
+	if sum%11 != 0 {
+		return errors.New("has an invalid check digit")
+	}
+	return nil
+}
+
+// checkISBN13 reports whether isbn is a normalized ISBN-13 with a valid
+// check digit.
+func checkISBN13(isbn string) error {
+	if len(isbn) != 13 || strings.Trim(isbn, "0123456789") != "" {
+		return errors.New("must have 13 digits")
+	}
+	if !strings.HasPrefix(isbn, "978") && !strings.HasPrefix(isbn, "979") {
+		return errors.New("must start with 978 or 979")
+	}
+	if isbn13CheckDigit(isbn[:12]) != isbn[12] {
+		return errors.New("has an invalid check digit")
+	}
+	return nil
+}
+
+func isbn13CheckDigit(digits string) byte {
+	sum := 0
+	for i, c := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(c-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ISBN10To13 converts a valid, normalized ISBN-10 to its ISBN-13.
+func ISBN10To13(isbn string) string {
+	digits := "978" + isbn[:9]
+	return digits + string(isbn13CheckDigit(digits))
+}
+
+// ISBN13To10 converts a valid, normalized ISBN-13 to its ISBN-10. ISBNs
+// starting with 979 have no ISBN-10 and give "".
+func ISBN13To10(isbn string) string {
+	if !strings.HasPrefix(isbn, "978") {
+		return ""
+	}
+	digits := isbn[3:12]
+	sum := 0
+	for i, c := range digits {
+		sum += (10 - i) * int(c-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return digits + "X"
+	}
+	return digits + string(byte('0'+check))
+}
@@ -0,0 +1,60 @@
+package models
+
+import "testing"
+
+func TestParseISBN(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"0-306-40615-2", "9780306406157", ""},
+		{"978-0-306-40615-7", "9780306406157", ""},
+		{" 0 8044 2957 X ", "9780804429573", ""},
+		{"080442957x", "9780804429573", ""},
+		{"979-10-90636-07-1", "9791090636071", ""},
+		{"0306406153", "", "has an invalid check digit"},
+		{"9780306406158", "", "has an invalid check digit"},
+		{"X306406152", "", "must have 10 digits, the last may be X"},
+		{"97803064061X7", "", "must have 13 digits"},
+		{"9770306406155", "", "must start with 978 or 979"},
+		{"12345", "", "must be an ISBN-10 or ISBN-13"},
+		{"", "", "must be an ISBN-10 or ISBN-13"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseISBN(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("ParseISBN(%q) = %q, %v; want error %q", tt.input, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseISBN(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestISBNConversion(t *testing.T) {
+	tests := []struct {
+		isbn10 string
+		isbn13 string
+	}{
+		{"0306406152", "9780306406157"},
+		{"080442957X", "9780804429573"},
+		{"097522980X", "9780975229804"},
+	}
+	for _, tt := range tests {
+		if got := ISBN10To13(tt.isbn10); got != tt.isbn13 {
+			t.Errorf("ISBN10To13(%s) = %s, want %s", tt.isbn10, got, tt.isbn13)
+		}
+		if got := ISBN13To10(tt.isbn13); got != tt.isbn10 {
+			t.Errorf("ISBN13To10(%s) = %s, want %s", tt.isbn13, got, tt.isbn10)
+		}
+	}
+	if got := ISBN13To10("9791090636071"); got != "" {
+		t.Errorf("ISBN13To10 of a 979 ISBN = %q, want none", got)
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Revision actions.
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionPatch   = "patch"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+	RevisionMove    = "move"
+)
+
+// Revision records one change of a book or collection. Revision is the
+// version the record had after the change. Snapshot is the full record as
+// it was written; it is left out of history listings.
+type Revision struct {
+	Revision     int             `json:"revision"`
+	Action       string          `json:"action"`
+	Actor        string          `json:"actor"`
+	RequestID    string          `json:"request_id,omitempty"`
+	RevertedFrom *int            `json:"reverted_from,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Changes      []FieldChange   `json:"changes"`
+	Snapshot     json.RawMessage `json:"snapshot,omitempty"`
+}
+
+// FieldChange is the old and new JSON value of a changed field; null when
+// the field was not set.
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old"`
+	New   json.RawMessage `json:"new"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// BookStats summarizes the books matching a filter.
+type BookStats struct {
+	Count   int `json:"count"`
+	Authors int `json:"authors"`
+	Genres  int `json:"genres"`
+	// Edition is nil when there are no books.
+	Edition *NumberStats `json:"edition"`
+	// PublishedDate bounds are dates in YYYY-MM-DD format.
+	PublishedDate *Range[string]    `json:"published_date"`
+	CreatedAt     *Range[time.Time] `json:"created_at"`
+	// InCollections counts the books that belong to at least one collection.
+	InCollections int `json:"in_collections"`
+	// AvgCollections is the average number of collections per book.
+	AvgCollections float64 `json:"avg_collections"`
+}
+
+type NumberStats struct {
+	Min int     `json:"min"`
+	Max int     `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+type Range[T any] struct {
+	Min T `json:"min"`
+	Max T `json:"max"`
+}
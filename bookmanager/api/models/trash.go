@@ -0,0 +1,13 @@
+package models
+
+// Trash lists the deleted books and collections, most recently deleted first.
+type Trash struct {
+	Books       []Book       `json:"books"`
+	Collections []Collection `json:"collections"`
+}
+
+// PurgeResult counts the books and collections removed from the trash.
+type PurgeResult struct {
+	Books       int `json:"books"`
+	Collections int `json:"collections"`
+}
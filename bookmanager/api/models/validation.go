@@ -0,0 +1,35 @@
+package models
+
+import "strings"
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every field problem found in a request.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (v *ValidationError) Error() string {
+	messages := make([]string, len(v.Errors))
+	for i, e := range v.Errors {
+		messages[i] = e.Field + " " + e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (v *ValidationError) Add(field, message string) {
+	v.Errors = append(v.Errors, FieldError{Field: field, Message: message})
+}
+
+// Err returns nil when no problems were added, so Validate methods can
+// simply `return v.Err()`.
+func (v *ValidationError) Err() error {
+	if len(v.Errors) == 0 {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,272 @@
+package patch
+
+import (
+	"bookmanager/api/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operation is one step of a JSON Patch. Path and From are JSON Pointers
+// into the document, e.g. "/description" or "/authors/0/role".
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is an RFC 6902 patch. Its operations are applied in order and
+// the patch fails as a whole if any of them fails.
+type JSONPatch []Operation
+
+func parseJSONPatch(body []byte) (JSONPatch, error) {
+	var p JSONPatch
+	if err := decode(body, &p); err != nil || p == nil {
+		return nil, bodyError("must be a JSON array of operations")
+	}
+
+	v := &models.ValidationError{}
+	for i, op := range p {
+		field := fmt.Sprintf("operations[%d]", i)
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				v.Add(field+".value", "is required")
+			}
+		case "move", "copy":
+			if _, ok := parsePointer(op.From); !ok {
+				v.Add(field+".from", "must point into the document, e.g. /description")
+			}
+		case "remove":
+		default:
+			v.Add(field+".op", "must be add, remove, replace, move, copy or test")
+		}
+		if _, ok := parsePointer(op.Path); !ok {
+			v.Add(field+".path", "must point into the document, e.g. /description")
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens.
+// The empty pointer, the whole document, cannot be patched.
+func parsePointer(pointer string) ([]string, bool) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, token := range tokens {
+		tokens[i] = unescape.Replace(token)
+	}
+	return tokens, true
+}
+
+func (p JSONPatch) apply(doc Document) error {
+	root := map[string]interface{}(doc)
+	for i, op := range p {
+		path, _ := parsePointer(op.Path)
+		pathErr := func(err error) error {
+			return &models.ValidationError{Errors: []models.FieldError{
+				{Field: fmt.Sprintf("operations[%d].path", i), Message: err.Error()},
+			}}
+		}
+
+		var value interface{}
+		if op.Value != nil {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return bodyError("must be a JSON array of operations")
+			}
+		}
+		if op.Op == "move" || op.Op == "copy" {
+			from, _ := parsePointer(op.From)
+			found, err := get(root, from, op.From)
+			if err != nil {
+				return &models.ValidationError{Errors: []models.FieldError{
+					{Field: fmt.Sprintf("operations[%d].from", i), Message: err.Error()},
+				}}
+			}
+			value = deepCopy(found)
+			if op.Op == "move" {
+				if strings.HasPrefix(op.Path, op.From+"/") {
+					return pathErr(fmt.Errorf("cannot move %s into itself", op.From))
+				}
+				if _, err := update(root, from, op.From, remove); err != nil {
+					return pathErr(err)
+				}
+			}
+		}
+
+		var err error
+		switch op.Op {
+		case "add", "move", "copy":
+			_, err = update(root, path, op.Path, func(container interface{}, token, pointer string) (interface{}, error) {
+				return add(container, token, pointer, value)
+			})
+		case "remove":
+			_, err = update(root, path, op.Path, remove)
+		case "replace":
+			_, err = update(root, path, op.Path, func(container interface{}, token, pointer string) (interface{}, error) {
+				return replace(container, token, pointer, value)
+			})
+		case "test":
+			var current interface{}
+			current, err = get(root, path, op.Path)
+			if err == nil && !equalJSON(current, op.Value) {
+				return fmt.Errorf("%w: operation %d: %s is not %s", ErrTestFailed, i, op.Path, op.Value)
+			}
+		}
+		if err != nil {
+			return pathErr(err)
+		}
+	}
+	return nil
+}
+
+// get returns the value pointer refers to.
+func get(node interface{}, tokens []string, pointer string) (interface{}, error) {
+	for _, token := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", pointer)
+			}
+			node = child
+		case []interface{}:
+			i, ok := arrayIndex(token, len(n)-1)
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", pointer)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+	}
+	return node, nil
+}
+
+// update applies change to the object or array holding the last token of
+// the pointer and stores the container change returns in its parent.
+func update(node interface{}, tokens []string, pointer string, change func(container interface{}, token, pointer string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return change(node, tokens[0], pointer)
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		changed, err := update(child, tokens[1:], pointer, change)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = changed
+		return n, nil
+	case []interface{}:
+		i, ok := arrayIndex(tokens[0], len(n)-1)
+		if !ok {
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		changed, err := update(n[i], tokens[1:], pointer, change)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = changed
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%s does not exist", pointer)
+	}
+}
+
+// add sets an object member, or inserts into an array before the index
+// token or, for "-", at its end.
+func add(container interface{}, token, pointer string, value interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		c[token] = value
+		return c, nil
+	case []interface{}:
+		i, ok := len(c), token == "-"
+		if !ok {
+			i, ok = arrayIndex(token, len(c))
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s is not an index of the array", pointer)
+		}
+		c = append(c, nil)
+		copy(c[i+1:], c[i:])
+		c[i] = value
+		return c, nil
+	default:
+		return nil, fmt.Errorf("%s does not exist", pointer)
+	}
+}
+
+func remove(container interface{}, token, pointer string) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		if _, ok := c[token]; !ok {
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		delete(c, token)
+		return c, nil
+	case []interface{}:
+		i, ok := arrayIndex(token, len(c)-1)
+		if !ok {
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		return append(c[:i], c[i+1:]...), nil
+	default:
+		return nil, fmt.Errorf("%s does not exist", pointer)
+	}
+}
+
+func replace(container interface{}, token, pointer string, value interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		if _, ok := c[token]; !ok {
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		c[token] = value
+		return c, nil
+	case []interface{}:
+		i, ok := arrayIndex(token, len(c)-1)
+		if !ok {
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		c[i] = value
+		return c, nil
+	default:
+		return nil, fmt.Errorf("%s does not exist", pointer)
+	}
+}
+
+// arrayIndex parses an array index token, which has no sign or leading
+// zeros, up to max.
+func arrayIndex(token string, max int) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(token)
+	return i, err == nil && i <= max
+}
+
+// equalJSON compares a document value with a raw JSON value by their
+// encodings, so 2 and 2.0 are equal.
+func equalJSON(value interface{}, raw json.RawMessage) bool {
+	var other interface{}
+	if err := json.Unmarshal(raw, &other); err != nil {
+		return false
+	}
+	a, errA := json.Marshal(value)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
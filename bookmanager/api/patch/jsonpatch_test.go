@@ -0,0 +1,149 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"bookmanager/api/models"
+)
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"foo":"bar","list":[1,2,3],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"add member", `[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"foo":"bar","baz":"qux","list":[1,2,3],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"add replaces member", `[{"op":"add","path":"/foo","value":[1]}]`,
+			`{"foo":[1],"list":[1,2,3],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"add nested", `[{"op":"add","path":"/obj/a/c","value":null}]`,
+			`{"foo":"bar","list":[1,2,3],"obj":{"a":{"b":1,"c":null}},"a/b":1,"m~n":2}`},
+		{"insert into array", `[{"op":"add","path":"/list/1","value":"x"}]`,
+			`{"foo":"bar","list":[1,"x",2,3],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"add at array end", `[{"op":"add","path":"/list/3","value":4}]`,
+			`{"foo":"bar","list":[1,2,3,4],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"append", `[{"op":"add","path":"/list/-","value":4}]`,
+			`{"foo":"bar","list":[1,2,3,4],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"remove member", `[{"op":"remove","path":"/foo"}]`,
+			`{"list":[1,2,3],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"remove element", `[{"op":"remove","path":"/list/0"}]`,
+			`{"foo":"bar","list":[2,3],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"remove escaped", `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/m~0n"}]`,
+			`{"foo":"bar","list":[1,2,3],"obj":{"a":{"b":1}}}`},
+		{"replace", `[{"op":"replace","path":"/obj/a/b","value":{"c":2}}]`,
+			`{"foo":"bar","list":[1,2,3],"obj":{"a":{"b":{"c":2}}},"a/b":1,"m~n":2}`},
+		{"replace element", `[{"op":"replace","path":"/list/2","value":9}]`,
+			`{"foo":"bar","list":[1,2,9],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"move", `[{"op":"move","path":"/obj/foo","from":"/foo"}]`,
+			`{"list":[1,2,3],"obj":{"a":{"b":1},"foo":"bar"},"a/b":1,"m~n":2}`},
+		{"move element", `[{"op":"move","path":"/list/0","from":"/list/2"}]`,
+			`{"foo":"bar","list":[3,1,2],"obj":{"a":{"b":1}},"a/b":1,"m~n":2}`},
+		{"copy", `[{"op":"copy","path":"/obj/list","from":"/list"},{"op":"add","path":"/list/-","value":4}]`,
+			`{"foo":"bar","list":[1,2,3,4],"obj":{"a":{"b":1},"list":[1,2,3]},"a/b":1,"m~n":2}`},
+		{"test", `[{"op":"test","path":"/obj","value":{"a":{"b":1.0}}},{"op":"test","path":"/list/1","value":2},{"op":"test","path":"/a~1b","value":1}]`,
+			doc},
+		{"operations in order", `[{"op":"add","path":"/n","value":1},{"op":"test","path":"/n","value":1},{"op":"remove","path":"/n"}]`,
+			doc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(JSONPatchType, []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Document(decodeJSON(t, doc).(map[string]interface{}))
+			if err := p.apply(got); err != nil {
+				t.Fatal(err)
+			}
+			if want := Document(decodeJSON(t, tt.want).(map[string]interface{})); !reflect.DeepEqual(got, want) {
+				t.Errorf("apply = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	doc := `{"foo":"bar","list":[1,2,3],"obj":{"a":1}}`
+	tests := []struct {
+		name  string
+		patch string
+		field string // "" for a failed test operation
+	}{
+		{"test fails", `[{"op":"test","path":"/foo","value":"baz"}]`, ""},
+		{"test type differs", `[{"op":"test","path":"/list/0","value":"1"}]`, ""},
+		{"later test fails", `[{"op":"remove","path":"/foo"},{"op":"test","path":"/obj","value":{}}]`, ""},
+		{"test missing", `[{"op":"test","path":"/missing","value":1}]`, "operations[0].path"},
+		{"remove missing", `[{"op":"remove","path":"/missing"}]`, "operations[0].path"},
+		{"replace missing", `[{"op":"replace","path":"/obj/b","value":1}]`, "operations[0].path"},
+		{"add without parent", `[{"op":"add","path":"/missing/a","value":1}]`, "operations[0].path"},
+		{"add into string", `[{"op":"add","path":"/foo/a","value":1}]`, "operations[0].path"},
+		{"index out of range", `[{"op":"add","path":"/list/4","value":1}]`, "operations[0].path"},
+		{"leading zero", `[{"op":"replace","path":"/list/01","value":1}]`, "operations[0].path"},
+		{"negative index", `[{"op":"remove","path":"/list/-1"}]`, "operations[0].path"},
+		{"dash outside add", `[{"op":"remove","path":"/list/-"}]`, "operations[0].path"},
+		{"move missing", `[{"op":"move","path":"/a","from":"/missing"}]`, "operations[0].from"},
+		{"move into itself", `[{"op":"move","path":"/obj/a/b","from":"/obj"}]`, "operations[0].path"},
+		{"copy missing", `[{"op":"copy","path":"/a","from":"/list/3"}]`, "operations[0].from"},
+		{"second operation", `[{"op":"remove","path":"/foo"},{"op":"remove","path":"/foo"}]`, "operations[1].path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(JSONPatchType, []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.apply(Document(decodeJSON(t, doc).(map[string]interface{})))
+			if tt.field == "" {
+				if !errors.Is(err, ErrTestFailed) {
+					t.Errorf("apply error = %v, want ErrTestFailed", err)
+				}
+				return
+			}
+			var v *models.ValidationError
+			if !errors.As(err, &v) || len(v.Errors) != 1 || v.Errors[0].Field != tt.field {
+				t.Errorf("apply error = %v, want a validation error on %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestParseJSONPatchErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		patch  string
+		fields []string
+	}{
+		{"not an array", `{"op":"remove","path":"/a"}`, []string{"body"}},
+		{"null", `null`, []string{"body"}},
+		{"unknown op", `[{"op":"merge","path":"/a"}]`, []string{"operations[0].op"}},
+		{"missing path", `[{"op":"remove"}]`, []string{"operations[0].path"}},
+		{"whole document", `[{"op":"replace","path":"","value":{}}]`, []string{"operations[0].path"}},
+		{"relative path", `[{"op":"remove","path":"a"}]`, []string{"operations[0].path"}},
+		{"missing value", `[{"op":"add","path":"/a"},{"op":"test","path":"/a"}]`, []string{"operations[0].value", "operations[1].value"}},
+		{"missing from", `[{"op":"move","path":"/a"},{"op":"copy","path":"/a","from":"b"}]`, []string{"operations[0].from", "operations[1].from"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(JSONPatchType, []byte(tt.patch))
+			var v *models.ValidationError
+			if !errors.As(err, &v) {
+				t.Fatalf("Parse error = %v, want a validation error", err)
+			}
+			var fields []string
+			for _, e := range v.Errors {
+				fields = append(fields, e.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("Parse reported %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+
+	// A null value is a value.
+	if _, err := Parse(JSONPatchType, []byte(`[{"op":"replace","path":"/a","value":null}]`)); err != nil {
+		t.Errorf("Parse(null value) = %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+// Package patch implements the PATCH document formats accepted by the API:
+// JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902).
+//
+// Patches are applied to a Document holding the editable fields of a record
+// and the result is checked against a Schema, so a patch can clear optional
+// fields but cannot remove required ones or change their type.
+package patch
+
+import (
+	"bookmanager/api/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"sort"
+)
+
+// Media types of the supported patch formats. Plain application/json is
+// treated as a merge patch.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned by Parse for other content types.
+	ErrUnsupportedMediaType = errors.New("unsupported patch media type")
+	// ErrTestFailed is returned when a JSON Patch test operation does not hold.
+	ErrTestFailed = errors.New("test failed")
+)
+
+// Document is a JSON object: field name to string, int, array, object
+// or nil.
+type Document map[string]interface{}
+
+// String returns the named string field, "" when it is missing.
+func (d Document) String(name string) string {
+	s, _ := d[name].(string)
+	return s
+}
+
+// Int returns the named integer field, 0 when it is missing.
+func (d Document) Int(name string) int {
+	n, _ := d[name].(int)
+	return n
+}
+
+// Patch is a parsed patch document.
+type Patch interface {
+	apply(doc Document) error
+}
+
+// Parse reads a patch of the given Content-Type.
+func Parse(contentType string, body []byte) (Patch, error) {
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+		}
+	}
+
+	switch mediaType {
+	case "application/json", MergePatchType:
+		return parseMergePatch(body)
+	case JSONPatchType:
+		return parseJSONPatch(body)
+	default:
+		return nil, fmt.Errorf("%w: %s (use %s or %s)", ErrUnsupportedMediaType, mediaType, MergePatchType, JSONPatchType)
+	}
+}
+
+// MergePatch is an RFC 7396 merge patch: members replace the fields of the
+// same name and null members remove them. Objects are merged recursively.
+type MergePatch map[string]interface{}
+
+func parseMergePatch(body []byte) (MergePatch, error) {
+	var p MergePatch
+	if err := decode(body, &p); err != nil || p == nil {
+		return nil, bodyError("must be a JSON object")
+	}
+	return p, nil
+}
+
+func (p MergePatch) apply(doc Document) error {
+	mergeObject(doc, p)
+	return nil
+}
+
+// merge returns target with patch applied, the MergePatch function of RFC
+// 7396. Objects of target are changed in place.
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+	mergeObject(t, p)
+	return t
+}
+
+func mergeObject(target, patch map[string]interface{}) {
+	for name, value := range patch {
+		if value == nil {
+			delete(target, name)
+		} else {
+			target[name] = merge(target[name], value)
+		}
+	}
+}
+
+// Kind is the JSON type of a field.
+type Kind int
+
+const (
+	String Kind = iota
+	Integer
+	// Array fields hold decoded JSON arrays, []interface{}. They are
+	// replaced as a whole.
+	Array
+	// Object fields hold decoded JSON objects, map[string]interface{}. A
+	// merge patch merges into them.
+	Object
+)
+
+// Field describes a patchable field. Only optional fields may be cleared.
+type Field struct {
+	Kind     Kind
+	Optional bool
+}
+
+// Schema lists the fields of a Document.
+type Schema map[string]Field
+
+// Apply applies p to a deep copy of doc. The result may only contain fields of the
+// schema with values of the right kind, and must still contain every field
+// that is not optional. Clearing a field, by null or by removing it, leaves
+// it out of the result.
+func (s Schema) Apply(p Patch, doc Document) (Document, error) {
+	out := make(Document, len(doc))
+	for name, value := range doc {
+		out[name] = deepCopy(value)
+	}
+	if err := p.apply(out); err != nil {
+		return nil, err
+	}
+
+	v := &models.ValidationError{}
+	for _, name := range sortedNames(out) {
+		field, ok := s[name]
+		if !ok {
+			v.Add(name, "cannot be patched")
+			continue
+		}
+		value, ok := field.normalize(out[name])
+		switch {
+		case !ok && field.Kind == Integer:
+			v.Add(name, "must be an integer")
+		case !ok && field.Kind == Array:
+			v.Add(name, "must be an array")
+		case !ok && field.Kind == Object:
+			v.Add(name, "must be an object")
+		case !ok:
+			v.Add(name, "must be a string")
+		case value == nil:
+			delete(out, name)
+		default:
+			out[name] = value
+		}
+	}
+	for _, name := range sortedNames(s) {
+		if _, ok := out[name]; !ok && !s[name].Optional {
+			v.Add(name, "cannot be cleared")
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// normalize converts a decoded JSON value to the Go type of the field. Null
+// stays nil; values of the wrong kind are reported with ok == false.
+func (f Field) normalize(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	switch f.Kind {
+	case Integer:
+		switch n := value.(type) {
+		case int:
+			return n, true
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+				return int(n), true
+			}
+		}
+		return nil, false
+	case Array:
+		a, ok := value.([]interface{})
+		return a, ok
+	case Object:
+		o, ok := value.(map[string]interface{})
+		return o, ok
+	default:
+		s, ok := value.(string)
+		return s, ok
+	}
+}
+
+// deepCopy copies the objects and arrays of a decoded JSON value, so a
+// patch can change them in place.
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for name, x := range v {
+			c[name] = deepCopy(x)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, x := range v {
+			c[i] = deepCopy(x)
+		}
+		return c
+	}
+	return value
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decode unmarshals exactly one JSON value.
+func decode(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the patch")
+	}
+	return nil
+}
+
+func bodyError(message string) error {
+	return &models.ValidationError{Errors: []models.FieldError{{Field: "body", Message: message}}}
+}
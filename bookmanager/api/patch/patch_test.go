@@ -0,0 +1,167 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"bookmanager/api/models"
+)
+
+// decodeJSON decodes a JSON value for comparisons.
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+// TestMerge runs the examples of RFC 7396, Appendix A.
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target+" "+tt.patch, func(t *testing.T) {
+			got := merge(decodeJSON(t, tt.target), decodeJSON(t, tt.patch))
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("merge = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+var testSchema = Schema{
+	"title":   {Kind: String},
+	"edition": {Kind: Integer},
+	"genre":   {Kind: String, Optional: true},
+	"authors": {Kind: Array},
+	"query":   {Kind: Object, Optional: true},
+}
+
+func testDocument() Document {
+	return Document{
+		"title":   "Dune",
+		"edition": 1,
+		"genre":   "Science Fiction",
+		"authors": []interface{}{
+			map[string]interface{}{"name": "Frank Herbert", "role": "author"},
+		},
+		"query": map[string]interface{}{"genre": "Science Fiction", "order_by": "title"},
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  Document
+	}{
+		{"replace and clear", `{"title":"Dune Messiah","edition":2.0,"genre":null}`, Document{
+			"title": "Dune Messiah", "edition": 2, "authors": testDocument()["authors"], "query": testDocument()["query"],
+		}},
+		{"merge object", `{"query":{"genre":null,"author":"Herbert"}}`, Document{
+			"title": "Dune", "edition": 1, "genre": "Science Fiction", "authors": testDocument()["authors"],
+			"query": map[string]interface{}{"author": "Herbert", "order_by": "title"},
+		}},
+		{"replace array", `{"authors":[{"name":"Brian Herbert"}]}`, Document{
+			"title": "Dune", "edition": 1, "genre": "Science Fiction", "query": testDocument()["query"],
+			"authors": []interface{}{map[string]interface{}{"name": "Brian Herbert"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(MergePatchType, []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := testDocument()
+			got, err := testSchema.Apply(p, doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(doc, testDocument()) {
+				t.Errorf("Apply changed its input to %v", doc)
+			}
+		})
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		field string
+	}{
+		{"clear required", `{"title":null}`, "title"},
+		{"unknown field", `{"id":5}`, "id"},
+		{"wrong kind", `{"edition":"second"}`, "edition"},
+		{"fraction", `{"edition":1.5}`, "edition"},
+		{"object for array", `{"authors":{"name":"x"}}`, "authors"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(MergePatchType, []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = testSchema.Apply(p, testDocument())
+			var v *models.ValidationError
+			if !errors.As(err, &v) || len(v.Errors) != 1 || v.Errors[0].Field != tt.field {
+				t.Errorf("Apply error = %v, want a validation error on %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{"", `{}`, nil},
+		{"application/json; charset=utf-8", `{}`, nil},
+		{MergePatchType, `{"a":1}`, nil},
+		{JSONPatchType, `[]`, nil},
+		{"text/plain", `{}`, ErrUnsupportedMediaType},
+		{"not a media type;", `{}`, ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			_, err := Parse(tt.contentType, []byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	for _, body := range []string{`[]`, `null`, `{"a":1} {}`, `{`} {
+		var v *models.ValidationError
+		if _, err := Parse(MergePatchType, []byte(body)); !errors.As(err, &v) {
+			t.Errorf("Parse(%s) error = %v, want a validation error", body, err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Field is a piece of a document with its rank weight. The PostgreSQL
+// weights are A=1.0, B=0.4, C=0.2 and D=0.1.
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// token is a word of a text with its byte offsets.
+type token struct {
+	word       string
+	start, end int
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// matches returns the positions in tokens where the term starts.
+func (t Term) matches(tokens []token) []int {
+	var positions []int
+	for i := 0; i+len(t.Words) <= len(tokens); i++ {
+		ok := true
+		for j, w := range t.Words {
+			got := tokens[i+j].word
+			if t.Prefix && j == len(t.Words)-1 {
+				ok = ok && strings.HasPrefix(got, w)
+			} else {
+				ok = ok && got == w
+			}
+		}
+		if ok {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// Rank scores the fields against the query: the weighted number of hits of
+// every matching clause. Zero means the document does not match. Unlike
+// PostgreSQL, words are compared without stemming.
+func (q *Query) Rank(fields ...Field) float64 {
+	tokens := make([][]token, len(fields))
+	for i, f := range fields {
+		tokens[i] = tokenize(f.Text)
+	}
+
+	rank := 0.0
+	for _, clause := range q.Clauses {
+		score, ok := 0.0, true
+		for _, t := range clause {
+			hits := 0.0
+			for i, f := range fields {
+				hits += float64(len(t.matches(tokens[i]))) * f.Weight
+			}
+			if (hits > 0) == t.Negated {
+				ok = false
+				break
+			}
+			score += hits
+		}
+		if ok {
+			rank += score
+		}
+	}
+	return rank
+}
+
+// Highlight HTML-escapes text and wraps the words matched by the query in
+// HighlightStart and HighlightStop.
+func (q *Query) Highlight(text string) string {
+	tokens := tokenize(text)
+	marked := make([]bool, len(tokens))
+	for _, clause := range q.Clauses {
+		for _, t := range clause {
+			if t.Negated {
+				continue
+			}
+			for _, pos := range t.matches(tokens) {
+				for j := range t.Words {
+					marked[pos+j] = true
+				}
+			}
+		}
+	}
+
+	var sb strings.Builder
+	last := 0
+	for i, tok := range tokens {
+		if !marked[i] {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[last:tok.start]))
+		sb.WriteString(HighlightStart)
+		sb.WriteString(html.EscapeString(text[tok.start:tok.end]))
+		sb.WriteString(HighlightStop)
+		last = tok.end
+	}
+	sb.WriteString(html.EscapeString(text[last:]))
+	return sb.String()
+}
+
+// Snippet shortens a highlighted text to about maxWords words around its
+// first highlight, marking cut ends with "...".
+func Snippet(highlighted string, maxWords int) string {
+	fields := strings.Fields(highlighted)
+	if len(fields) <= maxWords {
+		return highlighted
+	}
+	first := 0
+	for i, f := range fields {
+		if strings.Contains(f, HighlightStart) {
+			first = i
+			break
+		}
+	}
+	start := first - maxWords/3
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxWords
+	if end > len(fields) {
+		end = len(fields)
+		start = end - maxWords
+	}
+
+	snippet := strings.Join(fields[start:end], " ")
+	if start > 0 {
+		snippet = "... " + snippet
+	}
+	if end < len(fields) {
+		snippet += " ..."
+	}
+	return snippet
+}
@@ -0,0 +1,54 @@
+package search
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		want  string
+	}{
+		{"dune", "Dune Messiah", "<mark>Dune</mark> Messiah"},
+		{`"dune messiah"`, "Dune Messiah", "<mark>Dune</mark> <mark>Messiah</mark>"},
+		{"mess*", "Dune Messiah", "Dune <mark>Messiah</mark>"},
+		{"dune -messiah", "Dune Messiah", "<mark>Dune</mark> Messiah"},
+		{"foundation", "Dune Messiah", "Dune Messiah"},
+		// Highlights are HTML, with the text escaped around the markers.
+		{"dune", `<img src=x onerror="alert(1)"> Dune & Co's`, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>Dune</mark> &amp; Co&#39;s"},
+		{"mark", "<mark>x</mark>", "&lt;<mark>mark</mark>&gt;x&lt;/<mark>mark</mark>&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+" "+tt.text, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := q.Highlight(tt.text); got != tt.want {
+				t.Errorf("Highlight(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	q, err := Parse("dune -children OR foundation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		title, description string
+		want               float64
+	}{
+		{"Dune", "", 1.0},
+		{"Dune", "Dune again", 1.1},
+		{"Children of Dune", "", 0},
+		{"Foundation", "", 1.0},
+		{"Emma", "", 0},
+	}
+	for _, tt := range tests {
+		got := q.Rank(Field{Text: tt.title, Weight: 1.0}, Field{Text: tt.description, Weight: 0.1})
+		if got != tt.want {
+			t.Errorf("Rank(%q, %q) = %v, want %v", tt.title, tt.description, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package search parses the full-text search syntax used by
+// GET /api/v1/books/search and evaluates it in memory.
+//
+// A query is a list of terms that must all match:
+//
+//	dune herbert         both words
+//	"dune messiah"       the words next to each other, in order
+//	mess*                any word starting with "mess"
+//	-children            the word must not occur
+//	dune OR foundation   either side
+//
+// Terms made only of English stop words such as "the" are left out, as
+// PostgreSQL's english configuration ignores them.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Markers wrapped around matched words in highlights. Highlights are
+// HTML-escaped text, so the markers are their only markup.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// Query is a parsed search string. A document matches when all terms of any
+// clause match.
+type Query struct {
+	Clauses [][]Term
+}
+
+// Term is a word, a prefix or a phrase of several words.
+type Term struct {
+	Words   []string
+	Prefix  bool
+	Negated bool
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// words splits text into lower-cased words of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func Parse(input string) (*Query, error) {
+	q := &Query{}
+	var clause []Term
+	endClause := func() {
+		if len(clause) > 0 {
+			q.Clauses = append(q.Clauses, clause)
+			clause = nil
+		}
+	}
+
+	sawWords := false
+	i := 0
+	for i < len(input) {
+		if isSpace(input[i]) {
+			i++
+			continue
+		}
+
+		var t Term
+		if input[i] == '-' {
+			t.Negated = true
+			i++
+		}
+		if i < len(input) && input[i] == '"' {
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated phrase at position %d", i+1)
+			}
+			t.Words = words(input[i+1 : i+1+end])
+			i += end + 2
+		} else {
+			start := i
+			for i < len(input) && !isSpace(input[i]) {
+				i++
+			}
+			raw := input[start:i]
+			if raw == "OR" && !t.Negated {
+				endClause()
+				continue
+			}
+			if strings.HasSuffix(raw, "*") {
+				t.Prefix = true
+				raw = strings.TrimRight(raw, "*")
+			}
+			t.Words = words(raw)
+		}
+		if len(t.Words) > 0 {
+			sawWords = true
+			if !t.onlyStopWords() {
+				clause = append(clause, t)
+			}
+		}
+	}
+	endClause()
+
+	if len(q.Clauses) == 0 {
+		if sawWords {
+			return nil, fmt.Errorf("cannot consist of stop words only")
+		}
+		return nil, fmt.Errorf("must contain at least one word")
+	}
+	for _, clause := range q.Clauses {
+		positive := false
+		for _, t := range clause {
+			positive = positive || !t.Negated
+		}
+		if !positive {
+			return nil, fmt.Errorf("cannot consist of excluded words only")
+		}
+	}
+	return q, nil
+}
+
+// onlyStopWords reports whether the term only consists of stop words, which
+// to_tsquery drops.
+func (t Term) onlyStopWords() bool {
+	for _, w := range t.Words {
+		if !stopWords[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// TSQuery renders the query in to_tsquery syntax. Words only contain
+// letters and digits, so the result is always well-formed.
+func (q *Query) TSQuery() string {
+	clauses := make([]string, len(q.Clauses))
+	for i, clause := range q.Clauses {
+		terms := make([]string, len(clause))
+		for j, t := range clause {
+			s := strings.Join(t.Words, " <-> ")
+			if t.Prefix {
+				s += ":*"
+			}
+			if len(t.Words) > 1 {
+				s = "(" + s + ")"
+			}
+			if t.Negated {
+				s = "!" + s
+			}
+			terms[j] = s
+		}
+		clauses[i] = "(" + strings.Join(terms, " & ") + ")"
+	}
+	return strings.Join(clauses, " | ")
+}
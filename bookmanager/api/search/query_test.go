@@ -0,0 +1,69 @@
+package search
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		tsquery string
+	}{
+		{"dune", "(dune)"},
+		{"Dune HERBERT", "(dune & herbert)"},
+		{`"dune messiah"`, "((dune <-> messiah))"},
+		{`"Children of Dune"`, "((children <-> of <-> dune))"},
+		{"mess*", "(mess:*)"},
+		{`"dune mess"*`, "((dune <-> mess))"},
+		{"dune -children", "(dune & !children)"},
+		{`dune -"god emperor"`, "(dune & !(god <-> emperor))"},
+		{"dune OR foundation", "(dune) | (foundation)"},
+		{"dune herbert OR asimov -robots", "(dune & herbert) | (asimov & !robots)"},
+		{"dune -OR foundation", "(dune & foundation)"},
+		{"won't", "((won <-> t))"},
+		{"sci-fi", "((sci <-> fi))"},
+		{"  dune\t\n", "(dune)"},
+		// Stop words are left out, except within phrases.
+		{"the dune", "(dune)"},
+		{"the* of dune -a", "(dune)"},
+		{`"the of" dune`, "(dune)"},
+		{"dune OR the", "(dune)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			q, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if got := q.TSQuery(); got != tt.tsquery {
+				t.Errorf("Parse(%q).TSQuery() = %s, want %s", tt.input, got, tt.tsquery)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "must contain at least one word"},
+		{"  * - OR ", "must contain at least one word"},
+		{`""`, "must contain at least one word"},
+		{"the", "cannot consist of stop words only"},
+		{"the OR of a", "cannot consist of stop words only"},
+		{`"to be"`, "cannot consist of stop words only"},
+		{"don't", "cannot consist of stop words only"},
+		{"-dune", "cannot consist of excluded words only"},
+		{"dune OR -children", "cannot consist of excluded words only"},
+		{"-dune the", "cannot consist of excluded words only"},
+		{`"dune messiah`, "unterminated phrase at position 1"},
+		{`dune "messiah`, "unterminated phrase at position 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.input, err, tt.want)
+			}
+		})
+	}
+}
@@ -1,171 +1,287 @@
-package api
-
-import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"strconv"
-	"strings"
-)
-
-type APIClient struct {
-	baseURL string
-	verbose bool
-}
-
-func NewAPIClient(baseURL string, verbose bool) *APIClient {
-	return &APIClient{
-		baseURL: strings.TrimSuffix(baseURL, "/"),
-		verbose: verbose,
-	}
-}
-
-func (c *APIClient) Get(endpoint string, queryParams map[string]string) ([]byte, error) {
-    baseURL, err := url.Parse(c.baseURL)
-    if err != nil {
-        return nil, fmt.Errorf("invalid base URL: %w", err)
-    }
-
-    endpointURL, err := url.Parse(strings.TrimPrefix(endpoint, "/"))
-    if err != nil {
-        return nil, fmt.Errorf("invalid endpoint: %w", err)
-    }
-
-    fullURL := baseURL.ResolveReference(endpointURL)
-    if len(queryParams) > 0 {
-        q := fullURL.Query()
-        for k, v := range queryParams {
-            q.Add(k, v)
-        }
-        fullURL.RawQuery = q.Encode()
-    }
-
-    if c.verbose {
-        fmt.Printf("GET %s\n", fullURL.String())
-    }
-
-    resp, err := http.Get(fullURL.String())
-    if err != nil {
-        return nil, fmt.Errorf("request failed: %w", err)
-    }
-    defer resp.Body.Close()
-
-    body, err := io.ReadAll(resp.Body)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read response: %w", err)
-    }
-
-    if resp.StatusCode >= 400 {
-        return nil, fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
-    }
-
-    return body, nil
-}
-
-func (c *APIClient) Post(endpoint string, data interface{}) ([]byte, error) {
-	return c.sendRequest("POST", endpoint, data)
-}
-
-func (c *APIClient) Put(endpoint string, data interface{}) ([]byte, error) {
-	return c.sendRequest("PUT", endpoint, data)
-}
-
-func (c *APIClient) Patch(endpoint string, data interface{}) ([]byte, error) {
-	return c.sendRequest("PATCH", endpoint, data)
-}
-
-func (c *APIClient) Delete(endpoint string) error {
-	url := fmt.Sprintf("%s/%s", c.baseURL, strings.TrimPrefix(endpoint, "/"))
-
-	if c.verbose {
-		fmt.Printf("DELETE %s\n", url)
-	}
-
-	req, err := http.NewRequest("DELETE", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
-	}
-
-	return nil
-}
-
-func (c *APIClient) sendRequest(method, endpoint string, data interface{}) ([]byte, error) {
-	url := fmt.Sprintf("%s/%s", c.baseURL, strings.TrimPrefix(endpoint, "/"))
-
-	var body io.Reader
-	if data != nil {
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request data: %w", err)
-		}
-		body = bytes.NewBuffer(jsonData)
-	}
-
-	if c.verbose {
-		fmt.Printf("%s %s\n", method, url)
-		if body != nil {
-			fmt.Printf("Request Body: %s\n", body)
-		}
-	}
-
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API error (%d): %s", resp.StatusCode, string(respBody))
-	}
-
-	return respBody, nil
-}
-
-func (c *APIClient) BuildQueryParams(where, groupBy, orderBy string, limit, offset int) map[string]string {
-	params := make(map[string]string)
-	
-	if where != "" {
-		params["where"] = where
-	}
-	if groupBy != "" {
-		params["group_by"] = groupBy
-	}
-	if orderBy != "" {
-		params["order_by"] = orderBy
-	}
-	if limit > 0 {
-		params["limit"] = strconv.Itoa(limit)
-	}
-	if offset > 0 {
-		params["offset"] = strconv.Itoa(offset)
-	}
-	
-	return params
-}
-
-
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type APIClient struct {
+	baseURL string
+	verbose bool
+	actor   string
+}
+
+// NewAPIClient returns a client for the API at baseURL. Writes are recorded
+// in the revision history under actor, when it is not empty.
+func NewAPIClient(baseURL string, verbose bool, actor string) *APIClient {
+	return &APIClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		verbose: verbose,
+		actor:   actor,
+	}
+}
+
+func (c *APIClient) Get(endpoint string, queryParams map[string]string) ([]byte, error) {
+	baseURL, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	endpointURL, err := url.Parse(strings.TrimPrefix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+
+	fullURL := baseURL.ResolveReference(endpointURL)
+	if len(queryParams) > 0 {
+		q := fullURL.Query()
+		for k, v := range queryParams {
+			q.Add(k, v)
+		}
+		fullURL.RawQuery = q.Encode()
+	}
+
+	if c.verbose {
+		fmt.Printf("GET %s\n", fullURL.String())
+	}
+
+	resp, err := http.Get(fullURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	return body, nil
+}
+
+// Header is an extra request header. Headers without a name are skipped.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// IfMatch makes a write fail with 412 Precondition Failed unless the
+// resource is still at version. Version 0 makes the write unconditional.
+func IfMatch(version int) Header {
+	if version == 0 {
+		return Header{}
+	}
+	return Header{Name: "If-Match", Value: fmt.Sprintf(`"%d"`, version)}
+}
+
+func (c *APIClient) actorHeader() Header {
+	if c.actor == "" {
+		return Header{}
+	}
+	return Header{Name: "X-Actor", Value: c.actor}
+}
+
+func setHeaders(req *http.Request, headers []Header) {
+	for _, h := range headers {
+		if h.Name != "" {
+			req.Header.Set(h.Name, h.Value)
+		}
+	}
+}
+
+func (c *APIClient) Post(endpoint string, data interface{}, headers ...Header) ([]byte, error) {
+	return c.sendRequest("POST", endpoint, data, headers)
+}
+
+func (c *APIClient) Put(endpoint string, data interface{}, headers ...Header) ([]byte, error) {
+	return c.sendRequest("PUT", endpoint, data, headers)
+}
+
+// Patch sends data as a JSON Merge Patch: fields set to nil are cleared.
+func (c *APIClient) Patch(endpoint string, data interface{}, headers ...Header) ([]byte, error) {
+	headers = append([]Header{{Name: "Content-Type", Value: "application/merge-patch+json"}}, headers...)
+	return c.sendRequest("PATCH", endpoint, data, headers)
+}
+
+func (c *APIClient) Delete(endpoint string, headers ...Header) error {
+	url := fmt.Sprintf("%s/%s", c.baseURL, strings.TrimPrefix(endpoint, "/"))
+
+	if c.verbose {
+		fmt.Printf("DELETE %s\n", url)
+	}
+
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	setHeaders(req, append([]Header{c.actorHeader()}, headers...))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	return nil
+}
+
+// Upload posts size bytes read from body as they are, e.g. a file to import,
+// with the given content type and query parameters.
+func (c *APIClient) Upload(endpoint, contentType string, body io.Reader, size int64, queryParams map[string]string) ([]byte, error) {
+	fullURL, err := url.Parse(fmt.Sprintf("%s/%s", c.baseURL, strings.TrimPrefix(endpoint, "/")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	q := fullURL.Query()
+	for k, v := range queryParams {
+		q.Set(k, v)
+	}
+	fullURL.RawQuery = q.Encode()
+
+	if c.verbose {
+		fmt.Printf("POST %s (%s, %d bytes)\n", fullURL.String(), contentType, size)
+	}
+
+	req, err := http.NewRequest("POST", fullURL.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	setHeaders(req, []Header{c.actorHeader()})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	}
+
+	return respBody, nil
+}
+
+// Download copies the body of a GET request to w as it arrives, e.g. an
+// export too large to hold in memory, and returns the number of bytes
+// copied. Verbose output goes to stderr, as w may be stdout.
+func (c *APIClient) Download(endpoint string, queryParams map[string]string, w io.Writer) (int64, error) {
+	fullURL, err := url.Parse(fmt.Sprintf("%s/%s", c.baseURL, strings.TrimPrefix(endpoint, "/")))
+	if err != nil {
+		return 0, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	q := fullURL.Query()
+	for k, v := range queryParams {
+		q.Set(k, v)
+	}
+	fullURL.RawQuery = q.Encode()
+
+	if c.verbose {
+		fmt.Fprintf(os.Stderr, "GET %s\n", fullURL.String())
+	}
+
+	resp, err := http.Get(fullURL.String())
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, newAPIError(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("download interrupted: %w", err)
+	}
+	return n, nil
+}
+
+func (c *APIClient) sendRequest(method, endpoint string, data interface{}, headers []Header) ([]byte, error) {
+	url := fmt.Sprintf("%s/%s", c.baseURL, strings.TrimPrefix(endpoint, "/"))
+
+	var body io.Reader
+	if data != nil {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request data: %w", err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	if c.verbose {
+		fmt.Printf("%s %s\n", method, url)
+		if body != nil {
+			fmt.Printf("Request Body: %s\n", body)
+		}
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, append([]Header{c.actorHeader()}, headers...))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	}
+
+	return respBody, nil
+}
+
+func (c *APIClient) BuildQueryParams(where, groupBy, orderBy string, limit, offset int) map[string]string {
+	params := make(map[string]string)
+
+	if where != "" {
+		params["where"] = where
+	}
+	if groupBy != "" {
+		params["group_by"] = groupBy
+	}
+	if orderBy != "" {
+		params["order_by"] = orderBy
+	}
+	if limit > 0 {
+		params["limit"] = strconv.Itoa(limit)
+	}
+	if offset > 0 {
+		params["offset"] = strconv.Itoa(offset)
+	}
+
+	return params
+}
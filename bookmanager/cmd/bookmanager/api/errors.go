@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned for every response with a 4xx or 5xx status. When the
+// server sent an application/problem+json body its fields are filled in.
+type APIError struct {
+	StatusCode int
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail"`
+	Instance   string       `json:"instance"`
+	RequestID  string       `json:"request_id"`
+	Errors     []FieldError `json:"errors"`
+	// Body is the raw response body, used when it is not a problem document.
+	Body string `json:"-"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func newAPIError(statusCode int, contentType string, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: strings.TrimSpace(string(body))}
+	if strings.HasPrefix(contentType, "application/problem+json") {
+		json.Unmarshal(body, apiErr)
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Title == "" {
+		return fmt.Sprintf("API error (%d): %s", e.StatusCode, e.Body)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (%d)", e.Title, e.StatusCode)
+	if e.Detail != "" && len(e.Errors) == 0 {
+		fmt.Fprintf(&sb, ": %s", e.Detail)
+	}
+	for _, fe := range e.Errors {
+		fmt.Fprintf(&sb, "\n  - %s: %s", fe.Field, fe.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&sb, "\n  request id: %s", e.RequestID)
+	}
+	return sb.String()
+}
+
+// IsPreconditionFailed reports whether a conditional write was rejected
+// because the resource changed since the version it was based on.
+func IsPreconditionFailed(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed
+}
@@ -0,0 +1,376 @@
+package commands
+
+import (
+	"bookmanager/api/models"
+	"bookmanager/cmd/bookmanager/api"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func HandleAuthorCommand(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		printAuthorHelp()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "create":
+		createAuthor(client, args[1:])
+	case "list":
+		listAuthors(client, args[1:])
+	case "get":
+		getAuthor(client, args[1:])
+	case "update":
+		updateAuthor(client, args[1:])
+	case "delete":
+		deleteAuthor(client, args[1:])
+	case "books":
+		listAuthorBooks(client, args[1:])
+	case "help":
+		printAuthorHelp()
+	default:
+		fmt.Printf("Unknown author command: %s\n", args[0])
+		printAuthorHelp()
+		os.Exit(1)
+	}
+}
+
+func printAuthorHelp() {
+	fmt.Printf("%s\n", `Usage: bookmanager author <command> [options]
+
+Commands:
+  create      Add a new author
+  list        List authors, by sort name unless --order-by is given
+  get         Get details of a specific author
+  update      Update an author's information (only updates provided fields)
+  delete      Remove an author who is no longer credited on any book
+  books       List the books an author is credited on, oldest first
+  help        Show this help message
+
+Create and Update Options:
+  --name            Author name (required for create)
+  --sort-name       Name to sort by (default "Last, First" derived from the name)
+  --birth-date      Date of birth (YYYY-MM-DD)
+  --death-date      Date of death (YYYY-MM-DD)
+  --bio             Short biography
+
+List Options:
+  --where           Filter expression (e.g., "birth_date < '1900-01-01'")
+  --order-by        Comma separated fields with optional ASC/DESC (e.g., "birth_date DESC")
+  --limit           Page size (default 50, max 200)
+  --offset          Offset for pagination
+  --cursor          Continue from a cursor printed by a previous list
+  --all             Fetch all pages
+  --total           Show the total number of matching authors
+
+Update and Delete Options:
+  --if-version      Only write if the author is still at this version (shown by get)
+
+Renaming an author updates the author of all their books. Books created with
+an author name that has no record yet create one.
+
+Examples:
+  bookmanager author create --name "Ursula K. Le Guin" --sort-name "Le Guin, Ursula K." --birth-date 1929-10-21
+  bookmanager author list --where "name ILIKE '%tolkien%'"
+  bookmanager author update 3 --bio "English writer and philologist"
+  bookmanager author books 3`)
+}
+
+// authorFlags registers the editable author fields on fs.
+func authorFlags(fs *flag.FlagSet) map[string]*string {
+	return map[string]*string{
+		"name":       fs.String("name", "", "Author name"),
+		"sort_name":  fs.String("sort-name", "", "Name to sort by"),
+		"birth_date": fs.String("birth-date", "", "Date of birth (YYYY-MM-DD)"),
+		"death_date": fs.String("death-date", "", "Date of death (YYYY-MM-DD)"),
+		"bio":        fs.String("bio", "", "Short biography"),
+	}
+}
+
+func checkAuthorDates(fields map[string]*string) {
+	for _, name := range []string{"birth_date", "death_date"} {
+		if *fields[name] == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", *fields[name]); err != nil {
+			fmt.Println("Invalid date format. Please use YYYY-MM-DD")
+			os.Exit(1)
+		}
+	}
+}
+
+func createAuthor(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("author create", flag.ExitOnError)
+	fields := authorFlags(fs)
+	fs.Parse(args)
+
+	if *fields["name"] == "" {
+		fmt.Println("Name is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	checkAuthorDates(fields)
+
+	author := make(map[string]interface{})
+	for name, value := range fields {
+		if *value != "" {
+			author[name] = *value
+		}
+	}
+
+	body, err := client.Post("/authors", author)
+	if err != nil {
+		log.Fatalf("Error creating author: %v", err)
+	}
+
+	var createdAuthor models.Author
+	if err := json.Unmarshal(body, &createdAuthor); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+	fmt.Printf("Created author #%d: %s\n", createdAuthor.ID, createdAuthor.Name)
+}
+
+func listAuthors(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("author list", flag.ExitOnError)
+	where := fs.String("where", "", "Filter expression")
+	orderBy := fs.String("order-by", "", "Fields to order by")
+	limit := fs.Int("limit", 0, "Page size")
+	offset := fs.Int("offset", 0, "Offset for pagination")
+	cursor := fs.String("cursor", "", "Cursor of the page to fetch (from a previous list)")
+	all := fs.Bool("all", false, "Fetch all pages")
+	total := fs.Bool("total", false, "Show the total number of matching authors")
+	fs.Parse(args)
+
+	params := client.BuildQueryParams(*where, "", *orderBy, *limit, *offset)
+	if *cursor != "" {
+		params["cursor"] = *cursor
+	}
+	if *total {
+		params["include_total"] = "true"
+	}
+
+	shown := 0
+	info := fetchPages(client, "/v1/authors", params, *all, func(body []byte) {
+		var result struct {
+			Authors []models.Author `json:"authors"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			log.Fatalf("Error parsing response: %v", err)
+		}
+
+		for _, author := range result.Authors {
+			fmt.Printf("%d: %s%s\n", author.ID, author.Name, lifespan(author))
+		}
+		shown += len(result.Authors)
+	})
+
+	if shown == 0 {
+		fmt.Println("No authors found")
+		return
+	}
+	printPageFooter(info, shown)
+}
+
+// lifespan returns " (1892-1973)" for an author with known dates.
+func lifespan(author models.Author) string {
+	if author.BirthDate == "" && author.DeathDate == "" {
+		return ""
+	}
+	year := func(date string) string {
+		if len(date) < 4 {
+			return "?"
+		}
+		return date[:4]
+	}
+	if author.DeathDate == "" {
+		return fmt.Sprintf(" (born %s)", year(author.BirthDate))
+	}
+	return fmt.Sprintf(" (%s-%s)", year(author.BirthDate), year(author.DeathDate))
+}
+
+func getAuthor(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Author ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid author ID")
+		os.Exit(1)
+	}
+
+	body, err := client.Get(fmt.Sprintf("/v1/authors/%d", id), nil)
+	if err != nil {
+		log.Fatalf("Error getting author: %v", err)
+	}
+
+	var author models.Author
+	if err := json.Unmarshal(body, &author); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Author #%d\n", author.ID)
+	fmt.Printf("Name: %s\n", author.Name)
+	fmt.Printf("Sort Name: %s\n", author.SortName)
+	if author.BirthDate != "" {
+		fmt.Printf("Born: %s\n", author.BirthDate)
+	}
+	if author.DeathDate != "" {
+		fmt.Printf("Died: %s\n", author.DeathDate)
+	}
+	if author.Bio != "" {
+		fmt.Printf("Bio: %s\n", author.Bio)
+	}
+	fmt.Printf("Version: %d\n", author.Version)
+}
+
+func updateAuthor(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("author update", flag.ExitOnError)
+	fields := authorFlags(fs)
+	ifVersion := fs.Int("if-version", 0, "Only update if the author is still at this version")
+
+	if len(args) < 1 {
+		fmt.Println("Author ID is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid author ID")
+		os.Exit(1)
+	}
+
+	fs.Parse(args[1:])
+	checkAuthorDates(fields)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[strings.ReplaceAll(f.Name, "-", "_")] = true })
+
+	patchData := make(map[string]interface{})
+	for name, value := range fields {
+		if set[name] {
+			patchData[name] = *value
+		}
+	}
+	if len(patchData) == 0 {
+		fmt.Println("No fields to update provided")
+		os.Exit(1)
+	}
+
+	body, err := client.Patch(fmt.Sprintf("/authors/%d", id), patchData, api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "author", id)
+		log.Fatalf("Error updating author: %v", err)
+	}
+
+	var updatedAuthor models.Author
+	if err := json.Unmarshal(body, &updatedAuthor); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Updated author #%d: %s\n", updatedAuthor.ID, updatedAuthor.Name)
+}
+
+func deleteAuthor(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("author delete", flag.ExitOnError)
+	ifVersion := fs.Int("if-version", 0, "Only delete if the author is still at this version")
+
+	if len(args) < 1 {
+		fmt.Println("Author ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid author ID")
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+
+	err = client.Delete(fmt.Sprintf("/authors/%d", id), api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "author", id)
+		log.Fatalf("Error deleting author: %v", err)
+	}
+
+	fmt.Printf("Deleted author #%d\n", id)
+}
+
+func listAuthorBooks(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Author ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid author ID")
+		os.Exit(1)
+	}
+
+	body, err := client.Get(fmt.Sprintf("/v1/authors/%d/books", id), nil)
+	if err != nil {
+		log.Fatalf("Error listing books of author: %v", err)
+	}
+
+	var result struct {
+		Books []models.Book `json:"books"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	if len(result.Books) == 0 {
+		fmt.Printf("No books found for author #%d\n", id)
+		return
+	}
+
+	fmt.Printf("Books of author #%d:\n", id)
+	for _, book := range result.Books {
+		var roles []string
+		for _, credit := range book.Authors {
+			if credit.ID == id && credit.Role != models.RoleAuthor {
+				roles = append(roles, credit.Role)
+			}
+		}
+		line := fmt.Sprintf("- %s by %s (%s)", book.Title, book.Author, book.PublishedDate)
+		if len(roles) > 0 {
+			line += " as " + strings.Join(roles, " and ")
+		}
+		fmt.Println(line)
+	}
+}
+
+// credits collects repeated --credit role:name flags.
+type credits []models.BookAuthorRequest
+
+func (c *credits) String() string {
+	return fmt.Sprint(*c)
+}
+
+func (c *credits) Set(value string) error {
+	role, name, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected role:name, e.g. translator:Edith Grossman")
+	}
+	*c = append(*c, models.BookAuthorRequest{Name: strings.TrimSpace(name), Role: strings.TrimSpace(role)})
+	return nil
+}
+
+// printCredits prints the people credited on a book in roles other than
+// author.
+func printCredits(book models.Book) {
+	for _, credit := range book.Authors {
+		if credit.Role != models.RoleAuthor {
+			fmt.Printf("%s: %s\n", strings.ToUpper(credit.Role[:1])+credit.Role[1:], credit.Name)
+		}
+	}
+}
@@ -1,389 +1,634 @@
-package commands
-
-import (
-	"bookmanager/api/models"
-	"bookmanager/cmd/bookmanager/api"
-	"encoding/json"
-	"flag"
-	"fmt"
-	"log"
-	"os"
-	"strconv"
-	"time"
-)
-
-func HandleBookCommand(client *api.APIClient, args []string) {
-	if len(args) < 1 {
-		printBookHelp()
-		os.Exit(1)
-	}
-
-	switch args[0] {
-	case "create":
-		createBook(client, args[1:])
-	case "list":
-		listBooks(client, args[1:])
-	case "get":
-		getBook(client, args[1:])
-	case "update":
-		updateBook(client, args[1:])
-	case "patch":
-		patchBook(client, args[1:])
-	case "delete":
-		deleteBook(client, args[1:])
-	case "help":
-		printBookHelp()
-	default:
-		fmt.Printf("Unknown book command: %s\n", args[0])
-		printBookHelp()
-		os.Exit(1)
-	}
-}
-
-func printBookHelp() {
-	fmt.Println(`Usage: bookmanager book <command> [options]
-
-Commands:
-  create      Add a new book
-  list        List books with optional filters
-  get         Get details of a specific book
-  update      Update a book's information (all fields required)
-  patch	      Update a books' information partially (only updates provided fields)
-  delete      Remove a book from the system
-  help        Show this help message
-
-List Options:
-  --author          Filter by author
-  --genre           Filter by genre
-  --published-after Filter by publication date (after)
-  --published-before Filter by publication date (before)
-  --where           SQL-like WHERE clause (e.g., "title LIKE '%Hobbit%' AND edition > 1")
-  --order-by        SQL-like ORDER BY clause (e.g., "published_date DESC")
-  --group-by        SQL-like GROUP BY clause (e.g., "genre")
-  --limit           Limit number of results
-  --offset          Offset for pagination
-
-Examples:
-  bookmanager book create --title "The Hobbit" --author "J.R.R. Tolkien" --published-date "1937-09-21"
-  bookmanager book list --where "genre = 'Fantasy' AND published_date > '1950-01-01'"
-  bookmanager book list --group-by "author"`)
-}
-
-func createBook(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("book create", flag.ExitOnError)
-	title := fs.String("title", "", "Book title (required)")
-	author := fs.String("author", "", "Book author (required)")
-	publishedDate := fs.String("published-date", "", "Publication Date (YYYY-MM-DD) (required)")
-	edition := fs.Int("edition", 1, "Edition number")
-	description := fs.String("description", "", "Book description")
-	genre := fs.String("genre", "", "Book genre")
-	fs.Parse(args)
-
-	if *title == "" || *author == "" || *publishedDate == "" {
-		fmt.Println("Title, author, and published-date are required")
-		fs.PrintDefaults()
-		os.Exit(1)
-	}
-
-	if _, err := time.Parse("2006-01-02", *publishedDate); err != nil {
-		fmt.Println("Invalid date format. Please use YYYY-MM-DD")
-		os.Exit(1)
-	}
-
-	book := map[string]interface{}{
-		"title":          *title,
-		"author":         *author,
-		"published_date": *publishedDate,
-		"edition":        *edition,
-		"description":    *description,
-		"genre":          *genre,
-	}
-
-	body, err := client.Post("/books", book)
-	if err != nil {
-		log.Fatalf("Error creating book %v", err)
-	}
-
-	var createdBook models.Book
-	if err := json.Unmarshal(body, &createdBook); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-	fmt.Printf("Created book %d: %s by %s\n", createdBook.ID, createdBook.Title, createdBook.Author)
-}
-
-func getBook(client *api.APIClient, args []string) {
-	if len(args) < 1 {
-		fmt.Println("Book ID is required")
-		os.Exit(1)
-	}
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid book ID")
-		os.Exit(1)
-	}
-
-	body, err := client.Get(fmt.Sprintf("/v1/books/%d", id), nil)
-	if err != nil {
-		log.Fatalf("Error getting book: %v", err)
-	}
-
-	var book models.Book
-	if err := json.Unmarshal(body, &book); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	fmt.Printf("Book #%d\n", book.ID)
-	fmt.Printf("Title: %s\n", book.Title)
-	fmt.Printf("Author: %s\n", book.Author)
-	fmt.Printf("Published Date: %s\n", book.PublishedDate)
-	fmt.Printf("Edition: %d\n", book.Edition)
-	if book.Description != "" {
-		fmt.Printf("Description: %s\n", book.Description)
-	}
-	if book.Genre != "" {
-		fmt.Printf("Genre: %s\n", book.Genre)
-	}
-}
-
-func listBooks(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("book list", flag.ExitOnError)
-	where := fs.String("where", "", "SQL-like WHERE clause")
-	groupBy := fs.String("group-by", "", "SQL-like GROUP BY clause")
-	orderBy := fs.String("order-by", "", "SQL-like ORDER BY clause")
-	limit := fs.Int("limit", 0, "Limit number of results")
-	offset := fs.Int("offset", 0, "Offset for pagination")
-
-	author := fs.String("author", "", "Filter by author")
-	genre := fs.String("genre", "", "Filter by genre")
-	publishedAfter := fs.String("published-after", "", "Filter by publication date (after)")
-	publishedBefore := fs.String("published-before", "", "Filter by publication date (before)")
-
-	if err := fs.Parse(args); err != nil {
-		log.Fatalf("Error parsing flags: %v", err)
-	}
-
-	if *limit < 0 || *offset < 0 {
-		log.Fatal("Limit and offset must be positive numbers")
-	}
-
-	if *publishedAfter != "" {
-		if _, err := time.Parse("2006-01-02", *publishedAfter); err != nil {
-			log.Fatalf("Invalid published-after date format: %v", err)
-		}
-	}
-	if *publishedBefore != "" {
-		if _, err := time.Parse("2006-01-02", *publishedBefore); err != nil {
-			log.Fatalf("Invalid published-before date format: %v", err)
-		}
-	}
-
-	params := client.BuildQueryParams(*where, *groupBy, *orderBy, *limit, *offset)
-
-	if *author != "" {
-		params["author"] = *author
-	}
-	if *genre != "" {
-		params["genre"] = *genre
-	}
-	if *publishedAfter != "" {
-		params["published_after"] = *publishedAfter
-	}
-	if *publishedBefore != "" {
-		params["published_before"] = *publishedBefore
-	}
-
-	body, err := client.Get("/v1/books", params)
-	if err != nil {
-		log.Fatalf("API request failed: %v", err)
-	}
-
-	if *groupBy != "" {
-		var result struct {
-			Groups map[string]int `json:"groups"`
-		}
-		if err := json.Unmarshal(body, &result); err != nil {
-			log.Fatalf("Error parsing grouped response: %v", err)
-		}
-
-		if len(result.Groups) == 0 {
-			fmt.Println("No grouped results found")
-			return
-		}
-
-		fmt.Printf("Books grouped by %s:\n", *groupBy)
-		for group, count := range result.Groups {
-			fmt.Printf("- %s: %d\n", group, count)
-		}
-	} else {
-		var result struct {
-			Books []models.Book `json:"books"`
-		}
-		if err := json.Unmarshal(body, &result); err != nil {
-			log.Printf("Raw response: %s", string(body))
-			log.Fatalf("Failed to parse response: %v", err)
-		}
-
-		if len(result.Books) == 0 {
-			fmt.Println("No books found")
-			return
-		}
-
-		for _, book := range result.Books {
-			fmt.Printf("%d: %s by %s (%s)\n",
-				book.ID, book.Title, book.Author, book.PublishedDate)
-			if book.Edition > 1 {
-				fmt.Printf("   Edition: %d\n", book.Edition)
-			}
-			if book.Genre != "" {
-				fmt.Printf("   Genre: %s\n", book.Genre)
-			}
-			if book.Description != "" {
-				fmt.Printf("   Description: %s\n", book.Description)
-			}
-			fmt.Println()
-		}
-	}
-}
-
-func updateBook(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("book update", flag.ExitOnError)
-	title := fs.String("title", "", "Book title (required)")
-	author := fs.String("author", "", "Book author (required)")
-	publishedDate := fs.String("published-date", "", "Publication date (YYYY-MM-DD) (required)")
-	edition := fs.Int("edition", 0, "Edition number (required)")
-	description := fs.String("description", "", "Book description (required)")
-	genre := fs.String("genre", "", "Book genre (required)")
-
-	if len(args) < 1 {
-		fmt.Println("Book ID is required")
-		fs.PrintDefaults()
-		os.Exit(1)
-	}
-
-	fs.Parse(args[1:])
-
-	if *title == "" || *author == "" || *publishedDate == "" || *edition == 0 || *description == "" || *genre == "" {
-		fmt.Println("Error: All fields are required for update. Use 'patch' for partial updates.")
-		fmt.Println("Required flags:")
-
-		os.Exit(1)
-	}
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid book ID")
-		os.Exit(1)
-	}
-
-	if _, err := time.Parse("2006-01-02", *publishedDate); err != nil {
-		fmt.Println("Invalid date format. Please use YYYY-MM-DD")
-		os.Exit(1)
-	}
-
-	updateData := map[string]interface{}{
-		"title":          *title,
-		"author":         *author,
-		"published_date": *publishedDate,
-		"edition":        *edition,
-		"description":    *description,
-		"genre":          *genre,
-	}
-
-	body, err := client.Put(fmt.Sprintf("/books/%d", id), updateData)
-	if err != nil {
-		log.Fatalf("Error updating book: %v", err)
-	}
-
-	var updatedBook models.Book
-	if err := json.Unmarshal(body, &updatedBook); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	fmt.Printf("Updated book #%d: %s by %s\n", updatedBook.ID, updatedBook.Title, updatedBook.Author)
-}
-
-func patchBook(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("book patch", flag.ExitOnError)
-	title := fs.String("title", "", "Update book title (optional)")
-	author := fs.String("author", "", "Update book author (optional)")
-	publishedDate := fs.String("published-date", "", "Update publication date (YYYY-MM-DD, optional)")
-	edition := fs.Int("edition", 1, "Update edition number (optional, 1 to skip)")
-	description := fs.String("description", "", "Update book description (optional)")
-	genre := fs.String("genre", "", "Update book genre (optional)")
-
-	if len(args) == 0 {
-		fs.PrintDefaults()
-		os.Exit(1)
-	}
-
-	if len(args) < 1 {
-		fmt.Println("Book ID is required")
-		os.Exit(1)
-	}
-
-	fs.Parse(args[1:])
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid book ID")
-		os.Exit(1)
-	}
-
-	patchData := make(map[string]interface{})
-
-	if *title != "" {
-		patchData["title"] = *title
-	}
-	if *author != "" {
-		patchData["author"] = *author
-	}
-	if *publishedDate != "" {
-		patchData["published_date"] = *publishedDate
-	}
-	if *edition != -1 {
-		patchData["edition"] = *edition
-	}
-	if *description != "" {
-		patchData["description"] = *description
-	}
-	if *genre != "" {
-		patchData["genre"] = *genre
-	}
-
-	if len(patchData) == 0 {
-		fmt.Println("No fields to update provided")
-		os.Exit(1)
-	}
-
-	body, err := client.Patch(fmt.Sprintf("/books/%d", id), patchData)
-	if err != nil {
-		log.Fatalf("Error patching book: %v", err)
-	}
-
-	var patchedBook models.Book
-	if err := json.Unmarshal(body, &patchedBook); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	fmt.Printf("Successfully patched book #%d\n", patchedBook.ID)
-}
-
-func deleteBook(client *api.APIClient, args []string) {
-	if len(args) < 1 {
-		fmt.Println("Book ID is required")
-		os.Exit(1)
-	}
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid book ID")
-		os.Exit(1)
-	}
-
-	err = client.Delete(fmt.Sprintf("/books/%d", id))
-	if err != nil {
-		log.Fatalf("Error deleting book: %v", err)
-	}
-
-	fmt.Printf("Deleted book #%d\n", id)
-}
+package commands
+
+import (
+	"bookmanager/api/models"
+	"bookmanager/cmd/bookmanager/api"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func HandleBookCommand(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		printBookHelp()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "create":
+		createBook(client, args[1:])
+	case "list":
+		listBooks(client, args[1:])
+	case "search":
+		searchBooks(client, args[1:])
+	case "get":
+		getBook(client, args[1:])
+	case "update":
+		updateBook(client, args[1:])
+	case "patch":
+		patchBook(client, args[1:])
+	case "delete":
+		deleteBook(client, args[1:])
+	case "history":
+		showHistory(client, "book", args[1:])
+	case "revert":
+		revertTo(client, "book", args[1:])
+	case "import":
+		importBooks(client, args[1:])
+	case "help":
+		printBookHelp()
+	default:
+		fmt.Printf("Unknown book command: %s\n", args[0])
+		printBookHelp()
+		os.Exit(1)
+	}
+}
+
+func printBookHelp() {
+	fmt.Println(`Usage: bookmanager book <command> [options]
+
+Commands:
+  create      Add a new book
+  list        List books with optional filters
+  search      Full-text search over title, author, genre and description
+  get         Get details of a specific book, by ID or by --isbn
+  update      Update a book's information (all fields required)
+  patch	      Update a books' information partially (only updates provided fields)
+  delete      Remove a book from the system
+  history     Show who changed a book and how, as colored field diffs
+  revert      Revert a book to an earlier revision: revert <id> <revision>
+  import      Import books from a CSV, JSON or NDJSON file: import <file>
+  help        Show this help message
+
+List Options:
+  --author          Filter by author
+  --genre           Filter by genre
+  --isbn            Filter by ISBN-10 or ISBN-13, hyphens are ignored
+  --published-after Filter by publication date (after)
+  --published-before Filter by publication date (before)
+  --where           Filter expression (e.g., "title LIKE '%Hobbit%' AND edition > 1")
+  --order-by        Comma separated fields with optional ASC/DESC (e.g., "published_date DESC")
+  --group-by        Fields to group by, also year(), month() or decade() of a date (e.g., "genre, decade(published_date)")
+  --group-limit     Books listed per group (default 10, 0 for counts only)
+  --limit           Page size (default 50, max 200)
+  --offset          Offset for pagination
+  --cursor          Continue from a cursor printed by a previous list
+  --all             Fetch all pages
+  --total           Show the total number of matching books
+
+Search Options:
+  --limit           Number of results (default 50, max 200)
+  --offset          Offset for pagination
+  --where           Filter expression applied to the matches
+  --no-color        Mark hits with *asterisks* instead of colors
+
+Create Options:
+  --author          Author names, several joined with " & " (e.g., "Terry Pratchett & Neil Gaiman")
+  --credit          Credit someone in another role as role:name, where role is
+                    translator, illustrator or editor (repeatable)
+  --isbn            ISBN-10 or ISBN-13, with or without hyphens; the other form
+                    is derived (also for update and patch)
+
+Patch Options:
+  --clear-description  Remove the description
+  --clear-genre        Remove the genre
+  --clear-isbn         Remove the ISBN
+  Only the fields given are changed; --edition 0 and --genre "" are sent as given.
+
+Update, Patch, Delete and Revert Options:
+  --if-version      Only write if the book is still at this version (shown by get);
+                    fails instead of overwriting someone else's changes
+
+Import Options:
+  --format          csv, json or ndjson (default from the extension: .csv, .json, .ndjson or .jsonl)
+  --mapping         CSV column mapping as column:field pairs (e.g., "Book Title:title,Writer:author");
+                    other columns are matched by name, e.g. "Published Date" to published_date
+  --on-duplicate    Books whose ISBN is already used: fail (default), skip or upsert
+  --dry-run         Only report what would be imported
+  An import is all or nothing: if a row fails, no book is written.
+
+History Options:
+  --revision        Show a single revision with the full record
+  --no-color        Print diffs without colors
+
+Search Syntax:
+  dune herbert          books containing both words
+  "dune messiah"        the exact phrase
+  mess*                 words starting with "mess"
+  -children             books not containing the word
+  dune OR foundation    either side
+
+Examples:
+  bookmanager book create --title "The Hobbit" --author "J.R.R. Tolkien" --published-date "1937-09-21"
+  bookmanager book create --title "Don Quixote" --author "Miguel de Cervantes" --published-date "1605-01-16" --credit "translator:Edith Grossman"
+  bookmanager book list --where "genre = 'Fantasy' AND published_date > '1950-01-01'"
+  bookmanager book list --where "genre IN ('Fantasy', 'Horror') AND NOT edition BETWEEN 2 AND 4"
+  bookmanager book list --group-by "author"
+  bookmanager book get --isbn 978-0-441-17271-9
+  bookmanager book list --group-by "genre, decade(published_date)" --group-limit 3
+  bookmanager book search "\"dune messiah\" OR foundation"
+  bookmanager book patch 3 --genre "Science Fiction" --if-version 2
+  bookmanager book patch 3 --clear-description
+  bookmanager book import books.csv --mapping "Writer:author" --on-duplicate skip --dry-run
+  bookmanager book history 3
+  bookmanager book revert 3 2`)
+}
+
+func createBook(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book create", flag.ExitOnError)
+	title := fs.String("title", "", "Book title (required)")
+	author := fs.String("author", "", "Book author (required)")
+	publishedDate := fs.String("published-date", "", "Publication Date (YYYY-MM-DD) (required)")
+	edition := fs.Int("edition", 1, "Edition number")
+	description := fs.String("description", "", "Book description")
+	genre := fs.String("genre", "", "Book genre")
+	isbn := fs.String("isbn", "", "ISBN-10 or ISBN-13")
+	var credited credits
+	fs.Var(&credited, "credit", "Credit someone in another role as role:name, e.g. translator:Edith Grossman (repeatable)")
+	fs.Parse(args)
+
+	if *title == "" || *author == "" || *publishedDate == "" {
+		fmt.Println("Title, author, and published-date are required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if _, err := time.Parse("2006-01-02", *publishedDate); err != nil {
+		fmt.Println("Invalid date format. Please use YYYY-MM-DD")
+		os.Exit(1)
+	}
+
+	book := map[string]interface{}{
+		"title":          *title,
+		"author":         *author,
+		"published_date": *publishedDate,
+		"edition":        *edition,
+		"description":    *description,
+		"genre":          *genre,
+	}
+	if *isbn != "" {
+		book[isbnField(*isbn)] = *isbn
+	}
+	if len(credited) > 0 {
+		var authors []models.BookAuthorRequest
+		for _, name := range models.SplitAuthors(*author) {
+			authors = append(authors, models.BookAuthorRequest{Name: name, Role: models.RoleAuthor})
+		}
+		book["authors"] = append(authors, credited...)
+	}
+
+	body, err := client.Post("/books", book)
+	if err != nil {
+		log.Fatalf("Error creating book %v", err)
+	}
+
+	var createdBook models.Book
+	if err := json.Unmarshal(body, &createdBook); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+	fmt.Printf("Created book %d: %s by %s\n", createdBook.ID, createdBook.Title, createdBook.Author)
+}
+
+func getBook(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book get", flag.ExitOnError)
+	isbn := fs.String("isbn", "", "Get the book with this ISBN-10 or ISBN-13 instead")
+
+	endpoint := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Println("Invalid book ID")
+			os.Exit(1)
+		}
+		endpoint = fmt.Sprintf("/v1/books/%d", id)
+	} else {
+		fs.Parse(args)
+		if *isbn == "" {
+			fmt.Println("Book ID or --isbn is required")
+			os.Exit(1)
+		}
+		endpoint = "/v1/books/isbn/" + url.PathEscape(*isbn)
+	}
+
+	body, err := client.Get(endpoint, map[string]string{"include": "collections"})
+	if err != nil {
+		log.Fatalf("Error getting book: %v", err)
+	}
+
+	var book models.Book
+	if err := json.Unmarshal(body, &book); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Book #%d\n", book.ID)
+	fmt.Printf("Title: %s\n", book.Title)
+	fmt.Printf("Author: %s\n", book.Author)
+	printCredits(book)
+	fmt.Printf("Published Date: %s\n", book.PublishedDate)
+	fmt.Printf("Edition: %d\n", book.Edition)
+	if book.Description != "" {
+		fmt.Printf("Description: %s\n", book.Description)
+	}
+	if book.Genre != "" {
+		fmt.Printf("Genre: %s\n", book.Genre)
+	}
+	if book.ISBN13 != "" {
+		fmt.Printf("ISBN-13: %s\n", book.ISBN13)
+	}
+	if book.ISBN10 != "" {
+		fmt.Printf("ISBN-10: %s\n", book.ISBN10)
+	}
+	fmt.Printf("Version: %d\n", book.Version)
+	if len(book.Collections) > 0 {
+		fmt.Println("Collections:")
+		for _, c := range book.Collections {
+			if c.AddedAt == nil {
+				fmt.Printf("  %d: %s (matches the query)\n", c.ID, c.Name)
+			} else {
+				fmt.Printf("  %d: %s (added %s)\n", c.ID, c.Name, c.AddedAt.Local().Format("2006-01-02"))
+			}
+		}
+	}
+}
+
+func listBooks(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book list", flag.ExitOnError)
+	where := fs.String("where", "", "Filter expression")
+	groupBy := fs.String("group-by", "", "Fields to group by")
+	groupLimit := fs.Int("group-limit", -1, "Books listed per group (0 for counts only)")
+	orderBy := fs.String("order-by", "", "Fields to order by")
+	limit := fs.Int("limit", 0, "Page size")
+	offset := fs.Int("offset", 0, "Offset for pagination")
+	cursor := fs.String("cursor", "", "Cursor of the page to fetch (from a previous list)")
+	all := fs.Bool("all", false, "Fetch all pages")
+	total := fs.Bool("total", false, "Show the total number of matching books")
+
+	author := fs.String("author", "", "Filter by author")
+	genre := fs.String("genre", "", "Filter by genre")
+	isbn := fs.String("isbn", "", "Filter by ISBN-10 or ISBN-13")
+	publishedAfter := fs.String("published-after", "", "Filter by publication date (after)")
+	publishedBefore := fs.String("published-before", "", "Filter by publication date (before)")
+
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+
+	if *limit < 0 || *offset < 0 {
+		log.Fatal("Limit and offset must be positive numbers")
+	}
+
+	if *publishedAfter != "" {
+		if _, err := time.Parse("2006-01-02", *publishedAfter); err != nil {
+			log.Fatalf("Invalid published-after date format: %v", err)
+		}
+	}
+	if *publishedBefore != "" {
+		if _, err := time.Parse("2006-01-02", *publishedBefore); err != nil {
+			log.Fatalf("Invalid published-before date format: %v", err)
+		}
+	}
+
+	params := client.BuildQueryParams(*where, *groupBy, *orderBy, *limit, *offset)
+
+	if *author != "" {
+		params["author"] = *author
+	}
+	if *genre != "" {
+		params["genre"] = *genre
+	}
+	if *isbn != "" {
+		params["isbn"] = *isbn
+	}
+	if *publishedAfter != "" {
+		params["published_after"] = *publishedAfter
+	}
+	if *publishedBefore != "" {
+		params["published_before"] = *publishedBefore
+	}
+
+	if *groupBy != "" {
+		if *groupLimit >= 0 {
+			params["group_limit"] = strconv.Itoa(*groupLimit)
+		}
+		body, err := client.Get("/v1/books", params)
+		if err != nil {
+			log.Fatalf("API request failed: %v", err)
+		}
+		printGroups(body, *groupBy, *orderBy, "books", "book", func(items json.RawMessage) {
+			var books []models.Book
+			if err := json.Unmarshal(items, &books); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+			for _, book := range books {
+				fmt.Printf("  %d: %s by %s (%s)\n", book.ID, book.Title, book.Author, book.PublishedDate)
+			}
+		})
+		return
+	}
+
+	if *cursor != "" {
+		params["cursor"] = *cursor
+	}
+	if *total {
+		params["include_total"] = "true"
+	}
+
+	shown := 0
+	info := fetchPages(client, "/v1/books", params, *all, func(body []byte) {
+		var result struct {
+			Books []models.Book `json:"books"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			log.Printf("Raw response: %s", string(body))
+			log.Fatalf("Failed to parse response: %v", err)
+		}
+
+		for _, book := range result.Books {
+			fmt.Printf("%d: %s by %s (%s)\n",
+				book.ID, book.Title, book.Author, book.PublishedDate)
+			if book.Edition > 1 {
+				fmt.Printf("   Edition: %d\n", book.Edition)
+			}
+			if book.Genre != "" {
+				fmt.Printf("   Genre: %s\n", book.Genre)
+			}
+			if book.Description != "" {
+				fmt.Printf("   Description: %s\n", book.Description)
+			}
+			fmt.Println()
+		}
+		shown += len(result.Books)
+	})
+
+	if shown == 0 {
+		fmt.Println("No books found")
+		return
+	}
+	printPageFooter(info, shown)
+}
+
+func searchBooks(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book search", flag.ExitOnError)
+	limit := fs.Int("limit", 0, "Number of results")
+	offset := fs.Int("offset", 0, "Offset for pagination")
+	where := fs.String("where", "", "Filter expression")
+	noColor := fs.Bool("no-color", false, "Disable colored highlights")
+
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+	if fs.NArg() < 1 {
+		log.Fatal("Usage: bookmanager book search [options] <query>")
+	}
+
+	params := client.BuildQueryParams(*where, "", "", *limit, *offset)
+	params["q"] = strings.Join(fs.Args(), " ")
+
+	body, err := client.Get("/v1/books/search", params)
+	if err != nil {
+		log.Fatalf("API request failed: %v", err)
+	}
+
+	var result struct {
+		Results []struct {
+			models.Book
+			Rank       float64           `json:"rank"`
+			Highlights map[string]string `json:"highlights"`
+		} `json:"results"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if len(result.Results) == 0 {
+		fmt.Println("No books found")
+		return
+	}
+
+	// Highlights are HTML-escaped text with <mark> around the hits.
+	highlighter := strings.NewReplacer("<mark>", "\033[1;33m", "</mark>", "\033[0m")
+	if *noColor || !isTerminal(os.Stdout) {
+		highlighter = strings.NewReplacer("<mark>", "*", "</mark>", "*")
+	}
+	render := func(h string) string {
+		return html.UnescapeString(highlighter.Replace(h))
+	}
+	highlight := func(highlights map[string]string, field, plain string) string {
+		if h, ok := highlights[field]; ok {
+			return render(h)
+		}
+		return plain
+	}
+
+	for _, r := range result.Results {
+		fmt.Printf("%d: %s by %s (rank %.2f)\n", r.ID,
+			highlight(r.Highlights, "title", r.Title), highlight(r.Highlights, "author", r.Author), r.Rank)
+		if h, ok := r.Highlights["description"]; ok {
+			fmt.Printf("   %s\n", render(h))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Showing %d of %d\n", len(result.Results), result.Total)
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func updateBook(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book update", flag.ExitOnError)
+	title := fs.String("title", "", "Book title (required)")
+	author := fs.String("author", "", "Book author (required)")
+	publishedDate := fs.String("published-date", "", "Publication date (YYYY-MM-DD) (required)")
+	edition := fs.Int("edition", 0, "Edition number (required)")
+	description := fs.String("description", "", "Book description (required)")
+	genre := fs.String("genre", "", "Book genre (required)")
+	isbn := fs.String("isbn", "", "ISBN-10 or ISBN-13, removed if not given")
+	ifVersion := fs.Int("if-version", 0, "Only update if the book is still at this version")
+
+	if len(args) < 1 {
+		fmt.Println("Book ID is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	fs.Parse(args[1:])
+
+	if *title == "" || *author == "" || *publishedDate == "" || *edition == 0 || *description == "" || *genre == "" {
+		fmt.Println("Error: All fields are required for update. Use 'patch' for partial updates.")
+		fmt.Println("Required flags:")
+
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid book ID")
+		os.Exit(1)
+	}
+
+	if _, err := time.Parse("2006-01-02", *publishedDate); err != nil {
+		fmt.Println("Invalid date format. Please use YYYY-MM-DD")
+		os.Exit(1)
+	}
+
+	updateData := map[string]interface{}{
+		"title":          *title,
+		"author":         *author,
+		"published_date": *publishedDate,
+		"edition":        *edition,
+		"description":    *description,
+		"genre":          *genre,
+	}
+	if *isbn != "" {
+		updateData[isbnField(*isbn)] = *isbn
+	}
+
+	body, err := client.Put(fmt.Sprintf("/books/%d", id), updateData, api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "book", id)
+		log.Fatalf("Error updating book: %v", err)
+	}
+
+	var updatedBook models.Book
+	if err := json.Unmarshal(body, &updatedBook); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Updated book #%d: %s by %s\n", updatedBook.ID, updatedBook.Title, updatedBook.Author)
+}
+
+func patchBook(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book patch", flag.ExitOnError)
+	title := fs.String("title", "", "Update book title (optional)")
+	author := fs.String("author", "", "Update book author (optional)")
+	publishedDate := fs.String("published-date", "", "Update publication date (YYYY-MM-DD, optional)")
+	edition := fs.Int("edition", 0, "Update edition number (optional)")
+	description := fs.String("description", "", "Update book description (optional)")
+	genre := fs.String("genre", "", "Update book genre (optional)")
+	isbn := fs.String("isbn", "", "Update the ISBN-10 or ISBN-13 (optional)")
+	clearDescription := fs.Bool("clear-description", false, "Remove the book description")
+	clearGenre := fs.Bool("clear-genre", false, "Remove the book genre")
+	clearISBN := fs.Bool("clear-isbn", false, "Remove the book ISBN")
+	ifVersion := fs.Int("if-version", 0, "Only patch if the book is still at this version")
+
+	if len(args) == 0 {
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if len(args) < 1 {
+		fmt.Println("Book ID is required")
+		os.Exit(1)
+	}
+
+	fs.Parse(args[1:])
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid book ID")
+		os.Exit(1)
+	}
+
+	// Only flags given on the command line are sent, so any value, including
+	// an empty string or edition 0, can be set. A null clears the field.
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	patchData := make(map[string]interface{})
+	if set["title"] {
+		patchData["title"] = *title
+	}
+	if set["author"] {
+		patchData["author"] = *author
+	}
+	if set["published-date"] {
+		patchData["published_date"] = *publishedDate
+	}
+	if set["edition"] {
+		patchData["edition"] = *edition
+	}
+	if set["description"] {
+		patchData["description"] = *description
+	}
+	if set["genre"] {
+		patchData["genre"] = *genre
+	}
+	if set["isbn"] {
+		patchData[isbnField(*isbn)] = *isbn
+	}
+	if *clearDescription {
+		patchData["description"] = nil
+	}
+	if *clearGenre {
+		patchData["genre"] = nil
+	}
+	if *clearISBN {
+		patchData["isbn13"] = nil
+	}
+	if (set["description"] && *clearDescription) || (set["genre"] && *clearGenre) || (set["isbn"] && *clearISBN) {
+		fmt.Println("A field cannot be both set and cleared")
+		os.Exit(1)
+	}
+
+	if len(patchData) == 0 {
+		fmt.Println("No fields to update provided")
+		os.Exit(1)
+	}
+
+	body, err := client.Patch(fmt.Sprintf("/books/%d", id), patchData, api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "book", id)
+		log.Fatalf("Error patching book: %v", err)
+	}
+
+	var patchedBook models.Book
+	if err := json.Unmarshal(body, &patchedBook); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Successfully patched book #%d\n", patchedBook.ID)
+}
+
+// isbnField returns the request field for isbn: isbn10 for ten characters
+// without hyphens, isbn13 otherwise. The API validates the ISBN.
+func isbnField(isbn string) string {
+	if len(models.NormalizeISBN(isbn)) == 10 {
+		return "isbn10"
+	}
+	return "isbn13"
+}
+
+func deleteBook(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book delete", flag.ExitOnError)
+	ifVersion := fs.Int("if-version", 0, "Only delete if the book is still at this version")
+
+	if len(args) < 1 {
+		fmt.Println("Book ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid book ID")
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+
+	err = client.Delete(fmt.Sprintf("/books/%d", id), api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "book", id)
+		log.Fatalf("Error deleting book: %v", err)
+	}
+
+	fmt.Printf("Moved book #%d to the trash (restore with 'bookmanager trash restore book %d')\n", id, id)
+}
@@ -1,401 +1,1019 @@
-package commands
-
-import (
-	"bookmanager/api/models"
-	"bookmanager/cmd/bookmanager/api"
-	"encoding/json"
-	"flag"
-	"fmt"
-	"log"
-	"os"
-	"strconv"
-)
-
-func HandleCollectionCommand(client *api.APIClient, args []string) {
-	if len(args) < 1 {
-		printCollectionHelp()
-		os.Exit(1)
-	}
-
-	switch args[0] {
-	case "create":
-		createCollection(client, args[1:])
-	case "list":
-		listCollections(client, args[1:])
-	case "get":
-		getCollection(client, args[1:])
-	case "update":
-		updateCollection(client, args[1:])
-	case "patch":
-		patchCollection(client, args[1:])
-	case "delete":
-		deleteCollection(client, args[1:])
-	case "add-book":
-		addBookToCollection(client, args[1:])
-	case "remove-book":
-		removeBookFromCollection(client, args[1:])
-	case "list-books":
-		listBooksInCollection(client, args[1:])
-	case "help":
-		printCollectionHelp()
-	default:
-		fmt.Printf("Unknown collection command: %s\n", args[0])
-		printCollectionHelp()
-		os.Exit(1)
-	}
-}
-
-func printCollectionHelp() {
-
-	fmt.Printf("%s", `Usage: bookmanager collection <command> [options]
-
-Commands:
-	create        Create a new collection
-	list          List all collections with optional filters
-	get           Get details of a specific collection
-	update        Update a collection's information (requires all fields)
-	patch					Partially update collection fields (only updates provided fields)
-	delete        Remove a collection
-	add-book      Add a book to a collection
-	remove-book   Remove a book from a collection
-	list-books    List books in a collection
-	help          Show this help message
-
-List Options:
-	--where       SQL-like WHERE clause (e.g., "name LIKE '%Fantasy%'")
-	--group-by    SQL-like GROUP BY clause (e.g., "SUBSTRING(name, 1, 1)")
-	--order-by    SQL-like ORDER BY clause (e.g., "name DESC")
-	--limit       Limit number of results
-	--offset      Offset for pagination
-
-Examples:
-	bookmanager collection create --name "Fantasy Classics" --description "Classic fantasy books"
-	bookmanager collection list --where "name LIKE '%Classics%'"
-	bookmanager collection list --group-by "SUBSTRING(name, 1, 1)"`)
-}
-
-func createCollection(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("collection create", flag.ExitOnError)
-	name := fs.String("name", "", "Collection name (required)")
-	description := fs.String("description", "", "Collection description")
-	fs.Parse(args)
-
-	if *name == "" {
-		fmt.Println("Name is required")
-		fs.PrintDefaults()
-		os.Exit(1)
-	}
-
-	collection := map[string]interface{}{
-		"name":        *name,
-		"description": *description,
-	}
-
-	body, err := client.Post("/collections", collection)
-	if err != nil {
-		log.Fatalf("Error creating collection: %v", err)
-	}
-
-	var createdCollection models.Collection
-	if err := json.Unmarshal(body, &createdCollection); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	fmt.Printf("Created collection #%d:%s\n", createdCollection.ID, createdCollection.Name)
-}
-
-func listCollections(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("collection list", flag.ExitOnError)
-	where := fs.String("where", "", "SQL-like WHERE clause")
-	groupBy := fs.String("group-by", "", "SQL-like GROUP BY clause")
-	orderBy := fs.String("order-by", "", "SQL-like ORDER BY clause")
-	limit := fs.Int("limit", 0, "Limit number of results")
-	offset := fs.Int("offset", 0, "Offset for pagination")
-	fs.Parse(args)
-
-	params := client.BuildQueryParams(*where, *groupBy, *orderBy, *limit, *offset)
-
-	body, err := client.Get("/v1/collections", params)
-	if err != nil {
-		log.Fatalf("Error listing collections: %v", err)
-	}
-
-	if *groupBy != "" {
-		var result struct {
-			Groups map[string]int `json:"groups"`
-		}
-		if err := json.Unmarshal(body, &result); err != nil {
-			log.Fatalf("Error parsing grouped response: %v", err)
-		}
-
-		if len(result.Groups) == 0 {
-			fmt.Println("No grouped results found")
-			return
-		}
-
-		fmt.Printf("Collections grouped by %s:\n", *groupBy)
-		for group, count := range result.Groups {
-			fmt.Printf("- %s: %d\n", group, count)
-		}
-	} else {
-		var result struct {
-			Collections []models.Collection `json:"collections"`
-		}
-		if err := json.Unmarshal(body, &result); err != nil {
-			log.Fatalf("Error parsing response: %v", err)
-		}
-
-		if len(result.Collections) == 0 {
-			fmt.Println("No collections found")
-			return
-		}
-
-		for _, collection := range result.Collections {
-			fmt.Printf("%d: %s\n", collection.ID, collection.Name)
-			if collection.Description != "" {
-				fmt.Printf("   Description: %s\n", collection.Description)
-			}
-			fmt.Println()
-		}
-	}
-}
-
-func getCollection(client *api.APIClient, args []string) {
-	if len(args) < 1 {
-		fmt.Println("Collection ID is required")
-		os.Exit(1)
-	}
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid collection ID")
-		os.Exit(1)
-	}
-
-	body, err := client.Get(fmt.Sprintf("/v1/collections/%d", id), nil)
-	if err != nil {
-		log.Fatalf("Error getting collection: %v", err)
-	}
-
-	var collection models.Collection
-	if err := json.Unmarshal(body, &collection); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	fmt.Printf("Collection #%d\n", collection.ID)
-	fmt.Printf("Name: %s\n", collection.Name)
-	if collection.Description != "" {
-		fmt.Printf("Description: %s\n", collection.Description)
-	}
-}
-
-func updateCollection(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("collection update", flag.ExitOnError)
-	name := fs.String("name", "", "Collection name")
-	description := fs.String("description", "", "Collection description")
-
-	if len(args) < 1 {
-		fmt.Println("Collection ID is required")
-		fs.PrintDefaults()
-		os.Exit(1)
-	}
-
-	fs.Parse(args[1:])
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid collection ID")
-		os.Exit(1)
-	}
-
-	currentBody, err := client.Get(fmt.Sprintf("/v1/collections/%d", id), nil)
-	if err != nil {
-		log.Fatalf("Error getting current collection: %v", err)
-	}
-
-	var currentCollection models.Collection
-	if err := json.Unmarshal(currentBody, &currentCollection); err != nil {
-		log.Fatalf("Error parsing current collection: %v", err)
-	}
-
-	updateData := make(map[string]interface{})
-	if *name != "" {
-		updateData["name"] = *name
-	} else {
-		updateData["name"] = currentCollection.Name
-	}
-	if *description != "" {
-		updateData["description"] = *description
-	} else {
-		updateData["description"] = currentCollection.Description
-	}
-
-	body, err := client.Put(fmt.Sprintf("/collections/%d", id), updateData)
-	if err != nil {
-		log.Fatalf("Error updating collection: %v", err)
-	}
-
-	var updatedCollection models.Collection
-	if err := json.Unmarshal(body, &updatedCollection); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	fmt.Printf("Updated collection #%d: %s\n", updatedCollection.ID, updatedCollection.Name)
-}
-
-func patchCollection(client *api.APIClient, args []string) {
-	fs := flag.NewFlagSet("collection patch", flag.ExitOnError)
-	name := fs.String("name", "", "Update collection name (optional)")
-	description := fs.String("description", "", "Update collection description (optional)")
-
-	if len(args) < 1 {
-		fmt.Println("Collection ID is required")
-		fs.PrintDefaults()
-		os.Exit(1)
-	}
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid collection ID")
-		os.Exit(1)
-	}
-
-	fs.Parse(args[1:])
-
-	patchData := make(map[string]interface{})
-
-	if *name != "" {
-		patchData["name"] = *name
-	}
-	if *description != "" {
-		patchData["description"] = *description
-	}
-
-	if len(patchData) == 0 {
-		fmt.Println("No fields to update provided")
-		os.Exit(1)
-	}
-
-	body, err := client.Patch(fmt.Sprintf("/collections/%d", id), patchData)
-	if err != nil {
-		log.Fatalf("Error patching collection: %v", err)
-	}
-
-	var patchedCollection models.Collection
-	if err := json.Unmarshal(body, &patchedCollection); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	fmt.Printf("Successfully patched collection #%d\n", patchedCollection.ID)
-}
-
-func deleteCollection(client *api.APIClient, args []string) {
-	if len(args) < 1 {
-		fmt.Println("Collection ID is required")
-		os.Exit(1)
-	}
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid collection ID")
-		os.Exit(1)
-	}
-
-	err = client.Delete(fmt.Sprintf("/collections/%d", id))
-	if err != nil {
-		log.Fatalf("Error deleting collection: %v", err)
-	}
-
-	fmt.Printf("Deleted collection #%d\n", id)
-}
-
-func addBookToCollection(client *api.APIClient, args []string) {
-	if len(args) < 2 {
-		fmt.Println("Collection ID and Book ID are required")
-		os.Exit(1)
-	}
-
-	collectionID, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid collection ID")
-		os.Exit(1)
-	}
-
-	bookID, err := strconv.Atoi(args[1])
-	if err != nil {
-		fmt.Println("Invalid book ID")
-		os.Exit(1)
-	}
-
-	req := map[string]interface{}{
-		"book_id": bookID,
-	}
-	_, err = client.Post(fmt.Sprintf("/collections-books/%d", collectionID), req)
-	if err != nil {
-		log.Fatalf("Error adding book to collection: %v", err)
-	}
-
-	fmt.Printf("Added book #%d to collection #%d\n", bookID, collectionID)
-}
-
-func removeBookFromCollection(client *api.APIClient, args []string) {
-	if len(args) < 2 {
-		fmt.Println("Collection ID and Book ID are required")
-		os.Exit(1)
-	}
-
-	collectionID, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid collection ID")
-		os.Exit(1)
-	}
-
-	bookID, err := strconv.Atoi(args[1])
-	if err != nil {
-		fmt.Println("Invalid book ID")
-		os.Exit(1)
-	}
-
-	err = client.Delete(fmt.Sprintf("/collections-books/%d/%d", collectionID, bookID))
-	if err != nil {
-		log.Fatalf("Error removing book from collection: %v", err)
-	}
-
-	fmt.Printf("Removed book #%d from collection #%d\n", bookID, collectionID)
-}
-
-func listBooksInCollection(client *api.APIClient, args []string) {
-	if len(args) < 1 {
-		fmt.Println("Collection ID is required")
-		os.Exit(1)
-	}
-
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		fmt.Println("Invalid collection ID")
-		os.Exit(1)
-	}
-
-	body, err := client.Get(fmt.Sprintf("/v1/collections-books/%d", id), nil)
-	if err != nil {
-		log.Fatalf("Error listing books in collection: %v", err)
-
-	}
-
-	var result struct {
-		Books []models.Book `json:"books"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Fatalf("Error parsing response: %v", err)
-	}
-
-	if len(result.Books) == 0 {
-		fmt.Printf("No books found in collection #%d\n", id)
-		return
-	}
-
-	fmt.Printf("Books in collection #%d:\n", id)
-	for _, book := range result.Books {
-		fmt.Printf("- %s by %s (%s)\n", book.Title, book.Author, book.PublishedDate)
-	}
-}
+package commands
+
+import (
+	"bookmanager/api/models"
+	"bookmanager/cmd/bookmanager/api"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func HandleCollectionCommand(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		printCollectionHelp()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "create":
+		createCollection(client, args[1:])
+	case "list":
+		listCollections(client, args[1:])
+	case "get":
+		getCollection(client, args[1:])
+	case "update":
+		updateCollection(client, args[1:])
+	case "patch":
+		patchCollection(client, args[1:])
+	case "delete":
+		deleteCollection(client, args[1:])
+	case "history":
+		showHistory(client, "collection", args[1:])
+	case "revert":
+		revertTo(client, "collection", args[1:])
+	case "add-book":
+		addBookToCollection(client, args[1:])
+	case "remove-book":
+		removeBookFromCollection(client, args[1:])
+	case "list-books":
+		listBooksInCollection(client, args[1:])
+	case "move-book":
+		moveBookInCollection(client, args[1:])
+	case "reorder":
+		reorderCollection(client, args[1:])
+	case "copy-books":
+		transferBooks(client, models.TransferCopy, args[1:])
+	case "move-books":
+		transferBooks(client, models.TransferMove, args[1:])
+	case "move":
+		moveCollection(client, args[1:])
+	case "union":
+		combineCollections(client, "UNION", args[1:])
+	case "intersect":
+		combineCollections(client, "INTERSECT", args[1:])
+	case "diff":
+		combineCollections(client, "EXCEPT", args[1:])
+	case "help":
+		printCollectionHelp()
+	default:
+		fmt.Printf("Unknown collection command: %s\n", args[0])
+		printCollectionHelp()
+		os.Exit(1)
+	}
+}
+
+func printCollectionHelp() {
+
+	fmt.Printf("%s", `Usage: bookmanager collection <command> [options]
+
+Commands:
+	create        Create a new collection
+	list          List all collections with optional filters (--tree for the hierarchy)
+	get           Get details of a specific collection
+	update        Update a collection's information (requires all fields)
+	patch					Partially update collection fields (only updates provided fields)
+	delete        Remove a collection
+	move          Move a collection under another one: move <id> --parent N | --root
+	add-book      Add books to a collection: add-book <id> <book id>... or add-book <id> [filters]
+	remove-book   Remove books from a collection, by ID or by filters like add-book
+	list-books    List books in a collection, in order (--recursive to include sub-collections)
+	move-book     Move a book within a collection: move-book <id> <book id> --to N
+	reorder       Put books of a collection in order: reorder <id> <book id>...
+	copy-books    Copy books to another collection: copy-books <id> <other id> <book id>... or [filters]
+	move-books    Move books to another collection, like copy-books
+	union         List the books in any of the collections: union <id> <id>...
+	intersect     List the books in all of the collections: intersect <id> <id>...
+	diff          List the books in the first collection but none of the others: diff <id> <id>...
+	history       Show the changes made to a collection (--revision N for one revision)
+	revert        Revert a collection to an earlier revision: revert <id> <revision>
+	help          Show this help message
+
+Create and Update Options:
+	--name        Collection name
+	--description Collection description
+	--parent      ID of the collection this one is a sub-collection of
+	--smart       Create a smart collection, which holds the books matching its query
+	--author, --genre, --published-after, --published-before, --where, --order-by
+	              The query of a smart collection, like the filters of 'book list';
+	              any of them makes a new collection smart
+
+Books added to a smart collection by hand stay in it and books removed from it
+by hand stay out, whatever the query. Its books are in the order of the query.
+
+List Options:
+	--tree        Show all collections as a tree of sub-collections
+	--where       Filter expression (e.g., "name LIKE '%Fantasy%'" or "kind = 'smart'")
+	--group-by    Fields to group by, also year(), month() or decade() of a date (e.g., "description")
+	--group-limit Collections listed per group (default 10, 0 for counts only)
+	--order-by    Comma separated fields with optional ASC/DESC (e.g., "name DESC")
+	--limit       Page size (default 50, max 200)
+	--offset      Offset for pagination
+	--cursor      Continue from a cursor printed by a previous list
+	--all         Fetch all pages
+	--total       Show the total number of matching collections
+
+Patch Options:
+	--clear-description Remove the description
+
+Add Book Options:
+	--position    Place to add a single book at, 1 for the first (default: the end)
+
+Book Filters (add-book, remove-book, copy-books, move-books):
+	--author, --genre, --published-after, --published-before, --where
+	              Select the books matching these filters, like those of 'book list'
+	--order-by    Order in which matching books are added (default: title)
+
+add-book selects from all books, the other commands from the books of the
+collection. Books that are already where they should be, or do not exist, are
+listed and left alone.
+
+Union, Intersect and Diff Options:
+	--where, --order-by, --limit, --cursor, --all, --total
+	              Like the list options of 'book list' (default order: title)
+	--save        Save the books as a new collection with this name, in order
+	--description Description of the saved collection
+
+Move Book Options:
+	--to          Position to move the book to, 1 for the first
+	--before      Move the book right before the book with this ID
+	--after       Move the book right after the book with this ID
+
+Reorder lists books in their new order. They take the places they hold
+between them, so listing some books only reorders those.
+
+Patch, Delete and Move Options:
+	--if-version  Only write if the collection is still at this version (shown by get)
+
+Delete Options:
+	--cascade     Also move the sub-collections to the trash, instead of moving
+	              them up to the parent of the deleted collection
+
+Update keeps the current value of fields that are not given, and starts over
+when the collection changes while it is being updated.
+
+Examples:
+	bookmanager collection create --name "Fantasy Classics" --description "Classic fantasy books"
+	bookmanager collection create --name "Modern Fantasy" --genre Fantasy --published-after 2000-01-01
+	bookmanager collection create --name "Semester 1" --parent 7
+	bookmanager collection move 8 --root
+	bookmanager collection list --tree
+	bookmanager collection list-books 7 --recursive
+	bookmanager collection update 4 --order-by "published_date DESC"
+	bookmanager collection list --where "name LIKE '%Classics%'"
+	bookmanager collection list --group-by "description"
+	bookmanager collection add-book 3 10 --position 1
+	bookmanager collection add-book 3 10 11 12
+	bookmanager collection add-book 3 --genre Fantasy
+	bookmanager collection move-books 3 5 --where "published_date < '1950-01-01'"
+	bookmanager collection diff 3 5
+	bookmanager collection intersect 3 4 --save "Club Favorites"
+	bookmanager collection move-book 3 10 --to 4
+	bookmanager collection reorder 3 12 10 11`)
+}
+
+func createCollection(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("collection create", flag.ExitOnError)
+	name := fs.String("name", "", "Collection name (required)")
+	description := fs.String("description", "", "Collection description")
+	smart := fs.Bool("smart", false, "Create a smart collection")
+	parent := fs.Int("parent", 0, "ID of the parent collection (default: a top-level collection)")
+	query := smartQueryFlags(fs)
+	fs.Parse(args)
+
+	if *name == "" {
+		fmt.Println("Name is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	collection := map[string]interface{}{
+		"name":        *name,
+		"description": *description,
+	}
+	if *parent != 0 {
+		collection["parent_id"] = *parent
+	}
+	if q := query(nil); q != nil || *smart {
+		if q == nil {
+			q = &models.SmartQuery{}
+		}
+		collection["kind"] = models.CollectionSmart
+		collection["query"] = q
+	}
+
+	body, err := client.Post("/collections", collection)
+	if err != nil {
+		log.Fatalf("Error creating collection: %v", err)
+	}
+
+	var createdCollection models.Collection
+	if err := json.Unmarshal(body, &createdCollection); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Created collection #%d:%s\n", createdCollection.ID, createdCollection.Name)
+}
+
+// smartQueryFlags defines the flags of the query of a smart collection. The
+// returned function applies the flags that were given to a copy of current
+// and returns nil when current is nil and none were given.
+func smartQueryFlags(fs *flag.FlagSet) func(current *models.SmartQuery) *models.SmartQuery {
+	author := fs.String("author", "", "Books by this author")
+	genre := fs.String("genre", "", "Books of this genre")
+	publishedAfter := fs.String("published-after", "", "Books published on or after this date (YYYY-MM-DD)")
+	publishedBefore := fs.String("published-before", "", "Books published on or before this date (YYYY-MM-DD)")
+	where := fs.String("where", "", "Books matching this filter expression")
+	orderBy := fs.String("order-by", "", "Order of the books (default: title)")
+
+	return func(current *models.SmartQuery) *models.SmartQuery {
+		given := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+		var q models.SmartQuery
+		if current != nil {
+			q = *current
+		}
+		for name, field := range map[string]struct {
+			value *string
+			dest  *string
+		}{
+			"author":           {author, &q.Author},
+			"genre":            {genre, &q.Genre},
+			"published-after":  {publishedAfter, &q.PublishedAfter},
+			"published-before": {publishedBefore, &q.PublishedBefore},
+			"where":            {where, &q.Where},
+			"order-by":         {orderBy, &q.OrderBy},
+		} {
+			if given[name] {
+				*field.dest = *field.value
+			}
+		}
+		if current == nil && q == (models.SmartQuery{}) {
+			return nil
+		}
+		return &q
+	}
+}
+
+func listCollections(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("collection list", flag.ExitOnError)
+	where := fs.String("where", "", "Filter expression")
+	groupBy := fs.String("group-by", "", "Fields to group by")
+	groupLimit := fs.Int("group-limit", -1, "Collections listed per group (0 for counts only)")
+	orderBy := fs.String("order-by", "", "Fields to order by")
+	limit := fs.Int("limit", 0, "Page size")
+	offset := fs.Int("offset", 0, "Offset for pagination")
+	cursor := fs.String("cursor", "", "Cursor of the page to fetch (from a previous list)")
+	all := fs.Bool("all", false, "Fetch all pages")
+	total := fs.Bool("total", false, "Show the total number of matching collections")
+	tree := fs.Bool("tree", false, "Show all collections as a tree")
+	fs.Parse(args)
+
+	if *tree {
+		listCollectionTree(client)
+		return
+	}
+
+	params := client.BuildQueryParams(*where, *groupBy, *orderBy, *limit, *offset)
+
+	if *groupBy != "" {
+		if *groupLimit >= 0 {
+			params["group_limit"] = strconv.Itoa(*groupLimit)
+		}
+		body, err := client.Get("/v1/collections", params)
+		if err != nil {
+			log.Fatalf("Error listing collections: %v", err)
+		}
+		printGroups(body, *groupBy, *orderBy, "collections", "collection", func(items json.RawMessage) {
+			var collections []models.Collection
+			if err := json.Unmarshal(items, &collections); err != nil {
+				log.Fatalf("Error parsing response: %v", err)
+			}
+			for _, collection := range collections {
+				fmt.Printf("  %d: %s\n", collection.ID, collection.Name)
+			}
+		})
+		return
+	}
+
+	if *cursor != "" {
+		params["cursor"] = *cursor
+	}
+	if *total {
+		params["include_total"] = "true"
+	}
+
+	shown := 0
+	info := fetchPages(client, "/v1/collections", params, *all, func(body []byte) {
+		var result struct {
+			Collections []models.Collection `json:"collections"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			log.Fatalf("Error parsing response: %v", err)
+		}
+
+		for _, collection := range result.Collections {
+			if collection.Kind == models.CollectionSmart {
+				fmt.Printf("%d: %s (smart)\n", collection.ID, collection.Name)
+			} else {
+				fmt.Printf("%d: %s\n", collection.ID, collection.Name)
+			}
+			if collection.Description != "" {
+				fmt.Printf("   Description: %s\n", collection.Description)
+			}
+			fmt.Println()
+		}
+		shown += len(result.Collections)
+	})
+
+	if shown == 0 {
+		fmt.Println("No collections found")
+		return
+	}
+	printPageFooter(info, shown)
+}
+
+func listCollectionTree(client *api.APIClient) {
+	body, err := client.Get("/v1/collections/tree", nil)
+	if err != nil {
+		log.Fatalf("Error listing collections: %v", err)
+	}
+
+	var result struct {
+		Collections []models.CollectionNode `json:"collections"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	if len(result.Collections) == 0 {
+		fmt.Println("No collections found")
+		return
+	}
+	printCollectionTree(result.Collections, "")
+}
+
+// printCollectionTree prints collections with their sub-collections
+// indented below them.
+func printCollectionTree(nodes []models.CollectionNode, indent string) {
+	for _, node := range nodes {
+		if node.Kind == models.CollectionSmart {
+			fmt.Printf("%s%d: %s (smart)\n", indent, node.ID, node.Name)
+		} else {
+			fmt.Printf("%s%d: %s\n", indent, node.ID, node.Name)
+		}
+		printCollectionTree(node.Children, indent+"  ")
+	}
+}
+
+func getCollection(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	body, err := client.Get(fmt.Sprintf("/v1/collections/%d", id), nil)
+	if err != nil {
+		log.Fatalf("Error getting collection: %v", err)
+	}
+
+	var collection models.Collection
+	if err := json.Unmarshal(body, &collection); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Collection #%d\n", collection.ID)
+	fmt.Printf("Name: %s\n", collection.Name)
+	if collection.Description != "" {
+		fmt.Printf("Description: %s\n", collection.Description)
+	}
+	fmt.Printf("Kind: %s\n", collection.Kind)
+	if collection.ParentID != nil {
+		fmt.Printf("Parent: #%d\n", *collection.ParentID)
+		printCollectionPath(client, id)
+	}
+	if q := collection.Query; q != nil {
+		for _, field := range []struct{ name, value string }{
+			{"Author", q.Author},
+			{"Genre", q.Genre},
+			{"Published after", q.PublishedAfter},
+			{"Published before", q.PublishedBefore},
+			{"Where", q.Where},
+			{"Order by", q.OrderBy},
+		} {
+			if field.value != "" {
+				fmt.Printf("Query %s: %s\n", field.name, field.value)
+			}
+		}
+	}
+	fmt.Printf("Version: %d\n", collection.Version)
+}
+
+// printCollectionPath prints the names of a collection and the collections
+// above it, e.g. "Courses > 2025 > Semester 1".
+func printCollectionPath(client *api.APIClient, id int) {
+	body, err := client.Get(fmt.Sprintf("/v1/collections/%d/breadcrumb", id), nil)
+	if err != nil {
+		log.Fatalf("Error getting collection path: %v", err)
+	}
+
+	var result struct {
+		Collections []models.Collection `json:"collections"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	names := make([]string, len(result.Collections))
+	for i, collection := range result.Collections {
+		names[i] = collection.Name
+	}
+	fmt.Printf("Path: %s\n", strings.Join(names, " > "))
+}
+
+func updateCollection(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("collection update", flag.ExitOnError)
+	name := fs.String("name", "", "Collection name")
+	description := fs.String("description", "", "Collection description")
+	parent := fs.Int("parent", 0, "ID of the parent collection (default: the current one)")
+	query := smartQueryFlags(fs)
+
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	fs.Parse(args[1:])
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	// Unset fields keep their current value. The write is conditional on the
+	// version that was read, and starts over if someone else got in between.
+	body, err := retryOnConflict(func() ([]byte, error) {
+		currentBody, err := client.Get(fmt.Sprintf("/v1/collections/%d", id), nil)
+		if err != nil {
+			log.Fatalf("Error getting current collection: %v", err)
+		}
+
+		var currentCollection models.Collection
+		if err := json.Unmarshal(currentBody, &currentCollection); err != nil {
+			log.Fatalf("Error parsing current collection: %v", err)
+		}
+
+		updateData := make(map[string]interface{})
+		if *name != "" {
+			updateData["name"] = *name
+		} else {
+			updateData["name"] = currentCollection.Name
+		}
+		if *description != "" {
+			updateData["description"] = *description
+		} else {
+			updateData["description"] = currentCollection.Description
+		}
+		if *parent != 0 {
+			updateData["parent_id"] = *parent
+		} else if currentCollection.ParentID != nil {
+			updateData["parent_id"] = *currentCollection.ParentID
+		}
+		if currentCollection.Kind == models.CollectionSmart {
+			updateData["query"] = query(currentCollection.Query)
+		} else if query(nil) != nil {
+			fmt.Println("Only a smart collection has a query")
+			os.Exit(1)
+		}
+
+		return client.Put(fmt.Sprintf("/collections/%d", id), updateData, api.IfMatch(currentCollection.Version))
+	})
+	if err != nil {
+		log.Fatalf("Error updating collection: %v", err)
+	}
+
+	var updatedCollection models.Collection
+	if err := json.Unmarshal(body, &updatedCollection); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Updated collection #%d: %s\n", updatedCollection.ID, updatedCollection.Name)
+}
+
+func patchCollection(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("collection patch", flag.ExitOnError)
+	name := fs.String("name", "", "Update collection name (optional)")
+	description := fs.String("description", "", "Update collection description (optional)")
+	clearDescription := fs.Bool("clear-description", false, "Remove the collection description")
+	ifVersion := fs.Int("if-version", 0, "Only patch if the collection is still at this version")
+
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	fs.Parse(args[1:])
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	patchData := make(map[string]interface{})
+	if set["name"] {
+		patchData["name"] = *name
+	}
+	if set["description"] {
+		patchData["description"] = *description
+	}
+	if *clearDescription {
+		if set["description"] {
+			fmt.Println("The description cannot be both set and cleared")
+			os.Exit(1)
+		}
+		patchData["description"] = nil
+	}
+
+	if len(patchData) == 0 {
+		fmt.Println("No fields to update provided")
+		os.Exit(1)
+	}
+
+	body, err := client.Patch(fmt.Sprintf("/collections/%d", id), patchData, api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "collection", id)
+		log.Fatalf("Error patching collection: %v", err)
+	}
+
+	var patchedCollection models.Collection
+	if err := json.Unmarshal(body, &patchedCollection); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Successfully patched collection #%d\n", patchedCollection.ID)
+}
+
+func deleteCollection(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("collection delete", flag.ExitOnError)
+	ifVersion := fs.Int("if-version", 0, "Only delete if the collection is still at this version")
+	cascade := fs.Bool("cascade", false, "Also move the sub-collections to the trash")
+
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+
+	path := fmt.Sprintf("/collections/%d", id)
+	if *cascade {
+		path += "?children=" + models.DeleteCascade
+	}
+	err = client.Delete(path, api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "collection", id)
+		log.Fatalf("Error deleting collection: %v", err)
+	}
+
+	fmt.Printf("Moved collection #%d to the trash (restore with 'bookmanager trash restore collection %d')\n", id, id)
+}
+
+func moveCollection(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("collection move", flag.ExitOnError)
+	parent := fs.Int("parent", 0, "ID of the new parent collection")
+	root := fs.Bool("root", false, "Make the collection a top-level collection")
+	ifVersion := fs.Int("if-version", 0, "Only move if the collection is still at this version")
+
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+
+	if (*parent != 0) == *root {
+		fmt.Println("One of --parent and --root is required")
+		os.Exit(1)
+	}
+
+	var move models.CollectionMove
+	if !*root {
+		move.ParentID = parent
+	}
+	body, err := client.Post(fmt.Sprintf("/collections/%d/move", id), move, api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, "collection", id)
+		log.Fatalf("Error moving collection: %v", err)
+	}
+
+	var moved models.Collection
+	if err := json.Unmarshal(body, &moved); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	if moved.ParentID == nil {
+		fmt.Printf("Moved collection #%d to the top level\n", moved.ID)
+	} else {
+		fmt.Printf("Moved collection #%d under collection #%d\n", moved.ID, *moved.ParentID)
+	}
+}
+
+func addBookToCollection(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		os.Exit(1)
+	}
+
+	collectionID, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	bookIDs, rest := bookArgs(args[1:])
+	fs := flag.NewFlagSet("collection add-book", flag.ExitOnError)
+	position := fs.Int("position", 0, "Place to add the book at, 1 for the first (default: the end)")
+	query := smartQueryFlags(fs)
+	if err := fs.Parse(rest); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+	books := bookSelection(bookIDs, query(nil))
+
+	if len(bookIDs) == 1 {
+		req := models.CollectionBookRequest{BookID: bookIDs[0], Position: *position}
+		_, err = client.Post(fmt.Sprintf("/collections/%d/books", collectionID), req)
+		if err != nil {
+			log.Fatalf("Error adding book to collection: %v", err)
+		}
+		fmt.Printf("Added book #%d to collection #%d\n", bookIDs[0], collectionID)
+		return
+	}
+	if *position != 0 {
+		fmt.Println("--position only applies to a single book")
+		os.Exit(1)
+	}
+
+	body, err := client.Post(fmt.Sprintf("/collections/%d/books/bulk-add", collectionID), books)
+	if err != nil {
+		log.Fatalf("Error adding books to collection: %v", err)
+	}
+	report := readMembershipReport(body)
+	fmt.Printf("Added %d books to collection #%d\n", report.Added, collectionID)
+	printMemberResults(report, map[string]string{
+		models.MemberPresent: "already in the collection",
+		models.MemberMissing: "no such book",
+	})
+}
+
+func transferBooks(client *api.APIClient, mode string, args []string) {
+	if len(args) < 2 {
+		fmt.Println("Collection ID and the ID of the other collection are required")
+		os.Exit(1)
+	}
+
+	collectionID, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+	to, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	bookIDs, rest := bookArgs(args[2:])
+	fs := flag.NewFlagSet("collection "+mode+"-books", flag.ExitOnError)
+	query := smartQueryFlags(fs)
+	if err := fs.Parse(rest); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+
+	transfer := models.BookTransfer{BookSelection: bookSelection(bookIDs, query(nil)), To: to, Mode: mode}
+	body, err := client.Post(fmt.Sprintf("/collections/%d/books/transfer", collectionID), transfer)
+	if err != nil {
+		log.Fatalf("Error transferring books: %v", err)
+	}
+	report := readMembershipReport(body)
+	if mode == models.TransferMove {
+		fmt.Printf("Moved %d books from collection #%d to collection #%d\n", report.Added+report.Present, collectionID, to)
+	} else {
+		fmt.Printf("Copied %d books from collection #%d to collection #%d\n", report.Added, collectionID, to)
+	}
+	printMemberResults(report, map[string]string{
+		models.MemberPresent: fmt.Sprintf("already in collection #%d", to),
+		models.MemberAbsent:  fmt.Sprintf("not in collection #%d", collectionID),
+		models.MemberMissing: "no such book",
+	})
+}
+
+// combineCollections lists or saves the books of a set expression joining
+// the collections given before the flags with op.
+func combineCollections(client *api.APIClient, op string, args []string) {
+	var ids []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if _, err := strconv.Atoi(args[0]); err != nil {
+			fmt.Printf("Invalid collection ID: %s\n", args[0])
+			os.Exit(1)
+		}
+		ids = append(ids, args[0])
+		args = args[1:]
+	}
+	if len(ids) < 2 {
+		fmt.Println("At least two collection IDs are required")
+		os.Exit(1)
+	}
+	expr := strings.Join(ids, " "+op+" ")
+
+	fs := flag.NewFlagSet("collection "+strings.ToLower(op), flag.ExitOnError)
+	where := fs.String("where", "", "Filter expression")
+	orderBy := fs.String("order-by", "", "Fields to order by")
+	limit := fs.Int("limit", 0, "Page size")
+	cursor := fs.String("cursor", "", "Cursor of the page to fetch (from a previous list)")
+	all := fs.Bool("all", false, "Fetch all pages")
+	total := fs.Bool("total", false, "Show the total number of matching books")
+	save := fs.String("save", "", "Save the books as a new collection with this name")
+	description := fs.String("description", "", "Description of the saved collection")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+
+	if *save != "" {
+		request := models.CollectionSetRequest{
+			CollectionRequest: models.CollectionRequest{Name: *save, Description: *description},
+			Expr:              expr,
+			OrderBy:           *orderBy,
+		}
+		body, err := client.Post("/collections/set", request)
+		if err != nil {
+			log.Fatalf("Error creating collection: %v", err)
+		}
+		var createdCollection models.Collection
+		if err := json.Unmarshal(body, &createdCollection); err != nil {
+			log.Fatalf("Error parsing response: %v", err)
+		}
+		fmt.Printf("Created collection #%d:%s with the books of %s\n", createdCollection.ID, createdCollection.Name, expr)
+		return
+	}
+
+	params := client.BuildQueryParams(*where, "", *orderBy, *limit, 0)
+	params["expr"] = expr
+	if *cursor != "" {
+		params["cursor"] = *cursor
+	}
+	if *total {
+		params["include_total"] = "true"
+	}
+
+	shown := 0
+	info := fetchPages(client, "/v1/collections/set", params, *all, func(body []byte) {
+		var result struct {
+			Books []models.Book `json:"books"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			log.Fatalf("Error parsing response: %v", err)
+		}
+		for _, book := range result.Books {
+			fmt.Printf("%d: %s by %s (%s)\n", book.ID, book.Title, book.Author, book.PublishedDate)
+		}
+		shown += len(result.Books)
+	})
+
+	if shown == 0 {
+		fmt.Printf("No books found in %s\n", expr)
+		return
+	}
+	printPageFooter(info, shown)
+}
+
+// bookArgs reads the book IDs that come before the flags.
+func bookArgs(args []string) ([]int, []string) {
+	var ids []int
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return ids, args[i:]
+		}
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Printf("Invalid book ID: %s\n", arg)
+			os.Exit(1)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// bookSelection selects books by ID or by filters, and exits unless exactly
+// one of them is given.
+func bookSelection(bookIDs []int, query *models.SmartQuery) models.BookSelection {
+	if (len(bookIDs) == 0) == (query == nil) {
+		fmt.Println("Either book IDs or filters are required")
+		os.Exit(1)
+	}
+	return models.BookSelection{BookIDs: bookIDs, Query: query}
+}
+
+func readMembershipReport(body []byte) *models.MembershipReport {
+	var report models.MembershipReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+	return &report
+}
+
+// printMemberResults lists the books of a bulk change whose outcome has a
+// message.
+func printMemberResults(report *models.MembershipReport, messages map[string]string) {
+	for _, book := range report.Books {
+		if message, ok := messages[book.Status]; ok {
+			fmt.Printf("  #%d: %s\n", book.BookID, message)
+		}
+	}
+}
+
+func moveBookInCollection(client *api.APIClient, args []string) {
+	if len(args) < 2 {
+		fmt.Println("Collection ID and Book ID are required")
+		os.Exit(1)
+	}
+
+	collectionID, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	bookID, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Println("Invalid book ID")
+		os.Exit(1)
+	}
+
+	var move models.BookMove
+	fs := flag.NewFlagSet("collection move-book", flag.ExitOnError)
+	fs.IntVar(&move.Position, "to", 0, "Position to move the book to, 1 for the first")
+	fs.IntVar(&move.Before, "before", 0, "Move the book right before this book")
+	fs.IntVar(&move.After, "after", 0, "Move the book right after this book")
+	if err := fs.Parse(args[2:]); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+	if err := move.Validate(); err != nil {
+		fmt.Println("One of --to, --before and --after is required")
+		os.Exit(1)
+	}
+
+	body, err := client.Post(fmt.Sprintf("/collections/%d/books/%d/move", collectionID, bookID), move)
+	if err != nil {
+		log.Fatalf("Error moving book: %v", err)
+	}
+	fmt.Printf("Moved book #%d in collection #%d\n", bookID, collectionID)
+	printCollectionBooks(collectionID, body)
+}
+
+func reorderCollection(client *api.APIClient, args []string) {
+	if len(args) < 2 {
+		fmt.Println("Collection ID and Book IDs are required")
+		os.Exit(1)
+	}
+
+	collectionID, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	var req models.CollectionOrderRequest
+	for _, arg := range args[1:] {
+		bookID, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Printf("Invalid book ID: %s\n", arg)
+			os.Exit(1)
+		}
+		req.BookIDs = append(req.BookIDs, bookID)
+	}
+
+	body, err := client.Put(fmt.Sprintf("/collections/%d/books/order", collectionID), req)
+	if err != nil {
+		log.Fatalf("Error reordering collection: %v", err)
+	}
+	fmt.Printf("Reordered collection #%d\n", collectionID)
+	printCollectionBooks(collectionID, body)
+}
+
+func removeBookFromCollection(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		os.Exit(1)
+	}
+
+	collectionID, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	bookIDs, rest := bookArgs(args[1:])
+	fs := flag.NewFlagSet("collection remove-book", flag.ExitOnError)
+	query := smartQueryFlags(fs)
+	if err := fs.Parse(rest); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+	books := bookSelection(bookIDs, query(nil))
+
+	if len(bookIDs) == 1 {
+		err = client.Delete(fmt.Sprintf("/collections/%d/books/%d", collectionID, bookIDs[0]))
+		if err != nil {
+			log.Fatalf("Error removing book from collection: %v", err)
+		}
+		fmt.Printf("Removed book #%d from collection #%d\n", bookIDs[0], collectionID)
+		return
+	}
+
+	body, err := client.Post(fmt.Sprintf("/collections/%d/books/bulk-remove", collectionID), books)
+	if err != nil {
+		log.Fatalf("Error removing books from collection: %v", err)
+	}
+	report := readMembershipReport(body)
+	fmt.Printf("Removed %d books from collection #%d\n", report.Removed, collectionID)
+	printMemberResults(report, map[string]string{
+		models.MemberAbsent:  "not in the collection",
+		models.MemberMissing: "no such book",
+	})
+}
+
+func listBooksInCollection(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Collection ID is required")
+		os.Exit(1)
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println("Invalid collection ID")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("collection list-books", flag.ExitOnError)
+	recursive := fs.Bool("recursive", false, "Include the books of sub-collections")
+	if err := fs.Parse(args[1:]); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+
+	var params map[string]string
+	if *recursive {
+		params = map[string]string{"recursive": "true"}
+	}
+	body, err := client.Get(fmt.Sprintf("/v1/collections/%d/books", id), params)
+	if err != nil {
+		log.Fatalf("Error listing books in collection: %v", err)
+
+	}
+	printCollectionBooks(id, body)
+}
+
+// printCollectionBooks prints a {"books": [...]} response in order.
+func printCollectionBooks(id int, body []byte) {
+	var result struct {
+		Books []models.Book `json:"books"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	if len(result.Books) == 0 {
+		fmt.Printf("No books found in collection #%d\n", id)
+		return
+	}
+
+	fmt.Printf("Books in collection #%d:\n", id)
+	for _, book := range result.Books {
+		fmt.Printf("%3d. %s by %s (%s) [#%d]\n", book.Position, book.Title, book.Author, book.PublishedDate, book.ID)
+	}
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"bookmanager/cmd/bookmanager/api"
+	"log"
+)
+
+// conflictRetries is how often a read-modify-write command starts over when
+// the resource changed between its read and its write.
+const conflictRetries = 3
+
+// retryOnConflict runs attempt again while it fails with 412 Precondition
+// Failed, up to conflictRetries more times.
+func retryOnConflict(attempt func() ([]byte, error)) ([]byte, error) {
+	for i := 0; ; i++ {
+		body, err := attempt()
+		if err == nil || !api.IsPreconditionFailed(err) || i == conflictRetries {
+			return body, err
+		}
+	}
+}
+
+// exitOnConflict explains a write rejected because the resource is no longer
+// at the version given with --if-version.
+func exitOnConflict(err error, kind string, id int) {
+	if api.IsPreconditionFailed(err) {
+		log.Fatalf("Error: %s #%d was changed by someone else: %v\nRun 'bookmanager %s get %d' to see the current version.", kind, id, err, kind, id)
+	}
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"bookmanager/cmd/bookmanager/api"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportExtensions maps the extension of an output file to its format.
+var exportExtensions = map[string]string{
+	".csv":    "csv",
+	".json":   "json",
+	".ndjson": "ndjson",
+	".jsonl":  "ndjson",
+	".bib":    "bibtex",
+	".ris":    "ris",
+}
+
+func HandleExportCommand(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "", "csv, json, ndjson, bibtex or ris (default from the output file, else csv)")
+	output := fs.String("output", "", "File to write to (default stdout)")
+	collection := fs.Int("collection", 0, "Only export the books of this collection")
+	where := fs.String("where", "", "Filter expression")
+	orderBy := fs.String("order-by", "", "Fields to order by")
+	author := fs.String("author", "", "Filter by author")
+	genre := fs.String("genre", "", "Filter by genre")
+	isbn := fs.String("isbn", "", "Filter by ISBN-10 or ISBN-13")
+	publishedAfter := fs.String("published-after", "", "Filter by publication date (after)")
+	publishedBefore := fs.String("published-before", "", "Filter by publication date (before)")
+	fs.Usage = printExportHelp
+
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+	if fs.NArg() > 0 {
+		printExportHelp()
+		os.Exit(1)
+	}
+
+	for _, date := range []struct{ name, value string }{
+		{"published-after", *publishedAfter},
+		{"published-before", *publishedBefore},
+	} {
+		if date.value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date.value); err != nil {
+			log.Fatalf("Invalid %s date format: %v", date.name, err)
+		}
+	}
+
+	if *format == "" {
+		*format = "csv"
+		if f, ok := exportExtensions[strings.ToLower(filepath.Ext(*output))]; ok {
+			*format = f
+		}
+	}
+	params := map[string]string{"format": *format}
+	for name, value := range map[string]string{
+		"where":            *where,
+		"order_by":         *orderBy,
+		"author":           *author,
+		"genre":            *genre,
+		"isbn":             *isbn,
+		"published_after":  *publishedAfter,
+		"published_before": *publishedBefore,
+	} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+
+	endpoint := "/books/export"
+	if *collection != 0 {
+		endpoint = "/collections/" + strconv.Itoa(*collection) + "/books/export"
+	}
+
+	if *output == "" {
+		if _, err := client.Download(endpoint, params, os.Stdout); err != nil {
+			log.Fatalf("Error exporting books: %v", err)
+		}
+		return
+	}
+
+	// The export goes to a temporary file first, so a failed export does not
+	// leave a partial file or replace an earlier one.
+	tmp, err := os.CreateTemp(filepath.Dir(*output), ".bookmanager-export-*")
+	if err != nil {
+		log.Fatalf("Error creating file: %v", err)
+	}
+	// CreateTemp makes the file readable by its owner only.
+	tmp.Chmod(0o644)
+	n, err := client.Download(endpoint, params, tmp)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write file: %w", closeErr)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), *output)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Fatalf("Error exporting books: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported to %s (%s)\n", *output, byteSize(n))
+}
+
+// byteSize formats a number of bytes for people.
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	size, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB"} {
+		if size < unit {
+			break
+		}
+		size, suffix = size/unit, s
+	}
+	return fmt.Sprintf("%.1f %s", size, suffix)
+}
+
+func printExportHelp() {
+	fmt.Println(`Usage: bookmanager export [options]
+
+Exports the books matching the filters, or the books of a collection.
+
+Options:
+  --format             csv, json, ndjson, bibtex or ris; by default taken from the
+                       extension of --output (.csv, .json, .ndjson, .jsonl, .bib, .ris), else csv
+  --output             File to write to (default: stdout)
+  --collection         Only export the books of the collection with this ID
+  --where              Filter expression (e.g., "genre = 'Fantasy'")
+  --order-by           Fields to order by (e.g., "published_date desc")
+  --author             Filter by author
+  --genre              Filter by genre
+  --isbn               Filter by ISBN-10 or ISBN-13
+  --published-after    Filter by publication date (YYYY-MM-DD)
+  --published-before   Filter by publication date (YYYY-MM-DD)
+
+A CSV export can be imported again with 'bookmanager book import'.
+
+Examples:
+  bookmanager export --output books.csv
+  bookmanager export --format bibtex --genre "Science Fiction" > scifi.bib
+  bookmanager export --collection 2 --output reading-list.ris`)
+}
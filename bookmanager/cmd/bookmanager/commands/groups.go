@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+type groupResult struct {
+	Key        map[string]*string `json:"key"`
+	Count      int                `json:"count"`
+	Where      string             `json:"where"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+// printGroups renders a grouped list: a heading per group with its count,
+// followed by the group's items printed by printItems.
+func printGroups(body []byte, groupBy, orderBy, itemsKey, command string, printItems func(items json.RawMessage)) {
+	var result struct {
+		Groups []json.RawMessage `json:"groups"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatalf("Error parsing grouped response: %v", err)
+	}
+	if len(result.Groups) == 0 {
+		fmt.Println("No grouped results found")
+		return
+	}
+
+	// The server names keys without spaces, e.g. "decade(published_date)".
+	var keys []string
+	for _, k := range strings.Split(groupBy, ",") {
+		keys = append(keys, strings.ReplaceAll(k, " ", ""))
+	}
+
+	for _, raw := range result.Groups {
+		var g groupResult
+		if err := json.Unmarshal(raw, &g); err != nil {
+			log.Fatalf("Error parsing grouped response: %v", err)
+		}
+		var items map[string]json.RawMessage
+		json.Unmarshal(raw, &items)
+
+		labels := make([]string, len(keys))
+		for i, k := range keys {
+			switch v := g.Key[k]; {
+			case v == nil:
+				labels[i] = "(none)"
+			case *v == "":
+				labels[i] = "(empty)"
+			default:
+				labels[i] = *v
+			}
+		}
+		fmt.Printf("== %s (%d) ==\n", strings.Join(labels, " / "), g.Count)
+		if list, ok := items[itemsKey]; ok {
+			printItems(list)
+		}
+		if g.NextCursor != "" {
+			// The cursor is only valid with the same ordering.
+			order := ""
+			if orderBy != "" {
+				order = fmt.Sprintf(" --order-by %q", orderBy)
+			}
+			fmt.Printf("  ... %d more: bookmanager %s list --where %q%s --cursor %s\n",
+				g.Count-countItems(items[itemsKey]), command, g.Where, order, g.NextCursor)
+		}
+		fmt.Println()
+	}
+}
+
+func countItems(items json.RawMessage) int {
+	var list []json.RawMessage
+	json.Unmarshal(items, &list)
+	return len(list)
+}
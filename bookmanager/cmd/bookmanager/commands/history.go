@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"bookmanager/api/models"
+	"bookmanager/cmd/bookmanager/api"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// showHistory prints the history of the book or collection given in args
+// as field diffs, or a single revision with its snapshot.
+func showHistory(client *api.APIClient, kind string, args []string) {
+	fs := flag.NewFlagSet(kind+" history", flag.ExitOnError)
+	revision := fs.Int("revision", 0, "Show a single revision with the full record")
+	noColor := fs.Bool("no-color", false, "Disable colored diffs")
+
+	if len(args) < 1 {
+		fmt.Printf("Usage: bookmanager %s history <id> [--revision N] [--no-color]\n", kind)
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("Invalid %s ID\n", kind)
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+	color := !*noColor && isTerminal(os.Stdout)
+
+	if *revision > 0 {
+		body, err := client.Get(fmt.Sprintf("/v1/%ss/%d/history/%d", kind, id, *revision), nil)
+		if err != nil {
+			log.Fatalf("Error getting revision: %v", err)
+		}
+		var rev models.Revision
+		if err := json.Unmarshal(body, &rev); err != nil {
+			log.Fatalf("Error parsing response: %v", err)
+		}
+		printRevision(rev, color)
+		var snapshot bytes.Buffer
+		if err := json.Indent(&snapshot, rev.Snapshot, "  ", "  "); err == nil {
+			fmt.Printf("\n  %s\n", snapshot.String())
+		}
+		return
+	}
+
+	body, err := client.Get(fmt.Sprintf("/v1/%ss/%d/history", kind, id), nil)
+	if err != nil {
+		log.Fatalf("Error getting history: %v", err)
+	}
+	var result struct {
+		Revisions []models.Revision `json:"revisions"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	if len(result.Revisions) == 0 {
+		fmt.Printf("No history recorded for %s #%d\n", kind, id)
+		return
+	}
+	for i, rev := range result.Revisions {
+		if i > 0 {
+			fmt.Println()
+		}
+		printRevision(rev, color)
+	}
+}
+
+func printRevision(rev models.Revision, color bool) {
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + colorReset
+	}
+
+	action := rev.Action
+	if rev.RevertedFrom != nil {
+		action = fmt.Sprintf("%s to revision %d", action, *rev.RevertedFrom)
+	}
+	fmt.Printf("%s %s by %s on %s\n", paint(colorYellow, fmt.Sprintf("revision %d", rev.Revision)),
+		action, rev.Actor, rev.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	if rev.RequestID != "" {
+		fmt.Printf("  request %s\n", rev.RequestID)
+	}
+	for _, change := range rev.Changes {
+		if !isNull(change.Old) {
+			fmt.Println(paint(colorRed, fmt.Sprintf("  - %s: %s", change.Field, change.Old)))
+		}
+		if !isNull(change.New) {
+			fmt.Println(paint(colorGreen, fmt.Sprintf("  + %s: %s", change.Field, change.New)))
+		}
+	}
+}
+
+func isNull(v json.RawMessage) bool {
+	return len(v) == 0 || string(v) == "null"
+}
+
+// revertTo reverts the book or collection given in args to an earlier
+// revision.
+func revertTo(client *api.APIClient, kind string, args []string) {
+	fs := flag.NewFlagSet(kind+" revert", flag.ExitOnError)
+	ifVersion := fs.Int("if-version", 0, "Only revert if the "+kind+" is still at this version")
+
+	if len(args) < 2 {
+		fmt.Printf("Usage: bookmanager %s revert <id> <revision> [--if-version N]\n", kind)
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("Invalid %s ID\n", kind)
+		os.Exit(1)
+	}
+	revision, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Println("Invalid revision")
+		os.Exit(1)
+	}
+	fs.Parse(args[2:])
+
+	body, err := client.Post(fmt.Sprintf("/%ss/%d/history/%d/revert", kind, id, revision), nil, api.IfMatch(*ifVersion))
+	if err != nil {
+		exitOnConflict(err, kind, id)
+		log.Fatalf("Error reverting %s: %v", kind, err)
+	}
+	var reverted struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(body, &reverted); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Reverted %s #%d to revision %d (now at version %d)\n", kind, id, revision, reverted.Version)
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"bookmanager/api/models"
+	"bookmanager/cmd/bookmanager/api"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importTypes maps file extensions to the media type they are sent as.
+var importTypes = map[string]string{
+	".csv":    "text/csv",
+	".json":   "application/json",
+	".ndjson": "application/x-ndjson",
+	".jsonl":  "application/x-ndjson",
+}
+
+func importBooks(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("book import", flag.ExitOnError)
+	format := fs.String("format", "", "File format: csv, json or ndjson (default from the file extension)")
+	mapping := fs.String("mapping", "", "CSV column mapping, e.g. \"Book Title:title,Writer:author\"")
+	dryRun := fs.Bool("dry-run", false, "Only report what would be imported")
+	onDuplicate := fs.String("on-duplicate", "fail", "What to do with books whose ISBN exists: fail, skip or upsert")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("File to import is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	path := args[0]
+	fs.Parse(args[1:])
+
+	params := map[string]string{"on_duplicate": *onDuplicate}
+	if *dryRun {
+		params["dry_run"] = "true"
+	}
+	if *mapping != "" {
+		params["mapping"] = *mapping
+	}
+	contentType := importTypes[strings.ToLower(filepath.Ext(path))]
+	if *format != "" {
+		params["format"] = *format
+		contentType = "application/octet-stream"
+	} else if contentType == "" {
+		fmt.Println("Cannot tell the format from the file name, use --format")
+		os.Exit(1)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Error opening file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Error reading file: %v", err)
+	}
+
+	var body io.Reader = file
+	progress := isTerminal(os.Stderr)
+	if progress {
+		body = &progressReader{r: file, size: info.Size(), last: -1}
+	}
+	respBody, err := client.Upload("/books/import", contentType, body, info.Size(), params)
+	if progress {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	// A report with failed rows comes back as 422 Unprocessable Entity.
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity && strings.HasPrefix(apiErr.Body, "{") {
+		respBody, err = []byte(apiErr.Body), nil
+	}
+	if err != nil {
+		log.Fatalf("Error importing books: %v", err)
+	}
+
+	var report models.ImportReport
+	if err := json.Unmarshal(respBody, &report); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+	printImportReport(path, report)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printImportReport(path string, report models.ImportReport) {
+	for _, row := range report.Rows {
+		switch row.Status {
+		case models.ImportSkipped:
+			fmt.Printf("Row %d: skipped, %s\n", row.Row, row.Message)
+		case models.ImportFailed:
+			if len(row.Errors) == 0 {
+				fmt.Printf("Row %d: %s\n", row.Row, row.Message)
+				continue
+			}
+			fmt.Printf("Row %d:\n", row.Row)
+			for _, fe := range row.Errors {
+				fmt.Printf("  - %s: %s\n", fe.Field, fe.Message)
+			}
+		}
+	}
+
+	counts := fmt.Sprintf("%d created, %d updated, %d skipped, %d failed", report.Created, report.Updated, report.Skipped, report.Failed)
+	switch {
+	case report.Committed:
+		fmt.Printf("Imported %s: %s\n", path, counts)
+	case report.Failed > 0:
+		fmt.Printf("Nothing was imported from %s: %s\n", path, counts)
+	default:
+		fmt.Printf("Dry run of %s: %s\n", path, counts)
+	}
+}
+
+// progressReader draws a progress bar on stderr while a file is uploaded.
+type progressReader struct {
+	r    io.Reader
+	size int64
+	sent int64
+	last int
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.sent += int64(n)
+	percent := 100
+	if p.size > 0 {
+		percent = int(p.sent * 100 / p.size)
+	}
+	if percent != p.last {
+		p.last = percent
+		const width = 30
+		filled := percent * width / 100
+		fmt.Fprintf(os.Stderr, "\rUploading [%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat(" ", width-filled), percent)
+		if percent == 100 {
+			fmt.Fprint(os.Stderr, ", importing...")
+		}
+	}
+	return n, err
+}
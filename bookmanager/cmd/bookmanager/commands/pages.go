@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"bookmanager/cmd/bookmanager/api"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+type pageInfo struct {
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+	Total      *int   `json:"total"`
+}
+
+// fetchPages requests a list endpoint and hands every response body to
+// handle. With all set it follows next_cursor until the last page; otherwise
+// only one page is fetched. It returns the pagination info of the last page.
+func fetchPages(client *api.APIClient, endpoint string, params map[string]string, all bool, handle func(body []byte)) pageInfo {
+	for {
+		body, err := client.Get(endpoint, params)
+		if err != nil {
+			log.Fatalf("API request failed: %v", err)
+		}
+
+		var info pageInfo
+		if err := json.Unmarshal(body, &info); err != nil {
+			log.Fatalf("Failed to parse response: %v", err)
+		}
+		handle(body)
+
+		if !all || info.NextCursor == "" {
+			return info
+		}
+		// The cursor already encodes the position, offset would conflict.
+		delete(params, "offset")
+		params["cursor"] = info.NextCursor
+	}
+}
+
+// printPageFooter tells the user how to continue when more results exist.
+func printPageFooter(info pageInfo, shown int) {
+	if info.Total != nil {
+		fmt.Printf("Showing %d of %d\n", shown, *info.Total)
+	}
+	if info.NextCursor != "" {
+		fmt.Printf("More results available, continue with --cursor %s (or use --all)\n", info.NextCursor)
+	}
+}
@@ -0,0 +1,223 @@
+package commands
+
+import (
+	"bookmanager/cmd/bookmanager/api"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const barWidth = 40
+
+type bookStats struct {
+	Summary struct {
+		Count   int `json:"count"`
+		Authors int `json:"authors"`
+		Genres  int `json:"genres"`
+		Edition *struct {
+			Min int     `json:"min"`
+			Max int     `json:"max"`
+			Avg float64 `json:"avg"`
+		} `json:"edition"`
+		PublishedDate *struct {
+			Min string `json:"min"`
+			Max string `json:"max"`
+		} `json:"published_date"`
+		CreatedAt *struct {
+			Min time.Time `json:"min"`
+			Max time.Time `json:"max"`
+		} `json:"created_at"`
+		InCollections  int     `json:"in_collections"`
+		AvgCollections float64 `json:"avg_collections"`
+	} `json:"summary"`
+	Histogram *struct {
+		Key     string `json:"key"`
+		Buckets []struct {
+			Key   *string `json:"key"`
+			Count int     `json:"count"`
+		} `json:"buckets"`
+	} `json:"histogram"`
+	Pivot *struct {
+		RowKey       string    `json:"row_key"`
+		ColumnKey    string    `json:"column_key"`
+		Rows         []*string `json:"rows"`
+		Columns      []*string `json:"columns"`
+		Counts       [][]int   `json:"counts"`
+		RowTotals    []int     `json:"row_totals"`
+		ColumnTotals []int     `json:"column_totals"`
+	} `json:"pivot"`
+}
+
+func HandleStatsCommand(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	where := fs.String("where", "", "Filter expression")
+	author := fs.String("author", "", "Filter by author")
+	genre := fs.String("genre", "", "Filter by genre")
+	histogram := fs.String("histogram", "", "Key to draw a histogram for (e.g., \"decade(published_date)\")")
+	pivot := fs.String("pivot", "", "Row and column key of a pivot table (e.g., \"genre, decade(published_date)\")")
+	fs.Usage = printStatsHelp
+
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+	if fs.NArg() > 0 {
+		printStatsHelp()
+		os.Exit(1)
+	}
+
+	params := map[string]string{}
+	for name, value := range map[string]string{
+		"where":     *where,
+		"author":    *author,
+		"genre":     *genre,
+		"histogram": *histogram,
+		"pivot":     *pivot,
+	} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+
+	body, err := client.Get("/v1/stats/books", params)
+	if err != nil {
+		log.Fatalf("API request failed: %v", err)
+	}
+
+	var stats bookStats
+	if err := json.Unmarshal(body, &stats); err != nil {
+		log.Fatalf("Failed to parse response: %v", err)
+	}
+
+	s := stats.Summary
+	fmt.Printf("Books:           %d\n", s.Count)
+	fmt.Printf("Authors:         %d\n", s.Authors)
+	fmt.Printf("Genres:          %d\n", s.Genres)
+	if s.Edition != nil {
+		fmt.Printf("Edition:         min %d, max %d, avg %.2f\n", s.Edition.Min, s.Edition.Max, s.Edition.Avg)
+	}
+	if s.PublishedDate != nil {
+		fmt.Printf("Published:       %s .. %s\n", s.PublishedDate.Min, s.PublishedDate.Max)
+	}
+	if s.CreatedAt != nil {
+		fmt.Printf("Added:           %s .. %s\n", s.CreatedAt.Min.Format("2006-01-02"), s.CreatedAt.Max.Format("2006-01-02"))
+	}
+	fmt.Printf("In collections:  %d (avg %.2f collections per book)\n", s.InCollections, s.AvgCollections)
+
+	if h := stats.Histogram; h != nil {
+		fmt.Printf("\nBooks by %s:\n", h.Key)
+		labels := make([]string, len(h.Buckets))
+		counts := make([]int, len(h.Buckets))
+		for i, b := range h.Buckets {
+			labels[i] = keyLabel(b.Key)
+			counts[i] = b.Count
+		}
+		printBars(labels, counts)
+	}
+
+	if p := stats.Pivot; p != nil {
+		fmt.Printf("\nBooks by %s and %s:\n", p.RowKey, p.ColumnKey)
+		header := []string{p.RowKey + " \\ " + p.ColumnKey}
+		for _, c := range p.Columns {
+			header = append(header, keyLabel(c))
+		}
+		header = append(header, "Total")
+
+		table := [][]string{header}
+		for i, r := range p.Rows {
+			row := []string{keyLabel(r)}
+			for _, n := range p.Counts[i] {
+				row = append(row, fmt.Sprint(n))
+			}
+			table = append(table, append(row, fmt.Sprint(p.RowTotals[i])))
+		}
+		totals := []string{"Total"}
+		sum := 0
+		for _, n := range p.ColumnTotals {
+			totals = append(totals, fmt.Sprint(n))
+			sum += n
+		}
+		table = append(table, append(totals, fmt.Sprint(sum)))
+		printTable(table)
+	}
+}
+
+func printStatsHelp() {
+	fmt.Println(`Usage: bookmanager stats [options]
+
+Shows statistics about the books matching the filters.
+
+Options:
+  --where       Filter expression (e.g., "genre = 'Fantasy'")
+  --author      Filter by author
+  --genre       Filter by genre
+  --histogram   Draw a histogram over a key (e.g., "decade(published_date)", "month(created_at)")
+  --pivot       Count books by two keys (e.g., "genre, decade(published_date)")
+
+Keys are fields, or year(), month() or decade() of published_date, created_at or updated_at.
+
+Examples:
+  bookmanager stats
+  bookmanager stats --histogram "month(created_at)"
+  bookmanager stats --where "edition > 1" --pivot "genre, decade(published_date)"`)
+}
+
+func keyLabel(key *string) string {
+	switch {
+	case key == nil:
+		return "(none)"
+	case *key == "":
+		return "(empty)"
+	}
+	return *key
+}
+
+// printBars draws a horizontal bar per label, scaled to the largest count.
+func printBars(labels []string, counts []int) {
+	width, largest := 0, 0
+	for i := range labels {
+		width = max(width, len(labels[i]))
+		largest = max(largest, counts[i])
+	}
+	for i := range labels {
+		bar := 0
+		if largest > 0 {
+			bar = counts[i] * barWidth / largest
+		}
+		if bar == 0 && counts[i] > 0 {
+			bar = 1
+		}
+		fmt.Printf("  %-*s | %s %d\n", width, labels[i], strings.Repeat("#", bar), counts[i])
+	}
+}
+
+// printTable prints rows with aligned columns, the first row as header.
+func printTable(rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for r, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			if i == 0 {
+				cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+			} else {
+				cells[i] = fmt.Sprintf("%*s", widths[i], cell)
+			}
+		}
+		fmt.Println("  " + strings.Join(cells, " | "))
+		if r == 0 || r == len(rows)-2 {
+			seps := make([]string, len(widths))
+			for i, w := range widths {
+				seps[i] = strings.Repeat("-", w)
+			}
+			fmt.Println("  " + strings.Join(seps, "-+-"))
+		}
+	}
+}
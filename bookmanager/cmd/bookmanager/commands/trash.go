@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"bookmanager/api/models"
+	"bookmanager/cmd/bookmanager/api"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+func HandleTrashCommand(client *api.APIClient, args []string) {
+	if len(args) < 1 {
+		printTrashHelp()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		listTrash(client)
+	case "restore":
+		restoreFromTrash(client, args[1:])
+	case "purge":
+		purgeTrash(client, args[1:])
+	case "help":
+		printTrashHelp()
+	default:
+		fmt.Printf("Unknown trash command: %s\n", args[0])
+		printTrashHelp()
+		os.Exit(1)
+	}
+}
+
+func printTrashHelp() {
+	fmt.Println(`Usage: bookmanager trash <command> [options]
+
+Deleted books and collections are kept in the trash until they are restored
+or purged. The server purges items older than its retention period (30 days
+by default) on its own.
+
+Commands:
+  list                      List deleted books and collections
+  restore book <id>         Restore a book, back into its collections
+  restore collection <id>   Restore a collection with its books
+  purge                     Permanently delete items from the trash
+  help                      Show this help message
+
+Purge Options:
+  --older-than   Only purge items deleted longer ago than this (e.g., 24h);
+                 defaults to the server's retention period
+  --all          Empty the whole trash
+
+Examples:
+  bookmanager trash list
+  bookmanager trash restore book 3
+  bookmanager trash purge --older-than 168h`)
+}
+
+func listTrash(client *api.APIClient) {
+	resp, err := client.Get("/v1/trash", nil)
+	if err != nil {
+		log.Fatalf("Error listing trash: %v", err)
+	}
+
+	var trash models.Trash
+	if err := json.Unmarshal(resp, &trash); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	if len(trash.Books) == 0 && len(trash.Collections) == 0 {
+		fmt.Println("The trash is empty")
+		return
+	}
+	if len(trash.Books) > 0 {
+		fmt.Println("Books:")
+		for _, book := range trash.Books {
+			fmt.Printf("  %d: %s by %s (deleted %s)\n", book.ID, book.Title, book.Author, deletedAt(book.DeletedAt))
+		}
+	}
+	if len(trash.Collections) > 0 {
+		fmt.Println("Collections:")
+		for _, collection := range trash.Collections {
+			fmt.Printf("  %d: %s (deleted %s)\n", collection.ID, collection.Name, deletedAt(collection.DeletedAt))
+		}
+	}
+}
+
+func deletedAt(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func restoreFromTrash(client *api.APIClient, args []string) {
+	if len(args) < 2 || (args[0] != "book" && args[0] != "collection") {
+		fmt.Println("Usage: bookmanager trash restore book|collection <id>")
+		os.Exit(1)
+	}
+	kind := args[0]
+
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Printf("Invalid %s ID\n", kind)
+		os.Exit(1)
+	}
+
+	_, err = client.Post(fmt.Sprintf("/%ss/%d/restore", kind, id), nil)
+	if err != nil {
+		log.Fatalf("Error restoring %s: %v", kind, err)
+	}
+
+	fmt.Printf("Restored %s #%d\n", kind, id)
+}
+
+func purgeTrash(client *api.APIClient, args []string) {
+	fs := flag.NewFlagSet("trash purge", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", 0, "Only purge items deleted longer ago than this")
+	all := fs.Bool("all", false, "Empty the whole trash")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Error parsing flags: %v", err)
+	}
+
+	endpoint := "/trash/purge"
+	switch {
+	case *all:
+		endpoint += "?older_than=0s"
+	case *olderThan > 0:
+		endpoint += "?older_than=" + url.QueryEscape(olderThan.String())
+	}
+
+	resp, err := client.Post(endpoint, nil)
+	if err != nil {
+		log.Fatalf("Error purging trash: %v", err)
+	}
+
+	var result models.PurgeResult
+	if err := json.Unmarshal(resp, &result); err != nil {
+		log.Fatalf("Error parsing response: %v", err)
+	}
+
+	fmt.Printf("Purged %d books and %d collections\n", result.Books, result.Collections)
+}